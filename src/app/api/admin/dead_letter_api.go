@@ -0,0 +1,118 @@
+package admin
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mumu/cryptoSwap/src/app/api/dto"
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/app/service"
+	"github.com/mumu/cryptoSwap/src/app/sync"
+	commonUtil "github.com/mumu/cryptoSwap/src/common"
+	"github.com/mumu/cryptoSwap/src/core/result"
+)
+
+// DeadLetterApi 索引器管理接口：死信查询与重试
+// 重试依赖 sync 包，而 sync 引用了 api 包，因此管理接口单独成包，只挂载在索引器服务上
+type DeadLetterApi struct {
+	svc *service.DeadLetterService
+}
+
+func NewDeadLetterApi() *DeadLetterApi {
+	return &DeadLetterApi{
+		svc: service.NewDeadLetterService(),
+	}
+}
+
+// RetryDeadLettersRequest 死信重试请求参数，id 为 0 时按链与解码器批量重试
+type RetryDeadLettersRequest struct {
+	Id      int64  `json:"id"`
+	ChainId int    `json:"chainId"`
+	Decoder string `json:"decoder"`
+	Limit   int    `json:"limit"`
+}
+
+// List 获取死信列表
+// @Summary 获取死信列表
+// @Description 分页获取解码或处理失败的事件日志
+// @Tags admin
+// @Produce json
+// @Param chainId query int64 false "链ID"
+// @Param status query string false "状态 pending/resolved"
+// @Param decoder query string false "解码器名称"
+// @Param page query int false "页码" default(1)
+// @Param pageSize query int false "每页大小" default(20)
+// @Success 200 {object} result.Response{data=[]model.DeadLetterLog}
+// @Router /admin/deadLetters [get]
+func (a *DeadLetterApi) List(c *gin.Context) {
+	chainId, ok := commonUtil.ParseChainId(c.Query("chainId"))
+	if !ok {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+	status := c.Query("status")
+	if status != "" && status != model.DeadLetterPending && status != model.DeadLetterResolved {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+	pg := parsePagination(c.DefaultQuery("page", "1"), c.DefaultQuery("pageSize", "20"))
+
+	deadLetters, total, err := a.svc.ListDeadLetters(service.DeadLetterFilter{
+		ChainId: chainId,
+		Status:  status,
+		Decoder: c.Query("decoder"),
+	}, pg)
+	if err != nil {
+		result.SysError(c, "获取死信失败: "+err.Error())
+		return
+	}
+
+	result.OK(c, gin.H{
+		"records": deadLetters,
+		"total":   total,
+		"page":    pg.Page,
+		"size":    pg.PageSize,
+	})
+}
+
+// Retry 重试死信
+// @Summary 重试死信
+// @Description 用当前的解码器重新处理待处理的死信，返回成功与失败数量
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body RetryDeadLettersRequest true "重试参数"
+// @Success 200 {object} result.Response{data=sync.DeadLetterRetryResult}
+// @Router /admin/deadLetters/retry [post]
+func (a *DeadLetterApi) Retry(c *gin.Context) {
+	var req RetryDeadLettersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+
+	res, err := sync.RetryDeadLetters(c.Request.Context(), sync.DeadLetterRetryOptions{
+		ChainId: req.ChainId,
+		Id:      req.Id,
+		Decoder: req.Decoder,
+		Limit:   req.Limit,
+	})
+	if err != nil {
+		result.SysError(c, "重试死信失败: "+err.Error())
+		return
+	}
+	result.OK(c, res)
+}
+
+// parsePagination 解析分页参数，非法值取默认值
+func parsePagination(pageStr, pageSizeStr string) dto.Pagination {
+	page, err := strconv.Atoi(pageStr)
+	if err != nil || page < 1 {
+		page = 1
+	}
+	pageSize, err := strconv.Atoi(pageSizeStr)
+	if err != nil || pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	return dto.Pagination{Page: page, PageSize: pageSize, Offset: (page - 1) * pageSize}
+}
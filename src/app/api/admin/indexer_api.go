@@ -0,0 +1,213 @@
+package admin
+
+import (
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mumu/cryptoSwap/src/app/sync"
+	commonUtil "github.com/mumu/cryptoSwap/src/common"
+	"github.com/mumu/cryptoSwap/src/core/result"
+)
+
+// IndexerApi 索引器管理接口：监听状态、暂停/恢复、重置游标与回填任务，作用于运行中的监听器，无需重启
+type IndexerApi struct{}
+
+func NewIndexerApi() *IndexerApi {
+	return &IndexerApi{}
+}
+
+// PauseChainRequest 暂停/恢复请求参数，id 不为 0 时只作用于该条监听配置
+type PauseChainRequest struct {
+	ChainId int   `json:"chainId"`
+	Id      int64 `json:"id"`
+}
+
+// ResetCursorRequest 重置游标请求参数
+type ResetCursorRequest struct {
+	Id          int64   `json:"id" binding:"required"`
+	BlockNumber *uint64 `json:"blockNumber" binding:"required"`
+}
+
+// StartBackfillRequest 启动回填任务请求参数
+type StartBackfillRequest struct {
+	ChainId     int    `json:"chainId" binding:"required"`
+	Contract    string `json:"contract" binding:"required"`
+	ServiceType string `json:"serviceType"`
+	FromBlock   uint64 `json:"fromBlock"`
+	ToBlock     uint64 `json:"toBlock" binding:"required"`
+	Workers     int    `json:"workers"`
+	BatchSize   uint64 `json:"batchSize"`
+}
+
+// ListChains 获取监听状态
+// @Summary 获取监听状态
+// @Description 返回每条监听配置的游标、可安全索引高度、延迟、暂停状态与最近错误
+// @Tags admin
+// @Produce json
+// @Param chainId query int64 false "链ID"
+// @Success 200 {object} result.Response{data=[]sync.SyncerStatus}
+// @Router /admin/chains [get]
+func (a *IndexerApi) ListChains(c *gin.Context) {
+	chainId, ok := commonUtil.ParseChainId(c.Query("chainId"))
+	if !ok {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+	statuses, err := sync.ListSyncerStatus(int(chainId))
+	if err != nil {
+		result.SysError(c, "获取监听状态失败: "+err.Error())
+		return
+	}
+	result.OK(c, statuses)
+}
+
+// PauseChain 暂停监听
+// @Summary 暂停监听
+// @Description 暂停链上全部监听配置或指定的一条，当前批次完成后生效
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body PauseChainRequest true "暂停参数"
+// @Success 200 {object} result.Response
+// @Router /admin/chains/pause [post]
+func (a *IndexerApi) PauseChain(c *gin.Context) {
+	a.setPaused(c, true)
+}
+
+// ResumeChain 恢复监听
+// @Summary 恢复监听
+// @Description 恢复链上全部监听配置或指定的一条
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body PauseChainRequest true "恢复参数"
+// @Success 200 {object} result.Response
+// @Router /admin/chains/resume [post]
+func (a *IndexerApi) ResumeChain(c *gin.Context) {
+	a.setPaused(c, false)
+}
+
+func (a *IndexerApi) setPaused(c *gin.Context, paused bool) {
+	var req PauseChainRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.ChainId == 0 && req.Id == 0) {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+	affected, err := sync.SetChainPaused(req.ChainId, req.Id, paused)
+	if err != nil {
+		result.SysError(c, "更新监听状态失败: "+err.Error())
+		return
+	}
+	if affected == 0 {
+		result.Error(c, result.DBNotExist)
+		return
+	}
+	result.OK(c, gin.H{"affected": affected, "paused": paused})
+}
+
+// ResetCursor 重置游标
+// @Summary 重置游标
+// @Description 将监听配置的游标重置到指定区块，回退时撤销该区块之后的派生数据并由监听器重新索引
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body ResetCursorRequest true "重置参数"
+// @Success 200 {object} result.Response{data=model.Chain}
+// @Router /admin/chains/resetCursor [post]
+func (a *IndexerApi) ResetCursor(c *gin.Context) {
+	var req ResetCursorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+	chain, err := sync.ResetCursor(req.Id, *req.BlockNumber)
+	if err != nil {
+		result.SysError(c, "重置游标失败: "+err.Error())
+		return
+	}
+	result.OK(c, chain)
+}
+
+// StartBackfill 启动回填任务
+// @Summary 启动回填任务
+// @Description 在索引器进程内后台回填指定区块范围，返回任务 id，进度通过 GET /admin/backfills/:id 查询
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body StartBackfillRequest true "回填参数"
+// @Success 200 {object} result.Response{data=sync.BackfillJob}
+// @Router /admin/backfills [post]
+func (a *IndexerApi) StartBackfill(c *gin.Context) {
+	var req StartBackfillRequest
+	if err := c.ShouldBindJSON(&req); err != nil || !commonUtil.ValidateHexAddress(req.Contract) {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+	job, err := sync.StartBackfillJob(sync.BackfillOptions{
+		ChainId:     req.ChainId,
+		Contract:    req.Contract,
+		ServiceType: req.ServiceType,
+		FromBlock:   req.FromBlock,
+		ToBlock:     req.ToBlock,
+		Workers:     req.Workers,
+		BatchSize:   req.BatchSize,
+	})
+	if err != nil {
+		result.SysError(c, "启动回填任务失败: "+err.Error())
+		return
+	}
+	result.OK(c, job)
+}
+
+// ListBackfills 获取回填任务列表
+// @Summary 获取回填任务列表
+// @Description 返回本进程启动以来的回填任务及其进度
+// @Tags admin
+// @Produce json
+// @Success 200 {object} result.Response{data=[]sync.BackfillJob}
+// @Router /admin/backfills [get]
+func (a *IndexerApi) ListBackfills(c *gin.Context) {
+	result.OK(c, sync.ListBackfillJobs())
+}
+
+// GetBackfill 获取回填任务进度
+// @Summary 获取回填任务进度
+// @Tags admin
+// @Produce json
+// @Param id path int64 true "任务ID"
+// @Success 200 {object} result.Response{data=sync.BackfillJob}
+// @Router /admin/backfills/{id} [get]
+func (a *IndexerApi) GetBackfill(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+	job, ok := sync.GetBackfillJob(id)
+	if !ok {
+		result.Error(c, result.DBNotExist)
+		return
+	}
+	result.OK(c, job)
+}
+
+// CancelBackfill 取消回填任务
+// @Summary 取消回填任务
+// @Description 取消运行中的回填任务，已提交的区段保留
+// @Tags admin
+// @Produce json
+// @Param id path int64 true "任务ID"
+// @Success 200 {object} result.Response
+// @Router /admin/backfills/{id}/cancel [post]
+func (a *IndexerApi) CancelBackfill(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+	if !sync.CancelBackfillJob(id) {
+		result.Error(c, result.DBNotExist)
+		return
+	}
+	result.OK(c, nil)
+}
@@ -1,5 +1,11 @@
 package dto
 
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
 // Pagination 通用分页参数
 type Pagination struct {
 	Page     int `json:"page"`
@@ -14,7 +20,12 @@ type PoolListItemDTO struct {
 	Icon      string `json:"icon"`
 	APY       string `json:"apy"`
 	Volume24h string `json:"24hVolume"`
-	Fees24h   string `json:"24hFees"`
+	Fees24h   string `json:"24hFees"` // 手续费合计
+	FeeTier   string `json:"feeTier"` // 手续费率，如 0.30%
+	LPFees24h string `json:"24hLpFees"`
+	// 24h 协议分成与已结算的协议收入，feeTo 未开启时为 $0
+	ProtocolFees24h    string `json:"24hProtocolFees"`
+	ProtocolRevenue24h string `json:"24hProtocolRevenue"`
 }
 
 // PoolPerformanceItemDTO 池子表现项
@@ -30,3 +41,51 @@ type RewardDistributionItemDTO struct {
 	Icon         string `json:"icon"`
 	GrowthRate   string `json:"growthRate"`
 }
+
+// PoolHistoryItemDTO 交易对小时/日汇总项，APY 为该周期 LP 手续费按 TVL 年化的百分比，TVL 未知时为空
+type PoolHistoryItemDTO struct {
+	Time               time.Time           `json:"time"`
+	TVLUSD             decimal.NullDecimal `json:"tvlUsd"`
+	VolumeUSD          decimal.NullDecimal `json:"volumeUsd"`
+	FeesUSD            decimal.NullDecimal `json:"feesUsd"`
+	LPFeesUSD          decimal.NullDecimal `json:"lpFeesUsd"`
+	ProtocolFeesUSD    decimal.NullDecimal `json:"protocolFeesUsd"`
+	ProtocolRevenueUSD decimal.NullDecimal `json:"protocolRevenueUsd"`
+	APY                *float64            `json:"apy"`
+	TxCount            int64               `json:"txCount"`
+	SwapCount          int64               `json:"swapCount"`
+	LPCount            int64               `json:"lpCount"`
+	Reserve0           decimal.Decimal     `json:"reserve0"`
+	Reserve1           decimal.Decimal     `json:"reserve1"`
+}
+
+// LPPositionDTO 钱包在一个交易对中的持仓分析，金额单位为 USD
+// hodlValueUsd 与 impermanentLossUsd 在任一代币未定价时为空，impermanentLossUsd 为负表示损失
+type LPPositionDTO struct {
+	ChainId            int64     `json:"chainId"`
+	PoolAddress        string    `json:"poolAddress"`
+	PoolName           string    `json:"poolName"`
+	Balance            string    `json:"balance"`
+	Share              float64   `json:"share"`
+	Amount0            float64   `json:"amount0"`
+	Amount1            float64   `json:"amount1"`
+	ValueUSD           float64   `json:"valueUsd"`
+	DepositsUSD        float64   `json:"depositsUsd"`
+	WithdrawalsUSD     float64   `json:"withdrawalsUsd"`
+	FeesEarnedUSD      float64   `json:"feesEarnedUsd"`
+	HodlValueUSD       *float64  `json:"hodlValueUsd"`
+	ImpermanentLossUSD *float64  `json:"impermanentLossUsd"`
+	NetPnLUSD          float64   `json:"netPnlUsd"`
+	FirstAt            time.Time `json:"firstAt"`
+}
+
+// LPPositionTotalsDTO 钱包全部持仓的合计，持有价值与无常损失只计两侧代币均已定价的交易对
+type LPPositionTotalsDTO struct {
+	ValueUSD           float64 `json:"valueUsd"`
+	DepositsUSD        float64 `json:"depositsUsd"`
+	WithdrawalsUSD     float64 `json:"withdrawalsUsd"`
+	FeesEarnedUSD      float64 `json:"feesEarnedUsd"`
+	HodlValueUSD       float64 `json:"hodlValueUsd"`
+	ImpermanentLossUSD float64 `json:"impermanentLossUsd"`
+	NetPnLUSD          float64 `json:"netPnlUsd"`
+}
@@ -18,9 +18,7 @@ import (
 	"github.com/mumu/cryptoSwap/src/app/service"
 	commonUtil "github.com/mumu/cryptoSwap/src/common"
 	"github.com/mumu/cryptoSwap/src/core/ctx"
-	"github.com/mumu/cryptoSwap/src/core/log"
 	"github.com/mumu/cryptoSwap/src/core/result"
-	"go.uber.org/zap"
 )
 
 type LiquidityPoolApi struct {
@@ -132,51 +130,6 @@ func GetPoolReserves(poolAddress string, chainId int) (reserve0, reserve1, total
 	return reserves.Reserve0, reserves.Reserve1, totalSupply, nil
 }
 
-// GetUserLPTokenBalance 获取用户在 Uniswap V2 池子中的 LP 代币余额
-func GetUserLPTokenBalance(poolAddress, userAddress string, chainId int) (*big.Int, error) {
-	rpcURL := GetRPCURL(chainId)
-	if rpcURL == "" {
-		return nil, fmt.Errorf("不支持的 chainId: %d", chainId)
-	}
-
-	client, err := ethclient.Dial(rpcURL)
-	if err != nil {
-		return nil, err
-	}
-	defer client.Close()
-
-	contractAddress := common.HexToAddress(poolAddress)
-	ownerAddress := common.HexToAddress(userAddress)
-
-	// 使用ABI管理器单例获取UniswapV2Pair ABI
-	abiManager := abi.GetABIManager()
-	uniswapV2PairABI, exists := abiManager.GetABI("UniswapV2Pair")
-	if !exists {
-		return nil, fmt.Errorf("UniswapV2Pair ABI 未找到")
-	}
-
-	// 调用 balanceOf 函数
-	balanceData, err := uniswapV2PairABI.Pack("balanceOf", ownerAddress)
-
-	msg := ethereum.CallMsg{
-		To:   &contractAddress,
-		Data: balanceData,
-	}
-
-	balanceResult, err := client.CallContract(context.Background(), msg, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	var balance *big.Int
-	err = uniswapV2PairABI.UnpackIntoInterface(&balance, "balanceOf", balanceResult)
-	if err != nil {
-		return nil, err
-	}
-
-	return balance, nil
-}
-
 // GetUserLiquidityPoolsByLPToken 根据用户LP代币余额获取参与的流动性池
 func GetUserLiquidityPoolsByLPToken(c *gin.Context) {
 	userAddress := c.Query("userAddress")
@@ -207,48 +160,21 @@ func GetUserLiquidityPoolsByLPToken(c *gin.Context) {
 
 	offset := (page - 1) * pageSize
 
-	// 1. 查询所有流动性池信息
-	var allPools []model.LiquidityPool
-	query := ctx.Ctx.DB.Model(&model.LiquidityPool{}).Where("is_active = ?", true)
-	if chainId > 0 {
-		query = query.Where("chain_id = ?", chainId)
-	}
-
-	if err := query.Find(&allPools).Error; err != nil {
+	// 从 LP 持仓账本查询余额大于 0 的交易对
+	poolAddresses, err := service.NewLiquidityPoolService().ListUserLPPoolAddresses(userAddress, chainId)
+	if err != nil {
 		result.Error(c, result.DBQueryFailed)
 		return
 	}
-
-	if len(allPools) == 0 {
-		result.OK(c, gin.H{
-			"pools":    []model.LiquidityPool{},
-			"total":    0,
-			"page":     page,
-			"pageSize": pageSize,
-		})
-		return
-	}
-
-	// 2. 查询每个池子的LP代币余额
 	var userPools []model.LiquidityPool
-	for _, pool := range allPools {
-		// 直接调用Uniswap V2池子合约的balanceOf函数
-		balance, err := GetUserLPTokenBalance(pool.PoolAddress, userAddress, int(chainId))
-		if err != nil {
-			// 查询失败，跳过这个池子
-			log.Logger.Warn("查询LP代币余额失败",
-				zap.String("pool", pool.PoolAddress),
-				zap.Error(err))
-			continue
-		}
-
-		// 如果余额大于0，添加到结果中
-		if balance.Cmp(big.NewInt(0)) > 0 {
-			userPools = append(userPools, pool)
+	if len(poolAddresses) > 0 {
+		if userPools, err = listActivePoolsByAddresses(poolAddresses, chainId); err != nil {
+			result.Error(c, result.DBQueryFailed)
+			return
 		}
 	}
 
-	// 3. 分页处理
+	// 分页处理
 	total := len(userPools)
 	start := offset
 	end := offset + pageSize
@@ -388,7 +314,7 @@ func (lp *LiquidityPoolApi) GetLiquidityPoolEvents(c *gin.Context) {
 		return
 	}
 
-	if err := query.Offset(pg.Offset).Limit(pg.PageSize).Order("created_at DESC").Find(&events).Error; err != nil {
+	if err := query.Offset(pg.Offset).Limit(pg.PageSize).Order("COALESCE(block_timestamp, created_at) DESC").Find(&events).Error; err != nil {
 		result.Error(c, result.DBQueryFailed)
 		return
 	}
@@ -401,6 +327,201 @@ func (lp *LiquidityPoolApi) GetLiquidityPoolEvents(c *gin.Context) {
 	})
 }
 
+// 单次请求最多返回的 K 线/汇总条数，未指定 from 时按该数量往前推算起点
+const (
+	defaultCandleCount = 500
+	maxCandleCount     = 1000
+)
+
+// GetPoolCandles godoc
+// @Summary      获取交易对 K 线
+// @Description  返回交易对的 OHLCV K 线，价格为 1 个 token0 折合的 token1 数量，*Usd 字段为 token0 的 USD 价格（代币未定价时为空）
+// @Tags liquidity
+// @Produce      json
+// @Param        address   path   string  true   "交易对地址"
+// @Param        interval  query  string  true   "周期：1m/5m/1h/1d"
+// @Param        from      query  int     false  "起始时间（unix 秒），默认取结束时间之前 500 个周期"
+// @Param        to        query  int     false  "结束时间（unix 秒），默认当前时间"
+// @Param        chainId   query  int     false  "链ID"
+// @Success      200 {object} result.Response{data=map[string]interface{}}
+// @Router       /api/v1/liquidity/pools/{address}/candles [get]
+func (lp *LiquidityPoolApi) GetPoolCandles(c *gin.Context) {
+	poolAddress := c.Param("address")
+	resolution := c.Query("interval")
+	seconds, ok := service.CandleResolutions[resolution]
+	if !ok || !common.IsHexAddress(poolAddress) {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+	chainId, ok := commonUtil.ParseChainId(c.Query("chainId"))
+	if !ok {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+
+	from, to, ok := parseTimeRange(c, seconds)
+	if !ok {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+
+	candles, err := lp.svc.GetPoolCandles(chainId, poolAddress, resolution, from, to, maxCandleCount)
+	if err != nil {
+		result.Error(c, result.DBQueryFailed)
+		return
+	}
+	result.OK(c, gin.H{
+		"interval": resolution,
+		"candles":  candles,
+	})
+}
+
+// GetPoolHistory godoc
+// @Summary      获取交易对 TVL/交易量/手续费时间序列
+// @Description  返回交易对的小时或日汇总，只包含有事件或 LP 变动的周期；TVL 与 LP 人数为周期结束时的值
+// @Tags liquidity
+// @Produce      json
+// @Param        address   path   string  true   "交易对地址"
+// @Param        interval  query  string  false  "周期：1h/1d" default(1d)
+// @Param        from      query  int     false  "起始时间（unix 秒），默认取结束时间之前 500 个周期"
+// @Param        to        query  int     false  "结束时间（unix 秒），默认当前时间"
+// @Param        chainId   query  int     false  "链ID"
+// @Success      200 {object} result.Response{data=map[string]interface{}}
+// @Router       /api/v1/liquidity/pools/{address}/history [get]
+func (lp *LiquidityPoolApi) GetPoolHistory(c *gin.Context) {
+	poolAddress := c.Param("address")
+	resolution := c.DefaultQuery("interval", "1d")
+	seconds, ok := service.PoolDataResolutions[resolution]
+	if !ok || !common.IsHexAddress(poolAddress) {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+	chainId, ok := commonUtil.ParseChainId(c.Query("chainId"))
+	if !ok {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+	from, to, ok := parseTimeRange(c, seconds)
+	if !ok {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+
+	rows, err := lp.svc.GetPoolHistory(chainId, poolAddress, resolution, from, to, maxCandleCount)
+	if err != nil {
+		result.Error(c, result.DBQueryFailed)
+		return
+	}
+	periodsPerYear := float64(365*24*3600) / float64(seconds)
+	items := make([]dto.PoolHistoryItemDTO, 0, len(rows))
+	for _, row := range rows {
+		item := dto.PoolHistoryItemDTO{
+			Time:               row.PeriodStart,
+			TVLUSD:             row.TVLUSD,
+			VolumeUSD:          row.VolumeUSD,
+			FeesUSD:            row.FeesUSD,
+			LPFeesUSD:          row.LPFeesUSD,
+			ProtocolFeesUSD:    row.ProtocolFeesUSD,
+			ProtocolRevenueUSD: row.ProtocolRevenueUSD,
+			TxCount:            row.TxCount,
+			SwapCount:          row.SwapCount,
+			LPCount:            row.LPCount,
+			Reserve0:           row.Reserve0,
+			Reserve1:           row.Reserve1,
+		}
+		if row.TVLUSD.Valid && row.TVLUSD.Decimal.IsPositive() && row.LPFeesUSD.Valid {
+			apy := row.LPFeesUSD.Decimal.InexactFloat64() / row.TVLUSD.Decimal.InexactFloat64() * periodsPerYear * 100
+			item.APY = &apy
+		}
+		items = append(items, item)
+	}
+	result.OK(c, gin.H{
+		"interval": resolution,
+		"list":     items,
+	})
+}
+
+// parseTimeRange 解析 from/to（unix 秒），to 默认当前时间，from 默认取 to 之前 defaultCandleCount 个周期
+func parseTimeRange(c *gin.Context, seconds int64) (time.Time, time.Time, bool) {
+	to := time.Now()
+	if toStr := c.Query("to"); toStr != "" {
+		unix, err := strconv.ParseInt(toStr, 10, 64)
+		if err != nil {
+			return time.Time{}, time.Time{}, false
+		}
+		to = time.Unix(unix, 0)
+	}
+	from := to.Add(-time.Duration(seconds*defaultCandleCount) * time.Second)
+	if fromStr := c.Query("from"); fromStr != "" {
+		unix, err := strconv.ParseInt(fromStr, 10, 64)
+		if err != nil {
+			return time.Time{}, time.Time{}, false
+		}
+		from = time.Unix(unix, 0)
+	}
+	return from, to, !from.After(to)
+}
+
+// GetLPPositions 获取钱包 LP 持仓分析
+// @Summary      获取钱包 LP 持仓分析
+// @Description  按 LP 持仓账本返回钱包在各交易对（含已全部取出的）中的当前价值、存入/取出价值、手续费收益、无常损失与净收益，金额单位为 USD
+// @Tags liquidity
+// @Produce      json
+// @Param        walletAddress  query  string  true   "钱包地址"
+// @Param        chainId        query  int     false  "链ID"
+// @Success      200 {object} result.Response{data=map[string]interface{}}
+// @Router       /api/v1/liquidity/positions [get]
+func (lp *LiquidityPoolApi) GetLPPositions(c *gin.Context) {
+	walletAddress := c.Query("walletAddress")
+	if !common.IsHexAddress(walletAddress) {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+	chainId, ok := commonUtil.ParseChainId(c.Query("chainId"))
+	if !ok {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+
+	portfolio, err := lp.svc.GetLPPositions(walletAddress, chainId)
+	if err != nil {
+		result.Error(c, result.DBQueryFailed)
+		return
+	}
+	items := make([]dto.LPPositionDTO, 0, len(portfolio.Positions))
+	for _, p := range portfolio.Positions {
+		items = append(items, dto.LPPositionDTO{
+			ChainId:            p.ChainId,
+			PoolAddress:        p.PoolAddress,
+			PoolName:           fmt.Sprintf("%s/%s", p.Token0Symbol, p.Token1Symbol),
+			Balance:            p.Balance,
+			Share:              p.Share,
+			Amount0:            p.Amount0,
+			Amount1:            p.Amount1,
+			ValueUSD:           p.ValueUSD,
+			DepositsUSD:        p.DepositsUSD,
+			WithdrawalsUSD:     p.WithdrawalsUSD,
+			FeesEarnedUSD:      p.FeesEarnedUSD,
+			HodlValueUSD:       p.HodlValueUSD,
+			ImpermanentLossUSD: p.ImpermanentLossUSD,
+			NetPnLUSD:          p.NetPnLUSD,
+			FirstAt:            p.FirstAt,
+		})
+	}
+	result.OK(c, gin.H{
+		"list": items,
+		"total": dto.LPPositionTotalsDTO{
+			ValueUSD:           portfolio.ValueUSD,
+			DepositsUSD:        portfolio.DepositsUSD,
+			WithdrawalsUSD:     portfolio.WithdrawalsUSD,
+			FeesEarnedUSD:      portfolio.FeesEarnedUSD,
+			HodlValueUSD:       portfolio.HodlValueUSD,
+			ImpermanentLossUSD: portfolio.ImpermanentLossUSD,
+			NetPnLUSD:          portfolio.NetPnLUSD,
+		},
+	})
+}
+
 // GetLiquidityStats 处理流动性统计请求
 func (lp *LiquidityPoolApi) GetLiquidityStats(c *gin.Context) {
 	// 绑定请求参数
@@ -433,6 +554,9 @@ func (lp *LiquidityPoolApi) GetLiquidityStats(c *gin.Context) {
 		"myLiquidityPeriodChange": stats.MyLiquidityPeriodChange,
 		"totalFees":               stats.TotalFees,
 		"totalFeesTodayChange":    stats.TotalFeesTodayChange,
+		"totalLpFees":             stats.TotalLPFees,
+		"totalProtocolFees":       stats.TotalProtocolFees,
+		"totalProtocolRevenue":    stats.TotalProtocolRevenue,
 		"activePoolsCount":        stats.ActivePoolsCount,
 		"totalPoolsCount":         stats.TotalPoolsCount,
 	})
@@ -477,7 +601,7 @@ func (lp *LiquidityPoolApi) GetLiquidityPoolStats(c *gin.Context) {
 
 	// 查询今日事件数
 	var todayEvents int64
-	if err := eventQuery.Where("created_at::date = CURRENT_DATE").Count(&todayEvents).Error; err != nil {
+	if err := eventQuery.Where("COALESCE(block_timestamp, created_at)::date = CURRENT_DATE").Count(&todayEvents).Error; err != nil {
 		result.Error(c, result.DBQueryFailed)
 		return
 	}
@@ -535,13 +659,18 @@ func (lp *LiquidityPoolApi) GetPoolPerformance(c *gin.Context) {
 		return
 	}
 
+	activity, err := lp.svc.PoolActivityStats(pools)
+	if err != nil {
+		result.Error(c, result.DBQueryFailed)
+		return
+	}
+
 	items := make([]dto.PoolPerformanceItemDTO, 0, len(pools))
 	for _, p := range pools {
-		vol, _, _ := lp.svc.Compute24hStats(p)
 		pair := fmt.Sprintf("%s/%s", p.Token0Symbol, p.Token1Symbol)
 		items = append(items, dto.PoolPerformanceItemDTO{
 			PoolPair:  pair,
-			Volume24h: formatUSD(vol),
+			Volume24h: formatUSD(activity[p.Id].VolumeUSD24h),
 		})
 	}
 
@@ -600,18 +729,20 @@ func (lp *LiquidityPoolApi) GetRewardDistribution(c *gin.Context) {
 	}
 
 	// 计算每个池子的“累计收益”与近7天增长率（近7天与前7天对比）
-	now := time.Now()
-	last7Start := now.Add(-7 * 24 * time.Hour)
-	prev7Start := now.Add(-14 * 24 * time.Hour)
+	activity, err := lp.svc.PoolActivityStats(pools)
+	if err != nil {
+		result.Error(c, result.DBQueryFailed)
+		return
+	}
 
 	items := make([]dto.RewardDistributionItemDTO, 0, len(pools))
 	for _, p := range pools {
-		// 以当前24h手续费近似累计收益的代表（如需历史累计，可后续扩充）
-		_, fees24h, _ := lp.svc.Compute24hStats(p)
+		// 以当前24h LP 手续费近似累计收益的代表（如需历史累计，可后续扩充），协议分成不计入 LP 收益
+		fees24h := activity[p.Id].LPFeesUSD24h
 
 		// 近7天与前7天比较计算增长率
-		feesLast7 := lp.svc.ComputeFeesUSDForPeriod(p, last7Start, now)
-		feesPrev7 := lp.svc.ComputeFeesUSDForPeriod(p, prev7Start, last7Start)
+		feesLast7 := activity[p.Id].LPFeesUSDLast7d
+		feesPrev7 := activity[p.Id].LPFeesUSDPrev7d
 		var growth string
 		if feesPrev7 <= 0 && feesLast7 <= 0 {
 			growth = "0.0%"
@@ -694,19 +825,28 @@ func (lp *LiquidityPoolApi) PostLiquidityPools(c *gin.Context) {
 		}
 	}
 
-	// 组装返回并计算统计
+	// 组装返回，统计取交易对小时汇总
+	activity, err := lp.svc.PoolActivityStats(pools)
+	if err != nil {
+		result.Error(c, result.DBQueryFailed)
+		return
+	}
+
 	items := make([]dto.PoolListItemDTO, 0, len(pools))
 	for _, p := range pools {
-		volUSD, feesUSD, apy := lp.svc.Compute24hStats(p)
-
+		stats := activity[p.Id]
 		name := fmt.Sprintf("%s/%s", p.Token0Symbol, p.Token1Symbol)
 		items = append(items, dto.PoolListItemDTO{
-			PoolId:    fmt.Sprintf("%d", p.Id),
-			PoolName:  name,
-			Icon:      "https://example.com",
-			APY:       apy,
-			Volume24h: formatUSD(volUSD),
-			Fees24h:   formatUSD(feesUSD),
+			PoolId:             fmt.Sprintf("%d", p.Id),
+			PoolName:           name,
+			Icon:               "https://example.com",
+			APY:                stats.APY,
+			Volume24h:          formatUSD(stats.VolumeUSD24h),
+			Fees24h:            formatUSD(stats.FeesUSD24h),
+			FeeTier:            fmt.Sprintf("%.2f%%", stats.FeeRate*100),
+			LPFees24h:          formatUSD(stats.LPFeesUSD24h),
+			ProtocolFees24h:    formatUSD(stats.ProtocolFeesUSD24h),
+			ProtocolRevenue24h: formatUSD(stats.ProtocolRevenueUSD24h),
 		})
 	}
 
@@ -716,26 +856,6 @@ func (lp *LiquidityPoolApi) PostLiquidityPools(c *gin.Context) {
 	})
 }
 
-func isStable(symbol string) bool {
-	switch symbol {
-	case "USDC", "USDT", "DAI":
-		return true
-	default:
-		return false
-	}
-}
-
-func parseBigInt(s string) *big.Int {
-	if s == "" {
-		return big.NewInt(0)
-	}
-	v, ok := new(big.Int).SetString(s, 10)
-	if !ok {
-		return big.NewInt(0)
-	}
-	return v
-}
-
 func formatUSD(v float64) string {
 	if v <= 0 {
 		return "$0"
@@ -786,16 +906,6 @@ func listActivePoolsByAddresses(addresses []string, chainIdOpt int64) ([]model.L
 	return pools, nil
 }
 
-// makePairAndVolumeItem 将池子格式化为包含交易对与24小时交易量的条目
-func makePairAndVolumeItem(p model.LiquidityPool, svc *service.LiquidityPoolService) gin.H {
-	volUSD, _, _ := svc.Compute24hStats(p)
-	pair := fmt.Sprintf("%s/%s", p.Token0Symbol, p.Token1Symbol)
-	return gin.H{
-		"poolPair":  pair,
-		"24hVolume": formatUSD(volUSD),
-	}
-}
-
 //func (lp *LiquidityPoolApi) PoolMappingHandler(c *gin.Context) error {
 //	poolMap, err := c.service.GetPoolMapping()
 //	if err != nil {
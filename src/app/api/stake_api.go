@@ -1,11 +1,14 @@
 package api
 
 import (
+	"strconv"
+
 	"github.com/gin-gonic/gin"
 	"github.com/mumu/cryptoSwap/src/app/api/dto"
 	"github.com/mumu/cryptoSwap/src/app/service"
 	commonUtil "github.com/mumu/cryptoSwap/src/common"
 	"github.com/mumu/cryptoSwap/src/core/result"
+	"github.com/shopspring/decimal"
 )
 
 type StakeApi struct {
@@ -20,11 +23,11 @@ func NewStakeApi() *StakeApi {
 
 // StakeRequest 质押请求参数
 type StakeRequest struct {
-	UserAddress string  `json:"userAddress" binding:"required"`
-	ChainId     int64   `json:"chainId" binding:"required"`
-	Amount      float64 `json:"amount" binding:"required,gt=0"`
-	Token       string  `json:"token" binding:"required"`
-	PoolId      string  `json:"poolId" binding:"required"`
+	UserAddress string          `json:"userAddress" binding:"required"`
+	ChainId     int64           `json:"chainId" binding:"required"`
+	Amount      decimal.Decimal `json:"amount"` // 代币数量，支持数字或字符串，按 18 位小数换算为最小单位
+	Token       string          `json:"token" binding:"required"`
+	PoolId      string          `json:"poolId" binding:"required"`
 }
 
 // WithdrawRequest 提取请求参数
@@ -65,8 +68,8 @@ func (s *StakeApi) Stake(c *gin.Context) {
 		return
 	}
 
-	// 验证地址格式
-	if !commonUtil.ValidateHexAddress(req.UserAddress) {
+	// 验证地址格式与金额
+	if !commonUtil.ValidateHexAddress(req.UserAddress) || !req.Amount.IsPositive() {
 		result.Error(c, result.InvalidParameter)
 		return
 	}
@@ -204,3 +207,111 @@ func (s *StakeApi) GetStakeOverview(c *gin.Context) {
 
 	result.OK(c, overview)
 }
+
+// GetRewardClaimsRequest 获取收益领取记录请求参数
+type GetRewardClaimsRequest struct {
+	UserAddress string `json:"userAddress" binding:"required"`
+	ChainId     int64  `json:"chainId"`
+	Page        int    `json:"page"`
+	PageSize    int    `json:"pageSize"`
+}
+
+// GetStakingPools 获取质押池列表
+// @Summary 获取质押池列表
+// @Description 获取 PoolCreated 登记的质押池，以及质押合约的暂停状态与最新份额价格
+// @Tags stake
+// @Produce json
+// @Param chainId query int64 false "链ID"
+// @Success 200 {object} result.Response
+// @Router /api/v1/stake/pools [get]
+func (s *StakeApi) GetStakingPools(c *gin.Context) {
+	chainId, ok := commonUtil.ParseChainId(c.Query("chainId"))
+	if !ok {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+
+	pools, contracts, err := s.svc.GetStakingPools(chainId)
+	if err != nil {
+		result.SysError(c, "获取质押池失败: "+err.Error())
+		return
+	}
+
+	result.OK(c, gin.H{
+		"pools":     pools,
+		"contracts": contracts,
+	})
+}
+
+// GetRewardClaims 获取用户的收益领取记录
+// @Summary 获取用户的收益领取记录
+// @Description 分页获取用户在质押合约中的 ClaimRewards 记录
+// @Tags stake
+// @Accept json
+// @Produce json
+// @Param request body GetRewardClaimsRequest true "请求参数"
+// @Success 200 {object} result.Response{data=[]model.StakingRewardClaim}
+// @Router /api/v1/stake/rewardClaims [post]
+func (s *StakeApi) GetRewardClaims(c *gin.Context) {
+	var req GetRewardClaimsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+	if !commonUtil.ValidateHexAddress(req.UserAddress) {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+	pg := parsePagination(strconv.Itoa(req.Page), strconv.Itoa(req.PageSize))
+
+	claims, total, err := s.svc.GetRewardClaims(req.UserAddress, req.ChainId, pg)
+	if err != nil {
+		result.SysError(c, "获取记录失败: "+err.Error())
+		return
+	}
+
+	result.OK(c, gin.H{
+		"records": claims,
+		"total":   total,
+		"page":    pg.Page,
+		"size":    pg.PageSize,
+	})
+}
+
+// GetSharePrices 获取份额价格历史
+// @Summary 获取份额价格历史
+// @Description 分页获取质押合约 SharePriceUpdated 事件记录的份额价格
+// @Tags stake
+// @Produce json
+// @Param chainId query int64 false "链ID"
+// @Param contractAddress query string false "质押合约地址"
+// @Param page query int false "页码" default(1)
+// @Param pageSize query int false "每页大小" default(20)
+// @Success 200 {object} result.Response{data=[]model.StakingSharePrice}
+// @Router /api/v1/stake/sharePrices [get]
+func (s *StakeApi) GetSharePrices(c *gin.Context) {
+	chainId, ok := commonUtil.ParseChainId(c.Query("chainId"))
+	if !ok {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+	contractAddress := c.Query("contractAddress")
+	if contractAddress != "" && !commonUtil.ValidateHexAddress(contractAddress) {
+		result.Error(c, result.InvalidParameter)
+		return
+	}
+	pg := parsePagination(c.DefaultQuery("page", "1"), c.DefaultQuery("pageSize", "20"))
+
+	prices, total, err := s.svc.GetSharePriceHistory(chainId, contractAddress, pg)
+	if err != nil {
+		result.SysError(c, "获取份额价格失败: "+err.Error())
+		return
+	}
+
+	result.OK(c, gin.H{
+		"records": prices,
+		"total":   total,
+		"page":    pg.Page,
+		"size":    pg.PageSize,
+	})
+}
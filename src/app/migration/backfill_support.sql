@@ -0,0 +1,24 @@
+-- 区块回填（indexer backfill）所需的表结构调整
+-- 回填按（链, 合约, 区块范围）先撤销再重写派生数据，质押记录需要记录产生事件的合约地址
+
+BEGIN;
+
+ALTER TABLE user_operation_record ADD COLUMN IF NOT EXISTS contract_address VARCHAR(42);
+
+-- 历史记录补齐合约地址：取该链的质押监听配置（未配置 service_type 的旧记录也视为质押合约）
+UPDATE user_operation_record r
+SET contract_address = c.address
+FROM chain c
+WHERE r.contract_address IS NULL
+  AND c.chain_id = r.chain_id
+  AND c.service_type IN ('staking', '');
+
+CREATE INDEX IF NOT EXISTS idx_user_operation_record_chain_contract_block
+    ON user_operation_record (chain_id, LOWER(contract_address), block_number);
+
+CREATE INDEX IF NOT EXISTS idx_liquidity_pool_events_chain_pool_block
+    ON liquidity_pool_events (chain_id, LOWER(pool_address), block_number);
+
+COMMIT;
+
+COMMENT ON COLUMN user_operation_record.contract_address IS '产生事件的质押合约地址';
@@ -0,0 +1,17 @@
+-- 每个（链, 合约, 服务类型）独立维护监听游标
+-- chain 表的每一行对应一个被监听的合约，service_type 决定使用的事件解码器，last_block_num 为该合约最后已处理的区块号
+
+BEGIN;
+
+-- 原表按 chain_id 唯一，同一条链无法配置多个合约
+ALTER TABLE chain DROP CONSTRAINT IF EXISTS chain_chain_id_key;
+
+ALTER TABLE chain ADD COLUMN IF NOT EXISTS service_type VARCHAR(20) NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX IF NOT EXISTS uq_chain_contract_service
+    ON chain (chain_id, LOWER(address), service_type);
+
+COMMIT;
+
+COMMENT ON COLUMN chain.service_type IS '服务类型: staking/liquidity/airdrop，为空时解码全部事件';
+COMMENT ON COLUMN chain.last_block_num IS '该合约最后已处理的区块号（每行独立维护）';
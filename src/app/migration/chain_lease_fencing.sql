@@ -0,0 +1,17 @@
+-- 按链租约的防护令牌（fencing token）
+-- 多个索引器副本通过 Redis 租约选出每条链的处理者，租约每次易主令牌递增；
+-- 批次事务开头以自己的令牌写入本表，令牌小于已记录值（租约已被其他副本接管）时不更新，事务随之回滚
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS chain_leases (
+    chain_id BIGINT PRIMARY KEY,
+    fencing_token BIGINT NOT NULL,
+    holder VARCHAR(128) NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+COMMIT;
+
+COMMENT ON TABLE chain_leases IS '每条链最近一次写入派生数据的租约持有者与防护令牌';
+COMMENT ON COLUMN chain_leases.fencing_token IS 'Redis 租约令牌，单调递增，旧持有者的写入会被拒绝';
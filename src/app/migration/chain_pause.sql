@@ -0,0 +1,10 @@
+-- 监听配置暂停标记
+-- 通过索引器管理接口暂停/恢复监听时写入，重启后保持暂停状态
+
+BEGIN;
+
+ALTER TABLE chain ADD COLUMN IF NOT EXISTS paused BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMIT;
+
+COMMENT ON COLUMN chain.paused IS '是否已暂停监听，暂停期间游标不推进';
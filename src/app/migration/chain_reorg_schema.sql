@@ -0,0 +1,61 @@
+-- 链重组检测与回滚所需的表结构
+-- 索引器为每条链、每个合约记录已处理区块的哈希，发现父哈希不一致时回滚分叉点之后的派生数据并重新索引
+
+BEGIN;
+
+-- 已处理区块哈希
+CREATE TABLE IF NOT EXISTS block_hashes (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id BIGINT NOT NULL,
+    contract_address VARCHAR(42) NOT NULL,
+    block_number BIGINT NOT NULL,
+    block_hash VARCHAR(66) NOT NULL,
+    parent_hash VARCHAR(66),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (chain_id, contract_address, block_number)
+);
+
+CREATE INDEX IF NOT EXISTS idx_block_hashes_chain_block
+    ON block_hashes (chain_id, block_number DESC);
+
+-- 用户总奖励更新事件（UpdateTotalRewardUpdated）
+-- previous_total_reward 记录事件应用前白名单中的 total_reward，回滚时据此恢复；NULL 表示此前没有白名单记录
+CREATE TABLE IF NOT EXISTS total_reward_updates (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id INTEGER NOT NULL,
+    contract_address TEXT NOT NULL CHECK (contract_address ~ '^0x[0-9a-f]{40}$'),
+    airdrop_id NUMERIC(78,0) NOT NULL,
+    user_address TEXT NOT NULL CHECK (user_address ~ '^0x[0-9a-f]{40}$'),
+    total_reward NUMERIC(78,0) NOT NULL,
+    claimed_reward NUMERIC(78,0) NOT NULL,
+    pending_reward NUMERIC(78,0) NOT NULL,
+    previous_total_reward NUMERIC(78,0),
+    event_timestamp TIMESTAMPTZ NOT NULL,
+    block_number BIGINT NOT NULL,
+    tx_hash TEXT NOT NULL CHECK (tx_hash ~ '^0x[0-9a-f]{64}$'),
+    log_index INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (tx_hash, log_index)
+);
+
+CREATE INDEX IF NOT EXISTS idx_total_reward_updates_airdrop_user
+    ON total_reward_updates (airdrop_id, user_address, block_number DESC);
+
+CREATE INDEX IF NOT EXISTS idx_total_reward_updates_chain_block
+    ON total_reward_updates (chain_id, block_number DESC);
+
+-- 回滚按区块号删除派生数据
+CREATE INDEX IF NOT EXISTS idx_liquidity_pool_events_chain_block
+    ON liquidity_pool_events (chain_id, block_number);
+
+CREATE INDEX IF NOT EXISTS idx_reward_claimed_chain_block
+    ON reward_claimed_events (chain_id, block_number);
+
+COMMIT;
+
+COMMENT ON TABLE block_hashes IS '索引器已处理区块哈希，用于链重组检测';
+COMMENT ON COLUMN block_hashes.contract_address IS '监听的合约地址';
+COMMENT ON COLUMN block_hashes.block_hash IS '区块哈希';
+COMMENT ON COLUMN block_hashes.parent_hash IS '父区块哈希，仅批次末尾区块记录';
+COMMENT ON TABLE total_reward_updates IS '用户总奖励更新事件表';
+COMMENT ON COLUMN total_reward_updates.previous_total_reward IS '事件应用前的白名单总奖励，回滚时恢复';
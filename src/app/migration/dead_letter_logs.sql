@@ -0,0 +1,42 @@
+-- 事件死信队列
+-- 已注册事件的日志解码或处理失败时不再静默丢弃，与所在批次在同一事务中写入 dead_letter_logs；
+-- 修复解码逻辑后可通过 indexer retry-dead-letters 或索引器管理接口重试，回填/重建覆盖的区段会重新判定
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS dead_letter_logs (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id BIGINT NOT NULL,
+    chain_row_id BIGINT NOT NULL,
+    decoder VARCHAR(128) NOT NULL,
+    error TEXT NOT NULL,
+    address VARCHAR(42) NOT NULL,
+    topic0 VARCHAR(66),
+    topic1 VARCHAR(66),
+    topic2 VARCHAR(66),
+    topic3 VARCHAR(66),
+    data BYTEA,
+    block_number BIGINT NOT NULL,
+    block_hash VARCHAR(66) NOT NULL,
+    block_timestamp TIMESTAMPTZ,
+    tx_hash VARCHAR(66) NOT NULL,
+    tx_index INTEGER NOT NULL,
+    tx_sender VARCHAR(42),
+    log_index INTEGER NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_attempt_at TIMESTAMPTZ,
+    resolved_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_dead_letter_logs_row_block_log UNIQUE (chain_row_id, block_hash, log_index),
+    CONSTRAINT chk_dead_letter_logs_status CHECK (status IN ('pending', 'resolved'))
+);
+CREATE INDEX IF NOT EXISTS idx_dead_letter_logs_pending ON dead_letter_logs (chain_id, block_number) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_dead_letter_logs_row_block ON dead_letter_logs (chain_row_id, block_number);
+
+COMMIT;
+
+COMMENT ON TABLE dead_letter_logs IS '解码或处理失败的事件日志（死信队列）';
+COMMENT ON COLUMN dead_letter_logs.decoder IS '解码器名称，如 UniswapV2Pair.Swap';
+COMMENT ON COLUMN dead_letter_logs.status IS 'pending: 待处理; resolved: 已重试成功或已被回填/重建覆盖';
@@ -0,0 +1,20 @@
+-- 事件记录保存区块时间
+-- 索引器按区块解析交易发送者与出块时间，下游统计按 block_timestamp 而不是入库时间 created_at 计算
+
+BEGIN;
+
+ALTER TABLE user_operation_record ADD COLUMN IF NOT EXISTS block_timestamp TIMESTAMPTZ;
+ALTER TABLE liquidity_pool_events ADD COLUMN IF NOT EXISTS block_timestamp TIMESTAMPTZ;
+ALTER TABLE reward_claimed_events ADD COLUMN IF NOT EXISTS block_timestamp TIMESTAMPTZ;
+ALTER TABLE total_reward_updates ADD COLUMN IF NOT EXISTS block_timestamp TIMESTAMPTZ;
+
+-- 历史记录没有区块时间，查询时以 COALESCE(block_timestamp, created_at) 兼容；可用 indexer backfill 重新索引对应区块补齐
+CREATE INDEX IF NOT EXISTS idx_liquidity_pool_events_pool_block_timestamp
+    ON liquidity_pool_events (chain_id, pool_address, block_timestamp);
+
+COMMIT;
+
+COMMENT ON COLUMN user_operation_record.block_timestamp IS '事件所在区块的出块时间';
+COMMENT ON COLUMN liquidity_pool_events.block_timestamp IS '事件所在区块的出块时间';
+COMMENT ON COLUMN reward_claimed_events.block_timestamp IS '事件所在区块的出块时间';
+COMMENT ON COLUMN total_reward_updates.block_timestamp IS '事件所在区块的出块时间';
@@ -0,0 +1,15 @@
+-- Uniswap V2 工厂交易对自动发现
+-- service_type = 'factory' 的监听配置解码 PairCreated，并把新交易对登记到 liquidity_pools
+
+BEGIN;
+
+ALTER TABLE liquidity_pools ADD COLUMN IF NOT EXISTS factory_address VARCHAR(42);
+ALTER TABLE liquidity_pools ADD COLUMN IF NOT EXISTS created_block BIGINT;
+
+CREATE INDEX IF NOT EXISTS idx_liquidity_pools_factory
+    ON liquidity_pools (chain_id, LOWER(factory_address));
+
+COMMIT;
+
+COMMENT ON COLUMN liquidity_pools.factory_address IS '创建该交易对的工厂合约地址，手动登记的池子为空';
+COMMENT ON COLUMN liquidity_pools.created_block IS 'PairCreated 事件所在区块，通过 allPairs 补登记的交易对为空';
@@ -0,0 +1,56 @@
+-- 交易对 LP 代币持仓账本
+-- 索引器解码交易对合约的 ERC20 Transfer 事件写入 lp_token_transfers，并累加到 lp_balances；
+-- 任意区块的余额/总供应量由 lp_balance_changes 按 block_number 截止求和得到
+-- 账本只在交易对从创建区块起被完整索引时准确，已有交易对需用 backfill 命令从 created_block 回填
+-- 地址统一小写
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS lp_token_transfers (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id BIGINT NOT NULL,
+    pool_address VARCHAR(42) NOT NULL,
+    from_address VARCHAR(42) NOT NULL,
+    to_address VARCHAR(42) NOT NULL,
+    value NUMERIC(78,0) NOT NULL,
+    block_number BIGINT NOT NULL,
+    log_index INTEGER NOT NULL,
+    block_timestamp TIMESTAMPTZ,
+    tx_hash VARCHAR(66) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_lp_token_transfers_chain_tx_log UNIQUE (chain_id, tx_hash, log_index)
+);
+CREATE INDEX IF NOT EXISTS idx_lp_token_transfers_pool_block ON lp_token_transfers (chain_id, pool_address, block_number);
+CREATE INDEX IF NOT EXISTS idx_lp_token_transfers_from ON lp_token_transfers (chain_id, from_address, pool_address, block_number);
+CREATE INDEX IF NOT EXISTS idx_lp_token_transfers_to ON lp_token_transfers (chain_id, to_address, pool_address, block_number);
+
+CREATE TABLE IF NOT EXISTS lp_balances (
+    chain_id BIGINT NOT NULL,
+    pool_address VARCHAR(42) NOT NULL,
+    wallet_address VARCHAR(42) NOT NULL,
+    balance NUMERIC(78,0) NOT NULL DEFAULT 0,
+    last_block_num BIGINT,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (chain_id, pool_address, wallet_address)
+);
+CREATE INDEX IF NOT EXISTS idx_lp_balances_wallet ON lp_balances (chain_id, wallet_address) WHERE balance > 0;
+
+-- 每条 Transfer 拆成转出方的负变动与接收方的正变动；铸造没有转出方，销毁没有接收方
+-- MINIMUM_LIQUIDITY 铸造给零地址（from、to 均为零地址），计入零地址余额
+CREATE OR REPLACE VIEW lp_balance_changes AS
+SELECT chain_id, pool_address, to_address AS wallet_address, value AS delta,
+       block_number, log_index, block_timestamp, tx_hash
+FROM lp_token_transfers
+WHERE to_address <> '0x0000000000000000000000000000000000000000'
+   OR from_address = '0x0000000000000000000000000000000000000000'
+UNION ALL
+SELECT chain_id, pool_address, from_address AS wallet_address, -value AS delta,
+       block_number, log_index, block_timestamp, tx_hash
+FROM lp_token_transfers
+WHERE from_address <> '0x0000000000000000000000000000000000000000';
+
+COMMIT;
+
+COMMENT ON TABLE lp_token_transfers IS '交易对 LP 代币 Transfer 事件';
+COMMENT ON TABLE lp_balances IS '钱包在交易对中的当前 LP 余额，全部钱包之和为 LP 总供应量';
+COMMENT ON VIEW lp_balance_changes IS 'LP 余额变动明细，按区块截止求和得到历史余额';
@@ -0,0 +1,41 @@
+-- 交易对 OHLCV K 线
+-- 索引器在写入流动性池事件的同一事务中重算受影响的周期：先删除再由 liquidity_pool_events 聚合 1m，
+-- 再由 1m 汇总 5m/1h/1d；链重组、回填回滚时同样重算，可通过 indexer rebuild-candles 全量重建
+-- 价格取事件之后的储备量（同一交易中紧邻的 Sync），没有储备量的事件不参与
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS pool_candles (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id BIGINT NOT NULL,
+    pool_address VARCHAR(42) NOT NULL,
+    resolution VARCHAR(4) NOT NULL,
+    bucket_start TIMESTAMPTZ NOT NULL,
+    open NUMERIC(60, 30) NOT NULL,
+    high NUMERIC(60, 30) NOT NULL,
+    low NUMERIC(60, 30) NOT NULL,
+    close NUMERIC(60, 30) NOT NULL,
+    open_usd NUMERIC(60, 30),
+    high_usd NUMERIC(60, 30),
+    low_usd NUMERIC(60, 30),
+    close_usd NUMERIC(60, 30),
+    volume0 NUMERIC(78, 0) NOT NULL DEFAULT 0,
+    volume1 NUMERIC(78, 0) NOT NULL DEFAULT 0,
+    volume_usd NUMERIC(40, 6),
+    swap_count BIGINT NOT NULL DEFAULT 0,
+    event_count BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_pool_candles UNIQUE (chain_id, pool_address, resolution, bucket_start)
+);
+
+COMMIT;
+
+COMMENT ON TABLE pool_candles IS '交易对 OHLCV K 线（1m/5m/1h/1d）';
+COMMENT ON COLUMN pool_candles.pool_address IS '交易对地址（小写）';
+COMMENT ON COLUMN pool_candles.resolution IS '周期：1m/5m/1h/1d';
+COMMENT ON COLUMN pool_candles.bucket_start IS '周期起点，按 UTC 对齐';
+COMMENT ON COLUMN pool_candles.open IS '1 个 token0 折合的 token1 数量（已按精度换算）';
+COMMENT ON COLUMN pool_candles.open_usd IS 'token0 的 USD 价格，代币未定价时为空';
+COMMENT ON COLUMN pool_candles.volume0 IS 'Swap 中 token0 的成交量（最小单位）';
+COMMENT ON COLUMN pool_candles.volume_usd IS 'Swap 成交额（USD），按成交时的代币价格估值';
+COMMENT ON COLUMN pool_candles.event_count IS '参与聚合的事件数，含 Mint/Burn';
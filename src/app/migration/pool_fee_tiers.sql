@@ -0,0 +1,85 @@
+-- 交易对手续费档位与协议手续费
+-- dex_factories 记录工厂合约的默认手续费率、协议分成比例与 feeTo，索引器启动工厂监听时读取链上 feeTo；
+-- liquidity_pools.fee_rate 为单个交易对的手续费率，为空时取工厂配置，再为空时取 0.3%
+-- feeTo 非零地址时协议按 protocol_fee_share 分走手续费（UniswapV2 为 1/6），由 Mint/Burn 前铸造给 feeTo 的 LP 代币结算：
+-- 同一交易中紧随其后的池子事件为 Burn，或其后、下一个 Mint 之前还有一次铸造（用户存入）的铸造记为协议手续费
+-- 执行后需运行 indexer rebuild-candles 重建交易对汇总中的 LP 手续费与协议收入
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS dex_factories (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id BIGINT NOT NULL,
+    factory_address VARCHAR(42) NOT NULL,
+    fee_rate NUMERIC(8, 6) NOT NULL DEFAULT 0.003,
+    protocol_fee_share NUMERIC(8, 6) NOT NULL DEFAULT 0.166667,
+    fee_to VARCHAR(42),
+    fee_to_checked_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_dex_factories UNIQUE (chain_id, factory_address)
+);
+
+ALTER TABLE liquidity_pools ADD COLUMN IF NOT EXISTS fee_rate NUMERIC(8, 6);
+
+ALTER TABLE lp_token_transfers ADD COLUMN IF NOT EXISTS is_protocol_fee BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- 按上述规则标记已有的协议手续费铸造
+WITH mints AS (
+    SELECT t.id, t.chain_id, t.pool_address, t.tx_hash, t.log_index
+      FROM lp_token_transfers t
+     WHERE t.from_address = '0x0000000000000000000000000000000000000000'
+       AND t.to_address <> '0x0000000000000000000000000000000000000000'
+), classified AS (
+    SELECT m.id,
+           n.event_type AS next_event,
+           EXISTS (SELECT 1 FROM mints m2
+                    WHERE m2.chain_id = m.chain_id
+                      AND m2.pool_address = m.pool_address
+                      AND m2.tx_hash = m.tx_hash
+                      AND m2.log_index > m.log_index
+                      AND m2.log_index < n.log_index) AS has_later_mint
+      FROM mints m
+      JOIN LATERAL (
+           SELECT e.event_type, e.log_index
+             FROM liquidity_pool_events e
+            WHERE e.chain_id = m.chain_id
+              AND LOWER(e.pool_address) = m.pool_address
+              AND LOWER(e.tx_hash) = m.tx_hash
+              AND e.log_index > m.log_index
+            ORDER BY e.log_index ASC
+            LIMIT 1) n ON TRUE
+)
+UPDATE lp_token_transfers t
+   SET is_protocol_fee = TRUE
+  FROM classified c
+ WHERE t.id = c.id
+   AND (c.next_event = 'RemoveLiquidity' OR (c.next_event = 'AddLiquidity' AND c.has_later_mint));
+
+ALTER TABLE pool_hour_data
+    ADD COLUMN IF NOT EXISTS lp_fees_usd NUMERIC(40, 6),
+    ADD COLUMN IF NOT EXISTS protocol_fees_usd NUMERIC(40, 6),
+    ADD COLUMN IF NOT EXISTS protocol_revenue_usd NUMERIC(40, 6);
+
+ALTER TABLE pool_day_data
+    ADD COLUMN IF NOT EXISTS lp_fees_usd NUMERIC(40, 6),
+    ADD COLUMN IF NOT EXISTS protocol_fees_usd NUMERIC(40, 6),
+    ADD COLUMN IF NOT EXISTS protocol_revenue_usd NUMERIC(40, 6);
+
+COMMIT;
+
+COMMENT ON TABLE dex_factories IS 'DEX 工厂合约的手续费配置';
+COMMENT ON COLUMN dex_factories.factory_address IS '工厂合约地址（小写）';
+COMMENT ON COLUMN dex_factories.fee_rate IS '交易对默认手续费率，如 0.003 表示 0.3%';
+COMMENT ON COLUMN dex_factories.protocol_fee_share IS 'feeTo 开启时协议分走的手续费比例，UniswapV2 为 1/6';
+COMMENT ON COLUMN dex_factories.fee_to IS '链上 feeTo 地址（小写），零地址或为空表示未开启协议手续费';
+COMMENT ON COLUMN liquidity_pools.fee_rate IS '交易对手续费率，为空时取工厂配置';
+COMMENT ON COLUMN lp_token_transfers.is_protocol_fee IS '是否为铸造给 feeTo 的协议手续费';
+COMMENT ON COLUMN pool_hour_data.fees_usd IS 'Swap 手续费合计（USD），按交易对手续费率计算';
+COMMENT ON COLUMN pool_hour_data.lp_fees_usd IS '归属 LP 的手续费（USD），即手续费合计扣除协议分成';
+COMMENT ON COLUMN pool_hour_data.protocol_fees_usd IS '按工厂当前 feeTo 状态计提的协议分成（USD）';
+COMMENT ON COLUMN pool_hour_data.protocol_revenue_usd IS '周期内铸造给 feeTo 的 LP 代币按铸造时 TVL 折算的价值（USD），即已结算的协议收入';
+COMMENT ON COLUMN pool_day_data.fees_usd IS 'Swap 手续费合计（USD），按交易对手续费率计算';
+COMMENT ON COLUMN pool_day_data.lp_fees_usd IS '归属 LP 的手续费（USD），即手续费合计扣除协议分成';
+COMMENT ON COLUMN pool_day_data.protocol_fees_usd IS '按工厂当前 feeTo 状态计提的协议分成（USD）';
+COMMENT ON COLUMN pool_day_data.protocol_revenue_usd IS '周期内铸造给 feeTo 的 LP 代币按铸造时 TVL 折算的价值（USD），即已结算的协议收入';
@@ -0,0 +1,72 @@
+-- 交易对小时/日汇总：TVL、交易量、手续费、交易笔数与 LP 人数
+-- 索引器在更新 K 线的同一事务中按 1h/1d K 线与 LP 持仓账本重算受影响的周期，链重组、回填回滚时同样重算；
+-- 定价任务每轮按最新价格刷新当前周期的 TVL。只为有事件或 LP 变动的周期生成记录
+-- 历史数据需先执行 indexer rebuild-candles，重建 K 线时一并重建汇总
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS pool_hour_data (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id BIGINT NOT NULL,
+    pool_address VARCHAR(42) NOT NULL,
+    period_start TIMESTAMPTZ NOT NULL,
+    reserve0 NUMERIC(78, 0) NOT NULL DEFAULT 0,
+    reserve1 NUMERIC(78, 0) NOT NULL DEFAULT 0,
+    tvl_usd NUMERIC(40, 6),
+    volume0 NUMERIC(78, 0) NOT NULL DEFAULT 0,
+    volume1 NUMERIC(78, 0) NOT NULL DEFAULT 0,
+    volume_usd NUMERIC(40, 6),
+    fees_usd NUMERIC(40, 6),
+    tx_count BIGINT NOT NULL DEFAULT 0,
+    swap_count BIGINT NOT NULL DEFAULT 0,
+    lp_count BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_pool_hour_data UNIQUE (chain_id, pool_address, period_start)
+);
+
+CREATE TABLE IF NOT EXISTS pool_day_data (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id BIGINT NOT NULL,
+    pool_address VARCHAR(42) NOT NULL,
+    period_start TIMESTAMPTZ NOT NULL,
+    reserve0 NUMERIC(78, 0) NOT NULL DEFAULT 0,
+    reserve1 NUMERIC(78, 0) NOT NULL DEFAULT 0,
+    tvl_usd NUMERIC(40, 6),
+    volume0 NUMERIC(78, 0) NOT NULL DEFAULT 0,
+    volume1 NUMERIC(78, 0) NOT NULL DEFAULT 0,
+    volume_usd NUMERIC(40, 6),
+    fees_usd NUMERIC(40, 6),
+    tx_count BIGINT NOT NULL DEFAULT 0,
+    swap_count BIGINT NOT NULL DEFAULT 0,
+    lp_count BIGINT NOT NULL DEFAULT 0,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_pool_day_data UNIQUE (chain_id, pool_address, period_start)
+);
+
+-- 汇总统计按时间范围扫描全部池子
+CREATE INDEX IF NOT EXISTS idx_pool_hour_data_period ON pool_hour_data (period_start);
+CREATE INDEX IF NOT EXISTS idx_pool_day_data_period ON pool_day_data (period_start);
+
+-- LP 人数按交易对与时间截止求和
+CREATE INDEX IF NOT EXISTS idx_lp_token_transfers_pool_time ON lp_token_transfers (chain_id, pool_address, block_timestamp);
+
+COMMIT;
+
+COMMENT ON TABLE pool_hour_data IS '交易对小时汇总';
+COMMENT ON TABLE pool_day_data IS '交易对日汇总';
+COMMENT ON COLUMN pool_hour_data.pool_address IS '交易对地址（小写）';
+COMMENT ON COLUMN pool_hour_data.period_start IS '周期起点，按 UTC 对齐';
+COMMENT ON COLUMN pool_hour_data.reserve0 IS '周期结束时 token0 储备量（最小单位）';
+COMMENT ON COLUMN pool_hour_data.tvl_usd IS '按周期结束时（当前周期为最新）的代币价格估算的 TVL，两侧都未定价时为空';
+COMMENT ON COLUMN pool_hour_data.volume_usd IS 'Swap 成交额（USD），按成交时的代币价格估值';
+COMMENT ON COLUMN pool_hour_data.fees_usd IS 'LP 手续费（USD）';
+COMMENT ON COLUMN pool_hour_data.tx_count IS 'Swap/Mint/Burn 事件数';
+COMMENT ON COLUMN pool_hour_data.lp_count IS '周期结束时 LP 余额大于 0 的钱包数，不含零地址';
+COMMENT ON COLUMN pool_day_data.pool_address IS '交易对地址（小写）';
+COMMENT ON COLUMN pool_day_data.period_start IS '周期起点，按 UTC 对齐';
+COMMENT ON COLUMN pool_day_data.reserve0 IS '周期结束时 token0 储备量（最小单位）';
+COMMENT ON COLUMN pool_day_data.tvl_usd IS '按周期结束时（当前周期为最新）的代币价格估算的 TVL，两侧都未定价时为空';
+COMMENT ON COLUMN pool_day_data.volume_usd IS 'Swap 成交额（USD），按成交时的代币价格估值';
+COMMENT ON COLUMN pool_day_data.fees_usd IS 'LP 手续费（USD）';
+COMMENT ON COLUMN pool_day_data.tx_count IS 'Swap/Mint/Burn 事件数';
+COMMENT ON COLUMN pool_day_data.lp_count IS '周期结束时 LP 余额大于 0 的钱包数，不含零地址';
@@ -0,0 +1,17 @@
+-- 池子价格列加宽
+-- liquidity_pool_events.price、liquidity_pools.price 原为 DECIMAL(30,18)，整数部分最多 12 位；
+-- 价格为未按精度换算的 reserve1 / reserve0，精度相差较大或储备量只剩零头的交易对会超出列精度。
+-- 改为 NUMERIC(78,18)，整数部分可容纳 uint112 储备量的任意比值
+
+BEGIN;
+
+ALTER TABLE liquidity_pool_events
+    ALTER COLUMN price TYPE NUMERIC(78, 18) USING price::NUMERIC(78, 18);
+
+ALTER TABLE liquidity_pools
+    ALTER COLUMN price TYPE NUMERIC(78, 18) USING price::NUMERIC(78, 18);
+
+COMMIT;
+
+COMMENT ON COLUMN liquidity_pool_events.price IS '事件之后的价格（reserve1 / reserve0，未按精度换算）';
+COMMENT ON COLUMN liquidity_pools.price IS '价格（reserve1 / reserve0，未按精度换算）';
@@ -0,0 +1,19 @@
+-- Uniswap V2 Sync 事件维护池子储备量
+-- liquidity_pool_events.reserve0/reserve1/price 记录事件之后的储备量（同一交易中紧邻的 Sync）
+-- liquidity_pools.reserve0/reserve1/price 取最新一次 Sync，reserve_block_num/reserve_log_index 标记其位置，
+-- 回填较早区段时不会覆盖更新的储备量
+
+BEGIN;
+
+ALTER TABLE liquidity_pool_events ADD COLUMN IF NOT EXISTS log_index INTEGER;
+CREATE INDEX IF NOT EXISTS idx_liquidity_pool_events_pool_block
+    ON liquidity_pool_events (chain_id, pool_address, block_number DESC, log_index DESC);
+
+ALTER TABLE liquidity_pools ADD COLUMN IF NOT EXISTS reserve_block_num BIGINT;
+ALTER TABLE liquidity_pools ADD COLUMN IF NOT EXISTS reserve_log_index INTEGER;
+
+COMMIT;
+
+COMMENT ON COLUMN liquidity_pool_events.log_index IS '日志在区块中的索引';
+COMMENT ON COLUMN liquidity_pools.reserve_block_num IS '当前储备量对应的 Sync 事件所在区块，为空表示来自链上查询或尚未同步';
+COMMENT ON COLUMN liquidity_pools.reserve_log_index IS '当前储备量对应的 Sync 事件日志索引';
@@ -0,0 +1,46 @@
+-- 原始日志归档与离线重建（indexer rebuild）
+-- 实时监听与回填在写入派生数据的同一事务中归档本批次收到的全部原始日志，并记录已归档的区块范围；
+-- 解码逻辑修复后可通过 indexer rebuild 从归档重新生成派生表，不访问 RPC
+-- 链重组不删除归档，只把分叉点之后的日志标记为 orphaned，并截断已归档范围
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS raw_logs (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id BIGINT NOT NULL,
+    address VARCHAR(42) NOT NULL,
+    topic0 VARCHAR(66),
+    topic1 VARCHAR(66),
+    topic2 VARCHAR(66),
+    topic3 VARCHAR(66),
+    data BYTEA,
+    block_number BIGINT NOT NULL,
+    block_hash VARCHAR(66) NOT NULL,
+    block_timestamp TIMESTAMPTZ,
+    tx_hash VARCHAR(66) NOT NULL,
+    tx_index INTEGER NOT NULL,
+    tx_sender VARCHAR(42),
+    log_index INTEGER NOT NULL,
+    orphaned BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_raw_logs_chain_block_log UNIQUE (chain_id, block_hash, log_index)
+);
+CREATE INDEX IF NOT EXISTS idx_raw_logs_chain_address_block
+    ON raw_logs (chain_id, address, block_number, log_index) WHERE NOT orphaned;
+CREATE INDEX IF NOT EXISTS idx_raw_logs_chain_block ON raw_logs (chain_id, block_number);
+
+CREATE TABLE IF NOT EXISTS raw_log_ranges (
+    id BIGSERIAL PRIMARY KEY,
+    chain_row_id BIGINT NOT NULL,
+    from_block BIGINT NOT NULL,
+    to_block BIGINT NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_raw_log_ranges_chain_row ON raw_log_ranges (chain_row_id, from_block);
+
+COMMIT;
+
+COMMENT ON TABLE raw_logs IS '索引器收到的原始日志归档，只追加，链重组时标记 orphaned';
+COMMENT ON COLUMN raw_logs.tx_sender IS '交易发送者，仅对已注册处理函数的事件解析';
+COMMENT ON COLUMN raw_logs.block_timestamp IS '区块时间，仅对已注册处理函数的事件解析';
+COMMENT ON TABLE raw_log_ranges IS '每条监听配置（chain.id）已归档的连续区块范围';
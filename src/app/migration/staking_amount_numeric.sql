@@ -0,0 +1,63 @@
+-- 质押金额改为任意精度
+-- user_operation_record.amount、users.total_amount、users.jf_amount 原为 BIGINT，18 位小数的代币超过约 9.22 个即溢出；
+-- 改为 NUMERIC(78,0)（可容纳 uint256），以代币最小单位存储
+-- 溢出的历史记录已被截断为低 64 位，无法从表内恢复：能在原始日志归档中找到对应日志的 Staked/Withdrawn 记录，
+-- 按日志 data 的第一个字（amount）重新计算金额，并把差额补到 users.total_amount 与已计入积分的 jf_amount；
+-- 找不到归档日志的记录保持原值，可对相应区块执行 indexer backfill 修复。已累计的积分 jf 不追溯
+
+BEGIN;
+
+ALTER TABLE user_operation_record
+    ALTER COLUMN amount TYPE NUMERIC(78, 0) USING amount::NUMERIC(78, 0);
+
+ALTER TABLE users
+    ALTER COLUMN total_amount TYPE NUMERIC(78, 0) USING total_amount::NUMERIC(78, 0),
+    ALTER COLUMN jf_amount TYPE NUMERIC(78, 0) USING jf_amount::NUMERIC(78, 0);
+
+-- 由归档日志解码 amount（大端序 uint256）
+CREATE TEMP TABLE staking_amount_fix ON COMMIT DROP AS
+SELECT r.id,
+       r.chain_id,
+       r.token_address,
+       r.address,
+       r.operation_time,
+       CASE WHEN r.event_type = 'Withdrawn' THEN -1 ELSE 1 END AS sign,
+       r.amount AS old_amount,
+       (SELECT SUM(get_byte(l.data, i)::NUMERIC * power(256::NUMERIC, 31 - i))
+          FROM generate_series(0, 31) AS i)::NUMERIC(78, 0) AS new_amount
+  FROM user_operation_record r
+  JOIN raw_logs l
+    ON l.chain_id = r.chain_id
+   AND l.tx_hash = lower(r.tx_hash)
+   AND l.log_index = r.log_index
+   AND NOT l.orphaned
+ WHERE r.event_type IN ('Staked', 'Withdrawn')
+   AND length(l.data) >= 32;
+
+DELETE FROM staking_amount_fix WHERE new_amount = old_amount;
+
+-- 操作时间不晚于 jf_time 的记录已计入 jf_amount，与索引器、回滚逻辑一致
+UPDATE users u
+   SET total_amount = u.total_amount + d.delta,
+       jf_amount = u.jf_amount + d.jf_delta
+  FROM (SELECT u2.id,
+               SUM(f.sign * (f.new_amount - f.old_amount)) AS delta,
+               SUM(CASE WHEN u2.jf_time >= f.operation_time THEN f.sign * (f.new_amount - f.old_amount) ELSE 0 END) AS jf_delta
+          FROM staking_amount_fix f
+          JOIN users u2
+            ON u2.chain_id = f.chain_id
+           AND u2.token_address = f.token_address
+           AND u2.address = f.address
+         GROUP BY u2.id) d
+ WHERE u.id = d.id;
+
+UPDATE user_operation_record r
+   SET amount = f.new_amount
+  FROM staking_amount_fix f
+ WHERE r.id = f.id;
+
+COMMIT;
+
+COMMENT ON COLUMN user_operation_record.amount IS '操作数量（代币最小单位）';
+COMMENT ON COLUMN users.total_amount IS '总数量（代币最小单位）';
+COMMENT ON COLUMN users.jf_amount IS '计算积分时的总数量（代币最小单位）';
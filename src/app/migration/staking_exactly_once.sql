@@ -0,0 +1,15 @@
+-- 质押事件精确一次写入
+-- user_operation_record 记录日志索引，(chain_id, tx_hash, log_index) 唯一；
+-- 索引器使用 ON CONFLICT DO NOTHING 插入，只有实际插入的记录才累加 users.total_amount
+
+BEGIN;
+
+ALTER TABLE user_operation_record ADD COLUMN IF NOT EXISTS log_index INTEGER;
+
+-- 历史记录没有日志索引（NULL 不参与唯一约束），如需纳入去重可用 indexer backfill 重新索引对应区块
+CREATE UNIQUE INDEX IF NOT EXISTS uq_user_operation_record_chain_tx_log
+    ON user_operation_record (chain_id, tx_hash, log_index);
+
+COMMIT;
+
+COMMENT ON COLUMN user_operation_record.log_index IS '日志在区块中的索引';
@@ -0,0 +1,110 @@
+-- StakeV2 质押合约事件
+-- ClaimRewards / PoolCreated / SharePriceUpdated / Paused / Unpaused 分表保存，合约地址统一小写
+-- 质押池与合约暂停状态、最新份额价格通过 staking_pools 与视图 staking_contract_state 提供，链重组或回填删除事件后自动一致
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS staking_reward_claims (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id BIGINT NOT NULL,
+    contract_address VARCHAR(42) NOT NULL,
+    user_address VARCHAR(42) NOT NULL,
+    pool_id BIGINT NOT NULL,
+    reward NUMERIC(78,0) NOT NULL,
+    claimed_at TIMESTAMPTZ,
+    block_number BIGINT NOT NULL,
+    block_timestamp TIMESTAMPTZ,
+    tx_hash VARCHAR(66) NOT NULL,
+    log_index INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_staking_reward_claims_chain_tx_log UNIQUE (chain_id, tx_hash, log_index)
+);
+CREATE INDEX IF NOT EXISTS idx_staking_reward_claims_user ON staking_reward_claims (chain_id, user_address, block_number DESC);
+CREATE INDEX IF NOT EXISTS idx_staking_reward_claims_contract_block ON staking_reward_claims (chain_id, contract_address, block_number);
+
+CREATE TABLE IF NOT EXISTS staking_pools (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id BIGINT NOT NULL,
+    contract_address VARCHAR(42) NOT NULL,
+    pool_id BIGINT NOT NULL,
+    token_address VARCHAR(42) NOT NULL,
+    lock_duration BIGINT NOT NULL,
+    name VARCHAR(255),
+    block_number BIGINT NOT NULL,
+    block_timestamp TIMESTAMPTZ,
+    tx_hash VARCHAR(66) NOT NULL,
+    log_index INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_staking_pools_chain_contract_pool UNIQUE (chain_id, contract_address, pool_id)
+);
+CREATE INDEX IF NOT EXISTS idx_staking_pools_contract_block ON staking_pools (chain_id, contract_address, block_number);
+
+CREATE TABLE IF NOT EXISTS staking_share_prices (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id BIGINT NOT NULL,
+    contract_address VARCHAR(42) NOT NULL,
+    price_date NUMERIC(78,0) NOT NULL,
+    price NUMERIC(78,0) NOT NULL,
+    block_number BIGINT NOT NULL,
+    block_timestamp TIMESTAMPTZ,
+    tx_hash VARCHAR(66) NOT NULL,
+    log_index INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_staking_share_prices_chain_tx_log UNIQUE (chain_id, tx_hash, log_index)
+);
+CREATE INDEX IF NOT EXISTS idx_staking_share_prices_contract_block ON staking_share_prices (chain_id, contract_address, block_number DESC, log_index DESC);
+
+CREATE TABLE IF NOT EXISTS staking_pause_events (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id BIGINT NOT NULL,
+    contract_address VARCHAR(42) NOT NULL,
+    paused BOOLEAN NOT NULL,
+    account VARCHAR(42),
+    block_number BIGINT NOT NULL,
+    block_timestamp TIMESTAMPTZ,
+    tx_hash VARCHAR(66) NOT NULL,
+    log_index INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT uq_staking_pause_events_chain_tx_log UNIQUE (chain_id, tx_hash, log_index)
+);
+CREATE INDEX IF NOT EXISTS idx_staking_pause_events_contract_block ON staking_pause_events (chain_id, contract_address, block_number DESC, log_index DESC);
+
+-- 每个质押合约的当前状态：最近一次 Paused/Unpaused 与最近一次 SharePriceUpdated
+CREATE OR REPLACE VIEW staking_contract_state AS
+SELECT c.chain_id,
+       c.contract_address,
+       COALESCE(p.paused, FALSE) AS paused,
+       p.block_timestamp AS pause_updated_at,
+       s.price AS share_price,
+       s.price_date AS share_price_date,
+       s.block_timestamp AS share_price_updated_at
+FROM (
+    SELECT chain_id, contract_address FROM staking_pools
+    UNION
+    SELECT chain_id, contract_address FROM staking_pause_events
+    UNION
+    SELECT chain_id, contract_address FROM staking_share_prices
+) c
+LEFT JOIN LATERAL (
+    SELECT e.paused, e.block_timestamp
+    FROM staking_pause_events e
+    WHERE e.chain_id = c.chain_id AND e.contract_address = c.contract_address
+    ORDER BY e.block_number DESC, e.log_index DESC
+    LIMIT 1
+) p ON TRUE
+LEFT JOIN LATERAL (
+    SELECT sp.price, sp.price_date, sp.block_timestamp
+    FROM staking_share_prices sp
+    WHERE sp.chain_id = c.chain_id AND sp.contract_address = c.contract_address
+    ORDER BY sp.block_number DESC, sp.log_index DESC
+    LIMIT 1
+) s ON TRUE;
+
+COMMIT;
+
+COMMENT ON TABLE staking_reward_claims IS 'StakeV2 ClaimRewards 事件';
+COMMENT ON TABLE staking_pools IS 'StakeV2 PoolCreated 登记的质押池';
+COMMENT ON TABLE staking_share_prices IS 'StakeV2 SharePriceUpdated 份额价格历史';
+COMMENT ON TABLE staking_pause_events IS 'StakeV2 Paused/Unpaused 事件';
+COMMENT ON COLUMN staking_pools.lock_duration IS '锁定时长（秒）';
+COMMENT ON COLUMN staking_share_prices.price_date IS '合约 SharePriceUpdated 事件中的 date 参数';
@@ -0,0 +1,30 @@
+-- 代币 USD 价格历史
+-- 定价任务从锚定稳定币出发，按跳数逐层经已索引池子推导代币价格：每个代币取流动性达标的池子作为候选，
+-- 丢弃偏离按流动性加权中位数过多的候选后取最深的池子；价格变化或距上次记录超过一小时时追加一行
+
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS token_prices (
+    id BIGSERIAL PRIMARY KEY,
+    chain_id BIGINT NOT NULL,
+    token_address VARCHAR(42) NOT NULL,
+    symbol VARCHAR(64),
+    price_usd NUMERIC(60, 30) NOT NULL,
+    liquidity_usd NUMERIC(40, 2) NOT NULL DEFAULT 0,
+    hops INTEGER NOT NULL DEFAULT 0,
+    pool_address VARCHAR(42),
+    computed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- 按时间点查询价格：取该时间之前最近一条
+CREATE INDEX IF NOT EXISTS idx_token_prices_token_time
+    ON token_prices (chain_id, token_address, computed_at DESC);
+
+COMMIT;
+
+COMMENT ON TABLE token_prices IS '代币 USD 价格历史';
+COMMENT ON COLUMN token_prices.token_address IS '代币地址（小写）';
+COMMENT ON COLUMN token_prices.price_usd IS '每个代币（已按精度换算）的 USD 价格';
+COMMENT ON COLUMN token_prices.liquidity_usd IS '定价池子已定价一侧的流动性（USD）';
+COMMENT ON COLUMN token_prices.hops IS '到锚定稳定币的跳数，锚定币为 0';
+COMMENT ON COLUMN token_prices.pool_address IS '定价所用池子，锚定币为空';
@@ -17,6 +17,7 @@ type RewardClaimedEvent struct {
     PendingReward    string    `json:"pendingReward" gorm:"column:pending_reward;type:decimal(78,0);not null"`
     EventTimestamp   time.Time `json:"eventTimestamp" gorm:"column:event_timestamp;not null"`
     BlockNumber      int64     `json:"blockNumber" gorm:"column:block_number;not null"`
+    BlockTimestamp   time.Time `json:"blockTimestamp" gorm:"column:block_timestamp"` // 事件所在区块的出块时间
     TxHash           string    `json:"txHash" gorm:"column:tx_hash;not null"`
     LogIndex         int       `json:"logIndex" gorm:"column:log_index;not null"`
     CreatedAt        time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
@@ -36,8 +37,10 @@ type TotalRewardUpdatedEvent struct {
     TotalReward      string    `json:"totalReward" gorm:"column:total_reward;type:decimal(78,0);not null"`
     ClaimedReward    string    `json:"claimedReward" gorm:"column:claimed_reward;type:decimal(78,0);not null"`
     PendingReward    string    `json:"pendingReward" gorm:"column:pending_reward;type:decimal(78,0);not null"`
+    PreviousTotalReward *string `json:"previousTotalReward" gorm:"column:previous_total_reward;type:decimal(78,0)"` // 应用事件前白名单中的总奖励，NULL 表示此前无记录，用于回滚
     EventTimestamp   time.Time `json:"eventTimestamp" gorm:"column:event_timestamp;not null"`
     BlockNumber      int64     `json:"blockNumber" gorm:"column:block_number;not null"`
+    BlockTimestamp   time.Time `json:"blockTimestamp" gorm:"column:block_timestamp"` // 事件所在区块的出块时间
     TxHash           string    `json:"txHash" gorm:"column:tx_hash;not null"`
     LogIndex         int       `json:"logIndex" gorm:"column:log_index;not null"`
     CreatedAt        time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
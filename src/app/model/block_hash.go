@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// BlockHash 索引器已处理区块的哈希记录，用于链重组检测
+type BlockHash struct {
+	Id              int64     `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ChainId         int64     `json:"chainId" gorm:"column:chain_id;not null"`
+	ContractAddress string    `json:"contractAddress" gorm:"column:contract_address;not null"`
+	BlockNumber     uint64    `json:"blockNumber" gorm:"column:block_number;not null"`
+	BlockHash       string    `json:"blockHash" gorm:"column:block_hash;not null"`
+	ParentHash      string    `json:"parentHash" gorm:"column:parent_hash"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+// TableName 指定表名
+func (BlockHash) TableName() string {
+	return "block_hashes"
+}
@@ -4,9 +4,10 @@ type Chain struct {
 	Id           int64  `json:"id" gorm:"column:id;primaryKey"`
 	ChainId      int64  `json:"chainId" gorm:"column:chain_id"`
 	ChainName    string `json:"chainName" gorm:"column:chain_name"`
-	Address      string `json:"address" gorm:"column:address"`          // 质押池合约地址
-	ServiceType  string `json:"serviceType" gorm:"column:service_type"` // 服务类型: staking/liquidity
-	LastBlockNum uint64 `json:"lastBlockNum" gorm:"column:last_block_num"`
+	Address      string `json:"address" gorm:"column:address"`             // 监听的合约地址
+	ServiceType  string `json:"serviceType" gorm:"column:service_type"`    // 服务类型: staking/liquidity/airdrop
+	LastBlockNum uint64 `json:"lastBlockNum" gorm:"column:last_block_num"` // 该合约最后已处理的区块号
+	Paused       bool   `json:"paused" gorm:"column:paused"`               // 是否已通过管理接口暂停监听
 }
 
 // TableName 指定表名
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// ChainLease 每条链最近一次写入派生数据的租约持有者与防护令牌
+type ChainLease struct {
+	ChainId      int64     `json:"chainId" gorm:"column:chain_id;primaryKey"`
+	FencingToken int64     `json:"fencingToken" gorm:"column:fencing_token"`
+	Holder       string    `json:"holder" gorm:"column:holder"`
+	UpdatedAt    time.Time `json:"updatedAt" gorm:"column:updated_at"`
+}
+
+func (ChainLease) TableName() string {
+	return "chain_leases"
+}
@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// 死信状态
+const (
+	DeadLetterPending  = "pending"
+	DeadLetterResolved = "resolved"
+)
+
+// DeadLetterLog 已注册事件解码或处理失败的日志，保留原始日志与错误信息供排查和重试
+type DeadLetterLog struct {
+	Id             int64      `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ChainId        int64      `json:"chainId" gorm:"column:chain_id;not null"`
+	ChainRowId     int64      `json:"chainRowId" gorm:"column:chain_row_id;not null"` // 产生该日志的监听配置（chain 表 id），重试时据此选择解码器
+	Decoder        string     `json:"decoder" gorm:"column:decoder;not null"`
+	Error          string     `json:"error" gorm:"column:error;not null"`
+	Address        string     `json:"address" gorm:"column:address;not null"`
+	Topic0         *string    `json:"topic0" gorm:"column:topic0"`
+	Topic1         *string    `json:"topic1" gorm:"column:topic1"`
+	Topic2         *string    `json:"topic2" gorm:"column:topic2"`
+	Topic3         *string    `json:"topic3" gorm:"column:topic3"`
+	Data           []byte     `json:"data" gorm:"column:data"`
+	BlockNumber    int64      `json:"blockNumber" gorm:"column:block_number;not null"`
+	BlockHash      string     `json:"blockHash" gorm:"column:block_hash;not null"`
+	BlockTimestamp *time.Time `json:"blockTimestamp" gorm:"column:block_timestamp"`
+	TxHash         string     `json:"txHash" gorm:"column:tx_hash;not null"`
+	TxIndex        int        `json:"txIndex" gorm:"column:tx_index;not null"`
+	TxSender       *string    `json:"txSender" gorm:"column:tx_sender"`
+	LogIndex       int        `json:"logIndex" gorm:"column:log_index;not null"`
+	Status         string     `json:"status" gorm:"column:status;not null;default:pending"`
+	Attempts       int        `json:"attempts" gorm:"column:attempts;not null;default:0"` // 手动重试次数
+	LastAttemptAt  *time.Time `json:"lastAttemptAt" gorm:"column:last_attempt_at"`
+	ResolvedAt     *time.Time `json:"resolvedAt" gorm:"column:resolved_at"`
+	CreatedAt      time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt      time.Time  `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (DeadLetterLog) TableName() string {
+	return "dead_letter_logs"
+}
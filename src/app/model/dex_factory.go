@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// DexFactory DEX 工厂合约的手续费配置，启动工厂监听时登记并刷新链上 feeTo
+type DexFactory struct {
+	Id               int64           `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ChainId          int64           `json:"chainId" gorm:"column:chain_id;not null"`
+	FactoryAddress   string          `json:"factoryAddress" gorm:"column:factory_address;not null"`                        // 小写
+	FeeRate          decimal.Decimal `json:"feeRate" gorm:"column:fee_rate;type:numeric(8,6);not null"`                    // 交易对默认手续费率
+	ProtocolFeeShare decimal.Decimal `json:"protocolFeeShare" gorm:"column:protocol_fee_share;type:numeric(8,6);not null"` // feeTo 开启时协议分走的比例
+	FeeTo            string          `json:"feeTo" gorm:"column:fee_to"`                                                   // 零地址或为空表示未开启
+	FeeToCheckedAt   *time.Time      `json:"feeToCheckedAt" gorm:"column:fee_to_checked_at"`
+	CreatedAt        time.Time       `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt        time.Time       `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (DexFactory) TableName() string {
+	return "dex_factories"
+}
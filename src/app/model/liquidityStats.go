@@ -5,6 +5,9 @@ type LiquidityStatsResponse struct {
 	MyLiquidityPeriodChange string `json:"myLiquidityPeriodChange"`
 	TotalFees               string `json:"totalFees"`
 	TotalFeesTodayChange    string `json:"totalFeesTodayChange"`
+	TotalLPFees             string `json:"totalLpFees"`          // 归属 LP 的手续费
+	TotalProtocolFees       string `json:"totalProtocolFees"`    // 按 feeTo 状态计提的协议分成
+	TotalProtocolRevenue    string `json:"totalProtocolRevenue"` // 已结算给 feeTo 的协议收入
 	ActivePoolsCount        int    `json:"activePoolsCount"`
 	TotalPoolsCount         int    `json:"totalPoolsCount"`
 }
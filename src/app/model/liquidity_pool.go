@@ -6,26 +6,28 @@ import (
 
 // LiquidityPoolEvent 流动性池事件记录
 type LiquidityPoolEvent struct {
-	Id            int64     `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
-	ChainId       int64     `json:"chainId" gorm:"column:chain_id;not null"`
-	TxHash        string    `json:"txHash" gorm:"column:tx_hash;not null;index"`
-	BlockNumber   int64     `json:"blockNumber" gorm:"column:block_number;not null"`
-	EventType     string    `json:"eventType" gorm:"column:event_type;not null"` // Swap, AddLiquidity, RemoveLiquidity
-	PoolAddress   string    `json:"poolAddress" gorm:"column:pool_address;not null;index"`
-	Token0Address string    `json:"token0Address" gorm:"column:token0_address"`
-	Token1Address string    `json:"token1Address" gorm:"column:token1_address"`
-	UserAddress   string    `json:"userAddress" gorm:"column:user_address;not null;index"`
-	CallerAddress string    `json:"callerAddress" gorm:"column:caller_address"`
-	Amount0In     string    `json:"amount0In" gorm:"column:amount0_in;type:decimal(78,0)"` // 大数用字符串存储
-	Amount1In     string    `json:"amount1In" gorm:"column:amount1_in;type:decimal(78,0)"`
-	Amount0Out    string    `json:"amount0Out" gorm:"column:amount0_out;type:decimal(78,0)"`
-	Amount1Out    string    `json:"amount1Out" gorm:"column:amount1_out;type:decimal(78,0)"`
-	Reserve0      string    `json:"reserve0" gorm:"column:reserve0;type:decimal(78,0)"` // 池子储备量
-	Reserve1      string    `json:"reserve1" gorm:"column:reserve1;type:decimal(78,0)"`
-	Price         string    `json:"price" gorm:"column:price;type:decimal(30,18)"`        // 价格
-	Liquidity     string    `json:"liquidity" gorm:"column:liquidity;type:decimal(78,0)"` // 流动性
-	CreatedAt     time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt     time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+	Id             int64     `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ChainId        int64     `json:"chainId" gorm:"column:chain_id;not null"`
+	TxHash         string    `json:"txHash" gorm:"column:tx_hash;not null;index"`
+	BlockNumber    int64     `json:"blockNumber" gorm:"column:block_number;not null"`
+	LogIndex       int       `json:"logIndex" gorm:"column:log_index"`             // 日志在区块中的索引
+	BlockTimestamp time.Time `json:"blockTimestamp" gorm:"column:block_timestamp"` // 事件所在区块的出块时间
+	EventType      string    `json:"eventType" gorm:"column:event_type;not null"`  // Swap, AddLiquidity, RemoveLiquidity
+	PoolAddress    string    `json:"poolAddress" gorm:"column:pool_address;not null;index"`
+	Token0Address  string    `json:"token0Address" gorm:"column:token0_address"`
+	Token1Address  string    `json:"token1Address" gorm:"column:token1_address"`
+	UserAddress    string    `json:"userAddress" gorm:"column:user_address;not null;index"`
+	CallerAddress  string    `json:"callerAddress" gorm:"column:caller_address"`
+	Amount0In      string    `json:"amount0In" gorm:"column:amount0_in;type:decimal(78,0)"` // 大数用字符串存储
+	Amount1In      string    `json:"amount1In" gorm:"column:amount1_in;type:decimal(78,0)"`
+	Amount0Out     string    `json:"amount0Out" gorm:"column:amount0_out;type:decimal(78,0)"`
+	Amount1Out     string    `json:"amount1Out" gorm:"column:amount1_out;type:decimal(78,0)"`
+	Reserve0       string    `json:"reserve0" gorm:"column:reserve0;type:decimal(78,0)"` // 事件之后的池子储备量，取同一交易中紧邻的 Sync
+	Reserve1       string    `json:"reserve1" gorm:"column:reserve1;type:decimal(78,0)"`
+	Price          string    `json:"price" gorm:"column:price;type:numeric(78,18)"`        // 价格
+	Liquidity      string    `json:"liquidity" gorm:"column:liquidity;type:decimal(78,0)"` // 流动性
+	CreatedAt      time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt      time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
 }
 
 // TableName 指定表名
@@ -35,25 +37,30 @@ func (LiquidityPoolEvent) TableName() string {
 
 // LiquidityPool 流动性池信息
 type LiquidityPool struct {
-	Id             int64     `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
-	ChainId        int64     `json:"chainId" gorm:"column:chain_id;not null"`
-	PoolAddress    string    `json:"poolAddress" gorm:"column:pool_address;not null;uniqueIndex:idx_chain_pool"`
-	Token0Address  string    `json:"token0Address" gorm:"column:token0_address"`
-	Token1Address  string    `json:"token1Address" gorm:"column:token1_address"`
-	Token0Symbol   string    `json:"token0Symbol" gorm:"column:token0_symbol"`
-	Token1Symbol   string    `json:"token1Symbol" gorm:"column:token1_symbol"`
-	Token0Decimals int       `json:"token0Decimals" gorm:"column:token0_decimals"`
-	Token1Decimals int       `json:"token1Decimals" gorm:"column:token1_decimals"`
-	Reserve0       string    `json:"reserve0" gorm:"column:reserve0;type:decimal(78,0)"`
-	Reserve1       string    `json:"reserve1" gorm:"column:reserve1;type:decimal(78,0)"`
-	TotalSupply    string    `json:"totalSupply" gorm:"column:total_supply;type:decimal(78,0)"`
-	Price          string    `json:"price" gorm:"column:price;type:decimal(30,18)"`
-	Volume24h      string    `json:"volume24h" gorm:"column:volume_24h;type:decimal(78,0)"`
-	TxCount        int64     `json:"txCount" gorm:"column:tx_count"`
-	LastBlockNum   int64     `json:"lastBlockNum" gorm:"column:last_block_num"`
-	IsActive       bool      `json:"isActive" gorm:"column:is_active;default:true"`
-	CreatedAt      time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt      time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+	Id              int64     `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ChainId         int64     `json:"chainId" gorm:"column:chain_id;not null"`
+	PoolAddress     string    `json:"poolAddress" gorm:"column:pool_address;not null;uniqueIndex:idx_chain_pool"`
+	FactoryAddress  string    `json:"factoryAddress" gorm:"column:factory_address"` // 创建该交易对的工厂合约，手动登记的池子为空
+	Token0Address   string    `json:"token0Address" gorm:"column:token0_address"`
+	Token1Address   string    `json:"token1Address" gorm:"column:token1_address"`
+	Token0Symbol    string    `json:"token0Symbol" gorm:"column:token0_symbol"`
+	Token1Symbol    string    `json:"token1Symbol" gorm:"column:token1_symbol"`
+	Token0Decimals  int       `json:"token0Decimals" gorm:"column:token0_decimals"`
+	Token1Decimals  int       `json:"token1Decimals" gorm:"column:token1_decimals"`
+	Reserve0        string    `json:"reserve0" gorm:"column:reserve0;type:decimal(78,0)"`
+	Reserve1        string    `json:"reserve1" gorm:"column:reserve1;type:decimal(78,0)"`
+	TotalSupply     string    `json:"totalSupply" gorm:"column:total_supply;type:decimal(78,0)"`
+	Price           string    `json:"price" gorm:"column:price;type:numeric(78,18)"`
+	Volume24h       string    `json:"volume24h" gorm:"column:volume_24h;type:decimal(78,0)"`
+	TxCount         int64     `json:"txCount" gorm:"column:tx_count"`
+	LastBlockNum    int64     `json:"lastBlockNum" gorm:"column:last_block_num"`
+	ReserveBlockNum *int64    `json:"reserveBlockNum" gorm:"column:reserve_block_num"` // 储备量对应的 Sync 事件所在区块
+	ReserveLogIndex *int      `json:"reserveLogIndex" gorm:"column:reserve_log_index"`
+	CreatedBlock    *int64    `json:"createdBlock" gorm:"column:created_block"`         // PairCreated 所在区块，通过 allPairs 补登记的为空
+	FeeRate         *float64  `json:"feeRate" gorm:"column:fee_rate;type:numeric(8,6)"` // 交易对手续费率，为空时取工厂配置
+	IsActive        bool      `json:"isActive" gorm:"column:is_active;default:true"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt       time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
 }
 
 // TableName 指定表名
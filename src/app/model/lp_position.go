@@ -0,0 +1,55 @@
+package model
+
+import "time"
+
+// LPTokenTransfer 交易对 LP 代币的 Transfer 事件，是 LP 持仓账本的原始记录
+// from 为零地址表示铸造，to 为零地址表示销毁
+type LPTokenTransfer struct {
+	Id             int64     `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ChainId        int64     `json:"chainId" gorm:"column:chain_id;not null"`
+	PoolAddress    string    `json:"poolAddress" gorm:"column:pool_address;not null"`
+	FromAddress    string    `json:"fromAddress" gorm:"column:from_address;not null"`
+	ToAddress      string    `json:"toAddress" gorm:"column:to_address;not null"`
+	Value          string    `json:"value" gorm:"column:value;type:decimal(78,0);not null"`
+	BlockNumber    int64     `json:"blockNumber" gorm:"column:block_number;not null"`
+	LogIndex       int       `json:"logIndex" gorm:"column:log_index;not null"`
+	BlockTimestamp time.Time `json:"blockTimestamp" gorm:"column:block_timestamp"`
+	TxHash         string    `json:"txHash" gorm:"column:tx_hash;not null"`
+	IsProtocolFee  bool      `json:"isProtocolFee" gorm:"column:is_protocol_fee"` // Mint/Burn 前铸造给 feeTo 的协议手续费
+	CreatedAt      time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+func (LPTokenTransfer) TableName() string {
+	return "lp_token_transfers"
+}
+
+// LPBalance 钱包在交易对中的当前 LP 余额，由 lp_token_transfers 累加得到
+// 零地址的余额为永久锁定的 MINIMUM_LIQUIDITY，全部钱包余额之和即 LP 总供应量
+type LPBalance struct {
+	ChainId       int64     `json:"chainId" gorm:"column:chain_id;primaryKey"`
+	PoolAddress   string    `json:"poolAddress" gorm:"column:pool_address;primaryKey"`
+	WalletAddress string    `json:"walletAddress" gorm:"column:wallet_address;primaryKey"`
+	Balance       string    `json:"balance" gorm:"column:balance;type:decimal(78,0);not null"`
+	LastBlockNum  int64     `json:"lastBlockNum" gorm:"column:last_block_num"`
+	UpdatedAt     time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (LPBalance) TableName() string {
+	return "lp_balances"
+}
+
+// LPBalanceChange 钱包 LP 余额的一次变动（视图 lp_balance_changes），按区块顺序累加即余额历史
+type LPBalanceChange struct {
+	ChainId        int64     `json:"chainId" gorm:"column:chain_id"`
+	PoolAddress    string    `json:"poolAddress" gorm:"column:pool_address"`
+	WalletAddress  string    `json:"walletAddress" gorm:"column:wallet_address"`
+	Delta          string    `json:"delta" gorm:"column:delta"`
+	BlockNumber    int64     `json:"blockNumber" gorm:"column:block_number"`
+	LogIndex       int       `json:"logIndex" gorm:"column:log_index"`
+	BlockTimestamp time.Time `json:"blockTimestamp" gorm:"column:block_timestamp"`
+	TxHash         string    `json:"txHash" gorm:"column:tx_hash"`
+}
+
+func (LPBalanceChange) TableName() string {
+	return "lp_balance_changes"
+}
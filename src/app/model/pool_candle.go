@@ -0,0 +1,35 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PoolCandle 交易对 K 线，由 liquidity_pool_events 聚合：1m 直接由事件聚合，5m/1h/1d 由 1m 汇总
+// 价格为 1 个 token0 折合的 token1 数量（已按精度换算），USD 价格为 token0 的 USD 价格，代币未定价时为空
+type PoolCandle struct {
+	Id          int64               `json:"-" gorm:"column:id;primaryKey;autoIncrement"`
+	ChainId     int64               `json:"chainId" gorm:"column:chain_id;not null"`
+	PoolAddress string              `json:"poolAddress" gorm:"column:pool_address;not null"` // 小写
+	Resolution  string              `json:"interval" gorm:"column:resolution;not null"`      // 1m/5m/1h/1d
+	BucketStart time.Time           `json:"time" gorm:"column:bucket_start;not null"`        // 周期起点（UTC 对齐）
+	Open        decimal.Decimal     `json:"open" gorm:"column:open;type:numeric(60,30)"`
+	High        decimal.Decimal     `json:"high" gorm:"column:high;type:numeric(60,30)"`
+	Low         decimal.Decimal     `json:"low" gorm:"column:low;type:numeric(60,30)"`
+	Close       decimal.Decimal     `json:"close" gorm:"column:close;type:numeric(60,30)"`
+	OpenUSD     decimal.NullDecimal `json:"openUsd" gorm:"column:open_usd;type:numeric(60,30)"`
+	HighUSD     decimal.NullDecimal `json:"highUsd" gorm:"column:high_usd;type:numeric(60,30)"`
+	LowUSD      decimal.NullDecimal `json:"lowUsd" gorm:"column:low_usd;type:numeric(60,30)"`
+	CloseUSD    decimal.NullDecimal `json:"closeUsd" gorm:"column:close_usd;type:numeric(60,30)"`
+	Volume0     decimal.Decimal     `json:"volume0" gorm:"column:volume0;type:numeric(78,0)"` // Swap 中 token0 的成交量（最小单位）
+	Volume1     decimal.Decimal     `json:"volume1" gorm:"column:volume1;type:numeric(78,0)"`
+	VolumeUSD   decimal.NullDecimal `json:"volumeUsd" gorm:"column:volume_usd;type:numeric(40,6)"`
+	SwapCount   int64               `json:"swapCount" gorm:"column:swap_count"`
+	EventCount  int64               `json:"eventCount" gorm:"column:event_count"` // 含 Mint/Burn，二者只影响价格
+	UpdatedAt   time.Time           `json:"-" gorm:"column:updated_at"`
+}
+
+func (PoolCandle) TableName() string {
+	return "pool_candles"
+}
@@ -0,0 +1,48 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// PoolPeriodData 交易对在一个周期内的汇总，由 K 线与 LP 持仓账本汇总得到，只为有事件或 LP 变动的周期生成
+// 储备量、TVL 与 LP 人数为周期结束时（当前周期为最新）的值，交易量与手续费为周期内合计
+type PoolPeriodData struct {
+	Id                 int64               `json:"-" gorm:"column:id;primaryKey;autoIncrement"`
+	ChainId            int64               `json:"chainId" gorm:"column:chain_id;not null"`
+	PoolAddress        string              `json:"poolAddress" gorm:"column:pool_address;not null"` // 小写
+	PeriodStart        time.Time           `json:"periodStart" gorm:"column:period_start;not null"` // 周期起点（UTC 对齐）
+	Reserve0           decimal.Decimal     `json:"reserve0" gorm:"column:reserve0;type:numeric(78,0)"`
+	Reserve1           decimal.Decimal     `json:"reserve1" gorm:"column:reserve1;type:numeric(78,0)"`
+	TVLUSD             decimal.NullDecimal `json:"tvlUsd" gorm:"column:tvl_usd;type:numeric(40,6)"` // 两侧代币都未定价时为空
+	Volume0            decimal.Decimal     `json:"volume0" gorm:"column:volume0;type:numeric(78,0)"`
+	Volume1            decimal.Decimal     `json:"volume1" gorm:"column:volume1;type:numeric(78,0)"`
+	VolumeUSD          decimal.NullDecimal `json:"volumeUsd" gorm:"column:volume_usd;type:numeric(40,6)"`
+	FeesUSD            decimal.NullDecimal `json:"feesUsd" gorm:"column:fees_usd;type:numeric(40,6)"`                        // 手续费合计，按交易对手续费率计算
+	LPFeesUSD          decimal.NullDecimal `json:"lpFeesUsd" gorm:"column:lp_fees_usd;type:numeric(40,6)"`                   // 扣除协议分成后归属 LP 的部分
+	ProtocolFeesUSD    decimal.NullDecimal `json:"protocolFeesUsd" gorm:"column:protocol_fees_usd;type:numeric(40,6)"`       // 按工厂当前 feeTo 状态计提的协议分成
+	ProtocolRevenueUSD decimal.NullDecimal `json:"protocolRevenueUsd" gorm:"column:protocol_revenue_usd;type:numeric(40,6)"` // 已结算的协议收入：铸造给 feeTo 的 LP 代币按铸造时 TVL 折算
+	TxCount            int64               `json:"txCount" gorm:"column:tx_count"`                                           // Swap/Mint/Burn 事件数
+	SwapCount          int64               `json:"swapCount" gorm:"column:swap_count"`
+	LPCount            int64               `json:"lpCount" gorm:"column:lp_count"` // LP 余额大于 0 的钱包数，不含零地址
+	UpdatedAt          time.Time           `json:"-" gorm:"column:updated_at"`
+}
+
+// PoolHourData 交易对小时汇总
+type PoolHourData struct {
+	PoolPeriodData `gorm:"embedded"`
+}
+
+func (PoolHourData) TableName() string {
+	return "pool_hour_data"
+}
+
+// PoolDayData 交易对日汇总
+type PoolDayData struct {
+	PoolPeriodData `gorm:"embedded"`
+}
+
+func (PoolDayData) TableName() string {
+	return "pool_day_data"
+}
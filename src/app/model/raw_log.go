@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+// RawLog 索引器收到的原始日志，只追加不修改（链重组时仅标记 orphaned），用于离线重新解码
+type RawLog struct {
+	Id             int64      `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ChainId        int64      `json:"chainId" gorm:"column:chain_id;not null"`
+	Address        string     `json:"address" gorm:"column:address;not null"` // 产生日志的合约地址（小写）
+	Topic0         *string    `json:"topic0" gorm:"column:topic0"`
+	Topic1         *string    `json:"topic1" gorm:"column:topic1"`
+	Topic2         *string    `json:"topic2" gorm:"column:topic2"`
+	Topic3         *string    `json:"topic3" gorm:"column:topic3"`
+	Data           []byte     `json:"data" gorm:"column:data"`
+	BlockNumber    int64      `json:"blockNumber" gorm:"column:block_number;not null"`
+	BlockHash      string     `json:"blockHash" gorm:"column:block_hash;not null"`
+	BlockTimestamp *time.Time `json:"blockTimestamp" gorm:"column:block_timestamp"` // 未解码的日志（如 PairCreated）可能为空
+	TxHash         string     `json:"txHash" gorm:"column:tx_hash;not null"`
+	TxIndex        int        `json:"txIndex" gorm:"column:tx_index;not null"`
+	TxSender       *string    `json:"txSender" gorm:"column:tx_sender"` // 交易发送者，未解析时为空
+	LogIndex       int        `json:"logIndex" gorm:"column:log_index;not null"`
+	Orphaned       bool       `json:"orphaned" gorm:"column:orphaned;not null;default:false"` // 所在区块已被链重组移除
+	CreatedAt      time.Time  `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+func (RawLog) TableName() string {
+	return "raw_logs"
+}
+
+// RawLogRange 某条监听配置已归档的连续区块范围，离线重建前据此确认归档完整
+type RawLogRange struct {
+	Id         int64     `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ChainRowId int64     `json:"chainRowId" gorm:"column:chain_row_id;not null"` // chain 表的 id
+	FromBlock  uint64    `json:"fromBlock" gorm:"column:from_block;not null"`
+	ToBlock    uint64    `json:"toBlock" gorm:"column:to_block;not null"`
+	UpdatedAt  time.Time `json:"updatedAt" gorm:"column:updated_at;autoUpdateTime"`
+}
+
+func (RawLogRange) TableName() string {
+	return "raw_log_ranges"
+}
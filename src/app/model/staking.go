@@ -0,0 +1,94 @@
+package model
+
+import "time"
+
+// StakingRewardClaim StakeV2 ClaimRewards 事件，用户领取质押收益
+type StakingRewardClaim struct {
+	Id              int64     `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ChainId         int64     `json:"chainId" gorm:"column:chain_id;not null"`
+	ContractAddress string    `json:"contractAddress" gorm:"column:contract_address;not null"`
+	UserAddress     string    `json:"userAddress" gorm:"column:user_address;not null"`
+	PoolId          int64     `json:"poolId" gorm:"column:pool_id;not null"`
+	Reward          string    `json:"reward" gorm:"column:reward;type:decimal(78,0);not null"`
+	ClaimedAt       time.Time `json:"claimedAt" gorm:"column:claimed_at"`
+	BlockNumber     int64     `json:"blockNumber" gorm:"column:block_number;not null"`
+	BlockTimestamp  time.Time `json:"blockTimestamp" gorm:"column:block_timestamp"`
+	TxHash          string    `json:"txHash" gorm:"column:tx_hash;not null"`
+	LogIndex        int       `json:"logIndex" gorm:"column:log_index;not null"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+func (StakingRewardClaim) TableName() string {
+	return "staking_reward_claims"
+}
+
+// StakingPool StakeV2 PoolCreated 事件登记的质押池
+type StakingPool struct {
+	Id              int64     `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ChainId         int64     `json:"chainId" gorm:"column:chain_id;not null"`
+	ContractAddress string    `json:"contractAddress" gorm:"column:contract_address;not null"`
+	PoolId          int64     `json:"poolId" gorm:"column:pool_id;not null"`
+	TokenAddress    string    `json:"tokenAddress" gorm:"column:token_address;not null"`
+	LockDuration    int64     `json:"lockDuration" gorm:"column:lock_duration;not null"` // 锁定时长（秒）
+	Name            string    `json:"name" gorm:"column:name"`
+	BlockNumber     int64     `json:"blockNumber" gorm:"column:block_number;not null"`
+	BlockTimestamp  time.Time `json:"blockTimestamp" gorm:"column:block_timestamp"`
+	TxHash          string    `json:"txHash" gorm:"column:tx_hash;not null"`
+	LogIndex        int       `json:"logIndex" gorm:"column:log_index;not null"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+func (StakingPool) TableName() string {
+	return "staking_pools"
+}
+
+// StakingSharePrice StakeV2 SharePriceUpdated 事件，份额价格历史
+type StakingSharePrice struct {
+	Id              int64     `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ChainId         int64     `json:"chainId" gorm:"column:chain_id;not null"`
+	ContractAddress string    `json:"contractAddress" gorm:"column:contract_address;not null"`
+	PriceDate       string    `json:"priceDate" gorm:"column:price_date;type:decimal(78,0);not null"` // 合约传入的日期参数，原样保存
+	Price           string    `json:"price" gorm:"column:price;type:decimal(78,0);not null"`
+	BlockNumber     int64     `json:"blockNumber" gorm:"column:block_number;not null"`
+	BlockTimestamp  time.Time `json:"blockTimestamp" gorm:"column:block_timestamp"`
+	TxHash          string    `json:"txHash" gorm:"column:tx_hash;not null"`
+	LogIndex        int       `json:"logIndex" gorm:"column:log_index;not null"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+func (StakingSharePrice) TableName() string {
+	return "staking_share_prices"
+}
+
+// StakingPauseEvent StakeV2 Paused/Unpaused 事件
+type StakingPauseEvent struct {
+	Id              int64     `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ChainId         int64     `json:"chainId" gorm:"column:chain_id;not null"`
+	ContractAddress string    `json:"contractAddress" gorm:"column:contract_address;not null"`
+	Paused          bool      `json:"paused" gorm:"column:paused;not null"`
+	Account         string    `json:"account" gorm:"column:account"` // 执行暂停/恢复的账户
+	BlockNumber     int64     `json:"blockNumber" gorm:"column:block_number;not null"`
+	BlockTimestamp  time.Time `json:"blockTimestamp" gorm:"column:block_timestamp"`
+	TxHash          string    `json:"txHash" gorm:"column:tx_hash;not null"`
+	LogIndex        int       `json:"logIndex" gorm:"column:log_index;not null"`
+	CreatedAt       time.Time `json:"createdAt" gorm:"column:created_at;autoCreateTime"`
+}
+
+func (StakingPauseEvent) TableName() string {
+	return "staking_pause_events"
+}
+
+// StakingContractState 质押合约当前状态（视图 staking_contract_state），由最新的暂停事件与份额价格事件得出
+type StakingContractState struct {
+	ChainId             int64      `json:"chainId" gorm:"column:chain_id"`
+	ContractAddress     string     `json:"contractAddress" gorm:"column:contract_address"`
+	Paused              bool       `json:"paused" gorm:"column:paused"`
+	PauseUpdatedAt      *time.Time `json:"pauseUpdatedAt" gorm:"column:pause_updated_at"`
+	SharePrice          *string    `json:"sharePrice" gorm:"column:share_price"`
+	SharePriceDate      *string    `json:"sharePriceDate" gorm:"column:share_price_date"`
+	SharePriceUpdatedAt *time.Time `json:"sharePriceUpdatedAt" gorm:"column:share_price_updated_at"`
+}
+
+func (StakingContractState) TableName() string {
+	return "staking_contract_state"
+}
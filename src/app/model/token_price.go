@@ -0,0 +1,24 @@
+package model
+
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// TokenPrice 代币 USD 价格历史，由定价任务经池子路由推导，价格变化或距上次记录超过一小时时追加
+type TokenPrice struct {
+	Id           int64           `json:"id" gorm:"column:id;primaryKey;autoIncrement"`
+	ChainId      int64           `json:"chainId" gorm:"column:chain_id;not null"`
+	TokenAddress string          `json:"tokenAddress" gorm:"column:token_address;not null"` // 小写
+	Symbol       string          `json:"symbol" gorm:"column:symbol"`
+	PriceUSD     decimal.Decimal `json:"priceUsd" gorm:"column:price_usd;type:numeric(60,30);not null"`
+	LiquidityUSD decimal.Decimal `json:"liquidityUsd" gorm:"column:liquidity_usd;type:numeric(40,2);not null"` // 定价池子已定价一侧的流动性
+	Hops         int             `json:"hops" gorm:"column:hops;not null"`                                     // 到锚定稳定币的跳数，锚定币为 0
+	PoolAddress  string          `json:"poolAddress" gorm:"column:pool_address"`                               // 定价所用池子，锚定币为空
+	ComputedAt   time.Time       `json:"computedAt" gorm:"column:computed_at;not null"`
+}
+
+func (TokenPrice) TableName() string {
+	return "token_prices"
+}
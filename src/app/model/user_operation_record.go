@@ -1,19 +1,28 @@
 package model
 
-import "time"
+import (
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 type UserOperationRecord struct {
-	Id            int64     `json:"id" gorm:"column:id;primaryKey"`
-	ChainId       int64     `json:"chainId" gorm:"column:chain_id"`
-	Address       string    `json:"address" gorm:"column:address"`
-	PoolId        int64     `json:"poolId" gorm:"column:pool_id"`
-	Amount        int64     `json:"amount" gorm:"column:amount"`
-	OperationTime time.Time `json:"operationTime" gorm:"column:operation_time"` // 操作时间 (Operation Time)
-	UnlockTime    time.Time `json:"unlockTime" gorm:"column:unlock_time"`
-	TxHash        string    `json:"txHash" gorm:"column:tx_hash"`
-	BlockNumber   int64     `json:"blockNumber" gorm:"column:block_number"`
-	EventType     string    `json:"eventType" gorm:"column:event_type"` // 事件类型 (Event Type)
-	TokenAddress  string    `json:"tokenAddress" gorm:"column:token_address"`
+	Id            int64           `json:"id" gorm:"column:id;primaryKey"`
+	ChainId       int64           `json:"chainId" gorm:"column:chain_id"`
+	Address       string          `json:"address" gorm:"column:address"`
+	PoolId        int64           `json:"poolId" gorm:"column:pool_id"`
+	Amount        decimal.Decimal `json:"amount" gorm:"column:amount;type:numeric(78,0)"` // 代币最小单位
+	OperationTime time.Time       `json:"operationTime" gorm:"column:operation_time"`     // 操作时间 (Operation Time)
+	UnlockTime    time.Time       `json:"unlockTime" gorm:"column:unlock_time"`
+	TxHash        string          `json:"txHash" gorm:"column:tx_hash"`
+	LogIndex      int             `json:"logIndex" gorm:"column:log_index"` // 日志在区块中的索引，与 chain_id、tx_hash 共同唯一
+	BlockNumber   int64           `json:"blockNumber" gorm:"column:block_number"`
+	// BlockTimestamp 事件所在区块的出块时间
+	BlockTimestamp time.Time `json:"blockTimestamp" gorm:"column:block_timestamp"`
+	EventType      string    `json:"eventType" gorm:"column:event_type"` // 事件类型 (Event Type)
+	TokenAddress   string    `json:"tokenAddress" gorm:"column:token_address"`
+	// ContractAddress 产生事件的质押合约地址
+	ContractAddress string `json:"contractAddress" gorm:"column:contract_address"`
 }
 
 func (UserOperationRecord) TableName() string {
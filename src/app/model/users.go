@@ -10,9 +10,9 @@ type Users struct {
 	ChainId      int64           `json:"chainId" gorm:"column:chain_id"`
 	Address      string          `json:"address" gorm:"column:address"`
 	TokenAddress string          `json:"tokenAddress" gorm:"column:token_address"`
-	TotalAmount  int64           `json:"totalAmount" gorm:"column:total_amount"`
+	TotalAmount  decimal.Decimal `json:"totalAmount" gorm:"column:total_amount;type:numeric(78,0)"` // 代币最小单位
 	LastBlockNum int64           `json:"lastBlockNum" gorm:"column:last_block_num"`
-	JfAmount     int64           `json:"jfAmount" gorm:"column:jf_amount"`
+	JfAmount     decimal.Decimal `json:"jfAmount" gorm:"column:jf_amount;type:numeric(78,0)"`
 	JfTime       time.Time       `json:"jfTime" gorm:"column:jf_time"`
 	Jf           decimal.Decimal `json:"jf" gorm:"column:jf"`
 }
@@ -0,0 +1,50 @@
+package service
+
+import (
+	"fmt"
+
+	"github.com/mumu/cryptoSwap/src/app/api/dto"
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/ctx"
+)
+
+// DeadLetterService 死信查询
+type DeadLetterService struct{}
+
+func NewDeadLetterService() *DeadLetterService {
+	return &DeadLetterService{}
+}
+
+// DeadLetterFilter 死信筛选条件，零值字段不参与筛选
+type DeadLetterFilter struct {
+	ChainId int64
+	Status  string
+	Decoder string
+}
+
+// ListDeadLetters 分页获取死信，按区块倒序
+func (s *DeadLetterService) ListDeadLetters(filter DeadLetterFilter, pagination dto.Pagination) ([]model.DeadLetterLog, int64, error) {
+	var deadLetters []model.DeadLetterLog
+	var total int64
+
+	query := ctx.Ctx.DB.Model(&model.DeadLetterLog{})
+	if filter.ChainId > 0 {
+		query = query.Where("chain_id = ?", filter.ChainId)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.Decoder != "" {
+		query = query.Where("decoder = ?", filter.Decoder)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询记录总数失败: %v", err)
+	}
+	if err := query.Order("block_number DESC, log_index DESC").
+		Offset(pagination.Offset).
+		Limit(pagination.PageSize).
+		Find(&deadLetters).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询死信失败: %v", err)
+	}
+	return deadLetters, total, nil
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"math"
 	"math/big"
+	"strings"
 	"time"
 
 	"github.com/mumu/cryptoSwap/src/app/model"
@@ -35,7 +36,7 @@ func (s *LiquidityPoolService) GetPoolEvents(chainId int64, poolAddress string,
 		query = query.Where("event_type = ?", eventType)
 	}
 
-	err := query.Order("created_at DESC").Limit(limit).Find(&events).Error
+	err := query.Order("COALESCE(block_timestamp, created_at) DESC").Limit(limit).Find(&events).Error
 	return events, err
 }
 
@@ -43,7 +44,7 @@ func (s *LiquidityPoolService) GetPoolEvents(chainId int64, poolAddress string,
 func (s *LiquidityPoolService) GetUserEvents(chainId int64, userAddress string, limit int) ([]model.LiquidityPoolEvent, error) {
 	var events []model.LiquidityPoolEvent
 	err := ctx.Ctx.DB.Where("chain_id = ? AND user_address = ?", chainId, userAddress).
-		Order("created_at DESC").Limit(limit).Find(&events).Error
+		Order("COALESCE(block_timestamp, created_at) DESC").Limit(limit).Find(&events).Error
 	return events, err
 }
 
@@ -74,6 +75,21 @@ func (s *LiquidityPoolService) ListUserPoolAddresses(userAddress string, chainId
 	return poolAddresses, nil
 }
 
+// ListUserLPPoolAddresses 根据 LP 持仓账本查询用户当前持有 LP 代币的池子地址
+func (s *LiquidityPoolService) ListUserLPPoolAddresses(userAddress string, chainIdOpt int64) ([]string, error) {
+	var poolAddresses []string
+	query := ctx.Ctx.DB.Table("liquidity_pools p").
+		Joins("JOIN lp_balances b ON b.chain_id = p.chain_id AND b.pool_address = LOWER(p.pool_address)").
+		Where("b.wallet_address = ? AND b.balance > 0", strings.ToLower(userAddress))
+	if chainIdOpt > 0 {
+		query = query.Where("p.chain_id = ?", chainIdOpt)
+	}
+	if err := query.Distinct().Pluck("p.pool_address", &poolAddresses).Error; err != nil {
+		return nil, err
+	}
+	return poolAddresses, nil
+}
+
 // ListActivePoolsByAddresses 根据地址列表（可选链ID）查询活跃池子（不分页）
 func (s *LiquidityPoolService) ListActivePoolsByAddresses(addresses []string, chainIdOpt int64) ([]model.LiquidityPool, error) {
 	var pools []model.LiquidityPool
@@ -129,27 +145,11 @@ func (s *LiquidityPoolService) ListActivePools(chainIdOpt int64, offset, limit i
 	return pools, total, nil
 }
 
+// DefaultFeeRate 交易对与工厂均未配置手续费率时使用的 UniswapV2 费率
 const DefaultFeeRate = 0.003
 
-// Compute24hStats 计算 24h 交易量、手续费与 APY（APY 为字符串，含百分号）
-func (s *LiquidityPoolService) Compute24hStats(pool model.LiquidityPool) (volumeUSD float64, feesUSD float64, apy string) {
-	volumeUSD = compute24hVolumeUSD(pool)
-	feesUSD = volumeUSD * DefaultFeeRate
-	apy = computeAPY(pool, feesUSD)
-	return
-}
-
 // --- 计算辅助方法（从 API 迁移） ---
 
-func isStable(symbol string) bool {
-	switch symbol {
-	case "USDC", "USDT", "DAI":
-		return true
-	default:
-		return false
-	}
-}
-
 func parseBigInt(s string) *big.Int {
 	if s == "" {
 		return big.NewInt(0)
@@ -169,86 +169,8 @@ func toFloatWithDecimals(v *big.Int, decimals int) float64 {
 	return f
 }
 
-// computeVolumeUSDForPeriod 计算任意时间段内的 USD 交易量（稳定币侧）
-func computeVolumeUSDForPeriod(pool model.LiquidityPool, start, end time.Time) float64 {
-	var events []model.LiquidityPoolEvent
-	if err := ctx.Ctx.DB.Model(&model.LiquidityPoolEvent{}).
-		Where("chain_id = ? AND pool_address = ? AND event_type = ? AND created_at >= ? AND created_at < ?",
-			pool.ChainId, pool.PoolAddress, "Swap", start, end).
-		Order("created_at DESC").
-		Find(&events).Error; err != nil {
-		return 0
-	}
-
-	var total float64
-	token0Stable := isStable(pool.Token0Symbol)
-	token1Stable := isStable(pool.Token1Symbol)
-
-	for _, e := range events {
-		a0in := parseBigInt(e.Amount0In)
-		a0out := parseBigInt(e.Amount0Out)
-		a1in := parseBigInt(e.Amount1In)
-		a1out := parseBigInt(e.Amount1Out)
-
-		if token0Stable {
-			vol0 := new(big.Int).Add(a0in, a0out)
-			total += toFloatWithDecimals(vol0, pool.Token0Decimals)
-		} else if token1Stable {
-			vol1 := new(big.Int).Add(a1in, a1out)
-			total += toFloatWithDecimals(vol1, pool.Token1Decimals)
-		}
-	}
-	return total
-}
-
-// ComputeFeesUSDForPeriod 计算任意时间段内的 USD 手续费
-func (s *LiquidityPoolService) ComputeFeesUSDForPeriod(pool model.LiquidityPool, start, end time.Time) float64 {
-	vol := computeVolumeUSDForPeriod(pool, start, end)
-	return vol * DefaultFeeRate
-}
-
-// compute24hVolumeUSD 仅在稳定币池中返回 USD 交易量，否则为 0
-func compute24hVolumeUSD(pool model.LiquidityPool) float64 {
-	since := time.Now().Add(-24 * time.Hour)
-	var events []model.LiquidityPoolEvent
-	if err := ctx.Ctx.DB.Model(&model.LiquidityPoolEvent{}).
-		Where("chain_id = ? AND pool_address = ? AND event_type = ? AND created_at >= ?",
-			pool.ChainId, pool.PoolAddress, "Swap", since).
-		Order("created_at DESC").
-		Find(&events).Error; err != nil {
-		return 0
-	}
-
-	var total float64
-	token0Stable := isStable(pool.Token0Symbol)
-	token1Stable := isStable(pool.Token1Symbol)
-
-	for _, e := range events {
-		a0in := parseBigInt(e.Amount0In)
-		a0out := parseBigInt(e.Amount0Out)
-		a1in := parseBigInt(e.Amount1In)
-		a1out := parseBigInt(e.Amount1Out)
-
-		if token0Stable {
-			vol0 := new(big.Int).Add(a0in, a0out)
-			total += toFloatWithDecimals(vol0, pool.Token0Decimals)
-		} else if token1Stable {
-			vol1 := new(big.Int).Add(a1in, a1out)
-			total += toFloatWithDecimals(vol1, pool.Token1Decimals)
-		}
-	}
-	return total
-}
-
-// computeAPY 基于稳定币侧的 TVL 估算 APY
-func computeAPY(pool model.LiquidityPool, feesUSD24h float64) string {
-	var tvlUSD float64
-	if isStable(pool.Token0Symbol) {
-		tvlUSD = 2 * toFloatWithDecimals(parseBigInt(pool.Reserve0), pool.Token0Decimals)
-	} else if isStable(pool.Token1Symbol) {
-		tvlUSD = 2 * toFloatWithDecimals(parseBigInt(pool.Reserve1), pool.Token1Decimals)
-	}
-
+// formatAPY 由 24h 手续费与 TVL 年化，TVL 未知时为 "-"
+func formatAPY(feesUSD24h, tvlUSD float64) string {
 	if tvlUSD <= 0 {
 		return "-"
 	}
@@ -286,7 +208,7 @@ func (s *LiquidityPoolService) GetPoolStats(chainId int64) (map[string]interface
 
 	// 今日事件数
 	var todayEvents int64
-	err = ctx.Ctx.DB.Model(&model.LiquidityPoolEvent{}).Where("chain_id = ? AND created_at::date = CURRENT_DATE", chainId).Count(&todayEvents).Error
+	err = ctx.Ctx.DB.Model(&model.LiquidityPoolEvent{}).Where("chain_id = ? AND COALESCE(block_timestamp, created_at)::date = CURRENT_DATE", chainId).Count(&todayEvents).Error
 	if err != nil {
 		return nil, err
 	}
@@ -316,7 +238,7 @@ func (s *LiquidityPoolService) GetTopPools(chainId int64, limit int) ([]model.Li
 func (s *LiquidityPoolService) GetRecentEvents(chainId int64, limit int) ([]model.LiquidityPoolEvent, error) {
 	var events []model.LiquidityPoolEvent
 	err := ctx.Ctx.DB.Where("chain_id = ?", chainId).
-		Order("created_at DESC").Limit(limit).Find(&events).Error
+		Order("COALESCE(block_timestamp, created_at) DESC").Limit(limit).Find(&events).Error
 	return events, err
 }
 
@@ -337,7 +259,7 @@ func (s *LiquidityPoolService) GetPoolVolume(chainId int64, poolAddress string,
 	// 指定天数内的交易量
 	var periodVolume int64
 	err = ctx.Ctx.DB.Model(&model.LiquidityPoolEvent{}).
-		Where("chain_id = ? AND pool_address = ? AND event_type = ? AND created_at >= DATE_SUB(NOW(), INTERVAL ? DAY)",
+		Where("chain_id = ? AND pool_address = ? AND event_type = ? AND COALESCE(block_timestamp, created_at) >= DATE_SUB(NOW(), INTERVAL ? DAY)",
 			chainId, poolAddress, "Swap", days).
 		Count(&periodVolume).Error
 	if err != nil {
@@ -348,6 +270,26 @@ func (s *LiquidityPoolService) GetPoolVolume(chainId int64, poolAddress string,
 	return stats, nil
 }
 
+// CandleResolutions 支持的 K 线周期及其秒数，与索引器聚合的周期一致
+var CandleResolutions = map[string]int64{
+	"1m": 60,
+	"5m": 300,
+	"1h": 3600,
+	"1d": 86400,
+}
+
+// GetPoolCandles 按时间升序返回池子在 [from, to] 内的 K 线，没有事件的周期不返回；chainId 为 0 时不限链
+func (s *LiquidityPoolService) GetPoolCandles(chainId int64, poolAddress, resolution string, from, to time.Time, limit int) ([]model.PoolCandle, error) {
+	var candles []model.PoolCandle
+	query := ctx.Ctx.DB.Where("pool_address = ? AND resolution = ? AND bucket_start >= ? AND bucket_start <= ?",
+		strings.ToLower(poolAddress), resolution, from, to)
+	if chainId > 0 {
+		query = query.Where("chain_id = ?", chainId)
+	}
+	err := query.Order("bucket_start ASC").Limit(limit).Find(&candles).Error
+	return candles, err
+}
+
 // GetLiquidityStats 获取流动性统计信息
 func (s *LiquidityPoolService) GetLiquidityStats(req *model.LiquidityStatsRequest) (*model.LiquidityStatsResponse, error) {
 	stats := &model.LiquidityStatsResponse{}
@@ -380,6 +322,21 @@ func (s *LiquidityPoolService) GetLiquidityStats(req *model.LiquidityStatsReques
 	}
 	stats.TotalFeesTodayChange = fmt.Sprintf("+$%.2f", feesTodayChange)
 
+	// 累计手续费拆分为 LP 手续费与协议分成，协议收入为已结算给 feeTo 的部分
+	dayTable := model.PoolDayData{}.TableName()
+	end := time.Now().Add(24 * time.Hour)
+	for column, target := range map[string]*string{
+		"lp_fees_usd":          &stats.TotalLPFees,
+		"protocol_fees_usd":    &stats.TotalProtocolFees,
+		"protocol_revenue_usd": &stats.TotalProtocolRevenue,
+	} {
+		total, err := sumPoolFees(dayTable, column, req.ChainId, time.Time{}, end)
+		if err != nil {
+			return nil, err
+		}
+		*target = fmt.Sprintf("$%.2f", total)
+	}
+
 	// 获取活跃池子数量
 	activePoolsCount, err := s.getActivePoolsCount(req.ChainId)
 	if err != nil {
@@ -398,50 +355,133 @@ func (s *LiquidityPoolService) GetLiquidityStats(req *model.LiquidityStatsReques
 }
 
 // calculateMyLiquidityValue 计算我的流动性总价值
+// 按 LP 持仓账本计算：持仓对应的两侧代币数量 = 余额 / 总供应量 * 储备量，按最新价格估值，与 /liquidity/positions 的当前价值一致
 func (s *LiquidityPoolService) calculateMyLiquidityValue(userAddress string, chainId int64) (float64, error) {
-	var totalValue float64
+	return s.lpPositionsValue(userAddress, chainId, nil)
+}
 
-	// 查询用户参与的流动性池事件
-	var userEvents []model.LiquidityPoolEvent
-	query := ctx.Ctx.DB.Where("user_address = ?", userAddress)
-	if chainId > 0 {
-		query = query.Where("chain_id = ?", chainId)
-	}
+// lpPosition 钱包在某个交易对中的 LP 持仓
+type lpPosition struct {
+	ChainId     int64
+	PoolAddress string
+	Balance     string
+}
 
-	err := query.Find(&userEvents).Error
+// lpPositionsValue 计算钱包全部 LP 持仓的 USD 价值，asOf 为空时取当前余额、储备量与价格，否则取该时间点的历史值
+// 两侧代币都未定价的池子不计入
+func (s *LiquidityPoolService) lpPositionsValue(userAddress string, chainId int64, asOf *time.Time) (float64, error) {
+	positions, err := s.userLPPositions(userAddress, chainId, asOf)
 	if err != nil {
 		return 0, err
 	}
 
-	// 计算每个池子的流动性价值
-	poolValues := make(map[string]float64)
-	for _, event := range userEvents {
-		// 获取池子当前价格
+	var totalValue float64
+	for _, position := range positions {
 		var pool model.LiquidityPool
-		err := ctx.Ctx.DB.Where("pool_address = ? AND chain_id = ?", event.PoolAddress, event.ChainId).
-			First(&pool).Error
-		if err != nil {
+		if err := ctx.Ctx.DB.Where("chain_id = ? AND LOWER(pool_address) = ?", position.ChainId, position.PoolAddress).
+			First(&pool).Error; err != nil {
 			continue
 		}
+		supply, err := s.lpTotalSupply(position.ChainId, position.PoolAddress, asOf)
+		if err != nil {
+			return 0, err
+		}
+		reserve0, reserve1 := pool.Reserve0, pool.Reserve1
+		pricedAt := time.Now()
+		if asOf != nil {
+			pricedAt = *asOf
+			if reserve0, reserve1, err = s.reservesAt(position.ChainId, position.PoolAddress, *asOf); err != nil {
+				return 0, err
+			}
+		}
+		prices, err := tokenPricesAt(position.ChainId, []string{pool.Token0Address, pool.Token1Address}, pricedAt)
+		if err != nil {
+			return 0, err
+		}
+		amount0, amount1 := lpShareAmounts(position.Balance, supply, reserve0, reserve1, pool.Token0Decimals, pool.Token1Decimals)
+		totalValue += pairValueUSD(pool, amount0, amount1, prices)
+	}
+	return totalValue, nil
+}
 
-		// 计算用户在该池子的LP代币价值
-		price, _ := new(big.Float).SetString(pool.Price)
-		if price == nil {
-			continue
+// userLPPositions 查询钱包余额大于 0 的 LP 持仓
+func (s *LiquidityPoolService) userLPPositions(userAddress string, chainId int64, asOf *time.Time) ([]lpPosition, error) {
+	var positions []lpPosition
+	wallet := strings.ToLower(userAddress)
+	if asOf == nil {
+		query := ctx.Ctx.DB.Model(&model.LPBalance{}).
+			Select("chain_id, pool_address, balance::text AS balance").
+			Where("wallet_address = ? AND balance > 0", wallet)
+		if chainId > 0 {
+			query = query.Where("chain_id = ?", chainId)
 		}
+		err := query.Scan(&positions).Error
+		return positions, err
+	}
 
-		// 简化计算：假设用户LP代币价值与池子总价值成比例
-		// 实际应该根据用户LP代币数量计算
-		poolValue, _ := price.Float64()
-		poolValues[event.PoolAddress] = poolValue
+	query := ctx.Ctx.DB.Model(&model.LPBalanceChange{}).
+		Select("chain_id, pool_address, SUM(delta)::text AS balance").
+		Where("wallet_address = ? AND block_timestamp <= ?", wallet, *asOf)
+	if chainId > 0 {
+		query = query.Where("chain_id = ?", chainId)
 	}
+	err := query.Group("chain_id, pool_address").Having("SUM(delta) > 0").Scan(&positions).Error
+	return positions, err
+}
 
-	// 累加所有池子的价值
-	for _, value := range poolValues {
-		totalValue += value
+// lpTotalSupply 交易对的 LP 总供应量，即账本中全部钱包余额之和
+func (s *LiquidityPoolService) lpTotalSupply(chainId int64, poolAddress string, asOf *time.Time) (string, error) {
+	var supply []string
+	var err error
+	if asOf == nil {
+		err = ctx.Ctx.DB.Raw(`SELECT COALESCE(SUM(balance), 0)::text FROM lp_balances WHERE chain_id = ? AND pool_address = ?`,
+			chainId, poolAddress).Scan(&supply).Error
+	} else {
+		err = ctx.Ctx.DB.Raw(`SELECT COALESCE(SUM(delta), 0)::text FROM lp_balance_changes WHERE chain_id = ? AND pool_address = ? AND block_timestamp <= ?`,
+			chainId, poolAddress, *asOf).Scan(&supply).Error
+	}
+	if err != nil || len(supply) == 0 {
+		return "0", err
 	}
+	return supply[0], nil
+}
 
-	return totalValue, nil
+// reservesAt 该时间点之前最近一条流动性事件记录的储备量
+func (s *LiquidityPoolService) reservesAt(chainId int64, poolAddress string, asOf time.Time) (string, string, error) {
+	var reserves []struct {
+		Reserve0 string
+		Reserve1 string
+	}
+	err := ctx.Ctx.DB.Model(&model.LiquidityPoolEvent{}).
+		Select("reserve0::text AS reserve0, reserve1::text AS reserve1").
+		Where("chain_id = ? AND LOWER(pool_address) = ? AND block_timestamp <= ? AND reserve1 > 0", chainId, poolAddress, asOf).
+		Order("block_number DESC, log_index DESC NULLS LAST").
+		Limit(1).
+		Scan(&reserves).Error
+	if err != nil || len(reserves) == 0 {
+		return "0", "0", err
+	}
+	return reserves[0].Reserve0, reserves[0].Reserve1, nil
+}
+
+// lpShareAmounts LP 数量对应的两侧代币数量 = 数量 / 总供应量 * 储备量，按各自精度换算；数量为负时结果也为负
+func lpShareAmounts(lpAmount, totalSupply, reserve0, reserve1 string, token0Decimals, token1Decimals int) (float64, float64) {
+	amount, ok1 := new(big.Float).SetString(lpAmount)
+	supply, ok2 := new(big.Float).SetString(totalSupply)
+	if !ok1 || !ok2 || supply.Sign() <= 0 {
+		return 0, 0
+	}
+	share := func(reserve string, decimals int) float64 {
+		r, ok := new(big.Float).SetString(reserve)
+		if !ok {
+			return 0
+		}
+		value := new(big.Float).Quo(new(big.Float).Mul(amount, r), supply)
+		value.Quo(value, new(big.Float).SetFloat64(math.Pow10(decimals)))
+		v, _ := value.Float64()
+		return v
+	}
+	return share(reserve0, token0Decimals), share(reserve1, token1Decimals)
 }
 
 // calculateMyLiquidityPeriodChange 计算我的流动性变化率
@@ -466,77 +506,15 @@ func (s *LiquidityPoolService) calculateMyLiquidityPeriodChange(userAddress stri
 	return (currentValue - previousValue) / previousValue, nil
 }
 
-// calculateTotalFees 计算累计手续费
+// calculateTotalFees 累计手续费（USD），由日汇总求和
 func (s *LiquidityPoolService) calculateTotalFees(chainId int64) (float64, error) {
-	var totalFees float64
-
-	// 查询所有Swap事件，计算手续费
-	// 假设手续费为交易量的0.3%
-	var swapEvents []model.LiquidityPoolEvent
-	query := ctx.Ctx.DB.Where("event_type = ?", "Swap")
-	if chainId > 0 {
-		query = query.Where("chain_id = ?", chainId)
-	}
-
-	err := query.Find(&swapEvents).Error
-	if err != nil {
-		return 0, err
-	}
-
-	for _, event := range swapEvents {
-		// 计算交易量（取较大的输入或输出）
-		amount0In, _ := new(big.Float).SetString(event.Amount0In)
-		amount1In, _ := new(big.Float).SetString(event.Amount1In)
-		amount0Out, _ := new(big.Float).SetString(event.Amount0Out)
-		amount1Out, _ := new(big.Float).SetString(event.Amount1Out)
-
-		var volume *big.Float
-		if amount0In != nil && amount0In.Sign() > 0 {
-			volume = amount0In
-		} else if amount1In != nil && amount1In.Sign() > 0 {
-			volume = amount1In
-		} else if amount0Out != nil && amount0Out.Sign() > 0 {
-			volume = amount0Out
-		} else if amount1Out != nil && amount1Out.Sign() > 0 {
-			volume = amount1Out
-		}
-
-		if volume != nil {
-			// 手续费率为0.3%
-			fee := new(big.Float).Mul(volume, big.NewFloat(0.003))
-			feeFloat, _ := fee.Float64()
-			totalFees += feeFloat
-		}
-	}
-
-	return totalFees, nil
+	return sumPoolFees(model.PoolDayData{}.TableName(), "fees_usd", chainId, time.Time{}, time.Now().Add(24*time.Hour))
 }
 
-// calculateFeesTodayChange 计算今日手续费变化量
+// calculateFeesTodayChange 今日（UTC）手续费（USD）
 func (s *LiquidityPoolService) calculateFeesTodayChange(chainId int64) (float64, error) {
-	// 获取今日手续费
-	var todayFees float64
-	query := ctx.Ctx.DB.Where("event_type = ? AND created_at::date = CURRENT_DATE", "Swap")
-	if chainId > 0 {
-		query = query.Where("chain_id = ?", chainId)
-	}
-
-	var todayEvents []model.LiquidityPoolEvent
-	err := query.Find(&todayEvents).Error
-	if err != nil {
-		return 0, err
-	}
-
-	for _, event := range todayEvents {
-		amount0In, _ := new(big.Float).SetString(event.Amount0In)
-		if amount0In != nil {
-			fee := new(big.Float).Mul(amount0In, big.NewFloat(0.003))
-			feeFloat, _ := fee.Float64()
-			todayFees += feeFloat
-		}
-	}
-
-	return todayFees, nil
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+	return sumPoolFees(model.PoolDayData{}.TableName(), "fees_usd", chainId, today, today.Add(24*time.Hour))
 }
 
 // getActivePoolsCount 获取活跃池子数量
@@ -578,30 +556,7 @@ func (s *LiquidityPoolService) getPeriodStartTime(period string) time.Time {
 	}
 }
 
-// getHistoricalLiquidityValue 获取历史流动性价值
+// getHistoricalLiquidityValue 按 LP 持仓账本计算指定时间点的流动性价值
 func (s *LiquidityPoolService) getHistoricalLiquidityValue(userAddress string, chainId int64, startTime time.Time) (float64, error) {
-	// 简化实现：查询指定时间点之后的用户事件
-	var historicalValue float64
-
-	var userEvents []model.LiquidityPoolEvent
-	query := ctx.Ctx.DB.Where("user_address = ? AND created_at >= ?", userAddress, startTime)
-	if chainId > 0 {
-		query = query.Where("chain_id = ?", chainId)
-	}
-
-	err := query.Find(&userEvents).Error
-	if err != nil {
-		return 0, err
-	}
-
-	// 简化计算：使用事件发生时的价格估算历史价值
-	for _, event := range userEvents {
-		price, _ := new(big.Float).SetString(event.Price)
-		if price != nil {
-			priceFloat, _ := price.Float64()
-			historicalValue += priceFloat
-		}
-	}
-
-	return historicalValue, nil
+	return s.lpPositionsValue(userAddress, chainId, &startTime)
 }
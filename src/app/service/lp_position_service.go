@@ -0,0 +1,342 @@
+package service
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/ctx"
+)
+
+// LPPositionAnalytics 钱包在一个交易对中的持仓分析，金额均为 USD
+// 每次 LP 余额变动按所在交易结束时的储备量与总供应量折算为两侧代币数量，按成交时的代币价格估值：
+// 增加记为存入（铸造或转入），减少记为取出（销毁或转出）
+type LPPositionAnalytics struct {
+	ChainId      int64
+	PoolAddress  string
+	Token0Symbol string
+	Token1Symbol string
+	Balance      string  // 当前 LP 余额
+	Share        float64 // 占 LP 总供应量的比例
+	Amount0      float64 // 当前持仓对应的 token0 数量
+	Amount1      float64
+	ValueUSD     float64 // 当前价值，按最新价格
+	DepositsUSD  float64
+	// 取出的价值，含取出时一并领取的手续费
+	WithdrawalsUSD float64
+	// 按每小时结束时的持仓占比分摊该小时的 LP 手续费（已扣除协议分成）
+	FeesEarnedUSD float64
+	// 持有代币不做市的价值：存入的代币数量减去取出的代币数量，按最新价格；任一代币未定价时为空
+	HodlValueUSD *float64
+	// 无常损失 = 当前价值 - 手续费收益 - 持有价值，为负表示损失；任一代币未定价时为空
+	ImpermanentLossUSD *float64
+	// 净收益 = 当前价值 + 取出 - 存入
+	NetPnLUSD float64
+	FirstAt   time.Time // 第一次持有的时间
+}
+
+// LPPortfolio 钱包全部交易对持仓分析与合计，合计的持有价值与无常损失只计两侧代币均已定价的交易对
+type LPPortfolio struct {
+	Positions          []LPPositionAnalytics
+	ValueUSD           float64
+	DepositsUSD        float64
+	WithdrawalsUSD     float64
+	FeesEarnedUSD      float64
+	HodlValueUSD       float64
+	ImpermanentLossUSD float64
+	NetPnLUSD          float64
+}
+
+// lpFlowRow 钱包的一次 LP 余额变动及所在交易结束时的交易对状态
+type lpFlowRow struct {
+	ChainId        int64
+	PoolAddress    string
+	Delta          string
+	BlockTimestamp time.Time
+	Reserve0       string
+	Reserve1       string
+	Supply         string
+}
+
+// lpFlowsSQL 钱包按区块顺序的 LP 余额变动；储备量与总供应量取同一交易内最后的状态，
+// 首次铸造、先 Swap 后 Mint 的组合交易也能按实际比例折算
+// 总供应量按交易汇总后一次窗口扫描累加，只扫描钱包持有过的交易对
+const lpFlowsSQL = `
+WITH wallet_pools AS (
+	SELECT DISTINCT chain_id, pool_address
+	  FROM lp_balance_changes
+	 WHERE wallet_address = @wallet
+	   AND (@chain_id = 0 OR chain_id = @chain_id)
+), tx_supply AS (
+	SELECT s.chain_id,
+	       s.pool_address,
+	       s.tx_hash,
+	       SUM(SUM(s.delta)) OVER (PARTITION BY s.chain_id, s.pool_address
+	                               ORDER BY MIN(s.block_number), MAX(s.log_index)) AS supply
+	  FROM lp_balance_changes s
+	  JOIN wallet_pools w
+	    ON w.chain_id = s.chain_id
+	   AND w.pool_address = s.pool_address
+	 GROUP BY s.chain_id, s.pool_address, s.tx_hash
+)
+SELECT b.chain_id,
+       b.pool_address,
+       b.delta::text AS delta,
+       b.block_timestamp,
+       COALESCE(r.reserve0, 0)::text AS reserve0,
+       COALESCE(r.reserve1, 0)::text AS reserve1,
+       ts.supply::text AS supply
+  FROM lp_balance_changes b
+  JOIN tx_supply ts
+    ON ts.chain_id = b.chain_id
+   AND ts.pool_address = b.pool_address
+   AND ts.tx_hash = b.tx_hash
+  JOIN liquidity_pools p
+    ON p.chain_id = b.chain_id
+   AND LOWER(p.pool_address) = b.pool_address
+  LEFT JOIN LATERAL (
+       SELECT e.reserve0, e.reserve1
+         FROM liquidity_pool_events e
+        WHERE e.chain_id = b.chain_id
+          AND e.pool_address = p.pool_address
+          AND e.block_number <= b.block_number
+          AND ((e.block_number, e.log_index) < (b.block_number, b.log_index) OR LOWER(e.tx_hash) = b.tx_hash)
+          AND e.reserve0 > 0
+          AND e.reserve1 > 0
+        ORDER BY e.block_number DESC, e.log_index DESC
+        LIMIT 1) r ON TRUE
+ WHERE b.wallet_address = @wallet
+   AND (@chain_id = 0 OR b.chain_id = @chain_id)
+ ORDER BY b.chain_id, b.pool_address, b.block_number, b.log_index`
+
+// positionFeesSQL 钱包在各交易对中分得的 LP 手续费：每小时的 LP 手续费按该小时结束时的持仓占比分摊
+// 一次窗口扫描算出每个有变动的小时结束时的钱包余额与总供应量，该状态持续到下一个有变动的小时
+const positionFeesSQL = `
+WITH hourly AS (
+	SELECT b.chain_id,
+	       b.pool_address,
+	       to_timestamp(floor(extract(epoch FROM b.block_timestamp) / 3600) * 3600) AS period_start,
+	       SUM(b.delta) AS supply_delta,
+	       COALESCE(SUM(b.delta) FILTER (WHERE b.wallet_address = @wallet), 0) AS wallet_delta
+	  FROM lp_balance_changes b
+	 WHERE b.pool_address IN @pools
+	   AND (@chain_id = 0 OR b.chain_id = @chain_id)
+	 GROUP BY 1, 2, 3
+), running AS (
+	SELECT chain_id,
+	       pool_address,
+	       period_start,
+	       LEAD(period_start) OVER w AS next_start,
+	       SUM(supply_delta) OVER w AS supply,
+	       SUM(wallet_delta) OVER w AS balance
+	  FROM hourly
+	WINDOW w AS (PARTITION BY chain_id, pool_address ORDER BY period_start)
+)
+SELECT d.chain_id,
+       d.pool_address,
+       SUM(d.lp_fees_usd * r.balance / r.supply) AS fees_earned_usd
+  FROM running r
+  JOIN pool_hour_data d
+    ON d.chain_id = r.chain_id
+   AND d.pool_address = r.pool_address
+   AND d.period_start >= r.period_start
+   AND (r.next_start IS NULL OR d.period_start < r.next_start)
+ WHERE r.balance > 0
+   AND r.supply > 0
+ GROUP BY d.chain_id, d.pool_address`
+
+// poolAmountRow 按交易对汇总的数量
+type poolAmountRow struct {
+	ChainId     int64
+	PoolAddress string
+	Amount      string
+}
+
+// poolFeesRow 按交易对汇总的钱包手续费收益
+type poolFeesRow struct {
+	ChainId       int64
+	PoolAddress   string
+	FeesEarnedUSD float64
+}
+
+// GetLPPositions 计算钱包在各交易对中的持仓价值、存取、手续费收益、无常损失与净收益，包含已全部取出的交易对
+// chainId 为 0 时不限链，结果按当前价值降序；余额、总供应量与手续费收益对全部交易对各查询一次
+func (s *LiquidityPoolService) GetLPPositions(walletAddress string, chainId int64) (*LPPortfolio, error) {
+	wallet := strings.ToLower(walletAddress)
+	portfolio := &LPPortfolio{Positions: []LPPositionAnalytics{}}
+
+	var flows []lpFlowRow
+	if err := ctx.Ctx.DB.Raw(lpFlowsSQL, map[string]interface{}{
+		"wallet":   wallet,
+		"chain_id": chainId,
+	}).Scan(&flows).Error; err != nil {
+		return nil, err
+	}
+	if len(flows) == 0 {
+		return portfolio, nil
+	}
+
+	byPool := make(map[string][]lpFlowRow)
+	addresses := make(map[int64][]string)
+	var allAddresses []string
+	for _, flow := range flows {
+		key := poolKey(flow.ChainId, flow.PoolAddress)
+		if byPool[key] == nil {
+			addresses[flow.ChainId] = append(addresses[flow.ChainId], flow.PoolAddress)
+			allAddresses = append(allAddresses, flow.PoolAddress)
+		}
+		byPool[key] = append(byPool[key], flow)
+	}
+	params := map[string]interface{}{
+		"wallet":   wallet,
+		"chain_id": chainId,
+		"pools":    allAddresses,
+	}
+
+	var balanceRows, supplyRows []poolAmountRow
+	if err := ctx.Ctx.DB.Raw(`SELECT chain_id, pool_address, balance::text AS amount FROM lp_balances
+		WHERE wallet_address = @wallet AND pool_address IN @pools AND (@chain_id = 0 OR chain_id = @chain_id)`, params).
+		Scan(&balanceRows).Error; err != nil {
+		return nil, err
+	}
+	if err := ctx.Ctx.DB.Raw(`SELECT chain_id, pool_address, SUM(balance)::text AS amount FROM lp_balances
+		WHERE pool_address IN @pools AND (@chain_id = 0 OR chain_id = @chain_id)
+		GROUP BY chain_id, pool_address`, params).
+		Scan(&supplyRows).Error; err != nil {
+		return nil, err
+	}
+	var feeRows []poolFeesRow
+	if err := ctx.Ctx.DB.Raw(positionFeesSQL, params).Scan(&feeRows).Error; err != nil {
+		return nil, err
+	}
+	balances := make(map[string]string, len(balanceRows))
+	for _, row := range balanceRows {
+		balances[poolKey(row.ChainId, row.PoolAddress)] = row.Amount
+	}
+	supplies := make(map[string]string, len(supplyRows))
+	for _, row := range supplyRows {
+		supplies[poolKey(row.ChainId, row.PoolAddress)] = row.Amount
+	}
+	fees := make(map[string]float64, len(feeRows))
+	for _, row := range feeRows {
+		fees[poolKey(row.ChainId, row.PoolAddress)] = row.FeesEarnedUSD
+	}
+
+	now := time.Now()
+	for chainId, poolAddresses := range addresses {
+		var pools []model.LiquidityPool
+		if err := ctx.Ctx.DB.Where("chain_id = ? AND LOWER(pool_address) IN ?", chainId, poolAddresses).
+			Find(&pools).Error; err != nil {
+			return nil, err
+		}
+		tokens := make([]string, 0, 2*len(pools))
+		start := now
+		for _, pool := range pools {
+			tokens = append(tokens, pool.Token0Address, pool.Token1Address)
+			if first := byPool[poolKey(chainId, pool.PoolAddress)][0].BlockTimestamp; first.Before(start) {
+				start = first
+			}
+		}
+		series, err := loadPriceSeries(chainId, tokens, start, now)
+		if err != nil {
+			return nil, err
+		}
+		latest, err := latestTokenPrices(chainId, tokens)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, pool := range pools {
+			key := poolKey(chainId, pool.PoolAddress)
+			portfolio.Positions = append(portfolio.Positions,
+				analyzeLPPosition(pool, byPool[key], balances[key], supplies[key], fees[key], series, latest))
+		}
+	}
+
+	sort.Slice(portfolio.Positions, func(i, j int) bool {
+		return portfolio.Positions[i].ValueUSD > portfolio.Positions[j].ValueUSD
+	})
+	for _, position := range portfolio.Positions {
+		portfolio.ValueUSD += position.ValueUSD
+		portfolio.DepositsUSD += position.DepositsUSD
+		portfolio.WithdrawalsUSD += position.WithdrawalsUSD
+		portfolio.FeesEarnedUSD += position.FeesEarnedUSD
+		portfolio.NetPnLUSD += position.NetPnLUSD
+		if position.HodlValueUSD != nil {
+			portfolio.HodlValueUSD += *position.HodlValueUSD
+			portfolio.ImpermanentLossUSD += *position.ImpermanentLossUSD
+		}
+	}
+	return portfolio, nil
+}
+
+// poolKey 按链与小写交易对地址索引
+func poolKey(chainId int64, poolAddress string) string {
+	return fmt.Sprintf("%d:%s", chainId, strings.ToLower(poolAddress))
+}
+
+// analyzeLPPosition 由钱包在交易对中按时间升序的余额变动、当前余额与总供应量、已分摊的手续费计算持仓分析
+// series 为成交时的价格历史，latest 为最新价格；balance 与 supply 为空时按 0 处理
+func analyzeLPPosition(pool model.LiquidityPool, flows []lpFlowRow, balance, supply string, feesEarned float64,
+	series map[string]priceSeries, latest map[string]float64) LPPositionAnalytics {
+	token0 := strings.ToLower(pool.Token0Address)
+	token1 := strings.ToLower(pool.Token1Address)
+	position := LPPositionAnalytics{
+		ChainId:       pool.ChainId,
+		PoolAddress:   pool.PoolAddress,
+		Token0Symbol:  pool.Token0Symbol,
+		Token1Symbol:  pool.Token1Symbol,
+		Balance:       "0",
+		FeesEarnedUSD: feesEarned,
+	}
+	if len(flows) > 0 {
+		position.FirstAt = flows[0].BlockTimestamp
+	}
+
+	// 存入与取出的代币数量相抵即为不做市时仍持有的数量
+	var held0, held1 float64
+	for _, flow := range flows {
+		amount0, amount1 := lpShareAmounts(flow.Delta, flow.Supply, flow.Reserve0, flow.Reserve1, pool.Token0Decimals, pool.Token1Decimals)
+		held0 += amount0
+		held1 += amount1
+		prices := make(map[string]float64, 2)
+		if price, ok := series[token0].at(flow.BlockTimestamp); ok {
+			prices[token0] = price
+		}
+		if price, ok := series[token1].at(flow.BlockTimestamp); ok {
+			prices[token1] = price
+		}
+		if value := pairValueUSD(pool, amount0, amount1, prices); value >= 0 {
+			position.DepositsUSD += value
+		} else {
+			position.WithdrawalsUSD -= value
+		}
+	}
+
+	if balance != "" {
+		position.Balance = balance
+	}
+	if parseBigInt(position.Balance).Sign() > 0 {
+		if b, ok := new(big.Float).SetString(position.Balance); ok {
+			if total, ok := new(big.Float).SetString(supply); ok && total.Sign() > 0 {
+				position.Share, _ = new(big.Float).Quo(b, total).Float64()
+			}
+		}
+		position.Amount0, position.Amount1 = lpShareAmounts(position.Balance, supply, pool.Reserve0, pool.Reserve1, pool.Token0Decimals, pool.Token1Decimals)
+		position.ValueUSD = pairValueUSD(pool, position.Amount0, position.Amount1, latest)
+	}
+
+	position.NetPnLUSD = position.ValueUSD + position.WithdrawalsUSD - position.DepositsUSD
+	price0, ok0 := latest[token0]
+	price1, ok1 := latest[token1]
+	if ok0 && ok1 {
+		hodl := held0*price0 + held1*price1
+		il := position.ValueUSD - position.FeesEarnedUSD - hodl
+		position.HodlValueUSD = &hodl
+		position.ImpermanentLossUSD = &il
+	}
+	return position
+}
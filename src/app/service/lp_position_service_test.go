@@ -0,0 +1,183 @@
+package service
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/testutil"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	tokenA = "0x00000000000000000000000000000000000000AA"
+	tokenB = "0x00000000000000000000000000000000000000BB"
+	tokenC = "0x00000000000000000000000000000000000000cc"
+)
+
+func TestLPShareAmounts(t *testing.T) {
+	tests := []struct {
+		name                  string
+		lp, supply, r0, r1    string
+		d0, d1                int
+		wantAmount0, wantAmt1 float64
+	}{
+		{"full supply", "100", "100", "5000000000000000000", "7000000", 18, 6, 5, 7},
+		{"quarter share", "25", "100", "4000000000000000000", "8000000", 18, 6, 1, 2},
+		{"large values", "1000000000000000000", "3000000000000000000", "300000000000000000000000", "900000000000", 18, 6, 100000, 300000},
+		{"zero supply", "1", "0", "100", "100", 0, 0, 0, 0},
+		{"empty supply", "1", "", "100", "100", 0, 0, 0, 0},
+		{"invalid amount", "x", "10", "100", "100", 0, 0, 0, 0},
+		{"invalid reserve only zeroes that side", "5", "10", "x", "100", 0, 0, 0, 50},
+		{"negative delta is a withdrawal", "-25", "100", "400", "800", 0, 0, -100, -200},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			amount0, amount1 := lpShareAmounts(tt.lp, tt.supply, tt.r0, tt.r1, tt.d0, tt.d1)
+			if !testutil.ApproxEqual(amount0, tt.wantAmount0) || !testutil.ApproxEqual(amount1, tt.wantAmt1) {
+				t.Errorf("lpShareAmounts() = (%v, %v), want (%v, %v)", amount0, amount1, tt.wantAmount0, tt.wantAmt1)
+			}
+		})
+	}
+}
+
+func TestPairValueUSD(t *testing.T) {
+	pool := model.LiquidityPool{Token0Address: tokenA, Token1Address: tokenB}
+	tests := []struct {
+		name   string
+		prices map[string]float64
+		want   float64
+	}{
+		{"both priced", map[string]float64{"0x00000000000000000000000000000000000000aa": 2, "0x00000000000000000000000000000000000000bb": 3}, 2*10 + 3*20},
+		{"only token0 priced doubles its side", map[string]float64{"0x00000000000000000000000000000000000000aa": 2}, 2 * 2 * 10},
+		{"only token1 priced doubles its side", map[string]float64{"0x00000000000000000000000000000000000000bb": 3}, 2 * 3 * 20},
+		{"neither priced", map[string]float64{tokenC: 1}, 0},
+		{"lookup is case sensitive on lowercase keys", map[string]float64{tokenA: 2, tokenB: 3}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := pairValueUSD(pool, 10, 20, tt.prices); !testutil.ApproxEqual(got, tt.want) {
+				t.Errorf("pairValueUSD() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeLPPosition(t *testing.T) {
+	a := "0x00000000000000000000000000000000000000aa"
+	b := "0x00000000000000000000000000000000000000bb"
+	t0 := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	t1 := t0.Add(24 * time.Hour)
+
+	// A 价格由 1 涨到 4，B 恒为 1；池子按恒定乘积从 100A/100B 变为 50A/200B
+	pool := model.LiquidityPool{
+		ChainId:        1,
+		PoolAddress:    "0xPOOL",
+		Token0Address:  tokenA,
+		Token1Address:  tokenB,
+		Token0Decimals: 18,
+		Token1Decimals: 6,
+		Reserve0:       "50000000000000000000",
+		Reserve1:       "200000000",
+	}
+	series := map[string]priceSeries{
+		a: {
+			{PriceUSD: decimal.NewFromInt(1), ComputedAt: t0},
+			{PriceUSD: decimal.NewFromInt(4), ComputedAt: t1},
+		},
+		b: {
+			{PriceUSD: decimal.NewFromInt(1), ComputedAt: t0},
+		},
+	}
+	deposit := lpFlowRow{Delta: "100", Supply: "100", Reserve0: "100000000000000000000", Reserve1: "100000000", BlockTimestamp: t0}
+	withdraw := lpFlowRow{Delta: "-40", Supply: "100", Reserve0: "50000000000000000000", Reserve1: "200000000", BlockTimestamp: t1}
+	latest := map[string]float64{a: 4, b: 1}
+
+	ptr := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name            string
+		flows           []lpFlowRow
+		balance, supply string
+		fees            float64
+		latest          map[string]float64
+		want            LPPositionAnalytics
+	}{
+		{
+			name:    "held through price move",
+			flows:   []lpFlowRow{deposit},
+			balance: "100", supply: "100", fees: 10, latest: latest,
+			want: LPPositionAnalytics{
+				Balance: "100", Share: 1, Amount0: 50, Amount1: 200,
+				ValueUSD: 400, DepositsUSD: 200, FeesEarnedUSD: 10,
+				HodlValueUSD: ptr(500), ImpermanentLossUSD: ptr(-110), NetPnLUSD: 200,
+			},
+		},
+		{
+			name:    "partial withdrawal priced at withdrawal time",
+			flows:   []lpFlowRow{deposit, withdraw},
+			balance: "60", supply: "100", latest: latest,
+			want: LPPositionAnalytics{
+				Balance: "60", Share: 0.6, Amount0: 30, Amount1: 120,
+				ValueUSD: 240, DepositsUSD: 200, WithdrawalsUSD: 160,
+				HodlValueUSD: ptr(340), ImpermanentLossUSD: ptr(-100), NetPnLUSD: 200,
+			},
+		},
+		{
+			name:    "fully withdrawn position has no balance row",
+			flows:   []lpFlowRow{deposit, {Delta: "-100", Supply: "100", Reserve0: "50000000000000000000", Reserve1: "200000000", BlockTimestamp: t1}},
+			balance: "", supply: "0", latest: latest,
+			want: LPPositionAnalytics{
+				Balance: "0", DepositsUSD: 200, WithdrawalsUSD: 400,
+				HodlValueUSD: ptr(100), ImpermanentLossUSD: ptr(-100), NetPnLUSD: 200,
+			},
+		},
+		{
+			name:    "one side unpriced skips impermanent loss",
+			flows:   []lpFlowRow{deposit},
+			balance: "100", supply: "100", latest: map[string]float64{a: 4},
+			want: LPPositionAnalytics{
+				Balance: "100", Share: 1, Amount0: 50, Amount1: 200,
+				ValueUSD: 400, DepositsUSD: 200, NetPnLUSD: 200,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := analyzeLPPosition(pool, tt.flows, tt.balance, tt.supply, tt.fees, series, tt.latest)
+			if got.Balance != tt.want.Balance || !got.FirstAt.Equal(t0) || got.PoolAddress != pool.PoolAddress {
+				t.Errorf("balance/firstAt/pool = %s/%v/%s", got.Balance, got.FirstAt, got.PoolAddress)
+			}
+			checks := []struct {
+				field     string
+				got, want float64
+			}{
+				{"Share", got.Share, tt.want.Share},
+				{"Amount0", got.Amount0, tt.want.Amount0},
+				{"Amount1", got.Amount1, tt.want.Amount1},
+				{"ValueUSD", got.ValueUSD, tt.want.ValueUSD},
+				{"DepositsUSD", got.DepositsUSD, tt.want.DepositsUSD},
+				{"WithdrawalsUSD", got.WithdrawalsUSD, tt.want.WithdrawalsUSD},
+				{"FeesEarnedUSD", got.FeesEarnedUSD, tt.want.FeesEarnedUSD},
+				{"NetPnLUSD", got.NetPnLUSD, tt.want.NetPnLUSD},
+			}
+			for _, c := range checks {
+				if !testutil.ApproxEqual(c.got, c.want) {
+					t.Errorf("%s = %v, want %v", c.field, c.got, c.want)
+				}
+			}
+			if (got.HodlValueUSD == nil) != (tt.want.HodlValueUSD == nil) ||
+				(got.ImpermanentLossUSD == nil) != (tt.want.ImpermanentLossUSD == nil) {
+				t.Fatalf("HodlValueUSD/ImpermanentLossUSD presence = %v/%v, want %v/%v",
+					got.HodlValueUSD != nil, got.ImpermanentLossUSD != nil, tt.want.HodlValueUSD != nil, tt.want.ImpermanentLossUSD != nil)
+			}
+			if tt.want.HodlValueUSD != nil {
+				if !testutil.ApproxEqual(*got.HodlValueUSD, *tt.want.HodlValueUSD) {
+					t.Errorf("HodlValueUSD = %v, want %v", *got.HodlValueUSD, *tt.want.HodlValueUSD)
+				}
+				if !testutil.ApproxEqual(*got.ImpermanentLossUSD, *tt.want.ImpermanentLossUSD) {
+					t.Errorf("ImpermanentLossUSD = %v, want %v", *got.ImpermanentLossUSD, *tt.want.ImpermanentLossUSD)
+				}
+			}
+		})
+	}
+}
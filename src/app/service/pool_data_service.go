@@ -0,0 +1,169 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/ctx"
+)
+
+// PoolActivity 交易对近期的交易量、手续费与 APY，由小时汇总求和
+// 手续费合计拆分为归属 LP 的部分与协议分成，7 天对比与 APY 只计 LP 手续费
+type PoolActivity struct {
+	FeeRate               float64 // 交易对生效的手续费率
+	VolumeUSD24h          float64
+	FeesUSD24h            float64
+	LPFeesUSD24h          float64
+	ProtocolFeesUSD24h    float64
+	ProtocolRevenueUSD24h float64 // 已结算给 feeTo 的协议收入
+	LPFeesUSDLast7d       float64 // 最近 7 天
+	LPFeesUSDPrev7d       float64 // 再往前 7 天
+	APY                   string
+}
+
+// PoolDataResolutions 交易对汇总周期及其秒数
+var PoolDataResolutions = map[string]int64{
+	"1h": 3600,
+	"1d": 86400,
+}
+
+// poolActivityRow 按交易对聚合的小时汇总
+type poolActivityRow struct {
+	ChainId               int64
+	PoolAddress           string
+	VolumeUSD24h          float64
+	FeesUSD24h            float64
+	LPFeesUSD24h          float64
+	ProtocolFeesUSD24h    float64
+	ProtocolRevenueUSD24h float64
+	LPFeesUSDLast7d       float64
+	LPFeesUSDPrev7d       float64
+}
+
+// PoolActivityStats 批量计算交易对近期的交易量、手续费与 APY，结果按池子 id 索引
+// “最近 24 小时”为包含当前小时在内的 24 个小时汇总，7 天同理；APY 由 24h 手续费与按最新价格估算的 TVL 年化
+func (s *LiquidityPoolService) PoolActivityStats(pools []model.LiquidityPool) (map[int64]PoolActivity, error) {
+	stats := make(map[int64]PoolActivity, len(pools))
+	if len(pools) == 0 {
+		return stats, nil
+	}
+	addresses := make([]string, 0, len(pools))
+	tokens := make(map[int64][]string)
+	for _, pool := range pools {
+		addresses = append(addresses, strings.ToLower(pool.PoolAddress))
+		tokens[pool.ChainId] = append(tokens[pool.ChainId], pool.Token0Address, pool.Token1Address)
+	}
+
+	currentHour := time.Now().UTC().Truncate(time.Hour)
+	dayStart := currentHour.Add(-23 * time.Hour)
+	weekStart := currentHour.Add(-(7*24 - 1) * time.Hour)
+	prevWeekStart := weekStart.Add(-7 * 24 * time.Hour)
+
+	var rows []poolActivityRow
+	if err := ctx.Ctx.DB.Raw(`SELECT chain_id, pool_address,
+			COALESCE(SUM(volume_usd) FILTER (WHERE period_start >= @day_start), 0) AS volume_usd24h,
+			COALESCE(SUM(fees_usd) FILTER (WHERE period_start >= @day_start), 0) AS fees_usd24h,
+			COALESCE(SUM(lp_fees_usd) FILTER (WHERE period_start >= @day_start), 0) AS lp_fees_usd24h,
+			COALESCE(SUM(protocol_fees_usd) FILTER (WHERE period_start >= @day_start), 0) AS protocol_fees_usd24h,
+			COALESCE(SUM(protocol_revenue_usd) FILTER (WHERE period_start >= @day_start), 0) AS protocol_revenue_usd24h,
+			COALESCE(SUM(lp_fees_usd) FILTER (WHERE period_start >= @week_start), 0) AS lp_fees_usd_last7d,
+			COALESCE(SUM(lp_fees_usd) FILTER (WHERE period_start < @week_start), 0) AS lp_fees_usd_prev7d
+		FROM pool_hour_data
+		WHERE pool_address IN @pools AND period_start >= @prev_week_start
+		GROUP BY chain_id, pool_address`, map[string]interface{}{
+		"pools":           addresses,
+		"day_start":       dayStart,
+		"week_start":      weekStart,
+		"prev_week_start": prevWeekStart,
+	}).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	byPool := make(map[string]poolActivityRow, len(rows))
+	for _, row := range rows {
+		byPool[fmt.Sprintf("%d:%s", row.ChainId, row.PoolAddress)] = row
+	}
+
+	feeRates, err := poolFeeRates(pools)
+	if err != nil {
+		return nil, err
+	}
+
+	prices := make(map[int64]map[string]float64, len(tokens))
+	for chainId, chainTokens := range tokens {
+		chainPrices, err := latestTokenPrices(chainId, chainTokens)
+		if err != nil {
+			return nil, err
+		}
+		prices[chainId] = chainPrices
+	}
+
+	for _, pool := range pools {
+		row := byPool[fmt.Sprintf("%d:%s", pool.ChainId, strings.ToLower(pool.PoolAddress))]
+		stats[pool.Id] = PoolActivity{
+			FeeRate:               feeRates[pool.Id],
+			VolumeUSD24h:          row.VolumeUSD24h,
+			FeesUSD24h:            row.FeesUSD24h,
+			LPFeesUSD24h:          row.LPFeesUSD24h,
+			ProtocolFeesUSD24h:    row.ProtocolFeesUSD24h,
+			ProtocolRevenueUSD24h: row.ProtocolRevenueUSD24h,
+			LPFeesUSDLast7d:       row.LPFeesUSDLast7d,
+			LPFeesUSDPrev7d:       row.LPFeesUSDPrev7d,
+			APY:                   formatAPY(row.LPFeesUSD24h, poolTVLUSD(pool, prices[pool.ChainId])),
+		}
+	}
+	return stats, nil
+}
+
+// poolFeeRates 交易对生效的手续费率：交易对配置、工厂配置、DefaultFeeRate 中第一个非空的，按池子 id 索引
+func poolFeeRates(pools []model.LiquidityPool) (map[int64]float64, error) {
+	ids := make([]int64, 0, len(pools))
+	for _, pool := range pools {
+		ids = append(ids, pool.Id)
+	}
+	var rows []struct {
+		Id      int64
+		FeeRate float64
+	}
+	if err := ctx.Ctx.DB.Raw(`SELECT p.id, COALESCE(p.fee_rate, f.fee_rate, ?) AS fee_rate
+		FROM liquidity_pools p
+		LEFT JOIN dex_factories f ON f.chain_id = p.chain_id AND f.factory_address = LOWER(p.factory_address)
+		WHERE p.id IN ?`, DefaultFeeRate, ids).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	rates := make(map[int64]float64, len(rows))
+	for _, row := range rows {
+		rates[row.Id] = row.FeeRate
+	}
+	return rates, nil
+}
+
+// sumPoolFees 汇总表中 period_start 在 [start, end) 内某个金额列的合计，column 为 fees_usd/lp_fees_usd 等列名，chainId 为 0 时不限链
+func sumPoolFees(table, column string, chainId int64, start, end time.Time) (float64, error) {
+	var total float64
+	query := ctx.Ctx.DB.Table(table).
+		Select(fmt.Sprintf("COALESCE(SUM(%s), 0)", column)).
+		Where("period_start >= ? AND period_start < ?", start, end)
+	if chainId > 0 {
+		query = query.Where("chain_id = ?", chainId)
+	}
+	err := query.Row().Scan(&total)
+	return total, err
+}
+
+// GetPoolHistory 按时间升序返回交易对在 [from, to] 内的小时或日汇总，chainId 为 0 时不限链
+func (s *LiquidityPoolService) GetPoolHistory(chainId int64, poolAddress, resolution string, from, to time.Time, limit int) ([]model.PoolPeriodData, error) {
+	table := model.PoolHourData{}.TableName()
+	if resolution == "1d" {
+		table = model.PoolDayData{}.TableName()
+	}
+	var rows []model.PoolPeriodData
+	query := ctx.Ctx.DB.Table(table).
+		Where("pool_address = ? AND period_start >= ? AND period_start <= ?", strings.ToLower(poolAddress), from, to)
+	if chainId > 0 {
+		query = query.Where("chain_id = ?", chainId)
+	}
+	err := query.Order("period_start ASC").Limit(limit).Find(&rows).Error
+	return rows, err
+}
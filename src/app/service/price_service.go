@@ -0,0 +1,117 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/ctx"
+)
+
+// priceSeries 一个代币按时间升序的 USD 价格历史
+type priceSeries []model.TokenPrice
+
+// at 该时间点之前最近一次定价的价格；早于第一条记录时取第一条（定价任务启动前的成交按最早价格估值）
+func (s priceSeries) at(t time.Time) (float64, bool) {
+	if len(s) == 0 {
+		return 0, false
+	}
+	i := sort.Search(len(s), func(i int) bool { return s[i].ComputedAt.After(t) })
+	if i > 0 {
+		i--
+	}
+	return s[i].PriceUSD.InexactFloat64(), true
+}
+
+// loadPriceSeries 加载代币在 [start, end] 内的价格历史，并附带 start 之前最近的一条
+func loadPriceSeries(chainId int64, tokens []string, start, end time.Time) (map[string]priceSeries, error) {
+	tokens = lowerAddresses(tokens)
+	var before []model.TokenPrice
+	if err := ctx.Ctx.DB.Raw(`SELECT DISTINCT ON (token_address) * FROM token_prices
+		WHERE chain_id = ? AND token_address IN ? AND computed_at <= ?
+		ORDER BY token_address, computed_at DESC`, chainId, tokens, start).Scan(&before).Error; err != nil {
+		return nil, err
+	}
+	var within []model.TokenPrice
+	if err := ctx.Ctx.DB.Where("chain_id = ? AND token_address IN ? AND computed_at > ? AND computed_at <= ?",
+		chainId, tokens, start, end).
+		Order("computed_at ASC").
+		Find(&within).Error; err != nil {
+		return nil, err
+	}
+
+	series := make(map[string]priceSeries, len(tokens))
+	for _, price := range before {
+		series[price.TokenAddress] = append(series[price.TokenAddress], price)
+	}
+	for _, price := range within {
+		series[price.TokenAddress] = append(series[price.TokenAddress], price)
+	}
+	return series, nil
+}
+
+// latestTokenPrices 代币最新的 USD 价格，未定价的代币不在结果中
+func latestTokenPrices(chainId int64, tokens []string) (map[string]float64, error) {
+	var latest []model.TokenPrice
+	if err := ctx.Ctx.DB.Raw(`SELECT DISTINCT ON (token_address) * FROM token_prices
+		WHERE chain_id = ? AND token_address IN ?
+		ORDER BY token_address, computed_at DESC`, chainId, lowerAddresses(tokens)).Scan(&latest).Error; err != nil {
+		return nil, err
+	}
+	prices := make(map[string]float64, len(latest))
+	for _, price := range latest {
+		prices[price.TokenAddress] = price.PriceUSD.InexactFloat64()
+	}
+	return prices, nil
+}
+
+// tokenPricesAt 代币在该时间点的 USD 价格，未定价的代币不在结果中
+func tokenPricesAt(chainId int64, tokens []string, t time.Time) (map[string]float64, error) {
+	series, err := loadPriceSeries(chainId, tokens, t, t)
+	if err != nil {
+		return nil, err
+	}
+	prices := make(map[string]float64, len(series))
+	for token, s := range series {
+		if price, ok := s.at(t); ok {
+			prices[token] = price
+		}
+	}
+	return prices, nil
+}
+
+// poolTVLUSD 按代币价格估算池子 TVL
+func poolTVLUSD(pool model.LiquidityPool, prices map[string]float64) float64 {
+	return pairValueUSD(pool,
+		toFloatWithDecimals(parseBigInt(pool.Reserve0), pool.Token0Decimals),
+		toFloatWithDecimals(parseBigInt(pool.Reserve1), pool.Token1Decimals),
+		prices)
+}
+
+// pairValueUSD 按池子储备比例持有的两侧代币数量的 USD 价值：两侧都已定价时相加，只有一侧定价时取该侧的两倍
+func pairValueUSD(pool model.LiquidityPool, amount0, amount1 float64, prices map[string]float64) float64 {
+	price0, ok0 := prices[strings.ToLower(pool.Token0Address)]
+	price1, ok1 := prices[strings.ToLower(pool.Token1Address)]
+	value0 := amount0 * price0
+	value1 := amount1 * price1
+	switch {
+	case ok0 && ok1:
+		return value0 + value1
+	case ok0:
+		return 2 * value0
+	case ok1:
+		return 2 * value1
+	default:
+		return 0
+	}
+}
+
+// lowerAddresses 地址转为小写，价格表中的地址均为小写
+func lowerAddresses(addresses []string) []string {
+	lowered := make([]string, len(addresses))
+	for i, address := range addresses {
+		lowered[i] = strings.ToLower(address)
+	}
+	return lowered
+}
@@ -5,6 +5,7 @@ import (
 	"crypto/ecdsa"
 	"fmt"
 	"math/big"
+	"strings"
 	"time"
 
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
@@ -22,6 +23,9 @@ import (
 	"gorm.io/gorm"
 )
 
+// stakeTokenDecimals 质押代币精度，链上金额以最小单位存储，接口按此精度换算
+const stakeTokenDecimals = 18
+
 type StakeService struct {
 	// 私钥用于交易签名
 	privateKey string
@@ -40,7 +44,7 @@ func NewStakeService() *StakeService {
 }
 
 // ProcessStake 处理质押逻辑
-func (s *StakeService) ProcessStake(userAddress string, chainId int64, amount float64, token string, poolId int64) (*model.StakeRecord, error) {
+func (s *StakeService) ProcessStake(userAddress string, chainId int64, amount decimal.Decimal, token string, poolId int64) (*model.StakeRecord, error) {
 	// 1. 验证用户地址和代币有效性
 	if !common.IsHexAddress(userAddress) {
 		return nil, fmt.Errorf("无效的用户地址: %s", userAddress)
@@ -58,8 +62,8 @@ func (s *StakeService) ProcessStake(userAddress string, chainId int64, amount fl
 		return nil, fmt.Errorf("检查余额失败: %v", err)
 	}
 
-	// 将amount转换为最小单位（假设18位小数）
-	amountInWei := decimal.NewFromFloat(amount).Mul(decimal.New(1, 18)).BigInt()
+	// 将amount转换为最小单位，超出代币精度的部分截断
+	amountInWei := amount.Shift(stakeTokenDecimals).Truncate(0).BigInt()
 	if balance.Cmp(amountInWei) < 0 {
 		return nil, fmt.Errorf("余额不足，当前余额: %s, 需要: %s", balance.String(), amountInWei.String())
 	}
@@ -169,7 +173,7 @@ func (s *StakeService) ProcessStake(userAddress string, chainId int64, amount fl
 		ChainId:       chainId,
 		Address:       userAddress,
 		PoolId:        poolId,
-		Amount:        amountInWei.Int64(), // 存储为最小单位
+		Amount:        decimal.NewFromBigInt(amountInWei, 0), // 存储为最小单位
 		OperationTime: time.Now(),
 		UnlockTime:    time.Now().Add(7 * 24 * time.Hour), // 示例：7天锁定期
 		TxHash:        tx.Hash().Hex(),
@@ -263,7 +267,7 @@ func (s *StakeService) ProcessWithdraw(userAddress string, chainId int64, stakeI
 
 	// 9. 调用质押合约进行提取
 	poolIdBig := big.NewInt(poolId)
-	amountBig := big.NewInt(operationRecord.Amount)
+	amountBig := operationRecord.Amount.BigInt()
 	tx, err := stakeContract.Withdraw(auth, poolIdBig, amountBig)
 	if err != nil {
 		return nil, fmt.Errorf("提取失败: %v", err)
@@ -291,7 +295,7 @@ func (s *StakeService) ProcessWithdraw(userAddress string, chainId int64, stakeI
 		ID:          stakeId,
 		UserAddress: userAddress,
 		ChainId:     chainId,
-		Amount:      float64(operationRecord.Amount) / 1e18, // 转换回浮点数（假设18位小数）
+		Amount:      operationRecord.Amount.Shift(-stakeTokenDecimals),
 		Token:       operationRecord.TokenAddress,
 		Status:      "withdrawn",
 		CreatedAt:   operationRecord.OperationTime,
@@ -340,7 +344,7 @@ func (s *StakeService) GetStakeRecords(userAddress string, chainId int64, pagina
 			ID:          record.Id,
 			UserAddress: record.Address,
 			ChainId:     record.ChainId,
-			Amount:      float64(record.Amount) / 1e18, // 假设18位小数
+			Amount:      record.Amount.Shift(-stakeTokenDecimals),
 			Token:       record.TokenAddress,
 			Status:      status,
 			CreatedAt:   record.OperationTime,
@@ -354,7 +358,7 @@ func (s *StakeService) GetStakeRecords(userAddress string, chainId int64, pagina
 
 // GetStakeOverview 获取质押概览
 func (s *StakeService) GetStakeOverview(userAddress string, chainId int64) (*model.StakeOverview, error) {
-	var totalStaked int64
+	var totalStaked decimal.Decimal
 	var activeStakes int64
 
 	// 构建查询条件
@@ -368,11 +372,6 @@ func (s *StakeService) GetStakeOverview(userAddress string, chainId int64) (*mod
 	if err := query.Select("COALESCE(SUM(amount), 0)").Scan(&totalStaked).Error; err != nil {
 		return nil, fmt.Errorf("计算总质押量失败: %v", err)
 	}
-	// 将字符串结果转换为int64
-	//totalStaked, err := strconv.ParseInt(totalStakedStr, 10, 64)
-	//if err != nil {
-	//	return nil, fmt.Errorf("转换总质押量失败: %v", err)
-	//}
 	// 计算活跃质押数量（未提取的质押记录）
 	activeQuery := ctx.Ctx.DB.Model(&model.UserOperationRecord{}).Where("address = ? AND event_type = ?", userAddress, "Staked")
 	if chainId > 0 {
@@ -401,7 +400,7 @@ func (s *StakeService) GetStakeOverview(userAddress string, chainId int64) (*mod
 	}
 
 	overview := &model.StakeOverview{
-		TotalStaked:  float64(totalStaked) / 1e18, // 假设18位小数
+		TotalStaked:  totalStaked.Shift(-stakeTokenDecimals),
 		TotalRewards: totalRewards,
 		ActiveStakes: int(activeStakes),
 		UserAddress:  userAddress,
@@ -412,7 +411,7 @@ func (s *StakeService) GetStakeOverview(userAddress string, chainId int64) (*mod
 }
 
 // updateUserScore 更新用户积分
-func (s *StakeService) updateUserScore(userAddress string, chainId int64, token string, amount float64) {
+func (s *StakeService) updateUserScore(userAddress string, chainId int64, token string, amount decimal.Decimal) {
 	// 1. 获取积分规则
 	var scoreRule model.ScoreRules
 	if err := ctx.Ctx.DB.Where("chain_id = ? AND token_address = ?", chainId, token).First(&scoreRule).Error; err != nil {
@@ -427,8 +426,8 @@ func (s *StakeService) updateUserScore(userAddress string, chainId int64, token
 	}
 
 	// 2. 计算应得积分
-	amountDecimal := decimal.NewFromFloat(amount)
-	score := amountDecimal.Mul(scoreRule.Score)
+	score := amount.Mul(scoreRule.Score)
+	amountInWei := amount.Shift(stakeTokenDecimals).Truncate(0)
 
 	// 3. 更新用户积分信息
 	var user model.Users
@@ -439,8 +438,8 @@ func (s *StakeService) updateUserScore(userAddress string, chainId int64, token
 				ChainId:      chainId,
 				Address:      userAddress,
 				TokenAddress: token,
-				TotalAmount:  int64(amount * 1e18), // 假设18位小数
-				JfAmount:     score.IntPart(),
+				TotalAmount:  amountInWei,
+				JfAmount:     score.Truncate(0),
 				Jf:           score,
 				JfTime:       time.Now(),
 			}
@@ -454,8 +453,8 @@ func (s *StakeService) updateUserScore(userAddress string, chainId int64, token
 		}
 	} else {
 		// 更新现有用户记录
-		user.TotalAmount += int64(amount * 1e18) // 假设18位小数
-		user.JfAmount += score.IntPart()
+		user.TotalAmount = user.TotalAmount.Add(amountInWei)
+		user.JfAmount = user.JfAmount.Add(score.Truncate(0))
 		user.Jf = user.Jf.Add(score)
 		user.JfTime = time.Now()
 
@@ -469,22 +468,19 @@ func (s *StakeService) updateUserScore(userAddress string, chainId int64, token
 }
 
 // getUserRewards 获取用户收益（从积分信息计算）
-func (s *StakeService) getUserRewards(userAddress string, chainId int64) (float64, error) {
+func (s *StakeService) getUserRewards(userAddress string, chainId int64) (decimal.Decimal, error) {
 	var user model.Users
 	if err := ctx.Ctx.DB.Where("chain_id = ? AND address = ?", chainId, userAddress).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			return 0, nil // 用户不存在，返回0收益
+			return decimal.Zero, nil // 用户不存在，返回0收益
 		}
-		return 0, fmt.Errorf("查询用户信息失败: %v", err)
+		return decimal.Zero, fmt.Errorf("查询用户信息失败: %v", err)
 	}
 
 	// 将积分转换为收益（这里需要根据实际业务规则）
 	// 示例：1积分 = 0.01代币
 	rewardRate := decimal.NewFromFloat(0.01)
-	totalRewards := user.Jf.Mul(rewardRate)
-
-	reward, _ := totalRewards.Float64()
-	return reward, nil
+	return user.Jf.Mul(rewardRate), nil
 }
 
 // checkTokenBalance 检查代币余额
@@ -504,3 +500,91 @@ func (s *StakeService) checkTokenBalance(client *ethclient.Client, userAddress,
 
 	return balance, nil
 }
+
+// StakingPoolView 质押池及所属合约的当前状态
+type StakingPoolView struct {
+	model.StakingPool
+	Paused     bool    `json:"paused"`
+	SharePrice *string `json:"sharePrice"`
+}
+
+// GetStakingPools 获取链上已创建的质押池，附带所属合约的暂停状态与最新份额价格
+func (s *StakeService) GetStakingPools(chainId int64) ([]StakingPoolView, []model.StakingContractState, error) {
+	var pools []model.StakingPool
+	query := ctx.Ctx.DB.Model(&model.StakingPool{})
+	if chainId > 0 {
+		query = query.Where("chain_id = ?", chainId)
+	}
+	if err := query.Order("chain_id ASC, contract_address ASC, pool_id ASC").Find(&pools).Error; err != nil {
+		return nil, nil, fmt.Errorf("查询质押池失败: %v", err)
+	}
+
+	var states []model.StakingContractState
+	stateQuery := ctx.Ctx.DB.Model(&model.StakingContractState{})
+	if chainId > 0 {
+		stateQuery = stateQuery.Where("chain_id = ?", chainId)
+	}
+	if err := stateQuery.Find(&states).Error; err != nil {
+		return nil, nil, fmt.Errorf("查询质押合约状态失败: %v", err)
+	}
+
+	stateByContract := make(map[string]model.StakingContractState, len(states))
+	for _, state := range states {
+		stateByContract[fmt.Sprintf("%d:%s", state.ChainId, state.ContractAddress)] = state
+	}
+	views := make([]StakingPoolView, 0, len(pools))
+	for _, pool := range pools {
+		view := StakingPoolView{StakingPool: pool}
+		if state, ok := stateByContract[fmt.Sprintf("%d:%s", pool.ChainId, pool.ContractAddress)]; ok {
+			view.Paused = state.Paused
+			view.SharePrice = state.SharePrice
+		}
+		views = append(views, view)
+	}
+	return views, states, nil
+}
+
+// GetRewardClaims 分页获取用户的质押收益领取记录
+func (s *StakeService) GetRewardClaims(userAddress string, chainId int64, pagination dto.Pagination) ([]model.StakingRewardClaim, int64, error) {
+	var claims []model.StakingRewardClaim
+	var total int64
+
+	query := ctx.Ctx.DB.Model(&model.StakingRewardClaim{}).Where("user_address = ?", strings.ToLower(userAddress))
+	if chainId > 0 {
+		query = query.Where("chain_id = ?", chainId)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询记录总数失败: %v", err)
+	}
+	if err := query.Order("block_number DESC, log_index DESC").
+		Offset(pagination.Offset).
+		Limit(pagination.PageSize).
+		Find(&claims).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询收益领取记录失败: %v", err)
+	}
+	return claims, total, nil
+}
+
+// GetSharePriceHistory 分页获取份额价格历史，contractAddress 为空时返回该链全部质押合约
+func (s *StakeService) GetSharePriceHistory(chainId int64, contractAddress string, pagination dto.Pagination) ([]model.StakingSharePrice, int64, error) {
+	var prices []model.StakingSharePrice
+	var total int64
+
+	query := ctx.Ctx.DB.Model(&model.StakingSharePrice{})
+	if chainId > 0 {
+		query = query.Where("chain_id = ?", chainId)
+	}
+	if contractAddress != "" {
+		query = query.Where("contract_address = ?", strings.ToLower(contractAddress))
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询记录总数失败: %v", err)
+	}
+	if err := query.Order("block_number DESC, log_index DESC").
+		Offset(pagination.Offset).
+		Limit(pagination.PageSize).
+		Find(&prices).Error; err != nil {
+		return nil, 0, fmt.Errorf("查询份额价格失败: %v", err)
+	}
+	return prices, total, nil
+}
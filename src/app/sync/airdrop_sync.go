@@ -1,17 +1,10 @@
 package sync
 
 import (
-	"encoding/hex"
-	"math/big"
 	"strings"
 	"time"
 
-	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
-	appabi "github.com/mumu/cryptoSwap/src/abi"
 	"github.com/mumu/cryptoSwap/src/app/model"
-	"github.com/mumu/cryptoSwap/src/core/ctx"
 	"github.com/mumu/cryptoSwap/src/core/log"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
@@ -24,47 +17,22 @@ type AirdropEvents struct {
 	AirdropActivatedIds      []string
 }
 
-// ParseAirdropEvents 统一解析空投相关事件
-func ParseAirdropEvents(vLog types.Log, chainId int, address string) *AirdropEvents {
-	events := &AirdropEvents{}
-	topic0 := vLog.Topics[0].Hex()
-	// 定义所有空投相关事件的topic hash
-	rewardClaimedTopic := crypto.Keccak256Hash([]byte("RewardClaimed(uint256,address,uint256,uint256,uint256,uint256,uint256)")).Hex()
-	updateTotalRewardTopic := crypto.Keccak256Hash([]byte("UpdateTotalRewardUpdated(uint256,address,uint256,uint256,uint256,uint256)")).Hex()
-	airdropCreatedTopic := crypto.Keccak256Hash([]byte("AirdropCreated(uint256,string,bytes32,uint256,uint256)")).Hex()
-	airdropActivatedTopic := crypto.Keccak256Hash([]byte("AirdropActivated(uint256)")).Hex()
-	//rewardPoolUpdatedTopic := crypto.Keccak256Hash([]byte("RewardPoolUpdated(address,address)")).Hex()
-	switch topic0 {
-	case rewardClaimedTopic:
-		if e := parseRewardClaimedEvent(vLog, chainId); e != nil {
-			events.RewardClaimedEvents = append(events.RewardClaimedEvents, e)
-		}
-	case updateTotalRewardTopic:
-		if e := parseTotalRewardUpdatedEvent(vLog, chainId); e != nil {
-			events.TotalRewardUpdatedEvents = append(events.TotalRewardUpdatedEvents, e)
-		}
-	case airdropCreatedTopic:
-		if info := parseAirdropCreatedEvent(vLog, chainId); info != nil {
-			events.AirdropCreatedEvents = append(events.AirdropCreatedEvents, info)
-		}
-	case airdropActivatedTopic:
-		if id := parseAirdropActivatedEvent(vLog, chainId); id != "" {
-			events.AirdropActivatedIds = append(events.AirdropActivatedIds, id)
-		}
-	default:
-		return nil
+// airdropEvents 返回批次中的空投事件集合，不存在时创建
+func (b *decodedBatch) airdropEvents() *AirdropEvents {
+	if b.AirdropEvents == nil {
+		b.AirdropEvents = &AirdropEvents{}
 	}
-
-	return events
+	return b.AirdropEvents
 }
 
 // SaveAirdropEvents 统一保存空投事件
-func SaveAirdropEvents(events *AirdropEvents, chainId int, targetBlockNum uint64, addresses string) error {
+// 游标由调用方在全部事件保存成功后统一推进
+func SaveAirdropEvents(tx *gorm.DB, events *AirdropEvents, chainId int) error {
 	// 保存空投领取事件
 	if len(events.RewardClaimedEvents) > 0 {
 		log.Logger.Info("解析空投事件成功",
 			zap.Int("reward_claimed_count", len(events.RewardClaimedEvents)))
-		if err := saveAirdropEvents(events.RewardClaimedEvents, chainId, targetBlockNum, addresses); err != nil {
+		if err := saveAirdropEvents(tx, events.RewardClaimedEvents, chainId); err != nil {
 			log.Logger.Error("保存空投领取事件失败", zap.Error(err))
 			return err
 		}
@@ -74,7 +42,7 @@ func SaveAirdropEvents(events *AirdropEvents, chainId int, targetBlockNum uint64
 	if len(events.TotalRewardUpdatedEvents) > 0 {
 		log.Logger.Info("解析总奖励更新事件成功",
 			zap.Int("total_reward_updated_count", len(events.TotalRewardUpdatedEvents)))
-		if err := applyTotalRewardUpdates(events.TotalRewardUpdatedEvents, chainId, targetBlockNum, addresses); err != nil {
+		if err := applyTotalRewardUpdates(tx, events.TotalRewardUpdatedEvents, chainId); err != nil {
 			log.Logger.Error("应用总奖励更新事件失败", zap.Error(err))
 			return err
 		}
@@ -85,7 +53,7 @@ func SaveAirdropEvents(events *AirdropEvents, chainId int, targetBlockNum uint64
 		log.Logger.Info("解析空投活动管理事件成功",
 			zap.Int("created_count", len(events.AirdropCreatedEvents)),
 			zap.Int("activated_count", len(events.AirdropActivatedIds)))
-		if err := saveAirdropAdminEvents(events.AirdropCreatedEvents, events.AirdropActivatedIds, chainId, targetBlockNum, addresses); err != nil {
+		if err := saveAirdropAdminEvents(tx, events.AirdropCreatedEvents, events.AirdropActivatedIds, chainId); err != nil {
 			log.Logger.Error("保存空投活动管理事件失败", zap.Error(err))
 			return err
 		}
@@ -94,127 +62,164 @@ func SaveAirdropEvents(events *AirdropEvents, chainId int, targetBlockNum uint64
 	return nil
 }
 
-// parseRewardClaimedEvent 解析 RewardClaimed(uint256,address,uint256,uint256,uint256,uint256,uint256)
-func parseRewardClaimedEvent(vLog types.Log, chainId int) *model.RewardClaimedEvent {
-	if len(vLog.Topics) < 3 || len(vLog.Data) < 160 {
-		return nil
-	}
-
-	// indexed: airdropId, user
-	airdropId := new(big.Int).SetBytes(common.TrimLeftZeroes(vLog.Topics[1].Bytes()))
-	user := common.BytesToAddress(vLog.Topics[2].Bytes()).Hex()
-
-	data := vLog.Data
-	claimAmount := new(big.Int).SetBytes(common.TrimLeftZeroes(data[0:32]))
-	totalReward := new(big.Int).SetBytes(common.TrimLeftZeroes(data[32:64]))
-	claimedReward := new(big.Int).SetBytes(common.TrimLeftZeroes(data[64:96]))
-	pendingReward := new(big.Int).SetBytes(common.TrimLeftZeroes(data[96:128]))
-	ts := new(big.Int).SetBytes(common.TrimLeftZeroes(data[128:160]))
-	eventTime := time.Unix(ts.Int64(), 0)
-
-	return &model.RewardClaimedEvent{
-		ChainId:         int64(chainId),
-		ContractAddress: vLog.Address.Hex(),
-		AirdropId:       airdropId.String(),
-		UserAddress:     user,
-		ClaimAmount:     claimAmount.String(),
-		TotalReward:     totalReward.String(),
-		ClaimedReward:   claimedReward.String(),
-		PendingReward:   pendingReward.String(),
-		EventTimestamp:  eventTime,
-		BlockNumber:     int64(vLog.BlockNumber),
-		TxHash:          vLog.TxHash.Hex(),
-		LogIndex:        int(vLog.Index),
-	}
+// handleRewardClaimed RewardClaimed(uint256 indexed airdropId, address indexed user, uint256 claimAmount, uint256 totalReward, uint256 claimedReward, uint256 pendingReward, uint256 timestamp)
+func handleRewardClaimed(e *decodedEvent, batch *decodedBatch) error {
+	events := batch.airdropEvents()
+	events.RewardClaimedEvents = append(events.RewardClaimedEvents, &model.RewardClaimedEvent{
+		ChainId:         int64(e.ChainId),
+		ContractAddress: e.Log.Address.Hex(),
+		AirdropId:       e.bigInt("airdropId").String(),
+		UserAddress:     e.address("user"),
+		ClaimAmount:     e.bigInt("claimAmount").String(),
+		TotalReward:     e.bigInt("totalReward").String(),
+		ClaimedReward:   e.bigInt("claimedReward").String(),
+		PendingReward:   e.bigInt("pendingReward").String(),
+		EventTimestamp:  time.Unix(e.bigInt("timestamp").Int64(), 0),
+		BlockNumber:     int64(e.Log.BlockNumber),
+		BlockTimestamp:  e.BlockTime,
+		TxHash:          e.Log.TxHash.Hex(),
+		LogIndex:        int(e.Log.Index),
+	})
+	return nil
 }
 
-// parseTotalRewardUpdatedEvent 解析 UpdateTotalRewardUpdated(uint256,address,uint256,uint256,uint256,uint256)
-func parseTotalRewardUpdatedEvent(vLog types.Log, chainId int) *model.TotalRewardUpdatedEvent {
-	if len(vLog.Topics) < 3 || len(vLog.Data) < 128 {
-		return nil
-	}
-
-	airdropId := new(big.Int).SetBytes(common.TrimLeftZeroes(vLog.Topics[1].Bytes()))
-	user := common.BytesToAddress(vLog.Topics[2].Bytes()).Hex()
-
-	data := vLog.Data
-	totalReward := new(big.Int).SetBytes(common.TrimLeftZeroes(data[0:32]))
-	claimedReward := new(big.Int).SetBytes(common.TrimLeftZeroes(data[32:64]))
-	pendingReward := new(big.Int).SetBytes(common.TrimLeftZeroes(data[64:96]))
-	ts := new(big.Int).SetBytes(common.TrimLeftZeroes(data[96:128]))
-	eventTime := time.Unix(ts.Int64(), 0)
-
-	return &model.TotalRewardUpdatedEvent{
-		ChainId:         int64(chainId),
-		ContractAddress: vLog.Address.Hex(),
-		AirdropId:       airdropId.String(),
-		UserAddress:     user,
-		TotalReward:     totalReward.String(),
-		ClaimedReward:   claimedReward.String(),
-		PendingReward:   pendingReward.String(),
-		EventTimestamp:  eventTime,
-		BlockNumber:     int64(vLog.BlockNumber),
-		TxHash:          vLog.TxHash.Hex(),
-		LogIndex:        int(vLog.Index),
-	}
+// handleTotalRewardUpdated UpdateTotalRewardUpdated(uint256 indexed airdropId, address indexed user, uint256 totalReward, uint256 claimedReward, uint256 pendingReward, uint256 timestamp)
+func handleTotalRewardUpdated(e *decodedEvent, batch *decodedBatch) error {
+	events := batch.airdropEvents()
+	events.TotalRewardUpdatedEvents = append(events.TotalRewardUpdatedEvents, &model.TotalRewardUpdatedEvent{
+		ChainId:         int64(e.ChainId),
+		ContractAddress: e.Log.Address.Hex(),
+		AirdropId:       e.bigInt("airdropId").String(),
+		UserAddress:     e.address("user"),
+		TotalReward:     e.bigInt("totalReward").String(),
+		ClaimedReward:   e.bigInt("claimedReward").String(),
+		PendingReward:   e.bigInt("pendingReward").String(),
+		EventTimestamp:  time.Unix(e.bigInt("timestamp").Int64(), 0),
+		BlockNumber:     int64(e.Log.BlockNumber),
+		BlockTimestamp:  e.BlockTime,
+		TxHash:          e.Log.TxHash.Hex(),
+		LogIndex:        int(e.Log.Index),
+	})
+	return nil
 }
 
-// saveAirdropEvents 批量保存空投事件，并更新区块高度
-func saveAirdropEvents(rewardClaimed []*model.RewardClaimedEvent, chainId int, targetBlockNum uint64, addresses string) error {
-	return ctx.Ctx.DB.Transaction(func(tx *gorm.DB) error {
-		// RewardClaimedEvents 去重插入（按精简版 schema，仅插入必要字段）
-		if len(rewardClaimed) > 0 {
-			for _, e := range rewardClaimed {
-				if e == nil {
-					continue
-				}
-				// 保证地址与哈希小写，符合 CHECK 约束
-				contract := strings.ToLower(e.ContractAddress)
-				user := strings.ToLower(e.UserAddress)
-				txHash := strings.ToLower(e.TxHash)
-				if err := tx.Exec(`
+// saveAirdropEvents 批量保存空投事件
+func saveAirdropEvents(tx *gorm.DB, rewardClaimed []*model.RewardClaimedEvent, chainId int) error {
+	// RewardClaimedEvents 去重插入（按精简版 schema，仅插入必要字段）
+	if len(rewardClaimed) > 0 {
+		for _, e := range rewardClaimed {
+			if e == nil {
+				continue
+			}
+			// 保证地址与哈希小写，符合 CHECK 约束
+			contract := strings.ToLower(e.ContractAddress)
+			user := strings.ToLower(e.UserAddress)
+			txHash := strings.ToLower(e.TxHash)
+			if err := tx.Exec(`
                     INSERT INTO reward_claimed_events (
                         chain_id, contract_address, airdrop_id, user_address, claim_amount,
-                        event_timestamp, block_number, tx_hash, log_index
-                    ) VALUES (?, LOWER(?), ?, LOWER(?), ?, ?, ?, LOWER(?), ?)
+                        event_timestamp, block_number, block_timestamp, tx_hash, log_index
+                    ) VALUES (?, LOWER(?), ?, LOWER(?), ?, ?, ?, ?, LOWER(?), ?)
                     ON CONFLICT (tx_hash, log_index) DO NOTHING
-                `, e.ChainId, contract, e.AirdropId, user, e.ClaimAmount, e.EventTimestamp, e.BlockNumber, txHash, e.LogIndex).Error; err != nil {
-					log.Logger.Error("插入 RewardClaimed 事件失败", zap.Error(err))
-					return err
-				}
+                `, e.ChainId, contract, e.AirdropId, user, e.ClaimAmount, e.EventTimestamp, e.BlockNumber, e.BlockTimestamp, txHash, e.LogIndex).Error; err != nil {
+				log.Logger.Error("插入 RewardClaimed 事件失败", zap.Error(err))
+				return err
 			}
 		}
-
-		// 更新链区块高度
-		return updateBlockNumber(chainId, targetBlockNum, addresses)
-	})
+	}
+	return nil
 }
 
 // applyTotalRewardUpdates 使用 UpdateTotalRewardUpdated 事件更新用户白名单总奖励（UPSERT）
-func applyTotalRewardUpdates(totalUpdates []*model.TotalRewardUpdatedEvent, chainId int, targetBlockNum uint64, addresses string) error {
+func applyTotalRewardUpdates(tx *gorm.DB, totalUpdates []*model.TotalRewardUpdatedEvent, chainId int) error {
 	if len(totalUpdates) == 0 {
-		return updateBlockNumber(chainId, targetBlockNum, addresses)
+		return nil
 	}
-	return ctx.Ctx.DB.Transaction(func(tx *gorm.DB) error {
-		for _, e := range totalUpdates {
-			if e == nil {
-				continue
-			}
-			wallet := strings.ToLower(e.UserAddress)
-			txHash := strings.ToLower(e.TxHash)
-			// 以事件中的 total_reward 更新/插入白名单记录
-			if err := tx.Exec(`
+	for _, e := range totalUpdates {
+		if e == nil {
+			continue
+		}
+		wallet := strings.ToLower(e.UserAddress)
+		txHash := strings.ToLower(e.TxHash)
+		// 记录应用前的白名单总奖励，链重组回滚时据此恢复
+		previous, err := previousTotalReward(tx, e)
+		if err != nil {
+			log.Logger.Error("查询白名单总奖励失败", zap.Error(err), zap.String("tx_hash", txHash))
+			return err
+		}
+		e.PreviousTotalReward = previous
+		if err := tx.Exec(`
+                INSERT INTO total_reward_updates (
+                    chain_id, contract_address, airdrop_id, user_address, total_reward, claimed_reward,
+                    pending_reward, previous_total_reward, event_timestamp, block_number, block_timestamp, tx_hash, log_index
+                ) VALUES (?, LOWER(?), ?, LOWER(?), ?, ?, ?, ?, ?, ?, ?, LOWER(?), ?)
+                ON CONFLICT (tx_hash, log_index) DO NOTHING
+            `, e.ChainId, e.ContractAddress, e.AirdropId, wallet, e.TotalReward, e.ClaimedReward,
+			e.PendingReward, e.PreviousTotalReward, e.EventTimestamp, e.BlockNumber, e.BlockTimestamp, txHash, e.LogIndex).Error; err != nil {
+			log.Logger.Error("插入 UpdateTotalRewardUpdated 事件失败", zap.Error(err), zap.String("tx_hash", txHash))
+			return err
+		}
+		// 回填中间区段时，已有更晚的更新事件，白名单保持最新值
+		var later int64
+		if err := tx.Model(&model.TotalRewardUpdatedEvent{}).
+			Where("airdrop_id = ? AND user_address = ? AND (block_number, log_index) > (?, ?)", e.AirdropId, wallet, e.BlockNumber, e.LogIndex).
+			Count(&later).Error; err != nil {
+			log.Logger.Error("查询后续总奖励更新事件失败", zap.Error(err), zap.String("tx_hash", txHash))
+			return err
+		}
+		if later > 0 {
+			continue
+		}
+		// 以事件中的 total_reward 更新/插入白名单记录
+		if err := tx.Exec(`
                 INSERT INTO airdrop_whitelist (airdrop_id, wallet_address, total_reward, proof)
                 VALUES (?, LOWER(?), ?, NULL)
                 ON CONFLICT (airdrop_id, wallet_address) DO UPDATE
                 SET total_reward = EXCLUDED.total_reward
             `, e.AirdropId, wallet, e.TotalReward).Error; err != nil {
-				log.Logger.Error("更新用户白名单总奖励失败", zap.Error(err), zap.String("tx_hash", txHash))
-				return err
-			}
+			log.Logger.Error("更新用户白名单总奖励失败", zap.Error(err), zap.String("tx_hash", txHash))
+			return err
 		}
-		return updateBlockNumber(chainId, targetBlockNum, addresses)
-	})
+	}
+	return nil
+}
+
+// previousTotalReward 事件应用前的白名单总奖励
+// 依次取：同一用户更早的更新事件；更晚事件记录的 previous_total_reward（回填中间区段时）；当前白名单
+func previousTotalReward(tx *gorm.DB, e *model.TotalRewardUpdatedEvent) (*string, error) {
+	wallet := strings.ToLower(e.UserAddress)
+	var earlier []string
+	if err := tx.Raw(`
+		SELECT total_reward::text FROM total_reward_updates
+		WHERE airdrop_id = ? AND user_address = ? AND (block_number, log_index) < (?, ?)
+		ORDER BY block_number DESC, log_index DESC LIMIT 1
+	`, e.AirdropId, wallet, e.BlockNumber, e.LogIndex).Scan(&earlier).Error; err != nil {
+		return nil, err
+	}
+	if len(earlier) > 0 {
+		return &earlier[0], nil
+	}
+
+	var later []model.TotalRewardUpdatedEvent
+	if err := tx.Where("airdrop_id = ? AND user_address = ? AND (block_number, log_index) > (?, ?)", e.AirdropId, wallet, e.BlockNumber, e.LogIndex).
+		Order("block_number ASC, log_index ASC").
+		Limit(1).
+		Find(&later).Error; err != nil {
+		return nil, err
+	}
+	if len(later) > 0 {
+		return later[0].PreviousTotalReward, nil
+	}
+
+	var current []string
+	if err := tx.Raw(`SELECT total_reward::text FROM airdrop_whitelist WHERE airdrop_id = ? AND wallet_address = ?`,
+		e.AirdropId, wallet).Scan(&current).Error; err != nil {
+		return nil, err
+	}
+	if len(current) > 0 {
+		return &current[0], nil
+	}
+	return nil, nil
 }
 
 // --- 新增：解析与保存Airdrop创建与激活 ---
@@ -228,77 +233,35 @@ type AirdropCreatedInfo struct {
 	TotalReward     string
 }
 
-// parseAirdropCreatedEvent 解析 AirdropCreated(uint256 indexed airdropId, string name, bytes32 merkleRoot, uint256 totalReward, uint256 treeVersion)
-func parseAirdropCreatedEvent(vLog types.Log, chainId int) *AirdropCreatedInfo {
-	if len(vLog.Topics) < 2 {
-		return nil
-	}
-	abiInstance, ok := appabi.GetABIManager().GetABI(appabi.ABIMerkleAirdrop)
-	if !ok {
-		log.Logger.Warn("MerkleAirdrop ABI 未加载，跳过 AirdropCreated 解析")
-		return nil
-	}
-	ev, exists := abiInstance.Events["AirdropCreated"]
-	if !exists {
-		log.Logger.Warn("ABI 中未找到 AirdropCreated 事件定义")
-		return nil
-	}
-	// 非索引参数解包
-	values, err := ev.Inputs.NonIndexed().Unpack(vLog.Data)
-	if err != nil {
-		log.Logger.Warn("解包 AirdropCreated 事件数据失败", zap.Error(err), zap.String("tx_hash", vLog.TxHash.Hex()))
-		return nil
-	}
-	if len(values) < 4 {
-		return nil
-	}
-	// 解析索引参数 airdropId
-	airdropId := new(big.Int).SetBytes(common.TrimLeftZeroes(vLog.Topics[1].Bytes()))
-
-	// 解析非索引参数
-	name, _ := values[0].(string)
-	var merkleRootHex string
-	if mr, ok := values[1].([32]byte); ok {
-		merkleRootHex = "0x" + hex.EncodeToString(mr[:])
-	} else if b, ok := values[1].([]byte); ok && len(b) == 32 {
-		merkleRootHex = "0x" + hex.EncodeToString(b)
-	}
-	totalReward, _ := values[2].(*big.Int)
-
-	return &AirdropCreatedInfo{
-		AirdropId:       airdropId.String(),
-		ChainId:         int64(chainId),
-		ContractAddress: common.BytesToAddress(vLog.Address.Bytes()).Hex(),
-		Name:            name,
-		MerkleRoot:      merkleRootHex,
-		TotalReward: func() string {
-			if totalReward != nil {
-				return totalReward.String()
-			} else {
-				return "0"
-			}
-		}(),
-	}
+// handleAirdropCreated AirdropCreated(uint256 indexed airdropId, string name, bytes32 merkleRoot, uint256 totalReward, uint256 treeVersion)
+func handleAirdropCreated(e *decodedEvent, batch *decodedBatch) error {
+	events := batch.airdropEvents()
+	events.AirdropCreatedEvents = append(events.AirdropCreatedEvents, &AirdropCreatedInfo{
+		AirdropId:       e.bigInt("airdropId").String(),
+		ChainId:         int64(e.ChainId),
+		ContractAddress: e.Log.Address.Hex(),
+		Name:            e.str("name"),
+		MerkleRoot:      e.bytes32Hex("merkleRoot"),
+		TotalReward:     e.bigInt("totalReward").String(),
+	})
+	return nil
 }
 
-// parseAirdropActivatedEvent 解析 AirdropActivated(uint256 indexed airdropId)
-func parseAirdropActivatedEvent(vLog types.Log, chainId int) string {
-	if len(vLog.Topics) < 2 {
-		return ""
-	}
-	airdropId := new(big.Int).SetBytes(common.TrimLeftZeroes(vLog.Topics[1].Bytes()))
-	return airdropId.String()
+// handleAirdropActivated AirdropActivated(uint256 indexed airdropId)
+func handleAirdropActivated(e *decodedEvent, batch *decodedBatch) error {
+	events := batch.airdropEvents()
+	events.AirdropActivatedIds = append(events.AirdropActivatedIds, e.bigInt("airdropId").String())
+	return nil
 }
 
 // saveAirdropAdminEvents 保存活动创建与激活信息到 airdrop_campaigns
-func saveAirdropAdminEvents(created []*AirdropCreatedInfo, activated []string, chainId int, targetBlockNum uint64, addresses string) error {
-	return ctx.Ctx.DB.Transaction(func(tx *gorm.DB) error {
-		// 处理创建事件：存在则更新，不存在则插入（token_symbol 用占位符）
-		for _, e := range created {
-			if e == nil {
-				continue
-			}
-			if err := tx.Exec(`
+func saveAirdropAdminEvents(tx *gorm.DB, created []*AirdropCreatedInfo, activated []string, chainId int) error {
+	// 处理创建事件：存在则更新，不存在则插入（token_symbol 用占位符）
+	for _, e := range created {
+		if e == nil {
+			continue
+		}
+		if err := tx.Exec(`
                 INSERT INTO airdrop_campaigns (airdrop_id, chain_id, merkle_airdrop_contract, name, merkle_root, total_reward, token_symbol, is_active, created_at, updated_at)
                 VALUES (?, ?, LOWER(?), ?, ?, ?, 'CSWAP', FALSE, NOW(), NOW())
                 ON CONFLICT (airdrop_id) DO UPDATE
@@ -309,25 +272,22 @@ func saveAirdropAdminEvents(created []*AirdropCreatedInfo, activated []string, c
                     total_reward = EXCLUDED.total_reward,
                     updated_at = NOW()
             `, e.AirdropId, e.ChainId, e.ContractAddress, e.Name, e.MerkleRoot, e.TotalReward).Error; err != nil {
-				log.Logger.Error("保存 AirdropCreated 事件影响活动元数据失败", zap.Error(err))
-				return err
-			}
+			log.Logger.Error("保存 AirdropCreated 事件影响活动元数据失败", zap.Error(err))
+			return err
 		}
+	}
 
-		// 处理激活事件：直接更新 is_active
-		for _, id := range activated {
-			if id == "" {
-				continue
-			}
-			if err := tx.Exec(`
+	// 处理激活事件：直接更新 is_active
+	for _, id := range activated {
+		if id == "" {
+			continue
+		}
+		if err := tx.Exec(`
                 UPDATE airdrop_campaigns SET is_active = TRUE, updated_at = NOW() WHERE airdrop_id = ?
             `, id).Error; err != nil {
-				log.Logger.Error("更新 AirdropActivated 事件失败", zap.Error(err))
-				return err
-			}
+			log.Logger.Error("更新 AirdropActivated 事件失败", zap.Error(err))
+			return err
 		}
-
-		// 更新链区块高度
-		return updateBlockNumber(chainId, targetBlockNum, addresses)
-	})
+	}
+	return nil
 }
@@ -0,0 +1,178 @@
+package sync
+
+import (
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/chainclient/evm"
+	"github.com/mumu/cryptoSwap/src/core/ctx"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// newRawLogs 将本批次收到的全部日志转为归档记录，交易发送者与区块时间只对已解析的日志填写
+func newRawLogs(chainId int, logs []types.Log, lc *evm.LogContext) []*model.RawLog {
+	rawLogs := make([]*model.RawLog, 0, len(logs))
+	for _, vLog := range logs {
+		raw := &model.RawLog{
+			ChainId:     int64(chainId),
+			Address:     strings.ToLower(vLog.Address.Hex()),
+			Data:        vLog.Data,
+			BlockNumber: int64(vLog.BlockNumber),
+			BlockHash:   strings.ToLower(vLog.BlockHash.Hex()),
+			TxHash:      strings.ToLower(vLog.TxHash.Hex()),
+			TxIndex:     int(vLog.TxIndex),
+			LogIndex:    int(vLog.Index),
+		}
+		topics := []**string{&raw.Topic0, &raw.Topic1, &raw.Topic2, &raw.Topic3}
+		for i, topic := range vLog.Topics {
+			if i < len(topics) {
+				hex := strings.ToLower(topic.Hex())
+				*topics[i] = &hex
+			}
+		}
+		if sender, ok := lc.Senders[vLog.TxHash]; ok && sender != (common.Address{}) {
+			hex := strings.ToLower(sender.Hex())
+			raw.TxSender = &hex
+		}
+		if ts := lc.Timestamps[vLog.BlockHash]; ts > 0 {
+			blockTime := time.Unix(int64(ts), 0)
+			raw.BlockTimestamp = &blockTime
+		}
+		rawLogs = append(rawLogs, raw)
+	}
+	return rawLogs
+}
+
+// archiveBatch 在调用方的事务中归档本批次的原始日志，并记录已归档的区块范围
+// 同一日志再次归档（回填、链重组后重新出现）时恢复为非孤块，并补全缺失的发送者与区块时间
+func archiveBatch(tx *gorm.DB, chainRowId int64, batch *decodedBatch) error {
+	if batch.FromArchive {
+		return nil
+	}
+	if len(batch.RawLogs) > 0 {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns: []clause.Column{{Name: "chain_id"}, {Name: "block_hash"}, {Name: "log_index"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"orphaned":        false,
+				"tx_sender":       gorm.Expr("COALESCE(raw_logs.tx_sender, EXCLUDED.tx_sender)"),
+				"block_timestamp": gorm.Expr("COALESCE(raw_logs.block_timestamp, EXCLUDED.block_timestamp)"),
+			}),
+		}).CreateInBatches(batch.RawLogs, 500).Error; err != nil {
+			log.Logger.Error("归档原始日志失败", zap.Error(err))
+			return err
+		}
+	}
+	return recordArchivedRange(tx, chainRowId, batch.FromBlock, batch.ToBlock)
+}
+
+// recordArchivedRange 记录已归档的区块范围，与相邻或重叠的范围合并
+func recordArchivedRange(tx *gorm.DB, chainRowId int64, fromBlock, toBlock uint64) error {
+	var ranges []model.RawLogRange
+	lower := fromBlock
+	if lower > 0 {
+		lower--
+	}
+	if err := tx.Where("chain_row_id = ? AND from_block <= ? AND to_block >= ?", chainRowId, toBlock+1, lower).
+		Find(&ranges).Error; err != nil {
+		return err
+	}
+	merged := model.RawLogRange{ChainRowId: chainRowId, FromBlock: fromBlock, ToBlock: toBlock}
+	ids := make([]int64, 0, len(ranges))
+	for _, r := range ranges {
+		merged.FromBlock = min(merged.FromBlock, r.FromBlock)
+		merged.ToBlock = max(merged.ToBlock, r.ToBlock)
+		ids = append(ids, r.Id)
+	}
+	if len(ids) > 0 {
+		if err := tx.Where("id IN ?", ids).Delete(&model.RawLogRange{}).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Create(&merged).Error
+}
+
+// archiveCovers 归档是否完整覆盖 [fromBlock, toBlock]
+func archiveCovers(chainRowId int64, fromBlock, toBlock uint64) (bool, error) {
+	var count int64
+	err := ctx.Ctx.DB.Model(&model.RawLogRange{}).
+		Where("chain_row_id = ? AND from_block <= ? AND to_block >= ?", chainRowId, fromBlock, toBlock).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// orphanRawLogs 链重组时把分叉点之后的归档日志标记为孤块，并截断该链全部监听配置的已归档范围
+func orphanRawLogs(tx *gorm.DB, chainId int, forkBlock uint64) error {
+	if err := tx.Model(&model.RawLog{}).
+		Where("chain_id = ? AND block_number > ? AND NOT orphaned", chainId, forkBlock).
+		Update("orphaned", true).Error; err != nil {
+		return err
+	}
+	chainRows := tx.Model(&model.Chain{}).Select("id").Where("chain_id = ?", chainId)
+	if err := tx.Where("chain_row_id IN (?) AND from_block > ?", chainRows, forkBlock).
+		Delete(&model.RawLogRange{}).Error; err != nil {
+		return err
+	}
+	return tx.Model(&model.RawLogRange{}).
+		Where("chain_row_id IN (?) AND to_block > ?", chainRows, forkBlock).
+		Update("to_block", forkBlock).Error
+}
+
+// loadRawLogs 从归档读取地址列表在 [fromBlock, toBlock] 内的非孤块日志，按区块内顺序返回，
+// 同时由归档的发送者与区块时间构造日志上下文
+func loadRawLogs(chainId int, addresses []string, fromBlock, toBlock uint64) ([]types.Log, *evm.LogContext, error) {
+	lowered := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		lowered = append(lowered, strings.ToLower(address))
+	}
+	var rawLogs []model.RawLog
+	if err := ctx.Ctx.DB.
+		Where("chain_id = ? AND address IN ? AND block_number BETWEEN ? AND ? AND NOT orphaned", chainId, lowered, fromBlock, toBlock).
+		Order("block_number ASC, log_index ASC").
+		Find(&rawLogs).Error; err != nil {
+		return nil, nil, err
+	}
+
+	lc := &evm.LogContext{
+		Senders:    make(map[common.Hash]common.Address),
+		Timestamps: make(map[common.Hash]uint64),
+	}
+	logs := make([]types.Log, 0, len(rawLogs))
+	for i := range rawLogs {
+		logs = append(logs, rawLogToLog(&rawLogs[i], lc))
+	}
+	return logs, lc, nil
+}
+
+// rawLogToLog 由归档记录还原日志，并把发送者与区块时间写入日志上下文
+func rawLogToLog(raw *model.RawLog, lc *evm.LogContext) types.Log {
+	vLog := types.Log{
+		Address:     common.HexToAddress(raw.Address),
+		Data:        raw.Data,
+		BlockNumber: uint64(raw.BlockNumber),
+		BlockHash:   common.HexToHash(raw.BlockHash),
+		TxHash:      common.HexToHash(raw.TxHash),
+		TxIndex:     uint(raw.TxIndex),
+		Index:       uint(raw.LogIndex),
+	}
+	for _, topic := range []*string{raw.Topic0, raw.Topic1, raw.Topic2, raw.Topic3} {
+		if topic == nil {
+			break
+		}
+		vLog.Topics = append(vLog.Topics, common.HexToHash(*topic))
+	}
+	if raw.TxSender != nil {
+		lc.Senders[vLog.TxHash] = common.HexToAddress(*raw.TxSender)
+	}
+	if raw.BlockTimestamp != nil {
+		ts := uint64(raw.BlockTimestamp.Unix())
+		vLog.BlockTimestamp = ts
+		lc.Timestamps[vLog.BlockHash] = ts
+	}
+	return vLog
+}
@@ -0,0 +1,289 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/ctx"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 回填默认参数
+const (
+	defaultBackfillWorkers = 4
+	backfillFetchRetries   = 3
+)
+
+// BackfillOptions 区块回填参数
+type BackfillOptions struct {
+	ChainId     int    `json:"chainId"`
+	Contract    string `json:"contract"`
+	ServiceType string `json:"serviceType"` // 同一合约配置了多个服务类型时必填
+	FromBlock   uint64 `json:"fromBlock"`
+	ToBlock     uint64 `json:"toBlock"`
+	DryRun      bool   `json:"dryRun"`    // 只打印解码结果，不写库
+	Workers     int    `json:"workers"`   // 并发拉取/解码的区段数
+	BatchSize   uint64 `json:"batchSize"` // 每个区段的区块数，默认取链配置的 batch_size
+	// Progress 每提交一个区段后回调：已完成区段数、区段总数、已按顺序完成的最后区块
+	Progress func(done, total int, completedBlock uint64) `json:"-"`
+}
+
+// backfillResult 一个区段的解码结果
+type backfillResult struct {
+	index int
+	batch *decodedBatch
+	err   error
+}
+
+// RunBackfill 用实时监听相同的解码器重新索引 [FromBlock, ToBlock]
+// 区段并发拉取与解码，按区块顺序逐段提交；每段先撤销范围内已有的派生数据再写入，重复执行结果一致；
+// 不读写实时游标与区块哈希
+func RunBackfill(c context.Context, opts BackfillOptions) error {
+	if opts.FromBlock > opts.ToBlock {
+		return fmt.Errorf("起始区块 %d 大于结束区块 %d", opts.FromBlock, opts.ToBlock)
+	}
+	chain, err := findChainConfig(opts.ChainId, opts.Contract, opts.ServiceType)
+	if err != nil {
+		return err
+	}
+	// 回填区段必须已被实时监听处理过，否则实时监听之后还会再次写入
+	if !opts.DryRun && opts.ToBlock > chain.LastBlockNum {
+		return fmt.Errorf("结束区块 %d 超过实时游标 %d，请等待实时监听追上后再回填", opts.ToBlock, chain.LastBlockNum)
+	}
+
+	syncer := newChainSyncer(*chain)
+	if syncer == nil {
+		return fmt.Errorf("无法为合约 %s 创建监听器", chain.Address)
+	}
+	syncer.refreshWatchedAddresses()
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = defaultBackfillWorkers
+	}
+	batchSize := opts.BatchSize
+	if batchSize == 0 {
+		batchSize = syncer.settings.MaxBatchSize
+	}
+
+	var ranges [][2]uint64
+	for from := opts.FromBlock; from <= opts.ToBlock; from += batchSize {
+		to := from + batchSize - 1
+		if to > opts.ToBlock || to < from {
+			to = opts.ToBlock
+		}
+		ranges = append(ranges, [2]uint64{from, to})
+		if to == opts.ToBlock {
+			break
+		}
+	}
+
+	log.Logger.Info("开始回填",
+		zap.Int("chain_id", opts.ChainId),
+		zap.String("contract_address", chain.Address),
+		zap.String("service_type", chain.ServiceType),
+		zap.Uint64("from_block", opts.FromBlock),
+		zap.Uint64("to_block", opts.ToBlock),
+		zap.Int("range_count", len(ranges)),
+		zap.Int("workers", workers),
+		zap.Bool("dry_run", opts.DryRun))
+
+	if opts.Progress != nil {
+		opts.Progress(0, len(ranges), completedBlock(ranges, 0, opts.FromBlock))
+	}
+
+	runCtx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	// window 限制已解码但尚未提交的区段数量，避免前面的区段较慢时后面的结果无限堆积
+	window := make(chan struct{}, workers*2)
+	jobs := make(chan int)
+	results := make(chan backfillResult, workers)
+
+	go func() {
+		defer close(jobs)
+		for i := range ranges {
+			select {
+			case window <- struct{}{}:
+			case <-runCtx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-runCtx.Done():
+				return
+			}
+		}
+	}()
+	for w := 0; w < workers; w++ {
+		go func() {
+			for i := range jobs {
+				batch, err := syncer.decodeBackfillRange(runCtx, ranges[i][0], ranges[i][1])
+				select {
+				case results <- backfillResult{index: i, batch: batch, err: err}:
+				case <-runCtx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	// 按区段顺序提交
+	pending := make(map[int]*decodedBatch)
+	next := 0
+	for next < len(ranges) {
+		var res backfillResult
+		select {
+		case res = <-results:
+		case <-c.Done():
+			return fmt.Errorf("回填已取消，已完成至区块 %d", completedBlock(ranges, next, opts.FromBlock))
+		}
+		if res.err != nil {
+			return fmt.Errorf("区段 [%d, %d] 解码失败: %w，已完成至区块 %d",
+				ranges[res.index][0], ranges[res.index][1], res.err, completedBlock(ranges, next, opts.FromBlock))
+		}
+		pending[res.index] = res.batch
+
+		for batch, ok := pending[next]; ok; batch, ok = pending[next] {
+			if opts.DryRun {
+				printBatch(batch)
+			} else if err := replaceBatch(syncer, batch); err != nil {
+				return fmt.Errorf("区段 [%d, %d] 提交失败: %w，已完成至区块 %d",
+					batch.FromBlock, batch.ToBlock, err, completedBlock(ranges, next, opts.FromBlock))
+			}
+			log.Logger.Info("回填区段完成",
+				zap.Uint64("from_block", batch.FromBlock),
+				zap.Uint64("to_block", batch.ToBlock),
+				zap.Int("event_count", batch.eventCount()),
+				zap.Int("progress", next+1),
+				zap.Int("total", len(ranges)))
+			delete(pending, next)
+			next++
+			<-window
+			if opts.Progress != nil {
+				opts.Progress(next, len(ranges), completedBlock(ranges, next, opts.FromBlock))
+			}
+		}
+	}
+	log.Logger.Info("回填完成", zap.Uint64("from_block", opts.FromBlock), zap.Uint64("to_block", opts.ToBlock))
+	return nil
+}
+
+// findChainConfig 查找回填/重建对应的监听配置
+func findChainConfig(chainId int, contract, serviceType string) (*model.Chain, error) {
+	var chains []model.Chain
+	query := ctx.Ctx.DB.Where("chain_id = ? AND LOWER(address) = ?", chainId, strings.ToLower(contract))
+	if serviceType != "" {
+		query = query.Where("service_type = ?", serviceType)
+	}
+	if err := query.Find(&chains).Error; err != nil {
+		return nil, err
+	}
+	switch len(chains) {
+	case 0:
+		return nil, fmt.Errorf("未找到链 %d 上合约 %s 的监听配置", chainId, contract)
+	case 1:
+		return &chains[0], nil
+	default:
+		return nil, fmt.Errorf("合约 %s 配置了多个服务类型，请通过 --service 指定", contract)
+	}
+}
+
+// decodeBackfillRange 拉取并解码一个区段，拉取失败时重试
+func (s *chainSyncer) decodeBackfillRange(c context.Context, fromBlockNum, toBlockNum uint64) (*decodedBatch, error) {
+	var lastErr error
+	for attempt := 0; attempt < backfillFetchRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(time.Duration(attempt) * time.Second):
+			case <-c.Done():
+				return nil, c.Err()
+			}
+		}
+		logs, err := s.fetchLogs(fromBlockNum, toBlockNum)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return s.decodeRange(fromBlockNum, toBlockNum, logs)
+	}
+	return nil, lastErr
+}
+
+// replaceBatch 在同一事务中撤销区段内已有的派生数据并重新写入，回填的原始日志一并归档
+func replaceBatch(s *chainSyncer, batch *decodedBatch) error {
+	scope := rollbackScope{
+		ChainId:   s.chainId,
+		FromBlock: batch.FromBlock,
+		ToBlock:   batch.ToBlock,
+		Addresses: s.contractAddresses,
+	}
+	return ctx.Ctx.DB.Transaction(func(tx *gorm.DB) error {
+		if err := rollbackRange(tx, scope); err != nil {
+			return err
+		}
+		if err := resolveDeadLettersInRange(tx, s.chain.Id, batch.FromBlock, batch.ToBlock); err != nil {
+			return err
+		}
+		if err := saveBatch(tx, batch, s.chainId); err != nil {
+			return err
+		}
+		return archiveBatch(tx, s.chain.Id, batch)
+	})
+}
+
+// printBatch 将解码结果按 JSON 输出到标准输出
+func printBatch(batch *decodedBatch) {
+	out := map[string]interface{}{
+		"fromBlock": batch.FromBlock,
+		"toBlock":   batch.ToBlock,
+		"logCount":  len(batch.Logs),
+	}
+	if len(batch.Pairs) > 0 {
+		out["pairs"] = batch.Pairs
+	}
+	if len(batch.UserOperationRecords) > 0 {
+		out["userOperationRecords"] = batch.UserOperationRecords
+	}
+	if len(batch.LiquidityPoolEvents) > 0 {
+		out["liquidityPoolEvents"] = batch.LiquidityPoolEvents
+	}
+	if batch.AirdropEvents != nil {
+		out["airdropEvents"] = batch.AirdropEvents
+	}
+	if len(batch.LPTransfers) > 0 {
+		out["lpTransfers"] = batch.LPTransfers
+	}
+	if len(batch.PoolReserves) > 0 {
+		out["poolReserves"] = batch.PoolReserves
+	}
+	if batch.StakingEvents != nil {
+		out["stakingEvents"] = batch.StakingEvents
+	}
+	if len(batch.DeadLetters) > 0 {
+		out["deadLetters"] = batch.DeadLetters
+	}
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(out); err != nil {
+		log.Logger.Error("输出解码结果失败", zap.Error(err))
+	}
+}
+
+// completedBlock 已按顺序提交完成的最后区块，尚未完成任何区段时返回起始区块之前一块
+func completedBlock(ranges [][2]uint64, next int, fromBlock uint64) uint64 {
+	if next == 0 {
+		if fromBlock == 0 {
+			return 0
+		}
+		return fromBlock - 1
+	}
+	return ranges[next-1][1]
+}
@@ -0,0 +1,236 @@
+package sync
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/chainclient/evm"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// decodedBatch 一个区块范围内解码得到的全部事件，实时监听与回填共用
+type decodedBatch struct {
+	FromBlock            uint64
+	ToBlock              uint64
+	Logs                 []types.Log
+	Pairs                []*discoveredPair
+	UserOperationRecords []*model.UserOperationRecord
+	LiquidityPoolEvents  []*model.LiquidityPoolEvent
+	LPTransfers          []*model.LPTokenTransfer
+	AirdropEvents        *AirdropEvents
+	StakingEvents        *StakingEvents
+	PoolReserves         map[string]*poolReserves   // 池子地址 -> 本批次最近一次 Sync
+	RawLogs              []*model.RawLog            // 待归档的原始日志
+	FromArchive          bool                       // 由归档重建的批次，不再重复归档
+	Offline              bool                       // 离线批次：交易对信息只从数据库读取，不访问 RPC
+	PoolTokens           map[string][2]string       // 交易对地址 -> token0/token1，批次内缓存
+	NewPools             map[string]*discoveredPair // 未登记的交易对，解码时由链客户端读取，保存时登记
+	EvmClient            *evm.Evm                   // 读取未登记交易对的链客户端，离线批次为 nil
+	DeadLetters          []*model.DeadLetterLog     // 解码或处理失败的日志，与批次一同提交
+	EventCounts          map[string]int             // 解码器名称 -> 成功处理的日志数，用于指标
+}
+
+// eventCount 解码出的事件总数
+func (b *decodedBatch) eventCount() int {
+	count := len(b.Pairs) + len(b.UserOperationRecords) + len(b.LiquidityPoolEvents) + len(b.LPTransfers)
+	if b.AirdropEvents != nil {
+		count += len(b.AirdropEvents.RewardClaimedEvents) +
+			len(b.AirdropEvents.TotalRewardUpdatedEvents) +
+			len(b.AirdropEvents.AirdropCreatedEvents) +
+			len(b.AirdropEvents.AirdropActivatedIds)
+	}
+	if b.StakingEvents != nil {
+		count += b.StakingEvents.count()
+	}
+	return count
+}
+
+// decodeRange 按本服务类型的解码器解析日志，不写库
+func (s *chainSyncer) decodeRange(fromBlockNum, targetBlockNum uint64, allLogs []types.Log) (*decodedBatch, error) {
+	chainId := s.chainId
+	evmClient := s.evmClient
+	batch := &decodedBatch{FromBlock: fromBlockNum, ToBlock: targetBlockNum, EvmClient: evmClient, EventCounts: make(map[string]int)}
+
+	if s.isFactory() {
+		var err error
+		allLogs, batch.Pairs, err = s.expandNewPairs(batch, targetBlockNum, allLogs)
+		if err != nil {
+			return nil, err
+		}
+	}
+	batch.Logs = allLogs
+
+	eventLogs := s.eventLogs(allLogs)
+	// 按区块一次性解析交易发送者（真实用户地址）与区块时间
+	logContext, err := evmClient.ResolveLogContext(eventLogs)
+	if err != nil {
+		log.Logger.Error("解析交易发送者与区块时间失败", zap.Int("chain_id", chainId), zap.Error(err))
+		return nil, err
+	}
+	batch.RawLogs = newRawLogs(chainId, allLogs, logContext)
+	s.decodeLogs(batch, eventLogs, logContext)
+	return batch, nil
+}
+
+// eventLogs 筛选本服务类型注册了处理函数的日志，PairCreated 已在 expandNewPairs 中处理
+func (s *chainSyncer) eventLogs(allLogs []types.Log) []types.Log {
+	var eventLogs []types.Log
+	for _, vLog := range allLogs {
+		if len(vLog.Topics) == 0 {
+			continue
+		}
+		if d, ok := s.decoders[vLog.Topics[0]]; ok && d.handle != nil {
+			eventLogs = append(eventLogs, vLog)
+		}
+	}
+	return eventLogs
+}
+
+// decodeLogs 按 ABI 解码日志并交给处理函数；实时监听、回填与离线重建共用
+// 实时监听与回填中首次出现的交易对由 batch.EvmClient 读取代币地址，离线批次（batch.Offline）只读数据库
+// 解码或处理失败的日志写入死信队列，不阻塞同批次的其他日志
+func (s *chainSyncer) decodeLogs(batch *decodedBatch, eventLogs []types.Log, logContext *evm.LogContext) {
+	for _, vLog := range eventLogs {
+		d := s.decoders[vLog.Topics[0]]
+		if err := s.handleEvent(d, vLog, logContext, batch); err != nil {
+			batch.DeadLetters = append(batch.DeadLetters, s.newDeadLetter(vLog, d.name, err, logContext))
+			continue
+		}
+		if batch.EventCounts != nil {
+			batch.EventCounts[d.name]++
+		}
+	}
+}
+
+// expandNewPairs 解析本批次 PairCreated 创建的交易对，并补拉这些交易对在创建区块之后的日志
+// 新交易对在本批次内产生的 Swap/Mint/Burn 不在原地址列表中，需要单独拉取后合并
+// 解析失败的 PairCreated 写入批次的死信
+func (s *chainSyncer) expandNewPairs(batch *decodedBatch, targetBlockNum uint64, allLogs []types.Log) ([]types.Log, []*discoveredPair, error) {
+	watched := make(map[string]bool, len(s.contractAddresses))
+	for _, address := range s.contractAddresses {
+		watched[strings.ToLower(address)] = true
+	}
+
+	var pairs []*discoveredPair
+	for _, vLog := range allLogs {
+		if len(vLog.Topics) == 0 || !strings.EqualFold(vLog.Address.Hex(), s.chain.Address) {
+			continue
+		}
+		d, ok := s.decoders[vLog.Topics[0]]
+		if !ok || d.name != pairCreatedDecoder {
+			continue
+		}
+		pair, err := parsePairCreatedEvent(d, vLog)
+		if err != nil {
+			// 发送者与区块时间此时尚未解析，重试 PairCreated 不需要
+			batch.DeadLetters = append(batch.DeadLetters, s.newDeadLetter(vLog, d.name, err, &evm.LogContext{}))
+			continue
+		}
+		fillTokenMetadata(s.evmClient, pair)
+		pairs = append(pairs, pair)
+		batch.EventCounts[d.name]++
+		if watched[strings.ToLower(pair.PairAddress)] {
+			// 已在监听列表中（例如回填已登记的区段），日志已包含在本批次内
+			continue
+		}
+		logs, err := s.fetchAddressLogs([]string{pair.PairAddress}, pair.CreatedBlock, targetBlockNum)
+		if err != nil {
+			return nil, nil, err
+		}
+		allLogs = append(allLogs, logs...)
+	}
+	if len(pairs) == 0 {
+		return allLogs, nil, nil
+	}
+	log.Logger.Info("发现新交易对", zap.Int("chain_id", s.chainId), zap.Int("pair_count", len(pairs)))
+
+	sort.SliceStable(allLogs, func(i, j int) bool {
+		if allLogs[i].BlockNumber != allLogs[j].BlockNumber {
+			return allLogs[i].BlockNumber < allLogs[j].BlockNumber
+		}
+		return allLogs[i].Index < allLogs[j].Index
+	})
+	return allLogs, pairs, nil
+}
+
+// saveBatch 在调用方的事务中保存解码后的全部事件，游标由调用方在同一事务中推进
+func saveBatch(tx *gorm.DB, batch *decodedBatch, chainId int) error {
+	// 先登记交易对，后续流动性事件更新池子信息时能查到
+	if len(batch.Pairs) > 0 {
+		if err := savePairs(tx, chainId, batch.Pairs); err != nil {
+			log.Logger.Error("登记新交易对失败", zap.Error(err))
+			return err
+		}
+	}
+
+	if len(batch.UserOperationRecords) > 0 {
+		log.Logger.Info("解析质押池事件成功", zap.Int("event_count", len(batch.UserOperationRecords)))
+		if err := updateDbUserAmount(tx, batch.UserOperationRecords, chainId, batch.ToBlock); err != nil {
+			log.Logger.Error("保存质押池事件失败", zap.Error(err))
+			return err
+		}
+	}
+
+	if len(batch.LiquidityPoolEvents) > 0 {
+		log.Logger.Info("解析流动性池事件成功", zap.Int("event_count", len(batch.LiquidityPoolEvents)))
+		if err := saveLiquidityPoolEvents(tx, batch.LiquidityPoolEvents, batch.NewPools); err != nil {
+			log.Logger.Error("保存流动性池事件失败", zap.Error(err))
+			return err
+		}
+		if err := refreshBatchCandles(tx, chainId, batch.LiquidityPoolEvents); err != nil {
+			log.Logger.Error("更新 K 线失败", zap.Error(err))
+			return err
+		}
+	}
+
+	if batch.StakingEvents != nil {
+		log.Logger.Info("解析质押合约事件成功", zap.Int("event_count", batch.StakingEvents.count()))
+		if err := saveStakingEvents(tx, batch.StakingEvents); err != nil {
+			return err
+		}
+	}
+
+	if len(batch.LPTransfers) > 0 {
+		log.Logger.Info("解析 LP Transfer 成功", zap.Int("event_count", len(batch.LPTransfers)))
+		markProtocolFeeMints(batch)
+		if err := saveLPTransfers(tx, chainId, batch.LPTransfers, batch.ToBlock); err != nil {
+			log.Logger.Error("保存 LP Transfer 失败", zap.Error(err))
+			return err
+		}
+	}
+
+	// 储备量在池子登记之后更新
+	if len(batch.PoolReserves) > 0 {
+		if err := savePoolReserves(tx, batch.PoolReserves); err != nil {
+			return err
+		}
+	}
+
+	// 交易对汇总依赖本批次的 K 线与 LP 持仓账本
+	if len(batch.LiquidityPoolEvents) > 0 || len(batch.LPTransfers) > 0 {
+		if err := refreshBatchPoolData(tx, chainId, batch); err != nil {
+			log.Logger.Error("更新交易对汇总失败", zap.Error(err))
+			return err
+		}
+	}
+
+	// 统一保存空投事件
+	if batch.AirdropEvents != nil {
+		if err := SaveAirdropEvents(tx, batch.AirdropEvents, chainId); err != nil {
+			log.Logger.Error("保存空投事件失败", zap.Error(err))
+			return err
+		}
+	}
+
+	if len(batch.DeadLetters) > 0 {
+		if err := saveDeadLetters(tx, batch.DeadLetters); err != nil {
+			log.Logger.Error("保存死信失败", zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
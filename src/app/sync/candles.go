@@ -0,0 +1,290 @@
+package sync
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/ctx"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// candleInterval K 线周期，Seconds 均能整除一天，按 UTC 对齐
+type candleInterval struct {
+	Name    string
+	Seconds int64
+}
+
+// candleIntervals 第一个周期由事件直接聚合，其余由第一个周期汇总
+var candleIntervals = []candleInterval{
+	{Name: "1m", Seconds: 60},
+	{Name: "5m", Seconds: 300},
+	{Name: "1h", Seconds: 3600},
+	{Name: "1d", Seconds: 86400},
+}
+
+// candleBucket 时间点所在周期的起点
+func candleBucket(t time.Time, seconds int64) time.Time {
+	unix := t.Unix()
+	return time.Unix(unix-((unix%seconds)+seconds)%seconds, 0).UTC()
+}
+
+// aggregateMinuteCandlesSQL 由流动性池事件聚合 1m K 线
+// 价格取事件之后的储备量，1 个 token0 折合的 token1 数量；token0 的 USD 价格优先取 token0 自身定价，否则由 token1 定价换算
+// 代币价格取成交时间之前最近一次定价，早于第一条记录时取第一条，与服务层按时间点估值一致
+// 超出列精度的异常价格（如储备量极小的垃圾代币）不参与聚合，避免整个批次事务失败
+const aggregateMinuteCandlesSQL = `
+INSERT INTO pool_candles (chain_id, pool_address, resolution, bucket_start, open, high, low, close,
+	open_usd, high_usd, low_usd, close_usd, volume0, volume1, volume_usd, swap_count, event_count, updated_at)
+WITH ev AS (
+	SELECT e.chain_id,
+	       LOWER(e.pool_address) AS pool_address,
+	       e.block_number,
+	       e.log_index,
+	       e.event_type,
+	       COALESCE(e.block_timestamp, e.created_at) AS ts,
+	       (e.reserve1 / power(10::NUMERIC, p.token1_decimals)) / (e.reserve0 / power(10::NUMERIC, p.token0_decimals)) AS price,
+	       CASE WHEN e.event_type = 'Swap' THEN e.amount0_in + e.amount0_out ELSE 0 END AS vol0,
+	       CASE WHEN e.event_type = 'Swap' THEN e.amount1_in + e.amount1_out ELSE 0 END AS vol1,
+	       p.token0_decimals,
+	       p.token1_decimals,
+	       LOWER(p.token0_address) AS token0,
+	       LOWER(p.token1_address) AS token1
+	  FROM liquidity_pool_events e
+	  JOIN liquidity_pools p
+	    ON p.chain_id = e.chain_id
+	   AND LOWER(p.pool_address) = LOWER(e.pool_address)
+	 WHERE e.chain_id = @chain_id
+	   AND e.pool_address IN @event_pools
+	   AND COALESCE(e.block_timestamp, e.created_at) >= @start
+	   AND COALESCE(e.block_timestamp, e.created_at) < @end
+	   AND e.reserve0 > 0
+	   AND e.reserve1 > 0
+), priced AS (
+	SELECT ev.*,
+	       COALESCE(
+	         (SELECT t.price_usd FROM token_prices t
+	           WHERE t.chain_id = ev.chain_id AND t.token_address = ev.token0 AND t.computed_at <= ev.ts
+	           ORDER BY t.computed_at DESC LIMIT 1),
+	         (SELECT t.price_usd FROM token_prices t
+	           WHERE t.chain_id = ev.chain_id AND t.token_address = ev.token0
+	           ORDER BY t.computed_at ASC LIMIT 1)) AS price0_usd,
+	       COALESCE(
+	         (SELECT t.price_usd FROM token_prices t
+	           WHERE t.chain_id = ev.chain_id AND t.token_address = ev.token1 AND t.computed_at <= ev.ts
+	           ORDER BY t.computed_at DESC LIMIT 1),
+	         (SELECT t.price_usd FROM token_prices t
+	           WHERE t.chain_id = ev.chain_id AND t.token_address = ev.token1
+	           ORDER BY t.computed_at ASC LIMIT 1)) AS price1_usd
+	  FROM ev
+	 WHERE ev.price < 1e29
+), valued AS (
+	SELECT priced.*,
+	       to_timestamp(floor(extract(epoch FROM ts) / 60) * 60) AS bucket,
+	       CASE WHEN COALESCE(price0_usd, price * price1_usd) < 1e29
+	            THEN COALESCE(price0_usd, price * price1_usd) END AS usd,
+	       CASE WHEN event_type <> 'Swap' THEN 0
+	            WHEN price0_usd IS NOT NULL THEN vol0 / power(10::NUMERIC, token0_decimals) * price0_usd
+	            ELSE vol1 / power(10::NUMERIC, token1_decimals) * price1_usd END AS vol_usd
+	  FROM priced
+)
+SELECT chain_id,
+       pool_address,
+       '1m',
+       bucket,
+       (array_agg(price ORDER BY block_number, log_index))[1],
+       MAX(price),
+       MIN(price),
+       (array_agg(price ORDER BY block_number DESC, log_index DESC))[1],
+       (array_agg(usd ORDER BY block_number, log_index) FILTER (WHERE usd IS NOT NULL))[1],
+       MAX(usd),
+       MIN(usd),
+       (array_agg(usd ORDER BY block_number DESC, log_index DESC) FILTER (WHERE usd IS NOT NULL))[1],
+       SUM(vol0),
+       SUM(vol1),
+       CASE WHEN SUM(vol_usd) < 1e33 THEN SUM(vol_usd) END,
+       COUNT(*) FILTER (WHERE event_type = 'Swap'),
+       COUNT(*),
+       NOW()
+  FROM valued
+ GROUP BY chain_id, pool_address, bucket`
+
+// rollupCandlesSQL 由 1m K 线汇总更大的周期
+const rollupCandlesSQL = `
+INSERT INTO pool_candles (chain_id, pool_address, resolution, bucket_start, open, high, low, close,
+	open_usd, high_usd, low_usd, close_usd, volume0, volume1, volume_usd, swap_count, event_count, updated_at)
+SELECT chain_id,
+       pool_address,
+       @resolution,
+       to_timestamp(floor(extract(epoch FROM bucket_start) / @seconds) * @seconds) AS bucket,
+       (array_agg(open ORDER BY bucket_start))[1],
+       MAX(high),
+       MIN(low),
+       (array_agg(close ORDER BY bucket_start DESC))[1],
+       (array_agg(open_usd ORDER BY bucket_start) FILTER (WHERE open_usd IS NOT NULL))[1],
+       MAX(high_usd),
+       MIN(low_usd),
+       (array_agg(close_usd ORDER BY bucket_start DESC) FILTER (WHERE close_usd IS NOT NULL))[1],
+       SUM(volume0),
+       SUM(volume1),
+       SUM(volume_usd),
+       SUM(swap_count),
+       SUM(event_count),
+       NOW()
+  FROM pool_candles
+ WHERE chain_id = @chain_id
+   AND resolution = '1m'
+   AND pool_address IN @pools
+   AND bucket_start >= @start
+   AND bucket_start < @end
+ GROUP BY chain_id, pool_address, bucket`
+
+// refreshCandles 重算池子在 [from, to] 所覆盖周期内的 K 线：先删除再由事件重新聚合，写入、回滚时均可调用
+// eventPools 为事件表中的池子地址（原样大小写，便于使用索引）
+func refreshCandles(tx *gorm.DB, chainId int64, eventPools []string, from, to time.Time) error {
+	if len(eventPools) == 0 {
+		return nil
+	}
+	pools := lowerPoolAddresses(eventPools)
+	for i, interval := range candleIntervals {
+		start := candleBucket(from, interval.Seconds)
+		end := candleBucket(to, interval.Seconds).Add(time.Duration(interval.Seconds) * time.Second)
+		if err := tx.Where("chain_id = ? AND pool_address IN ? AND resolution = ? AND bucket_start >= ? AND bucket_start < ?",
+			chainId, pools, interval.Name, start, end).
+			Delete(&model.PoolCandle{}).Error; err != nil {
+			return err
+		}
+
+		args := map[string]interface{}{
+			"chain_id":    chainId,
+			"event_pools": eventPools,
+			"pools":       pools,
+			"start":       start,
+			"end":         end,
+			"resolution":  interval.Name,
+			"seconds":     interval.Seconds,
+		}
+		query := rollupCandlesSQL
+		if i == 0 {
+			query = aggregateMinuteCandlesSQL
+		}
+		if err := tx.Exec(query, args).Error; err != nil {
+			return fmt.Errorf("聚合 %s K 线失败: %w", interval.Name, err)
+		}
+	}
+	return nil
+}
+
+// refreshBatchCandles 批次写入流动性池事件后重算所涉及池子与时间段的 K 线
+func refreshBatchCandles(tx *gorm.DB, chainId int, events []*model.LiquidityPoolEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool)
+	var pools []string
+	var from, to time.Time
+	for _, event := range events {
+		if !seen[event.PoolAddress] {
+			seen[event.PoolAddress] = true
+			pools = append(pools, event.PoolAddress)
+		}
+		ts := event.BlockTimestamp
+		if ts.IsZero() {
+			ts = event.CreatedAt
+		}
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		if from.IsZero() || ts.Before(from) {
+			from = ts
+		}
+		if ts.After(to) {
+			to = ts
+		}
+	}
+	return refreshCandles(tx, int64(chainId), pools, from, to)
+}
+
+// lowerPoolAddresses 地址转为小写，K 线表中的地址均为小写
+func lowerPoolAddresses(addresses []string) []string {
+	lowered := make([]string, len(addresses))
+	for i, address := range addresses {
+		lowered[i] = strings.ToLower(address)
+	}
+	return lowered
+}
+
+// CandleRebuildOptions K 线重建参数
+type CandleRebuildOptions struct {
+	ChainId int
+	Pool    string    // 为空时重建该链全部池子
+	From    time.Time // 为零值时从最早的事件开始
+	To      time.Time // 为零值时取当前时间
+}
+
+// RebuildCandles 由流动性池事件重建 K 线及交易对小时/日汇总，按天分段、每段一个事务，可中断后重跑
+// 代币价格历史补齐后可用于回填早期 K 线的 USD 价格
+func RebuildCandles(c context.Context, opts CandleRebuildOptions) error {
+	query := ctx.Ctx.DB.Model(&model.LiquidityPoolEvent{}).Where("chain_id = ?", opts.ChainId)
+	if opts.Pool != "" {
+		query = query.Where("LOWER(pool_address) = ?", strings.ToLower(opts.Pool))
+	}
+	var pools []string
+	if err := query.Distinct("pool_address").Pluck("pool_address", &pools).Error; err != nil {
+		return err
+	}
+	if len(pools) == 0 {
+		return fmt.Errorf("链 %d 没有可重建的流动性池事件", opts.ChainId)
+	}
+
+	from := opts.From
+	if from.IsZero() {
+		var earliest sql.NullTime
+		if err := ctx.Ctx.DB.Model(&model.LiquidityPoolEvent{}).
+			Where("chain_id = ? AND pool_address IN ?", opts.ChainId, pools).
+			Select("MIN(COALESCE(block_timestamp, created_at))").
+			Row().Scan(&earliest); err != nil {
+			return err
+		}
+		if !earliest.Valid {
+			return nil
+		}
+		from = earliest.Time
+	}
+	to := opts.To
+	if to.IsZero() {
+		to = time.Now()
+	}
+	if from.After(to) {
+		return fmt.Errorf("起始时间 %s 晚于结束时间 %s", from.Format(time.RFC3339), to.Format(time.RFC3339))
+	}
+
+	day := time.Duration(candleIntervals[len(candleIntervals)-1].Seconds) * time.Second
+	for start := candleBucket(from, int64(day/time.Second)); !start.After(to); start = start.Add(day) {
+		select {
+		case <-c.Done():
+			return c.Err()
+		default:
+		}
+		// 每段覆盖整天，日线由完整的 1m 汇总
+		end := start.Add(day - time.Second)
+		if err := ctx.Ctx.DB.Transaction(func(tx *gorm.DB) error {
+			if err := refreshCandles(tx, int64(opts.ChainId), pools, start, end); err != nil {
+				return err
+			}
+			return refreshPoolData(tx, int64(opts.ChainId), lowerPoolAddresses(pools), start, end)
+		}); err != nil {
+			return fmt.Errorf("重建 %s 的 K 线失败: %w", start.Format("2006-01-02"), err)
+		}
+		log.Logger.Info("K 线重建进度",
+			zap.Int("chain_id", opts.ChainId),
+			zap.Int("pool_count", len(pools)),
+			zap.String("day", start.Format("2006-01-02")))
+	}
+	return nil
+}
@@ -0,0 +1,410 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/ctx"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"github.com/mumu/cryptoSwap/src/core/metrics"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 回填任务状态
+const (
+	BackfillRunning   = "running"
+	BackfillSucceeded = "succeeded"
+	BackfillFailed    = "failed"
+	BackfillCancelled = "cancelled"
+)
+
+// syncerState 监听器运行状态，由监听协程写入、管理接口读取
+type syncerState struct {
+	mu          sync.RWMutex
+	head        uint64 // 最近一次读取到的可安全索引高度
+	lastError   string
+	lastErrorAt *time.Time
+	lastBatchAt *time.Time
+}
+
+// SyncerStatus 一条监听配置的运行状态
+type SyncerStatus struct {
+	Id          int64      `json:"id"`
+	ChainId     int64      `json:"chainId"`
+	ChainName   string     `json:"chainName"`
+	Address     string     `json:"address"`
+	ServiceType string     `json:"serviceType"`
+	Running     bool       `json:"running"`
+	Paused      bool       `json:"paused"`
+	Cursor      uint64     `json:"cursor"`
+	Head        uint64     `json:"head"`
+	Lag         uint64     `json:"lag"`
+	LastError   string     `json:"lastError,omitempty"`
+	LastErrorAt *time.Time `json:"lastErrorAt,omitempty"`
+	LastBatchAt *time.Time `json:"lastBatchAt,omitempty"`
+	LeaseHolder string     `json:"leaseHolder,omitempty"` // 最近写入该链的副本，多副本部署时有值
+}
+
+// runningSyncers 正在运行的监听器，按 chain 表 id 索引
+var runningSyncers = struct {
+	sync.RWMutex
+	byId map[int64]*chainSyncer
+}{byId: make(map[int64]*chainSyncer)}
+
+// indexerCtx StartSync 的上下文，管理接口启动的回填任务随索引器一同停止
+var indexerCtx atomic.Value
+
+// registerSyncer 登记运行中的监听器
+func registerSyncer(s *chainSyncer) {
+	runningSyncers.Lock()
+	defer runningSyncers.Unlock()
+	runningSyncers.byId[s.chain.Id] = s
+}
+
+// unregisterSyncer 监听器退出后注销
+func unregisterSyncer(s *chainSyncer) {
+	runningSyncers.Lock()
+	defer runningSyncers.Unlock()
+	delete(runningSyncers.byId, s.chain.Id)
+}
+
+// lookupSyncer 查找运行中的监听器
+func lookupSyncer(id int64) (*chainSyncer, bool) {
+	runningSyncers.RLock()
+	defer runningSyncers.RUnlock()
+	s, ok := runningSyncers.byId[id]
+	return s, ok
+}
+
+// beginBatch 获取批次锁，监听已暂停时返回 false；暂停与重置游标需要等待进行中的批次完成
+// 已暂停时从数据库读取暂停状态，其他副本的管理接口恢复监听后能及时感知
+func (s *chainSyncer) beginBatch() bool {
+	s.batchMu.Lock()
+	if s.paused.Load() {
+		var chain model.Chain
+		if err := ctx.Ctx.DB.Select("paused").Where("id = ?", s.chain.Id).First(&chain).Error; err != nil || chain.Paused {
+			s.batchMu.Unlock()
+			return false
+		}
+		s.paused.Store(false)
+		log.Logger.Info("监听已恢复", zap.Int64("id", s.chain.Id))
+	}
+	return true
+}
+
+// endBatch 释放批次锁
+func (s *chainSyncer) endBatch() {
+	s.batchMu.Unlock()
+}
+
+// setHead 记录可安全索引高度
+func (s *chainSyncer) setHead(head uint64) {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	s.state.head = head
+}
+
+// updateLag 按最近读取的可安全索引高度更新落后区块数指标
+func (s *chainSyncer) updateLag(cursor uint64) {
+	s.state.mu.RLock()
+	head := s.state.head
+	s.state.mu.RUnlock()
+	var behind uint64
+	if head > cursor {
+		behind = head - cursor
+	}
+	metrics.BlocksBehind.WithLabelValues(strconv.Itoa(s.chainId), strings.ToLower(s.chain.Address), s.chain.ServiceType).Set(float64(behind))
+}
+
+// recordError 记录最近一次错误
+func (s *chainSyncer) recordError(err error) {
+	now := time.Now()
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	s.state.lastError = err.Error()
+	s.state.lastErrorAt = &now
+}
+
+// recordBatch 记录最近一次成功提交批次的时间
+func (s *chainSyncer) recordBatch() {
+	now := time.Now()
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	s.state.lastBatchAt = &now
+}
+
+// ListSyncerStatus 返回全部监听配置的游标、高度、延迟与最近错误，chainId 为 0 时不限链
+func ListSyncerStatus(chainId int) ([]SyncerStatus, error) {
+	var chains []model.Chain
+	query := ctx.Ctx.DB.Order("chain_id ASC, id ASC")
+	if chainId != 0 {
+		query = query.Where("chain_id = ?", chainId)
+	}
+	if err := query.Find(&chains).Error; err != nil {
+		return nil, err
+	}
+	// 多副本部署时只有持有租约的副本在运行监听器，其他副本通过租约表给出处理者
+	var leases []model.ChainLease
+	if err := ctx.Ctx.DB.Find(&leases).Error; err != nil {
+		return nil, err
+	}
+	holders := make(map[int64]string, len(leases))
+	for _, lease := range leases {
+		holders[lease.ChainId] = lease.Holder
+	}
+
+	statuses := make([]SyncerStatus, 0, len(chains))
+	for _, chain := range chains {
+		status := SyncerStatus{
+			Id:          chain.Id,
+			ChainId:     chain.ChainId,
+			ChainName:   chain.ChainName,
+			Address:     chain.Address,
+			ServiceType: chain.ServiceType,
+			Paused:      chain.Paused,
+			Cursor:      chain.LastBlockNum,
+			LeaseHolder: holders[chain.ChainId],
+		}
+		if s, ok := lookupSyncer(chain.Id); ok {
+			status.Running = true
+			status.Paused = s.paused.Load()
+			s.state.mu.RLock()
+			status.Head = s.state.head
+			status.LastError = s.state.lastError
+			status.LastErrorAt = s.state.lastErrorAt
+			status.LastBatchAt = s.state.lastBatchAt
+			s.state.mu.RUnlock()
+		}
+		if status.Head > status.Cursor {
+			status.Lag = status.Head - status.Cursor
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// SetChainPaused 暂停或恢复监听，id 不为 0 时只作用于该条监听配置，否则作用于链上全部监听配置
+// 暂停在数据库中持久化，重启后保持；返回前等待运行中监听器的当前批次完成
+func SetChainPaused(chainId int, id int64, paused bool) (int64, error) {
+	query := ctx.Ctx.DB.Model(&model.Chain{})
+	if id != 0 {
+		query = query.Where("id = ?", id)
+	} else if chainId != 0 {
+		query = query.Where("chain_id = ?", chainId)
+	} else {
+		return 0, errors.New("chainId 与 id 不能同时为空")
+	}
+	var ids []int64
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	if err := ctx.Ctx.DB.Model(&model.Chain{}).Where("id IN ?", ids).Update("paused", paused).Error; err != nil {
+		return 0, err
+	}
+
+	for _, rowId := range ids {
+		s, ok := lookupSyncer(rowId)
+		if !ok {
+			continue
+		}
+		s.paused.Store(paused)
+		// 取一次批次锁，确保返回时暂停前已开始的批次已经提交
+		s.batchMu.Lock()
+		s.batchMu.Unlock()
+	}
+	log.Logger.Info("监听状态已更新", zap.Int("chain_id", chainId), zap.Int64("id", id), zap.Bool("paused", paused))
+	return int64(len(ids)), nil
+}
+
+// ResetCursor 将监听配置的游标重置到指定区块
+// 回退时在同一事务中撤销该配置在 (blockNum, 当前游标] 内的派生数据并清理区块哈希，之后由监听器重新索引；
+// 前移时跳过的区块不会被索引，需要时通过回填补齐。运行中的监听器在当前批次完成后才会重置
+func ResetCursor(id int64, blockNum uint64) (*model.Chain, error) {
+	if s, ok := lookupSyncer(id); ok {
+		s.batchMu.Lock()
+		defer s.batchMu.Unlock()
+	}
+
+	var chain model.Chain
+	if err := ctx.Ctx.DB.Where("id = ?", id).First(&chain).Error; err != nil {
+		return nil, err
+	}
+	// 工厂配置需要带上已登记交易对的地址
+	addresses := []string{chain.Address}
+	if chain.ServiceType == serviceTypeFactory {
+		pairs, err := loadFactoryPairs(int(chain.ChainId), chain.Address)
+		if err != nil {
+			return nil, err
+		}
+		addresses = append(addresses, pairs...)
+	}
+
+	err := ctx.Ctx.DB.Transaction(func(tx *gorm.DB) error {
+		if blockNum < chain.LastBlockNum {
+			scope := rollbackScope{
+				ChainId:   int(chain.ChainId),
+				FromBlock: blockNum + 1,
+				ToBlock:   chain.LastBlockNum,
+				Addresses: addresses,
+			}
+			if err := rollbackRange(tx, scope); err != nil {
+				return err
+			}
+			if err := resolveDeadLettersInRange(tx, chain.Id, blockNum+1, math.MaxInt64); err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("chain_id = ? AND contract_address = ? AND block_number > ?", chain.ChainId, chain.Address, blockNum).
+			Delete(&model.BlockHash{}).Error; err != nil {
+			return err
+		}
+		return updateBlockNumber(tx, chain.Id, blockNum)
+	})
+	if err != nil {
+		log.Logger.Error("重置游标失败", zap.Int64("id", id), zap.Uint64("block_number", blockNum), zap.Error(err))
+		return nil, err
+	}
+	log.Logger.Info("游标已重置",
+		zap.Int64("id", id),
+		zap.Int64("chain_id", chain.ChainId),
+		zap.String("contract_address", chain.Address),
+		zap.Uint64("from", chain.LastBlockNum),
+		zap.Uint64("to", blockNum))
+	chain.LastBlockNum = blockNum
+	return &chain, nil
+}
+
+// BackfillJob 管理接口启动的回填任务
+type BackfillJob struct {
+	Id             int64           `json:"id"`
+	Options        BackfillOptions `json:"options"`
+	Status         string          `json:"status"`
+	TotalRanges    int             `json:"totalRanges"`
+	DoneRanges     int             `json:"doneRanges"`
+	CompletedBlock uint64          `json:"completedBlock"` // 已按顺序提交完成的最后区块
+	Error          string          `json:"error,omitempty"`
+	StartedAt      time.Time       `json:"startedAt"`
+	FinishedAt     *time.Time      `json:"finishedAt,omitempty"`
+}
+
+// backfillJobs 回填任务记录，只保存在内存中
+var backfillJobs = struct {
+	sync.RWMutex
+	nextId  int64
+	byId    map[int64]*BackfillJob
+	cancels map[int64]context.CancelFunc
+}{byId: make(map[int64]*BackfillJob), cancels: make(map[int64]context.CancelFunc)}
+
+// StartBackfillJob 在后台启动回填任务，立即返回任务快照；任务随索引器停止而取消
+func StartBackfillJob(opts BackfillOptions) (BackfillJob, error) {
+	if opts.DryRun {
+		return BackfillJob{}, errors.New("后台回填任务不支持 dry-run")
+	}
+	if opts.FromBlock > opts.ToBlock {
+		return BackfillJob{}, fmt.Errorf("起始区块 %d 大于结束区块 %d", opts.FromBlock, opts.ToBlock)
+	}
+	if _, err := findChainConfig(opts.ChainId, opts.Contract, opts.ServiceType); err != nil {
+		return BackfillJob{}, err
+	}
+
+	parent, ok := indexerCtx.Load().(context.Context)
+	if !ok {
+		parent = context.Background()
+	}
+	jobCtx, cancel := context.WithCancel(parent)
+
+	backfillJobs.Lock()
+	backfillJobs.nextId++
+	job := &BackfillJob{
+		Id:             backfillJobs.nextId,
+		Options:        opts,
+		Status:         BackfillRunning,
+		CompletedBlock: completedBlock(nil, 0, opts.FromBlock),
+		StartedAt:      time.Now(),
+	}
+	backfillJobs.byId[job.Id] = job
+	backfillJobs.cancels[job.Id] = cancel
+	snapshot := *job
+	backfillJobs.Unlock()
+
+	opts.Progress = func(done, total int, completed uint64) {
+		backfillJobs.Lock()
+		defer backfillJobs.Unlock()
+		job.DoneRanges, job.TotalRanges, job.CompletedBlock = done, total, completed
+	}
+	go func() {
+		defer cancel()
+		err := RunBackfill(jobCtx, opts)
+
+		backfillJobs.Lock()
+		defer backfillJobs.Unlock()
+		now := time.Now()
+		job.FinishedAt = &now
+		delete(backfillJobs.cancels, job.Id)
+		switch {
+		case err == nil:
+			job.Status = BackfillSucceeded
+		case jobCtx.Err() != nil:
+			job.Status = BackfillCancelled
+			job.Error = err.Error()
+		default:
+			job.Status = BackfillFailed
+			job.Error = err.Error()
+		}
+		log.Logger.Info("回填任务结束", zap.Int64("job_id", job.Id), zap.String("status", job.Status), zap.Error(err))
+	}()
+	log.Logger.Info("回填任务已启动",
+		zap.Int64("job_id", snapshot.Id),
+		zap.Int("chain_id", opts.ChainId),
+		zap.String("contract_address", opts.Contract),
+		zap.Uint64("from_block", opts.FromBlock),
+		zap.Uint64("to_block", opts.ToBlock))
+	return snapshot, nil
+}
+
+// GetBackfillJob 返回回填任务快照
+func GetBackfillJob(id int64) (BackfillJob, bool) {
+	backfillJobs.RLock()
+	defer backfillJobs.RUnlock()
+	job, ok := backfillJobs.byId[id]
+	if !ok {
+		return BackfillJob{}, false
+	}
+	return *job, true
+}
+
+// ListBackfillJobs 返回全部回填任务快照，按 id 倒序
+func ListBackfillJobs() []BackfillJob {
+	backfillJobs.RLock()
+	defer backfillJobs.RUnlock()
+	jobs := make([]BackfillJob, 0, len(backfillJobs.byId))
+	for _, job := range backfillJobs.byId {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Id > jobs[j].Id })
+	return jobs
+}
+
+// CancelBackfillJob 取消运行中的回填任务，已提交的区段保留
+func CancelBackfillJob(id int64) bool {
+	backfillJobs.RLock()
+	defer backfillJobs.RUnlock()
+	cancel, ok := backfillJobs.cancels[id]
+	if ok {
+		cancel()
+	}
+	return ok
+}
@@ -0,0 +1,258 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/chainclient/evm"
+	"github.com/mumu/cryptoSwap/src/core/ctx"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultDeadLetterRetryLimit 单次重试处理的死信条数上限
+const defaultDeadLetterRetryLimit = 100
+
+// DeadLetterRetryOptions 死信重试参数，Id 不为 0 时只重试该条
+type DeadLetterRetryOptions struct {
+	ChainId int
+	Id      int64
+	Decoder string
+	Limit   int
+}
+
+// DeadLetterRetryResult 死信重试结果
+type DeadLetterRetryResult struct {
+	Total    int              `json:"total"`
+	Resolved int              `json:"resolved"`
+	Failed   int              `json:"failed"`
+	Errors   map[int64]string `json:"errors,omitempty"` // 死信 id -> 本次失败原因
+}
+
+// newDeadLetter 由解码或处理失败的日志构造死信记录
+func (s *chainSyncer) newDeadLetter(vLog types.Log, decoder string, cause error, lc *evm.LogContext) *model.DeadLetterLog {
+	raw := newRawLogs(s.chainId, []types.Log{vLog}, lc)[0]
+	log.Logger.Error("事件处理失败，已写入死信队列",
+		zap.Int("chain_id", s.chainId),
+		zap.String("decoder", decoder),
+		zap.String("tx_hash", vLog.TxHash.Hex()),
+		zap.Uint("log_index", vLog.Index),
+		zap.Error(cause))
+	return &model.DeadLetterLog{
+		ChainId:        raw.ChainId,
+		ChainRowId:     s.chain.Id,
+		Decoder:        decoder,
+		Error:          cause.Error(),
+		Address:        raw.Address,
+		Topic0:         raw.Topic0,
+		Topic1:         raw.Topic1,
+		Topic2:         raw.Topic2,
+		Topic3:         raw.Topic3,
+		Data:           raw.Data,
+		BlockNumber:    raw.BlockNumber,
+		BlockHash:      raw.BlockHash,
+		BlockTimestamp: raw.BlockTimestamp,
+		TxHash:         raw.TxHash,
+		TxIndex:        raw.TxIndex,
+		TxSender:       raw.TxSender,
+		LogIndex:       raw.LogIndex,
+		Status:         model.DeadLetterPending,
+	}
+}
+
+// handleEvent 解码日志并交给处理函数，处理函数 panic 时转为错误，避免单条异常日志中断整条链的监听
+func (s *chainSyncer) handleEvent(d *eventDecoder, vLog types.Log, lc *evm.LogContext, batch *decodedBatch) (err error) {
+	fields, err := d.decode(vLog)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("处理函数 panic: %v", r)
+		}
+	}()
+	return d.handle(&decodedEvent{
+		Log:       vLog,
+		ChainId:   s.chainId,
+		Sender:    lc.Sender(vLog),
+		BlockTime: time.Unix(int64(lc.BlockTime(vLog)), 0),
+		Fields:    fields,
+	}, batch)
+}
+
+// saveDeadLetters 在批次事务中写入死信，同一日志再次失败时更新错误信息并重新置为待处理
+// 写库失败会使整个批次回滚并由监听循环重试，不会丢失日志
+func saveDeadLetters(tx *gorm.DB, deadLetters []*model.DeadLetterLog) error {
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "chain_row_id"}, {Name: "block_hash"}, {Name: "log_index"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"decoder":     gorm.Expr("EXCLUDED.decoder"),
+			"error":       gorm.Expr("EXCLUDED.error"),
+			"status":      model.DeadLetterPending,
+			"resolved_at": nil,
+			"updated_at":  time.Now(),
+		}),
+	}).CreateInBatches(deadLetters, 100).Error
+}
+
+// resolveDeadLettersInRange 回填/重建重新处理区段前，将区段内的死信标记为已处理；仍然失败的日志会在同一事务中重新写入
+func resolveDeadLettersInRange(tx *gorm.DB, chainRowId int64, fromBlock, toBlock uint64) error {
+	now := time.Now()
+	return tx.Model(&model.DeadLetterLog{}).
+		Where("chain_row_id = ? AND block_number BETWEEN ? AND ? AND status = ?", chainRowId, fromBlock, toBlock, model.DeadLetterPending).
+		Updates(map[string]interface{}{"status": model.DeadLetterResolved, "resolved_at": now, "updated_at": now}).Error
+}
+
+// deleteOrphanedDeadLetters 链重组时删除分叉点之后的死信，这些日志已不在主链上
+func deleteOrphanedDeadLetters(tx *gorm.DB, chainId int, forkBlock uint64) error {
+	return tx.Where("chain_id = ? AND block_number > ?", chainId, forkBlock).
+		Delete(&model.DeadLetterLog{}).Error
+}
+
+// RetryDeadLetters 用当前的解码器重新处理待处理的死信，成功的写入派生数据并标记为已处理，失败的记录本次错误
+// 每条死信在独立事务中提交；死信所在区块须已被实时监听处理过，否则实时监听之后还会再次写入
+func RetryDeadLetters(c context.Context, opts DeadLetterRetryOptions) (*DeadLetterRetryResult, error) {
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = defaultDeadLetterRetryLimit
+	}
+	query := ctx.Ctx.DB.Where("status = ?", model.DeadLetterPending)
+	if opts.Id != 0 {
+		query = query.Where("id = ?", opts.Id)
+	}
+	if opts.ChainId != 0 {
+		query = query.Where("chain_id = ?", opts.ChainId)
+	}
+	if opts.Decoder != "" {
+		query = query.Where("decoder = ?", opts.Decoder)
+	}
+	var deadLetters []*model.DeadLetterLog
+	if err := query.Order("chain_row_id ASC, block_number ASC, log_index ASC").Limit(limit).Find(&deadLetters).Error; err != nil {
+		return nil, err
+	}
+
+	result := &DeadLetterRetryResult{Total: len(deadLetters), Errors: make(map[int64]string)}
+	syncers := make(map[int64]*chainSyncer)
+	for _, dl := range deadLetters {
+		if c.Err() != nil {
+			return result, c.Err()
+		}
+		syncer, ok := syncers[dl.ChainRowId]
+		if !ok {
+			var err error
+			if syncer, err = retrySyncer(dl.ChainRowId); err != nil {
+				return result, err
+			}
+			syncers[dl.ChainRowId] = syncer
+		}
+
+		err := syncer.retryDeadLetter(dl)
+		if err == nil {
+			result.Resolved++
+			log.Logger.Info("死信重试成功", zap.Int64("id", dl.Id), zap.String("decoder", dl.Decoder))
+			continue
+		}
+		result.Failed++
+		result.Errors[dl.Id] = err.Error()
+		log.Logger.Warn("死信重试失败", zap.Int64("id", dl.Id), zap.String("decoder", dl.Decoder), zap.Error(err))
+		now := time.Now()
+		if updateErr := ctx.Ctx.DB.Model(&model.DeadLetterLog{}).Where("id = ?", dl.Id).Updates(map[string]interface{}{
+			"error":           err.Error(),
+			"attempts":        gorm.Expr("attempts + 1"),
+			"last_attempt_at": now,
+			"updated_at":      now,
+		}).Error; updateErr != nil {
+			return result, updateErr
+		}
+	}
+	return result, nil
+}
+
+// retrySyncer 为死信所属的监听配置构造监听器，链客户端已初始化时使用在线监听器以便补全交易对元数据
+func retrySyncer(chainRowId int64) (*chainSyncer, error) {
+	var chain model.Chain
+	if err := ctx.Ctx.DB.Where("id = ?", chainRowId).First(&chain).Error; err != nil {
+		return nil, fmt.Errorf("未找到监听配置 %d: %w", chainRowId, err)
+	}
+	if _, ok := ctx.Ctx.ChainMap[int(chain.ChainId)]; ok {
+		if syncer := newChainSyncer(chain); syncer != nil {
+			return syncer, nil
+		}
+	}
+	return newOfflineSyncer(chain)
+}
+
+// retryDeadLetter 重新解码处理一条死信，在同一事务中写入派生数据并标记为已处理
+func (s *chainSyncer) retryDeadLetter(dl *model.DeadLetterLog) error {
+	vLog, lc := deadLetterToLog(dl)
+	if uint64(dl.BlockNumber) > s.chain.LastBlockNum {
+		return fmt.Errorf("区块 %d 尚未被实时监听处理", dl.BlockNumber)
+	}
+	if len(vLog.Topics) == 0 {
+		return fmt.Errorf("日志缺少 topic0")
+	}
+	d, ok := s.decoders[vLog.Topics[0]]
+	if !ok {
+		return fmt.Errorf("监听配置未注册 topic0 %s 的解码器", vLog.Topics[0].Hex())
+	}
+
+	block := vLog.BlockNumber
+	batch := &decodedBatch{FromBlock: block, ToBlock: block, Logs: []types.Log{vLog}, FromArchive: true, Offline: s.evmClient == nil, EvmClient: s.evmClient}
+	if d.name == pairCreatedDecoder {
+		pair, err := parsePairCreatedEvent(d, vLog)
+		if err != nil {
+			return err
+		}
+		if s.evmClient != nil {
+			fillTokenMetadata(s.evmClient, pair)
+		}
+		batch.Pairs = append(batch.Pairs, pair)
+	} else if err := s.handleEvent(d, vLog, lc, batch); err != nil {
+		return err
+	}
+
+	return ctx.Ctx.DB.Transaction(func(tx *gorm.DB) error {
+		if err := saveBatch(tx, batch, s.chainId); err != nil {
+			return err
+		}
+		now := time.Now()
+		return tx.Model(&model.DeadLetterLog{}).Where("id = ?", dl.Id).Updates(map[string]interface{}{
+			"status":          model.DeadLetterResolved,
+			"attempts":        gorm.Expr("attempts + 1"),
+			"last_attempt_at": now,
+			"resolved_at":     now,
+			"updated_at":      now,
+		}).Error
+	})
+}
+
+// deadLetterToLog 由死信还原日志及其发送者与区块时间
+func deadLetterToLog(dl *model.DeadLetterLog) (types.Log, *evm.LogContext) {
+	lc := &evm.LogContext{
+		Senders:    make(map[common.Hash]common.Address),
+		Timestamps: make(map[common.Hash]uint64),
+	}
+	vLog := rawLogToLog(&model.RawLog{
+		ChainId:        dl.ChainId,
+		Address:        dl.Address,
+		Topic0:         dl.Topic0,
+		Topic1:         dl.Topic1,
+		Topic2:         dl.Topic2,
+		Topic3:         dl.Topic3,
+		Data:           dl.Data,
+		BlockNumber:    dl.BlockNumber,
+		BlockHash:      dl.BlockHash,
+		BlockTimestamp: dl.BlockTimestamp,
+		TxHash:         dl.TxHash,
+		TxIndex:        dl.TxIndex,
+		TxSender:       dl.TxSender,
+		LogIndex:       dl.LogIndex,
+	}, lc)
+	return vLog, lc
+}
@@ -0,0 +1,226 @@
+package sync
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	appabi "github.com/mumu/cryptoSwap/src/abi"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"go.uber.org/zap"
+)
+
+// pairCreatedDecoder PairCreated 在 expandNewPairs 中先于其他事件处理，不注册处理函数
+const pairCreatedDecoder = appabi.ABIUniswapV2Factory + ".PairCreated"
+
+// eventHandler 处理一条按 ABI 解码后的事件，将结果追加到批次；返回错误时该日志写入死信
+type eventHandler func(e *decodedEvent, batch *decodedBatch) error
+
+// eventDecoder 一个 ABI 事件及其处理函数
+type eventDecoder struct {
+	name   string
+	event  abi.Event
+	handle eventHandler // 为 nil 时只参与日志过滤，不在 decodeRange 中处理
+}
+
+// decodedEvent 按 ABI 解码后的事件，Fields 以参数名为键，包含索引与非索引参数
+type decodedEvent struct {
+	Log       types.Log
+	ChainId   int
+	Sender    string    // 交易发送者（真实用户地址）
+	BlockTime time.Time // 区块时间
+	Fields    map[string]interface{}
+}
+
+// decoderRegistry 事件解码器注册表，按 topic0 查找解码器，按服务类型汇总监听的事件
+type decoderRegistry struct {
+	decoders map[common.Hash]*eventDecoder
+	services map[string][]common.Hash
+}
+
+var (
+	registry     *decoderRegistry
+	registryOnce sync.Once
+)
+
+// getDecoderRegistry 返回事件解码器注册表，首次调用时从 ABIManager 构建，需在 ABI 加载完成后调用
+// 新增事件只需在此注册处理函数，监听过滤条件会自动包含该事件
+func getDecoderRegistry() *decoderRegistry {
+	registryOnce.Do(func() {
+		r := &decoderRegistry{
+			decoders: make(map[common.Hash]*eventDecoder),
+			services: make(map[string][]common.Hash),
+		}
+
+		// 质押池：线上合约的 Staked/Withdrawn 带 indexed tokenAddress，与 StakeV2 ABI 中的定义不同，单独构造
+		r.registerEvent(serviceTypeStaking, "Stake.Staked", newEvent("Staked",
+			eventArg("user", "address", true),
+			eventArg("poolId", "uint256", true),
+			eventArg("tokenAddress", "address", true),
+			eventArg("amount", "uint256", false),
+			eventArg("stakedAt", "uint256", false),
+			eventArg("unlockTime", "uint256", false),
+		), handleStaked)
+		r.registerEvent(serviceTypeStaking, "Stake.Withdrawn", newEvent("Withdrawn",
+			eventArg("user", "address", true),
+			eventArg("poolId", "uint256", true),
+			eventArg("tokenAddress", "address", true),
+			eventArg("amount", "uint256", false),
+			eventArg("withdrawnAt", "uint256", false),
+		), handleWithdrawn)
+		r.register(serviceTypeStaking, appabi.STAKEV2, "ClaimRewards", handleClaimRewards)
+		r.register(serviceTypeStaking, appabi.STAKEV2, "PoolCreated", handlePoolCreated)
+		r.register(serviceTypeStaking, appabi.STAKEV2, "SharePriceUpdated", handleSharePriceUpdated)
+		r.register(serviceTypeStaking, appabi.STAKEV2, "Paused", handlePaused)
+		r.register(serviceTypeStaking, appabi.STAKEV2, "Unpaused", handleUnpaused)
+
+		// 流动性池：单独配置的交易对与工厂发现的交易对使用同一组解码器
+		for _, service := range []string{serviceTypeLiquidity, serviceTypeFactory} {
+			// Sync 在同一交易中先于 Swap/Mint/Burn 发出，事件记录的储备量取自该 Sync
+			r.register(service, appabi.ABIUniswapV2Pair, "Sync", handleSync)
+			r.register(service, appabi.ABIUniswapV2Pair, "Swap", handleSwap)
+			r.register(service, appabi.ABIUniswapV2Pair, "Mint", handleMint)
+			r.register(service, appabi.ABIUniswapV2Pair, "Burn", handleBurn)
+			r.register(service, appabi.ABIUniswapV2Pair, "Transfer", handleLPTransfer)
+		}
+		r.register(serviceTypeFactory, appabi.ABIUniswapV2Factory, "PairCreated", nil)
+
+		// 空投
+		r.register(serviceTypeAirdrop, appabi.ABIMerkleAirdrop, "RewardClaimed", handleRewardClaimed)
+		r.register(serviceTypeAirdrop, appabi.ABIMerkleAirdrop, "UpdateTotalRewardUpdated", handleTotalRewardUpdated)
+		r.register(serviceTypeAirdrop, appabi.ABIMerkleAirdrop, "AirdropCreated", handleAirdropCreated)
+		r.register(serviceTypeAirdrop, appabi.ABIMerkleAirdrop, "AirdropActivated", handleAirdropActivated)
+
+		registry = r
+	})
+	return registry
+}
+
+// register 注册 ABIManager 中已加载 ABI 的事件，ABI 或事件不存在时跳过
+func (r *decoderRegistry) register(service, abiName, eventName string, handle eventHandler) {
+	contractABI, ok := appabi.GetABIManager().GetABI(abiName)
+	if !ok {
+		log.Logger.Error("ABI 未加载，跳过事件注册", zap.String("abi", abiName), zap.String("event", eventName))
+		return
+	}
+	event, ok := contractABI.Events[eventName]
+	if !ok {
+		log.Logger.Error("ABI 中未找到事件定义，跳过事件注册", zap.String("abi", abiName), zap.String("event", eventName))
+		return
+	}
+	r.registerEvent(service, abiName+"."+eventName, event, handle)
+}
+
+// registerEvent 注册事件解码器，同一事件可以属于多个服务类型
+func (r *decoderRegistry) registerEvent(service, name string, event abi.Event, handle eventHandler) {
+	if _, exists := r.decoders[event.ID]; !exists {
+		r.decoders[event.ID] = &eventDecoder{name: name, event: event, handle: handle}
+	}
+	r.services[service] = append(r.services[service], event.ID)
+}
+
+// forService 返回服务类型对应的解码器，未配置 service_type 的旧记录解码除工厂外的全部事件
+func (r *decoderRegistry) forService(serviceType string) (map[common.Hash]*eventDecoder, bool) {
+	var services []string
+	if serviceType == "" {
+		for service := range r.services {
+			// 工厂监听需要维护交易对地址列表，必须显式配置
+			if service != serviceTypeFactory {
+				services = append(services, service)
+			}
+		}
+	} else if _, ok := r.services[serviceType]; ok {
+		services = []string{serviceType}
+	} else {
+		return nil, false
+	}
+
+	decoders := make(map[common.Hash]*eventDecoder)
+	for _, service := range services {
+		for _, id := range r.services[service] {
+			decoders[id] = r.decoders[id]
+		}
+	}
+	return decoders, true
+}
+
+// topicFilter 由解码器构建 FilterQuery.Topics，只拉取已注册的事件
+func topicFilter(decoders map[common.Hash]*eventDecoder) [][]common.Hash {
+	ids := make([]common.Hash, 0, len(decoders))
+	for id := range decoders {
+		ids = append(ids, id)
+	}
+	return [][]common.Hash{ids}
+}
+
+// decode 按 ABI 解码日志的索引与非索引参数
+func (d *eventDecoder) decode(vLog types.Log) (map[string]interface{}, error) {
+	fields := make(map[string]interface{})
+	if nonIndexed := d.event.Inputs.NonIndexed(); len(nonIndexed) > 0 {
+		if err := nonIndexed.UnpackIntoMap(fields, vLog.Data); err != nil {
+			return nil, err
+		}
+	}
+	var indexed abi.Arguments
+	for _, arg := range d.event.Inputs {
+		if arg.Indexed {
+			indexed = append(indexed, arg)
+		}
+	}
+	if len(vLog.Topics) != len(indexed)+1 {
+		return nil, fmt.Errorf("索引参数数量不匹配: 期望 %d, 实际 %d", len(indexed), len(vLog.Topics)-1)
+	}
+	if err := abi.ParseTopicsIntoMap(fields, indexed, vLog.Topics[1:]); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
+
+// bigInt 读取整数参数，缺失时返回 0
+func (e *decodedEvent) bigInt(name string) *big.Int {
+	if v, ok := e.Fields[name].(*big.Int); ok && v != nil {
+		return v
+	}
+	return new(big.Int)
+}
+
+// address 读取地址参数，缺失时返回空字符串
+func (e *decodedEvent) address(name string) string {
+	if v, ok := e.Fields[name].(common.Address); ok {
+		return v.Hex()
+	}
+	return ""
+}
+
+// str 读取字符串参数
+func (e *decodedEvent) str(name string) string {
+	v, _ := e.Fields[name].(string)
+	return v
+}
+
+// bytes32Hex 读取 bytes32 参数并转为 0x 开头的十六进制字符串
+func (e *decodedEvent) bytes32Hex(name string) string {
+	if v, ok := e.Fields[name].([32]byte); ok {
+		return "0x" + hex.EncodeToString(v[:])
+	}
+	return ""
+}
+
+// newEvent 构造 ABI 文件之外的事件定义
+func newEvent(name string, inputs ...abi.Argument) abi.Event {
+	return abi.NewEvent(name, name, false, inputs)
+}
+
+// eventArg 构造事件参数，类型为合法的 Solidity 基础类型
+func eventArg(name, solidityType string, indexed bool) abi.Argument {
+	t, err := abi.NewType(solidityType, "", nil)
+	if err != nil {
+		panic(fmt.Sprintf("非法的事件参数类型 %s: %v", solidityType, err))
+	}
+	return abi.Argument{Name: name, Type: t, Indexed: indexed}
+}
@@ -0,0 +1,399 @@
+package sync
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	appabi "github.com/mumu/cryptoSwap/src/abi"
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/chainclient/evm"
+	"github.com/mumu/cryptoSwap/src/core/ctx"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 代币未实现 decimals() 时按 18 位处理，与 liquidity_pools 表默认值一致
+const defaultTokenDecimals = 18
+
+// maxTokenSymbolLength liquidity_pools.token*_symbol 为 VARCHAR(20)，按字符截断
+const maxTokenSymbolLength = 20
+
+// discoveredPair 工厂合约创建的交易对
+type discoveredPair struct {
+	FactoryAddress string
+	PairAddress    string
+	Token0Address  string
+	Token1Address  string
+	Token0Symbol   string
+	Token1Symbol   string
+	Token0Decimals int
+	Token1Decimals int
+	// decimals 是否读取成功：失败时新登记的交易对按默认精度写入，已登记的交易对保留原值
+	Token0DecimalsOK bool
+	Token1DecimalsOK bool
+	CreatedBlock     uint64
+	Reserve0         *big.Int // 通过 allPairs 补登记时从链上读取，PairCreated 发现的交易对初始储备量为 0
+	Reserve1         *big.Int
+	TotalSupply      *big.Int
+}
+
+// parsePairCreatedEvent 解析 PairCreated(address indexed token0, address indexed token1, address pair, uint256)
+func parsePairCreatedEvent(d *eventDecoder, vLog types.Log) (*discoveredPair, error) {
+	fields, err := d.decode(vLog)
+	if err != nil {
+		return nil, err
+	}
+	e := &decodedEvent{Log: vLog, Fields: fields}
+	return &discoveredPair{
+		FactoryAddress: vLog.Address.Hex(),
+		PairAddress:    e.address("pair"),
+		Token0Address:  e.address("token0"),
+		Token1Address:  e.address("token1"),
+		CreatedBlock:   vLog.BlockNumber,
+	}, nil
+}
+
+// fillTokenMetadata 从代币合约读取 symbol 与 decimals
+func fillTokenMetadata(evmClient *evm.Evm, pair *discoveredPair) {
+	pair.Token0Symbol, pair.Token0Decimals, pair.Token0DecimalsOK = getTokenMetadata(evmClient, pair.Token0Address)
+	pair.Token1Symbol, pair.Token1Decimals, pair.Token1DecimalsOK = getTokenMetadata(evmClient, pair.Token1Address)
+}
+
+// getTokenMetadata 读取 ERC20 代币的 symbol 与 decimals，读取失败时返回空 symbol 与默认精度，第三个返回值表示 decimals 是否读取成功
+func getTokenMetadata(evmClient *evm.Evm, tokenAddress string) (string, int, bool) {
+	erc20ABI, ok := appabi.GetABIManager().GetABI(appabi.ABIERC20)
+	if !ok {
+		log.Logger.Warn("ERC20 ABI 未加载，跳过代币元数据读取", zap.String("token", tokenAddress))
+		return "", defaultTokenDecimals, false
+	}
+
+	symbol := ""
+	if data, err := erc20ABI.Pack("symbol"); err == nil {
+		if out, err := evmClient.CallContract(tokenAddress, data); err == nil {
+			if values, err := erc20ABI.Unpack("symbol", out); err == nil && len(values) > 0 {
+				symbol, _ = values[0].(string)
+			} else {
+				// 部分早期代币的 symbol 返回 bytes32
+				symbol = string(common.TrimRightZeroes(out))
+			}
+		} else {
+			log.Logger.Warn("读取代币 symbol 失败", zap.String("token", tokenAddress), zap.Error(err))
+		}
+	}
+	symbol = sanitizeTokenSymbol(symbol)
+
+	decimals, decimalsOK := defaultTokenDecimals, false
+	if data, err := erc20ABI.Pack("decimals"); err == nil {
+		if out, err := evmClient.CallContract(tokenAddress, data); err == nil {
+			if values, err := erc20ABI.Unpack("decimals", out); err == nil && len(values) > 0 {
+				if d, ok := values[0].(uint8); ok {
+					decimals, decimalsOK = int(d), true
+				}
+			}
+		} else {
+			log.Logger.Warn("读取代币 decimals 失败", zap.String("token", tokenAddress), zap.Error(err))
+		}
+	}
+	return symbol, decimals, decimalsOK
+}
+
+// sanitizeTokenSymbol 清理合约返回的 symbol：PostgreSQL 文本不接受 NUL 与非法 UTF-8，
+// 写入失败会让整个批次反复重试；去掉非法字节与 NUL 后按字符截断到列宽
+func sanitizeTokenSymbol(symbol string) string {
+	symbol = strings.ToValidUTF8(symbol, "")
+	symbol = strings.ReplaceAll(symbol, "\x00", "")
+	symbol = strings.TrimSpace(symbol)
+	if runes := []rune(symbol); len(runes) > maxTokenSymbolLength {
+		symbol = string(runes[:maxTokenSymbolLength])
+	}
+	return symbol
+}
+
+// loadFactoryPairs 读取已登记的该工厂合约的全部交易对地址
+func loadFactoryPairs(chainId int, factoryAddress string) ([]string, error) {
+	var pairs []string
+	err := ctx.Ctx.DB.Model(&model.LiquidityPool{}).
+		Where("chain_id = ? AND LOWER(factory_address) = LOWER(?)", chainId, factoryAddress).
+		Order("id ASC").
+		Pluck("pool_address", &pairs).Error
+	return pairs, err
+}
+
+// savePairs 登记交易对到 liquidity_pools，已存在的池子只补全工厂与代币信息；
+// 本次未读到的 symbol 与 decimals 保留原值，避免一次 RPC 失败把已登记的精度改回默认值；
+// 储备量与总供应量仅在池子尚未由 Sync 维护时用链上读取的值补齐，不覆盖已同步的储备量
+func savePairs(tx *gorm.DB, chainId int, pairs []*discoveredPair) error {
+	for _, p := range pairs {
+		// 通过 allPairs 补登记的交易对不知道创建区块，记为 NULL
+		var createdBlock interface{}
+		if p.CreatedBlock > 0 {
+			createdBlock = p.CreatedBlock
+		}
+		reserve0, reserve1, totalSupply := bigOrZero(p.Reserve0), bigOrZero(p.Reserve1), bigOrZero(p.TotalSupply)
+		decimals0, decimals1 := p.Token0Decimals, p.Token1Decimals
+		if !p.Token0DecimalsOK {
+			decimals0 = defaultTokenDecimals
+		}
+		if !p.Token1DecimalsOK {
+			decimals1 = defaultTokenDecimals
+		}
+		if err := tx.Exec(`
+			INSERT INTO liquidity_pools (
+				chain_id, pool_address, factory_address, token0_address, token1_address,
+				token0_symbol, token1_symbol, token0_decimals, token1_decimals, created_block, last_block_num,
+				reserve0, reserve1, total_supply, price, is_active
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, TRUE)
+			ON CONFLICT (chain_id, pool_address) DO UPDATE SET
+				factory_address = EXCLUDED.factory_address,
+				token0_address = EXCLUDED.token0_address,
+				token1_address = EXCLUDED.token1_address,
+				token0_symbol = COALESCE(NULLIF(EXCLUDED.token0_symbol, ''), liquidity_pools.token0_symbol),
+				token1_symbol = COALESCE(NULLIF(EXCLUDED.token1_symbol, ''), liquidity_pools.token1_symbol),
+				token0_decimals = CASE WHEN ? THEN EXCLUDED.token0_decimals ELSE liquidity_pools.token0_decimals END,
+				token1_decimals = CASE WHEN ? THEN EXCLUDED.token1_decimals ELSE liquidity_pools.token1_decimals END,
+				created_block = COALESCE(liquidity_pools.created_block, EXCLUDED.created_block),
+				reserve0 = CASE WHEN liquidity_pools.reserve_block_num IS NULL AND EXCLUDED.total_supply > 0
+					THEN EXCLUDED.reserve0 ELSE liquidity_pools.reserve0 END,
+				reserve1 = CASE WHEN liquidity_pools.reserve_block_num IS NULL AND EXCLUDED.total_supply > 0
+					THEN EXCLUDED.reserve1 ELSE liquidity_pools.reserve1 END,
+				price = CASE WHEN liquidity_pools.reserve_block_num IS NULL AND EXCLUDED.total_supply > 0
+					THEN EXCLUDED.price ELSE liquidity_pools.price END,
+				total_supply = CASE WHEN liquidity_pools.reserve_block_num IS NULL AND EXCLUDED.total_supply > 0
+					THEN EXCLUDED.total_supply ELSE liquidity_pools.total_supply END,
+				updated_at = NOW()
+		`, chainId, p.PairAddress, p.FactoryAddress, p.Token0Address, p.Token1Address,
+			p.Token0Symbol, p.Token1Symbol, decimals0, decimals1, createdBlock, p.CreatedBlock,
+			reserve0.String(), reserve1.String(), totalSupply.String(), calculatePrice(reserve0, reserve1),
+			p.Token0DecimalsOK, p.Token1DecimalsOK).Error; err != nil {
+			log.Logger.Error("登记交易对失败", zap.String("pair", p.PairAddress), zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+// backfillFactoryPairs 通过 allPairsLength/allPairs 补登记工厂合约已有的交易对
+// 交易对列表只追加不修改，已登记数量与链上数量一致时直接跳过；否则从已登记的前缀之后开始逐个读取
+func backfillFactoryPairs(evmClient *evm.Evm, chainId int, factoryAddress string) error {
+	factoryABI, ok := appabi.GetABIManager().GetABI(appabi.ABIUniswapV2Factory)
+	if !ok {
+		return fmt.Errorf("获取ABI失败: %s 未加载", appabi.ABIUniswapV2Factory)
+	}
+	pairABI, ok := appabi.GetABIManager().GetABI(appabi.ABIUniswapV2Pair)
+	if !ok {
+		return fmt.Errorf("获取ABI失败: %s 未加载", appabi.ABIUniswapV2Pair)
+	}
+
+	data, err := factoryABI.Pack("allPairsLength")
+	if err != nil {
+		return err
+	}
+	out, err := evmClient.CallContract(factoryAddress, data)
+	if err != nil {
+		return err
+	}
+	values, err := factoryABI.Unpack("allPairsLength", out)
+	if err != nil || len(values) == 0 {
+		return fmt.Errorf("解析 allPairsLength 失败: %v", err)
+	}
+	total, _ := values[0].(*big.Int)
+	if total == nil {
+		return fmt.Errorf("解析 allPairsLength 失败")
+	}
+
+	known, err := loadFactoryPairs(chainId, factoryAddress)
+	if err != nil {
+		return err
+	}
+	if uint64(len(known)) >= total.Uint64() {
+		return nil
+	}
+	knownSet := make(map[string]bool, len(known))
+	for _, p := range known {
+		knownSet[strings.ToLower(p)] = true
+	}
+
+	pairAt := func(i uint64) (common.Address, error) {
+		data, err := factoryABI.Pack("allPairs", new(big.Int).SetUint64(i))
+		if err != nil {
+			return common.Address{}, err
+		}
+		out, err := evmClient.CallContract(factoryAddress, data)
+		if err != nil {
+			return common.Address{}, err
+		}
+		values, err := factoryABI.Unpack("allPairs", out)
+		if err != nil || len(values) == 0 {
+			return common.Address{}, fmt.Errorf("解析 allPairs(%d) 失败: %v", i, err)
+		}
+		pairAddress, _ := values[0].(common.Address)
+		return pairAddress, nil
+	}
+	start, err := knownPairsPrefix(known, pairAt)
+	if err != nil {
+		return err
+	}
+
+	log.Logger.Info("开始补登记工厂交易对",
+		zap.Int("chain_id", chainId),
+		zap.String("factory", factoryAddress),
+		zap.Int("known", len(known)),
+		zap.Uint64("start", start),
+		zap.Uint64("total", total.Uint64()))
+
+	var pending []*discoveredPair
+	for i := start; i < total.Uint64(); i++ {
+		pairAddress, err := pairAt(i)
+		if err != nil {
+			return err
+		}
+		if knownSet[strings.ToLower(pairAddress.Hex())] {
+			continue
+		}
+
+		pair := &discoveredPair{FactoryAddress: factoryAddress, PairAddress: pairAddress.Hex()}
+		if pair.Token0Address, err = callAddress(evmClient, pairABI, pair.PairAddress, "token0"); err != nil {
+			return err
+		}
+		if pair.Token1Address, err = callAddress(evmClient, pairABI, pair.PairAddress, "token1"); err != nil {
+			return err
+		}
+		fillTokenMetadata(evmClient, pair)
+		// 已有交易对的历史 Sync 不会被重新索引，储备量从链上读取一次，之后由 Sync 事件维护
+		if pair.Reserve0, pair.Reserve1, pair.TotalSupply, err = getPairReserves(evmClient, pairABI, pair.PairAddress); err != nil {
+			log.Logger.Warn("读取交易对储备量失败", zap.String("pair", pair.PairAddress), zap.Error(err))
+		}
+		pending = append(pending, pair)
+
+		// 分批落库，中途失败时已登记的部分下次启动会跳过
+		if len(pending) >= 100 {
+			if err := savePairs(ctx.Ctx.DB, chainId, pending); err != nil {
+				return err
+			}
+			pending = pending[:0]
+		}
+	}
+	if len(pending) > 0 {
+		if err := savePairs(ctx.Ctx.DB, chainId, pending); err != nil {
+			return err
+		}
+	}
+	log.Logger.Info("工厂交易对补登记完成", zap.Int("chain_id", chainId), zap.String("factory", factoryAddress))
+	return nil
+}
+
+// knownPairsPrefix 已登记交易对（按登记顺序）中与 allPairs 序号一一对应的前缀长度，补登记从该序号开始
+// 补登记按序号写入、PairCreated 按创建顺序写入，前缀之后的已登记交易对序号都大于其位置，按位置二分查找只需 O(log n) 次调用
+func knownPairsPrefix(known []string, pairAt func(uint64) (common.Address, error)) (uint64, error) {
+	var searchErr error
+	prefix := sort.Search(len(known), func(i int) bool {
+		if searchErr != nil {
+			return true
+		}
+		pairAddress, err := pairAt(uint64(i))
+		if err != nil {
+			searchErr = err
+			return true
+		}
+		return !strings.EqualFold(pairAddress.Hex(), known[i])
+	})
+	if searchErr != nil {
+		return 0, searchErr
+	}
+	return uint64(prefix), nil
+}
+
+// syncFactoryFeeTo 读取工厂合约当前的 feeTo 并登记到 dex_factories，手续费率与协议分成比例保留已有配置
+// UniswapV2 修改 feeTo 不发出事件，索引器每次启动工厂监听时刷新一次
+func syncFactoryFeeTo(evmClient *evm.Evm, chainId int, factoryAddress string) error {
+	factoryABI, ok := appabi.GetABIManager().GetABI(appabi.ABIUniswapV2Factory)
+	if !ok {
+		return fmt.Errorf("获取ABI失败: %s 未加载", appabi.ABIUniswapV2Factory)
+	}
+	feeTo, err := callAddress(evmClient, factoryABI, factoryAddress, "feeTo")
+	if err != nil {
+		return err
+	}
+	feeTo = strings.ToLower(feeTo)
+	if err := ctx.Ctx.DB.Exec(`
+		INSERT INTO dex_factories (chain_id, factory_address, fee_to, fee_to_checked_at, created_at, updated_at)
+		VALUES (?, ?, ?, NOW(), NOW(), NOW())
+		ON CONFLICT (chain_id, factory_address) DO UPDATE SET
+			fee_to = EXCLUDED.fee_to,
+			fee_to_checked_at = NOW(),
+			updated_at = NOW()
+	`, chainId, strings.ToLower(factoryAddress), feeTo).Error; err != nil {
+		return err
+	}
+	log.Logger.Info("工厂 feeTo 已刷新",
+		zap.Int("chain_id", chainId),
+		zap.String("factory", factoryAddress),
+		zap.String("fee_to", feeTo),
+		zap.Bool("protocol_fee_on", feeTo != zeroAddress))
+	return nil
+}
+
+// getPairReserves 读取交易对当前的储备量与 LP 总供应量
+func getPairReserves(evmClient *evm.Evm, pairABI abi.ABI, pairAddress string) (*big.Int, *big.Int, *big.Int, error) {
+	data, err := pairABI.Pack("getReserves")
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	out, err := evmClient.CallContract(pairAddress, data)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var reserves struct {
+		Reserve0           *big.Int
+		Reserve1           *big.Int
+		BlockTimestampLast uint32
+	}
+	if err := pairABI.UnpackIntoInterface(&reserves, "getReserves", out); err != nil {
+		return nil, nil, nil, err
+	}
+
+	if data, err = pairABI.Pack("totalSupply"); err != nil {
+		return nil, nil, nil, err
+	}
+	if out, err = evmClient.CallContract(pairAddress, data); err != nil {
+		return nil, nil, nil, err
+	}
+	values, err := pairABI.Unpack("totalSupply", out)
+	if err != nil || len(values) == 0 {
+		return nil, nil, nil, fmt.Errorf("解析 totalSupply 失败: %v", err)
+	}
+	totalSupply, _ := values[0].(*big.Int)
+	return reserves.Reserve0, reserves.Reserve1, totalSupply, nil
+}
+
+// bigOrZero 为 nil 时返回 0
+func bigOrZero(v *big.Int) *big.Int {
+	if v == nil {
+		return new(big.Int)
+	}
+	return v
+}
+
+// callAddress 调用返回 address 的无参只读方法
+func callAddress(evmClient *evm.Evm, contractABI abi.ABI, contractAddress, method string) (string, error) {
+	data, err := contractABI.Pack(method)
+	if err != nil {
+		return "", err
+	}
+	out, err := evmClient.CallContract(contractAddress, data)
+	if err != nil {
+		return "", err
+	}
+	values, err := contractABI.Unpack(method, out)
+	if err != nil || len(values) == 0 {
+		return "", fmt.Errorf("解析 %s 失败: %v", method, err)
+	}
+	address, _ := values[0].(common.Address)
+	return address.Hex(), nil
+}
@@ -0,0 +1,98 @@
+package sync
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+func TestSanitizeTokenSymbol(t *testing.T) {
+	tests := []struct {
+		name   string
+		symbol string
+		want   string
+	}{
+		{"plain", "USDC", "USDC"},
+		{"bytes32 padding", "MKR\x00\x00\x00", "MKR"},
+		{"interior nul", "MK\x00R", "MKR"},
+		{"invalid utf8", "A\xff\xfeB", "AB"},
+		{"surrounding spaces", "  WETH ", "WETH"},
+		{"truncated by rune", "代币代币代币代币代币代币代币代币代币代币代币", "代币代币代币代币代币代币代币代币代币代币"},
+		{"long ascii", "ABCDEFGHIJKLMNOPQRSTUVWXYZ", "ABCDEFGHIJKLMNOPQRST"},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := sanitizeTokenSymbol(tt.symbol)
+			if got != tt.want {
+				t.Errorf("sanitizeTokenSymbol(%q) = %q, want %q", tt.symbol, got, tt.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("sanitizeTokenSymbol(%q) returned invalid UTF-8", tt.symbol)
+			}
+		})
+	}
+}
+
+func TestKnownPairsPrefix(t *testing.T) {
+	// 链上 allPairs(i) 依次为 1000 个不同的交易对地址
+	onChain := make([]common.Address, 1000)
+	for i := range onChain {
+		onChain[i] = common.BigToAddress(big.NewInt(int64(0xa000 + i)))
+	}
+	known := func(indexes ...int) []string {
+		pairs := make([]string, 0, len(indexes))
+		for _, i := range indexes {
+			pairs = append(pairs, onChain[i].Hex())
+		}
+		return pairs
+	}
+	span := func(from, to int) []int {
+		var indexes []int
+		for i := from; i < to; i++ {
+			indexes = append(indexes, i)
+		}
+		return indexes
+	}
+
+	tests := []struct {
+		name     string
+		known    []string
+		want     uint64
+		maxCalls int
+	}{
+		{"nothing registered", nil, 0, 0},
+		{"fully backfilled", known(span(0, 1000)...), 1000, 10},
+		{"interrupted backfill", known(span(0, 400)...), 400, 9},
+		{"backfill prefix then newer PairCreated", known(append(span(0, 300), 990, 991, 992)...), 300, 9},
+		{"indexer started mid-history", known(span(600, 1000)...), 0, 9},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := 0
+			got, err := knownPairsPrefix(tt.known, func(i uint64) (common.Address, error) {
+				calls++
+				return onChain[i], nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("knownPairsPrefix() = %d, want %d", got, tt.want)
+			}
+			if calls > tt.maxCalls {
+				t.Errorf("allPairs called %d times, want at most %d", calls, tt.maxCalls)
+			}
+		})
+	}
+
+	want := errors.New("rpc unavailable")
+	if _, err := knownPairsPrefix(known(0, 1), func(uint64) (common.Address, error) {
+		return common.Address{}, want
+	}); !errors.Is(err, want) {
+		t.Errorf("err = %v, want %v", err, want)
+	}
+}
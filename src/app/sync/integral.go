@@ -1,7 +1,7 @@
 package sync
 
 import (
-	"fmt"
+	"context"
 	"github.com/mumu/cryptoSwap/src/app/model"
 	"github.com/mumu/cryptoSwap/src/core/ctx"
 	"github.com/mumu/cryptoSwap/src/core/log"
@@ -9,21 +9,30 @@ import (
 	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 	"strconv"
+	"sync"
 	"time"
 )
 
-func InitComputeIntegral() {
-	c := cron.New()
-	_, err := c.AddFunc("0 * * * *", computeIntegral)
-	if err != nil {
-		fmt.Printf("添加定时任务失败: %v\n", err)
-		return
+// RunComputeIntegral 启动积分定时任务（每整点执行），阻塞直到上下文取消，并等待正在执行的任务完成
+func RunComputeIntegral(c context.Context) error {
+	scheduler := cron.New()
+	// 多副本部署时每小时只由一个副本计算
+	job := func() {
+		if tryRunOnce("integral", time.Hour) {
+			computeIntegral()
+		}
+	}
+	if _, err := scheduler.AddFunc("0 * * * *", job); err != nil {
+		log.Logger.Error("添加定时任务失败", zap.Error(err))
+		return err
 	}
-	c.Start()
-	defer c.Stop()
-	// 阻塞主线程，防止程序退出
-	fmt.Println("定时任务已启动，每整点执行一次...")
-	select {}
+	scheduler.Start()
+	log.Logger.Info("积分定时任务已启动，每整点执行一次")
+
+	<-c.Done()
+	<-scheduler.Stop().Done()
+	log.Logger.Info("积分定时任务已停止")
+	return nil
 }
 func computeIntegral() {
 	currentTime := time.Now()
@@ -37,8 +46,13 @@ func computeIntegral() {
 	for _, scoreRule := range scoreRules {
 		scoreRuleMap[strconv.FormatInt(scoreRule.ChainId, 10)+scoreRule.TokenAddress] = scoreRule
 	}
+	// 等待各链处理完成，定时任务停止时能等到本轮积分全部写入
+	var wg sync.WaitGroup
+	defer wg.Wait()
 	for chainId := range ctx.Ctx.ChainMap {
+		wg.Add(1)
 		go func(chainId int) {
+			defer wg.Done()
 			log.Logger.Info("开始处理chainId", zap.Int("chain_id", chainId))
 			//获取到chainId，只处理这一个链的数据，获取该链上的所有用户数据
 			var users []model.Users
@@ -72,8 +86,8 @@ func computeIntegral() {
 				}
 				user.JfTime = currentTime
 				//历史值
-				newJf := decimal.NewFromInt(user.JfAmount).Mul(rule.Score).
-					Div(decimal.NewFromInt(10).Pow(decimal.NewFromInt(rule.Decimals)))
+				unit := decimal.New(1, int32(rule.Decimals))
+				newJf := user.JfAmount.Mul(rule.Score).Div(unit)
 				if len(operationRecords) == 0 {
 					user.Jf = user.Jf.Add(newJf)
 					// 更新数据库中的用户积分信息
@@ -85,22 +99,17 @@ func computeIntegral() {
 						continue
 					}
 				} else {
-					var amount int64
+					// 周期内的质押/提取只计入 jf_amount，本周期积分仍按周期开始时的 jf_amount 计算，
+					// 不按操作时间折算周期内的增减
+					amount := decimal.Zero
 					for _, record := range operationRecords {
-						minutes := int64(currentTime.Sub(record.OperationTime).Minutes())
 						if record.EventType == "Staked" {
-							newJf.Add(decimal.NewFromInt(record.Amount).Mul(rule.Score).
-								Div(decimal.NewFromInt(10).Pow(decimal.NewFromInt(rule.Decimals))).
-								Mul(decimal.NewFromInt(minutes)).DivRound(decimal.NewFromInt(60), 2))
-							amount += record.Amount
+							amount = amount.Add(record.Amount)
 						} else if record.EventType == "Withdrawn" {
-							newJf.Sub(decimal.NewFromInt(record.Amount).Mul(rule.Score).
-								Div(decimal.NewFromInt(10).Pow(decimal.NewFromInt(rule.Decimals))).
-								Mul(decimal.NewFromInt(minutes)).DivRound(decimal.NewFromInt(60), 2))
-							amount -= record.Amount
+							amount = amount.Sub(record.Amount)
 						}
 					}
-					user.JfAmount += amount
+					user.JfAmount = user.JfAmount.Add(amount)
 					user.Jf = user.Jf.Add(newJf)
 					// 更新数据库中的用户积分信息
 					if err := ctx.Ctx.DB.Model(&model.Users{}).Where("id = ?", user.Id).Updates(map[string]interface{}{
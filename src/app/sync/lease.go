@@ -0,0 +1,229 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/config"
+	"github.com/mumu/cryptoSwap/src/core/ctx"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// defaultLeaseTTL 租约默认有效期
+const defaultLeaseTTL = 10 * time.Second
+
+// errStaleLease 租约已被其他副本接管，本副本的写入被拒绝
+var errStaleLease = errors.New("租约已被其他副本接管")
+
+var (
+	// acquireLeaseScript 租约空闲时占用并递增令牌，返回新令牌；已被占用时返回 0
+	acquireLeaseScript = redis.NewScript(`
+if redis.call('SET', KEYS[1], ARGV[1], 'NX', 'PX', ARGV[2]) then
+	return redis.call('INCR', KEYS[2])
+end
+return 0`)
+	// renewLeaseScript 仍为持有者时续期
+	renewLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0`)
+	// releaseLeaseScript 仍为持有者时释放
+	releaseLeaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0`)
+)
+
+// chainLease 一条链的租约，token 为获取租约时分配的防护令牌
+type chainLease struct {
+	chainId int
+	holder  string
+	token   int64
+	ttl     time.Duration
+}
+
+// leaseEnabled 是否启用租约
+func leaseEnabled() bool {
+	return !config.Conf.Lease.Disable && ctx.Ctx.Redis != nil
+}
+
+// leaseTTL 配置的租约有效期
+func leaseTTL() time.Duration {
+	if config.Conf.Lease.TTL > 0 {
+		return time.Duration(config.Conf.Lease.TTL) * time.Second
+	}
+	return defaultLeaseTTL
+}
+
+// replicaId 本副本标识
+func replicaId() string {
+	if config.Conf.Lease.ReplicaId != "" {
+		return config.Conf.Lease.ReplicaId
+	}
+	hostname, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+func leaseKey(chainId int) string {
+	return "indexer:lease:" + strconv.Itoa(chainId)
+}
+
+func leaseTokenKey(chainId int) string {
+	return "indexer:lease:" + strconv.Itoa(chainId) + ":token"
+}
+
+// tryAcquireLease 尝试获取链的租约，已被其他副本持有时返回 nil
+func tryAcquireLease(c context.Context, chainId int, holder string, ttl time.Duration) (*chainLease, error) {
+	token, err := acquireLeaseScript.Run(c, ctx.Ctx.Redis,
+		[]string{leaseKey(chainId), leaseTokenKey(chainId)}, holder, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if token == 0 {
+		return nil, nil
+	}
+	return &chainLease{chainId: chainId, holder: holder, token: token, ttl: ttl}, nil
+}
+
+// renew 续期，返回 false 表示租约已不属于本副本
+func (l *chainLease) renew(c context.Context) (bool, error) {
+	n, err := renewLeaseScript.Run(c, ctx.Ctx.Redis, []string{leaseKey(l.chainId)}, l.holder, l.ttl.Milliseconds()).Int64()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// release 主动释放租约，其他副本无需等待过期即可接管
+func (l *chainLease) release() {
+	c, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := releaseLeaseScript.Run(c, ctx.Ctx.Redis, []string{leaseKey(l.chainId)}, l.holder).Err(); err != nil {
+		log.Logger.Warn("释放租约失败，等待自动过期", zap.Int("chain_id", l.chainId), zap.Error(err))
+	}
+}
+
+// fence 在批次事务开头写入本副本的防护令牌，已有更大的令牌时返回 errStaleLease 使事务回滚
+// 该行同时作为链级行锁，同一条链的批次事务串行提交
+func (l *chainLease) fence(tx *gorm.DB) error {
+	if l == nil {
+		return nil
+	}
+	res := tx.Exec(`INSERT INTO chain_leases (chain_id, fencing_token, holder, updated_at) VALUES (?, ?, ?, NOW())
+ON CONFLICT (chain_id) DO UPDATE SET fencing_token = EXCLUDED.fencing_token, holder = EXCLUDED.holder, updated_at = EXCLUDED.updated_at
+WHERE chain_leases.fencing_token <= EXCLUDED.fencing_token`, l.chainId, l.token, l.holder)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		log.Logger.Warn("防护令牌已过期，拒绝写入", zap.Int("chain_id", l.chainId), zap.Int64("token", l.token))
+		return errStaleLease
+	}
+	return nil
+}
+
+// runWithLease 持有链的租约期间运行该链全部监听配置，租约丢失时停止监听器并重新竞争
+// 未持有租约的副本每 ttl/3 尝试一次，租约持有者异常退出后在 ttl 内被接管
+func runWithLease(c context.Context, chainId int, chains []model.Chain) {
+	ttl := leaseTTL()
+	interval := ttl / 3
+	holder := replicaId()
+	standbyLogged := false
+	for {
+		lease, err := tryAcquireLease(c, chainId, holder, ttl)
+		if err != nil {
+			log.Logger.Error("获取租约失败", zap.Int("chain_id", chainId), zap.Error(err))
+		}
+		if lease == nil {
+			if err == nil && !standbyLogged {
+				log.Logger.Info("链租约由其他副本持有，进入待命", zap.Int("chain_id", chainId), zap.String("replica", holder))
+				standbyLogged = true
+			}
+			select {
+			case <-c.Done():
+				return
+			case <-time.After(interval):
+				continue
+			}
+		}
+		standbyLogged = false
+		log.Logger.Info("已获取链租约，开始监听",
+			zap.Int("chain_id", chainId),
+			zap.String("replica", holder),
+			zap.Int64("fencing_token", lease.token))
+
+		if stopped := holdLease(c, lease, chains, interval); stopped {
+			return
+		}
+	}
+}
+
+// holdLease 运行监听器并定期续约，返回 true 表示上下文已取消、不再竞争租约
+func holdLease(c context.Context, lease *chainLease, chains []model.Chain, interval time.Duration) bool {
+	leaderCtx, cancel := context.WithCancel(c)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		runChainSyncers(leaderCtx, chains, lease)
+	}()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	renewedAt := time.Now()
+	for {
+		select {
+		case <-c.Done():
+			<-done
+			lease.release()
+			return true
+		case <-done:
+			// 监听器全部无法启动（例如链客户端缺失），释放租约交给其他副本
+			lease.release()
+			return true
+		case <-ticker.C:
+			ok, err := lease.renew(c)
+			if err == nil && ok {
+				renewedAt = time.Now()
+				continue
+			}
+			// Redis 暂时不可用时在租约过期前继续重试
+			if err != nil && time.Since(renewedAt) < lease.ttl-interval {
+				log.Logger.Warn("租约续期失败，稍后重试", zap.Int("chain_id", lease.chainId), zap.Error(err))
+				continue
+			}
+			log.Logger.Warn("链租约已丢失，停止监听并等待重新获取",
+				zap.Int("chain_id", lease.chainId),
+				zap.Int64("fencing_token", lease.token),
+				zap.Error(err))
+			cancel()
+			<-done
+			return false
+		}
+	}
+}
+
+// tryRunOnce 多副本部署时保证同一周期的定时任务只在一个副本执行，未启用租约时总是执行
+func tryRunOnce(name string, period time.Duration) bool {
+	if !leaseEnabled() {
+		return true
+	}
+	slot := time.Now().Truncate(period).Unix()
+	key := fmt.Sprintf("indexer:cron:%s:%d", name, slot)
+	ok, err := ctx.Ctx.Redis.SetNX(context.Background(), key, replicaId(), 2*period).Result()
+	if err != nil {
+		log.Logger.Error("获取定时任务锁失败，跳过本轮", zap.String("job", name), zap.Error(err))
+		return false
+	}
+	return ok
+}
@@ -1,309 +1,210 @@
 package sync
 
 import (
+	"errors"
 	"fmt"
 	"math/big"
 
-	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
-	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
-	"github.com/ethereum/go-ethereum/ethclient"
-	"github.com/mumu/cryptoSwap/src/abi"
-	"github.com/mumu/cryptoSwap/src/app/api"
+	appabi "github.com/mumu/cryptoSwap/src/abi"
 	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/chainclient/evm"
 	"github.com/mumu/cryptoSwap/src/core/ctx"
 	"github.com/mumu/cryptoSwap/src/core/log"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
-// parseLiquidityPoolEvent 解析流动性池事件
-func parseLiquidityPoolEvent(vLog types.Log, chainId int, address string) *model.LiquidityPoolEvent {
-	if len(vLog.Topics) == 0 {
-		return nil
-	}
-
-	topic0 := vLog.Topics[0].Hex()
-
-	// Swap事件解析
-	if topic0 == crypto.Keccak256Hash([]byte("Swap(address,uint256,uint256,uint256,uint256,address)")).Hex() {
-		return parseSwapEvent(vLog, chainId, address)
-	}
+// poolReserves Sync(uint112 reserve0, uint112 reserve1) 事件记录的池子储备量
+type poolReserves struct {
+	ChainId     int64
+	PoolAddress string
+	Reserve0    *big.Int
+	Reserve1    *big.Int
+	BlockNumber int64
+	LogIndex    int
+	TxHash      common.Hash
+}
 
-	// Mint事件解析
-	if topic0 == crypto.Keccak256Hash([]byte("Mint(address,uint256,uint256)")).Hex() {
-		return parseMintEvent(vLog, chainId, address)
+// poolReserves 返回批次中各池子最近一次 Sync 的储备量，不存在时创建
+func (b *decodedBatch) poolReserves() map[string]*poolReserves {
+	if b.PoolReserves == nil {
+		b.PoolReserves = make(map[string]*poolReserves)
 	}
+	return b.PoolReserves
+}
 
-	// Burn事件解析
-	if topic0 == crypto.Keccak256Hash([]byte("Burn(address,uint256,uint256,address)")).Hex() {
-		return parseBurnEvent(vLog, chainId, address)
+// handleSync Sync(uint112 reserve0, uint112 reserve1)
+// 日志按区块顺序处理，记录的始终是该池子到当前日志为止最近一次 Sync
+func handleSync(e *decodedEvent, batch *decodedBatch) error {
+	poolAddress := e.Log.Address.Hex()
+	batch.poolReserves()[poolAddress] = &poolReserves{
+		ChainId:     int64(e.ChainId),
+		PoolAddress: poolAddress,
+		Reserve0:    e.bigInt("reserve0"),
+		Reserve1:    e.bigInt("reserve1"),
+		BlockNumber: int64(e.Log.BlockNumber),
+		LogIndex:    int(e.Log.Index),
+		TxHash:      e.Log.TxHash,
 	}
-
 	return nil
 }
-func getPoolTokenAddressesFromContract(poolAddress string, chainId int) (string, string, error) {
-	// 使用ABI管理器单例获取UniswapV2Pair ABI
-	var err error
-	abiManager := abi.GetABIManager()
-	uniswapV2PairABI, flag := abiManager.GetABI("UniswapV2Pair")
-	if !flag {
-		log.Logger.Error("获取UniswapV2Pair ABI失败")
-		return "", "", fmt.Errorf("获取ABI失败: %w", flag)
-	}
 
-	contractAddress := common.HexToAddress(poolAddress)
-
-	rpcURL := api.GetRPCURL(chainId)
-	if rpcURL == "" {
-		err := fmt.Errorf("未找到对应链的RPC端点")
-		log.Logger.Error("获取RPC URL失败", zap.Int("chain_id", chainId), zap.Error(err))
-		return "", "", err
+// resolvePoolTokens 事件所属交易对的 token0/token1 地址，优先读取已登记的 liquidity_pools 并在批次内缓存
+// 未登记或代币地址未知的交易对由链客户端读取一次代币地址与储备量，记入 batch.NewPools，在事务中登记而不在事务内访问 RPC；
+// 离线批次没有链客户端，直接返回错误，由调用方写入死信
+func resolvePoolTokens(batch *decodedBatch, poolAddress string, chainId int) (string, string, error) {
+	if tokens, ok := batch.PoolTokens[poolAddress]; ok {
+		return tokens[0], tokens[1], nil
 	}
-	// 建立区块链连接
-	client, err := ethclient.Dial(rpcURL)
-	if err != nil {
-		err := fmt.Errorf("连接区块链失败")
-		log.Logger.Error("连接区块链失败", zap.Error(err))
-		return "", "", fmt.Errorf("连接区块链失败: %w", err)
+	var pool model.LiquidityPool
+	err := ctx.Ctx.DB.Where("pool_address = ? AND chain_id = ?", poolAddress, chainId).First(&pool).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", "", err
 	}
-	defer client.Close()
-	// 正确创建合约实例：明确三个角色（可共用client，但语义更清晰）
-	var contract = bind.NewBoundContract(
-		contractAddress,  // 合约地址
-		uniswapV2PairABI, // 合约ABI
-		client,           // 用于调用只读方法（caller）
-		client,           // 用于发送交易（transactor）
-		client,           // 用于过滤日志（filterer）
-	) // 调用token0()函数获取代币0地址
-	// 调用token0()函数获取代币0地址
-	token0Address := new(common.Address)
-	if err := contract.Call(&bind.CallOpts{}, &[]any{token0Address}, "token0"); err != nil {
-		log.Logger.Error("获取token0地址失败", zap.Error(err))
-		return "", "", fmt.Errorf("获取token0失败: %w", err)
+	zero := common.Address{}.Hex()
+	if err == nil && pool.Token0Address != "" && pool.Token1Address != "" && pool.Token0Address != zero && pool.Token1Address != zero {
+		batch.cachePoolTokens(poolAddress, pool.Token0Address, pool.Token1Address)
+		return pool.Token0Address, pool.Token1Address, nil
 	}
-
-	// 调用token1()函数获取代币1地址
-	token1Address := new(common.Address)
-	if err := contract.Call(&bind.CallOpts{}, &[]any{token1Address}, "token1"); err != nil {
-		log.Logger.Error("获取token1地址失败", zap.Error(err))
-		return "", "", fmt.Errorf("获取token1失败: %w", err)
+	if batch.Offline || batch.EvmClient == nil {
+		return "", "", fmt.Errorf("交易对 %s 未登记或代币地址未知，离线处理不访问 RPC", poolAddress)
 	}
 
-	return token0Address.Hex(), token1Address.Hex(), nil
-}
-
-// getPoolTokenAddresses 获取池子的代币地址（优先从数据库获取，失败则从合约获取）
-func getPoolTokenAddresses(poolAddress string, chainId int) (string, string) {
-	var pool model.LiquidityPool
-	err := ctx.Ctx.DB.Where("pool_address = ? AND chain_id = ?", poolAddress, chainId).First(&pool).Error
+	pair, err := readPoolFromChain(batch.EvmClient, poolAddress)
 	if err != nil {
-		log.Logger.Warn("查询流动性池代币地址失败，尝试从合约获取",
-			zap.String("pool_address", poolAddress),
-			zap.Int("chain_id", chainId),
-			zap.Error(err))
-
-		// 从合约获取代币地址
-		token0Address, token1Address, err := getPoolTokenAddressesFromContract(poolAddress, chainId)
-		if err != nil {
-			log.Logger.Error("从合约获取代币地址失败",
-				zap.String("pool_address", poolAddress),
-				zap.Int("chain_id", chainId),
-				zap.Error(err))
-			// 返回默认值，避免空字符串
-			return "0x0000000000000000000000000000000000000000", "0x0000000000000000000000000000000000000000"
-		}
-
-		//// 更新数据库中的代币地址
-		//if err := updatePoolTokenAddresses(poolAddress, chainId, token0Address, token1Address); err != nil {
-		//	log.Logger.Warn("更新数据库代币地址失败",
-		//		zap.String("pool_address", poolAddress),
-		//		zap.Int("chain_id", chainId),
-		//		zap.Error(err))
-		//}
-
-		return token0Address, token1Address
+		return "", "", err
 	}
-
-	// 检查代币地址是否为默认值，如果是则重新从合约获取
-	if pool.Token0Address == "0x0000000000000000000000000000000000000000" ||
-		pool.Token1Address == "0x0000000000000000000000000000000000000000" {
-		log.Logger.Info("检测到默认代币地址，重新从合约获取",
-			zap.String("pool_address", poolAddress),
-			zap.Int("chain_id", chainId))
-
-		token0Address, token1Address, err := getPoolTokenAddressesFromContract(poolAddress, chainId)
-		if err != nil {
-			log.Logger.Error("从合约获取代币地址失败",
-				zap.String("pool_address", poolAddress),
-				zap.Int("chain_id", chainId),
-				zap.Error(err))
-			return pool.Token0Address, pool.Token1Address
-		}
-
-		// 更新数据库中的代币地址
-		if err := updatePoolTokenAddresses(poolAddress, chainId, token0Address, token1Address); err != nil {
-			log.Logger.Warn("更新数据库代币地址失败",
-				zap.String("pool_address", poolAddress),
-				zap.Int("chain_id", chainId),
-				zap.Error(err))
-		}
-
-		return token0Address, token1Address
+	if batch.NewPools == nil {
+		batch.NewPools = make(map[string]*discoveredPair)
 	}
-
-	return pool.Token0Address, pool.Token1Address
+	batch.NewPools[poolAddress] = pair
+	batch.cachePoolTokens(poolAddress, pair.Token0Address, pair.Token1Address)
+	return pair.Token0Address, pair.Token1Address, nil
 }
 
-// updatePoolTokenAddresses 更新数据库中的代币地址
-func updatePoolTokenAddresses(poolAddress string, chainId int, token0Address, token1Address string) error {
-	return ctx.Ctx.DB.Clauses(clause.OnConflict{
-		Columns: []clause.Column{
-			{Name: "pool_address"},
-			{Name: "chain_id"},
-		},
-		UpdateAll: true,
-	}).Create(&model.LiquidityPool{
-		PoolAddress:   poolAddress,
-		ChainId:       int64(chainId),
-		Token0Address: token0Address,
-		Token1Address: token1Address,
-	}).Error
+// cachePoolTokens 批次内缓存交易对的代币地址
+func (b *decodedBatch) cachePoolTokens(poolAddress, token0Address, token1Address string) {
+	if b.PoolTokens == nil {
+		b.PoolTokens = make(map[string][2]string)
+	}
+	b.PoolTokens[poolAddress] = [2]string{token0Address, token1Address}
 }
 
-// parseSwapEvent 解析Swap事件
-func parseSwapEvent(vLog types.Log, chainId int, address string) *model.LiquidityPoolEvent {
-	if len(vLog.Topics) < 3 || len(vLog.Data) < 128 {
-		return nil
+// readPoolFromChain 通过链客户端读取交易对的代币地址，以及当前储备量与总供应量作为初始值，之后由 Sync 事件维护
+func readPoolFromChain(evmClient *evm.Evm, poolAddress string) (*discoveredPair, error) {
+	pairABI, ok := appabi.GetABIManager().GetABI(appabi.ABIUniswapV2Pair)
+	if !ok {
+		return nil, fmt.Errorf("获取ABI失败: %s 未加载", appabi.ABIUniswapV2Pair)
 	}
-
-	// Swap(address indexed sender, uint amount0In, uint amount1In, uint amount0Out, uint amount1Out, address indexed to)
-	sender := common.BytesToAddress(vLog.Topics[1].Bytes()).Hex()
-	_ = common.BytesToAddress(vLog.Topics[2].Bytes()).Hex() // to address (not used in current implementation)
-
-	data := vLog.Data
-	amount0In := new(big.Int).SetBytes(common.TrimLeftZeroes(data[0:32]))
-	amount1In := new(big.Int).SetBytes(common.TrimLeftZeroes(data[32:64]))
-	amount0Out := new(big.Int).SetBytes(common.TrimLeftZeroes(data[64:96]))
-	amount1Out := new(big.Int).SetBytes(common.TrimLeftZeroes(data[96:128]))
-	// 获取池子的代币地址
-	token0Address, token1Address := getPoolTokenAddresses(vLog.Address.Hex(), chainId)
-	return &model.LiquidityPoolEvent{
-		ChainId:       int64(chainId),
-		TxHash:        vLog.TxHash.Hex(),
-		BlockNumber:   int64(vLog.BlockNumber),
-		EventType:     "Swap",
-		PoolAddress:   vLog.Address.Hex(),
-		Token0Address: token0Address,
-		Token1Address: token1Address,
-		UserAddress:   address,
-		CallerAddress: sender,
-		Amount0In:     amount0In.String(),  // 改为字符串
-		Amount1In:     amount1In.String(),  // 改为字符串
-		Amount0Out:    amount0Out.String(), // 改为字符串
-		Amount1Out:    amount1Out.String(), // 改为字符串
-		Reserve0:      "0",                 // 改为字符串
-		Reserve1:      "0",                 // 改为字符串
-		Price:         "0",                 // 改为字符串
-		Liquidity:     "0",                 // 改为字符串
+	pair := &discoveredPair{PairAddress: poolAddress}
+	var err error
+	if pair.Token0Address, err = callAddress(evmClient, pairABI, poolAddress, "token0"); err != nil {
+		return nil, fmt.Errorf("获取交易对 %s 的 token0 失败: %w", poolAddress, err)
 	}
-}
-
-// parseMintEvent 解析Mint事件
-func parseMintEvent(vLog types.Log, chainId int, address string) *model.LiquidityPoolEvent {
-	if len(vLog.Topics) < 2 || len(vLog.Data) < 64 {
-		return nil
+	if pair.Token1Address, err = callAddress(evmClient, pairABI, poolAddress, "token1"); err != nil {
+		return nil, fmt.Errorf("获取交易对 %s 的 token1 失败: %w", poolAddress, err)
 	}
+	if pair.Reserve0, pair.Reserve1, pair.TotalSupply, err = getPairReserves(evmClient, pairABI, poolAddress); err != nil {
+		log.Logger.Warn("获取链上储备量失败", zap.String("pool_address", poolAddress), zap.Error(err))
+	}
+	return pair, nil
+}
 
-	// Mint(address indexed sender, uint amount0, uint amount1)
-	sender := common.BytesToAddress(vLog.Topics[1].Bytes()).Hex()
-
-	data := vLog.Data
-	amount0 := new(big.Int).SetBytes(common.TrimLeftZeroes(data[0:32]))
-	amount1 := new(big.Int).SetBytes(common.TrimLeftZeroes(data[32:64]))
+// newLiquidityPoolEvent 构造流动性池事件记录，金额默认为 0
+// UniswapV2Pair 在 Swap/Mint/Burn 之前于同一交易中发出 Sync，储备量与价格取该 Sync
+func newLiquidityPoolEvent(e *decodedEvent, batch *decodedBatch, eventType string) (*model.LiquidityPoolEvent, error) {
+	poolAddress := e.Log.Address.Hex()
 	// 获取池子的代币地址
-	token0Address, token1Address := getPoolTokenAddresses(vLog.Address.Hex(), chainId)
-	return &model.LiquidityPoolEvent{
-		ChainId:       int64(chainId),
-		TxHash:        vLog.TxHash.Hex(),
-		BlockNumber:   int64(vLog.BlockNumber),
-		EventType:     "AddLiquidity",
-		PoolAddress:   vLog.Address.Hex(),
-		Token0Address: token0Address,
-		Token1Address: token1Address,
-		UserAddress:   address,
-		CallerAddress: sender,
-		Amount0In:     amount0.String(), // 改为字符串
-		Amount1In:     amount1.String(), // 改为字符串
-		Amount0Out:    "0",              // 改为字符串
-		Amount1Out:    "0",              // 改为字符串
-		Reserve0:      "0",              // 改为字符串
-		Reserve1:      "0",              // 改为字符串
-		Price:         "0",              // 改为字符串
-		Liquidity:     "0",              // 改为字符串
+	token0Address, token1Address, err := resolvePoolTokens(batch, poolAddress, e.ChainId)
+	if err != nil {
+		return nil, err
+	}
+	event := &model.LiquidityPoolEvent{
+		ChainId:        int64(e.ChainId),
+		TxHash:         e.Log.TxHash.Hex(),
+		BlockNumber:    int64(e.Log.BlockNumber),
+		LogIndex:       int(e.Log.Index),
+		BlockTimestamp: e.BlockTime,
+		EventType:      eventType,
+		PoolAddress:    poolAddress,
+		Token0Address:  token0Address,
+		Token1Address:  token1Address,
+		UserAddress:    e.Sender,
+		CallerAddress:  e.address("sender"),
+		Amount0In:      "0",
+		Amount1In:      "0",
+		Amount0Out:     "0",
+		Amount1Out:     "0",
+		Reserve0:       "0",
+		Reserve1:       "0",
+		Price:          "0",
+		Liquidity:      "0",
+	}
+	if r, ok := batch.PoolReserves[poolAddress]; ok && r.TxHash == e.Log.TxHash && r.LogIndex < int(e.Log.Index) {
+		event.Reserve0 = r.Reserve0.String()
+		event.Reserve1 = r.Reserve1.String()
+		event.Price = calculatePrice(r.Reserve0, r.Reserve1)
 	}
+	return event, nil
 }
 
-// parseBurnEvent 解析Burn事件
-func parseBurnEvent(vLog types.Log, chainId int, address string) *model.LiquidityPoolEvent {
-	if len(vLog.Topics) < 3 || len(vLog.Data) < 64 {
-		return nil
+// handleSwap Swap(address indexed sender, uint amount0In, uint amount1In, uint amount0Out, uint amount1Out, address indexed to)
+func handleSwap(e *decodedEvent, batch *decodedBatch) error {
+	event, err := newLiquidityPoolEvent(e, batch, "Swap")
+	if err != nil {
+		return err
 	}
+	event.Amount0In = e.bigInt("amount0In").String()
+	event.Amount1In = e.bigInt("amount1In").String()
+	event.Amount0Out = e.bigInt("amount0Out").String()
+	event.Amount1Out = e.bigInt("amount1Out").String()
+	batch.LiquidityPoolEvents = append(batch.LiquidityPoolEvents, event)
+	return nil
+}
 
-	// Burn(address indexed sender, uint amount0, uint amount1, address indexed to)
-	sender := common.BytesToAddress(vLog.Topics[1].Bytes()).Hex()
-	_ = common.BytesToAddress(vLog.Topics[2].Bytes()).Hex() // to address (not used in current implementation)
+// handleMint Mint(address indexed sender, uint amount0, uint amount1)
+func handleMint(e *decodedEvent, batch *decodedBatch) error {
+	event, err := newLiquidityPoolEvent(e, batch, "AddLiquidity")
+	if err != nil {
+		return err
+	}
+	event.Amount0In = e.bigInt("amount0").String()
+	event.Amount1In = e.bigInt("amount1").String()
+	batch.LiquidityPoolEvents = append(batch.LiquidityPoolEvents, event)
+	return nil
+}
 
-	data := vLog.Data
-	amount0 := new(big.Int).SetBytes(common.TrimLeftZeroes(data[0:32]))
-	amount1 := new(big.Int).SetBytes(common.TrimLeftZeroes(data[32:64]))
-	// 获取池子的代币地址
-	token0Address, token1Address := getPoolTokenAddresses(vLog.Address.Hex(), chainId)
-	return &model.LiquidityPoolEvent{
-		ChainId:       int64(chainId),
-		TxHash:        vLog.TxHash.Hex(),
-		BlockNumber:   int64(vLog.BlockNumber),
-		EventType:     "RemoveLiquidity",
-		PoolAddress:   vLog.Address.Hex(),
-		Token0Address: token0Address,
-		Token1Address: token1Address,
-		UserAddress:   address,
-		CallerAddress: sender,
-		Amount0In:     "0",              // 改为字符串
-		Amount1In:     "0",              // 改为字符串
-		Amount0Out:    amount0.String(), // 改为字符串
-		Amount1Out:    amount1.String(), // 改为字符串
-		Reserve0:      "0",              // 改为字符串
-		Reserve1:      "0",              // 改为字符串
-		Price:         "0",              // 改为字符串
-		Liquidity:     "0",              // 改为字符串
+// handleBurn Burn(address indexed sender, uint amount0, uint amount1, address indexed to)
+func handleBurn(e *decodedEvent, batch *decodedBatch) error {
+	event, err := newLiquidityPoolEvent(e, batch, "RemoveLiquidity")
+	if err != nil {
+		return err
 	}
+	event.Amount0Out = e.bigInt("amount0").String()
+	event.Amount1Out = e.bigInt("amount1").String()
+	batch.LiquidityPoolEvents = append(batch.LiquidityPoolEvents, event)
+	return nil
 }
 
-// saveLiquidityPoolEvents 保存流动性池事件到数据库
-func saveLiquidityPoolEvents(events []*model.LiquidityPoolEvent, chainId int, targetBlockNum uint64, addresses string) error {
+// saveLiquidityPoolEvents 保存流动性池事件到数据库，游标由调用方统一推进；newPools 为解码时从链上读取的未登记交易对
+func saveLiquidityPoolEvents(tx *gorm.DB, events []*model.LiquidityPoolEvent, newPools map[string]*discoveredPair) error {
 	if len(events) == 0 {
-		return updateLiquidityPoolBlockNumber(chainId, targetBlockNum, addresses)
+		return nil
 	}
 
-	return ctx.Ctx.DB.Transaction(func(tx *gorm.DB) error {
-		// 批量插入流动性池事件
-		if err := tx.CreateInBatches(events, 100).Error; err != nil {
-			log.Logger.Error("批量插入流动性池事件失败", zap.Error(err))
-			return err
-		}
-
-		// 更新流动性池信息
-		if err := updateLiquidityPoolInfo(tx, events); err != nil {
-			log.Logger.Error("更新流动性池信息失败", zap.Error(err))
-			return err
-		}
+	// 批量插入流动性池事件
+	if err := tx.CreateInBatches(events, 100).Error; err != nil {
+		log.Logger.Error("批量插入流动性池事件失败", zap.Error(err))
+		return err
+	}
 
-		// 更新区块号
-		return updateLiquidityPoolBlockNumber(chainId, targetBlockNum, addresses)
-	})
+	// 更新流动性池信息
+	if err := updateLiquidityPoolInfo(tx, events, newPools); err != nil {
+		log.Logger.Error("更新流动性池信息失败", zap.Error(err))
+		return err
+	}
+	return nil
 }
 
 // calculatePrice 计算代币价格
@@ -322,7 +223,8 @@ func calculatePrice(reserve0, reserve1 *big.Int) string {
 }
 
 // updateLiquidityPoolInfo 更新流动性池信息
-func updateLiquidityPoolInfo(tx *gorm.DB, events []*model.LiquidityPoolEvent) error {
+// 首次出现的池子按解码时从链上读取的代币地址与储备量创建，事务内不访问 RPC；newPools 中没有的未登记池子直接返回错误
+func updateLiquidityPoolInfo(tx *gorm.DB, events []*model.LiquidityPoolEvent, newPools map[string]*discoveredPair) error {
 	// 按池子地址分组
 	poolEvents := make(map[string][]*model.LiquidityPoolEvent)
 	for _, event := range events {
@@ -333,34 +235,29 @@ func updateLiquidityPoolInfo(tx *gorm.DB, events []*model.LiquidityPoolEvent) er
 		// 检查池子是否存在
 		var pool model.LiquidityPool
 		err := tx.Where("pool_address = ? AND chain_id = ?", poolAddress, poolEventList[0].ChainId).First(&pool).Error
+		newPool := newPools[poolAddress]
 		if err == gorm.ErrRecordNotFound {
-			// 创建新的流动性池记录前，先获取真实的代币地址
-			token0Address, token1Address, err := getPoolTokenAddressesFromContract(poolAddress, int(poolEventList[0].ChainId))
-			if err != nil {
-				log.Logger.Warn("创建流动性池时获取代币地址失败，使用默认值",
-					zap.String("pool_address", poolAddress),
-					zap.Int64("chain_id", poolEventList[0].ChainId),
-					zap.Error(err))
-				token0Address = "0x0000000000000000000000000000000000000000"
-				token1Address = "0x0000000000000000000000000000000000000000"
+			if newPool == nil {
+				return fmt.Errorf("交易对 %s 未登记，且未从链上读取交易对信息", poolAddress)
 			}
+			reserve0, reserve1, totalSupply := bigOrZero(newPool.Reserve0), bigOrZero(newPool.Reserve1), bigOrZero(newPool.TotalSupply)
 
 			// 创建新的流动性池记录
 			pool = model.LiquidityPool{
 				ChainId:        poolEventList[0].ChainId,
 				PoolAddress:    poolAddress,
-				Token0Address:  token0Address, // 使用从合约获取的真实地址
-				Token1Address:  token1Address, // 使用从合约获取的真实地址
-				Token0Symbol:   "",            // 暂时留空
-				Token1Symbol:   "",            // 暂时留空
-				Token0Decimals: 0,             // 默认值
-				Token1Decimals: 0,             // 默认值
-				Reserve0:       "0",           // 默认值
-				Reserve1:       "0",           // 默认值
-				TotalSupply:    "0",           // 默认值
-				Price:          "0",           // 默认值
-				Volume24h:      "0",           // 默认值
-				TxCount:        0,             // 默认值
+				Token0Address:  newPool.Token0Address,
+				Token1Address:  newPool.Token1Address,
+				Token0Symbol:   "", // 暂时留空
+				Token1Symbol:   "", // 暂时留空
+				Token0Decimals: 0,  // 默认值
+				Token1Decimals: 0,  // 默认值
+				Reserve0:       reserve0.String(),
+				Reserve1:       reserve1.String(),
+				TotalSupply:    totalSupply.String(),
+				Price:          calculatePrice(reserve0, reserve1),
+				Volume24h:      "0", // 默认值
+				TxCount:        0,   // 默认值
 				LastBlockNum:   poolEventList[len(poolEventList)-1].BlockNumber,
 				IsActive:       true,
 			}
@@ -373,8 +270,14 @@ func updateLiquidityPoolInfo(tx *gorm.DB, events []*model.LiquidityPoolEvent) er
 			log.Logger.Error("查询流动性池记录失败", zap.Error(err))
 			return err
 		} else {
+			updates := map[string]interface{}{"last_block_num": poolEventList[len(poolEventList)-1].BlockNumber}
+			// 已登记但代币地址未知的池子补全代币地址
+			if newPool != nil {
+				updates["token0_address"] = newPool.Token0Address
+				updates["token1_address"] = newPool.Token1Address
+			}
 			// 更新现有池子的区块号
-			if err := tx.Model(&pool).Update("last_block_num", poolEventList[len(poolEventList)-1].BlockNumber).Error; err != nil {
+			if err := tx.Model(&pool).Updates(updates).Error; err != nil {
 				log.Logger.Error("更新流动性池区块号失败", zap.Error(err))
 				return err
 			}
@@ -385,37 +288,28 @@ func updateLiquidityPoolInfo(tx *gorm.DB, events []*model.LiquidityPoolEvent) er
 			log.Logger.Error("更新流动性池交易计数失败", zap.Error(err))
 			return err
 		}
-		// 直接从Uniswap V2池子合约获取最新储备量
-		reserve0, reserve1, totalSupply, err := api.GetPoolReserves(poolAddress, int(poolEventList[0].ChainId))
-		if err != nil {
-			log.Logger.Warn("获取链上储备量失败", zap.Error(err))
-			// 使用默认值继续处理
-			reserve0 = big.NewInt(0)
-			reserve1 = big.NewInt(0)
-			totalSupply = big.NewInt(0)
-		}
+	}
 
-		// 计算价格
-		price := calculatePrice(reserve0, reserve1)
+	return nil
+}
 
-		// 更新池子信息
-		if err := tx.Model(&pool).Updates(map[string]interface{}{
-			"reserve0": reserve0.String(),
-			"reserve1": reserve1.String(),
-			//"liquidity":    totalSupply.String(),
-			"total_supply": totalSupply.String(),
-			"price":        price,
-		}).Error; err != nil {
-			log.Logger.Error("更新流动性池信息失败", zap.Error(err))
+// savePoolReserves 将各池子最近一次 Sync 的储备量与价格写入 liquidity_pools
+// 仅当该 Sync 晚于池子当前记录的储备量位置时更新，回填较早区段不会覆盖更新的储备量
+func savePoolReserves(tx *gorm.DB, reserves map[string]*poolReserves) error {
+	for _, r := range reserves {
+		if err := tx.Model(&model.LiquidityPool{}).
+			Where("chain_id = ? AND pool_address = ?", r.ChainId, r.PoolAddress).
+			Where("reserve_block_num IS NULL OR (reserve_block_num, COALESCE(reserve_log_index, -1)) < (?, ?)", r.BlockNumber, r.LogIndex).
+			Updates(map[string]interface{}{
+				"reserve0":          r.Reserve0.String(),
+				"reserve1":          r.Reserve1.String(),
+				"price":             calculatePrice(r.Reserve0, r.Reserve1),
+				"reserve_block_num": r.BlockNumber,
+				"reserve_log_index": r.LogIndex,
+			}).Error; err != nil {
+			log.Logger.Error("更新流动性池储备量失败", zap.String("pool_address", r.PoolAddress), zap.Error(err))
 			return err
 		}
 	}
-
 	return nil
 }
-
-// updateLiquidityPoolBlockNumber 更新流动性池监听的区块号
-func updateLiquidityPoolBlockNumber(chainId int, blockNumber uint64, address string) error {
-	// 更新流动性池服务配置的区块号
-	return ctx.Ctx.DB.Model(&model.Chain{}).Where("chain_id = ? AND address = ?", int64(chainId), address).Update("last_block_num", blockNumber).Error
-}
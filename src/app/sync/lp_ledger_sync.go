@@ -0,0 +1,185 @@
+package sync
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// zeroAddress 铸造/销毁 LP 代币时 Transfer 的对手方
+var zeroAddress = strings.ToLower(common.Address{}.Hex())
+
+// handleLPTransfer Transfer(address indexed from, address indexed to, uint value)，交易对合约即 LP 代币合约
+func handleLPTransfer(e *decodedEvent, batch *decodedBatch) error {
+	batch.LPTransfers = append(batch.LPTransfers, &model.LPTokenTransfer{
+		ChainId:        int64(e.ChainId),
+		PoolAddress:    strings.ToLower(e.Log.Address.Hex()),
+		FromAddress:    strings.ToLower(e.address("from")),
+		ToAddress:      strings.ToLower(e.address("to")),
+		Value:          e.bigInt("value").String(),
+		BlockNumber:    int64(e.Log.BlockNumber),
+		LogIndex:       int(e.Log.Index),
+		BlockTimestamp: e.BlockTime,
+		TxHash:         strings.ToLower(e.Log.TxHash.Hex()),
+	})
+	return nil
+}
+
+// markProtocolFeeMints 标记批次中铸造给 feeTo 的协议手续费
+// UniswapV2 在 mint/burn 开头的 _mintFee 中先铸造协议手续费，再铸造给存入者或发出 Burn：
+// 同一交易中铸造之后最近的池子事件为 Burn，或为 Mint 且两者之间还有另一次铸造时，该铸造即为协议手续费
+// 批次按完整区块划分，同一交易的 Transfer 与池子事件总在同一批次
+func markProtocolFeeMints(batch *decodedBatch) {
+	type txPool struct {
+		TxHash      string
+		PoolAddress string
+	}
+	events := make(map[txPool][]*model.LiquidityPoolEvent)
+	for _, event := range batch.LiquidityPoolEvents {
+		key := txPool{TxHash: strings.ToLower(event.TxHash), PoolAddress: strings.ToLower(event.PoolAddress)}
+		events[key] = append(events[key], event)
+	}
+	mints := make(map[txPool][]*model.LPTokenTransfer)
+	for _, t := range batch.LPTransfers {
+		if t.FromAddress == zeroAddress && t.ToAddress != zeroAddress {
+			key := txPool{TxHash: t.TxHash, PoolAddress: t.PoolAddress}
+			mints[key] = append(mints[key], t)
+		}
+	}
+
+	for key, transfers := range mints {
+		for _, t := range transfers {
+			var next *model.LiquidityPoolEvent
+			for _, event := range events[key] {
+				if event.LogIndex > t.LogIndex && (next == nil || event.LogIndex < next.LogIndex) {
+					next = event
+				}
+			}
+			if next == nil {
+				continue
+			}
+			switch next.EventType {
+			case "RemoveLiquidity":
+				t.IsProtocolFee = true
+			case "AddLiquidity":
+				for _, other := range transfers {
+					if other.LogIndex > t.LogIndex && other.LogIndex < next.LogIndex {
+						t.IsProtocolFee = true
+						break
+					}
+				}
+			}
+			if t.IsProtocolFee {
+				log.Logger.Info("识别到协议手续费铸造",
+					zap.String("pool_address", t.PoolAddress),
+					zap.String("fee_to", t.ToAddress),
+					zap.String("tx_hash", t.TxHash))
+			}
+		}
+	}
+}
+
+// lpBalanceKey LP 余额账本的主键
+type lpBalanceKey struct {
+	PoolAddress   string
+	WalletAddress string
+}
+
+// lpBalanceDeltas 汇总 Transfer 对各钱包余额的变动，sign 为 -1 时用于回滚
+// 铸造不扣减转出方，销毁不增加接收方；MINIMUM_LIQUIDITY 铸造给零地址，计入零地址余额
+func lpBalanceDeltas(transfers []*model.LPTokenTransfer, sign int64) map[lpBalanceKey]*big.Int {
+	deltas := make(map[lpBalanceKey]*big.Int)
+	add := func(pool, wallet string, value *big.Int) {
+		key := lpBalanceKey{PoolAddress: pool, WalletAddress: wallet}
+		if deltas[key] == nil {
+			deltas[key] = new(big.Int)
+		}
+		deltas[key].Add(deltas[key], value)
+	}
+	for _, t := range transfers {
+		value, ok := new(big.Int).SetString(t.Value, 10)
+		if !ok {
+			continue
+		}
+		value.Mul(value, big.NewInt(sign))
+		if t.ToAddress != zeroAddress || t.FromAddress == zeroAddress {
+			add(t.PoolAddress, t.ToAddress, value)
+		}
+		if t.FromAddress != zeroAddress {
+			add(t.PoolAddress, t.FromAddress, new(big.Int).Neg(value))
+		}
+	}
+	return deltas
+}
+
+// applyLPBalanceDeltas 把余额变动累加到 lp_balances；变动与处理顺序无关，回填与回滚均可直接累加
+func applyLPBalanceDeltas(tx *gorm.DB, chainId int, deltas map[lpBalanceKey]*big.Int, blockNum int64) error {
+	for key, delta := range deltas {
+		if delta.Sign() == 0 {
+			continue
+		}
+		if err := tx.Exec(`
+			INSERT INTO lp_balances (chain_id, pool_address, wallet_address, balance, last_block_num, updated_at)
+			VALUES (?, ?, ?, ?, ?, NOW())
+			ON CONFLICT (chain_id, pool_address, wallet_address) DO UPDATE SET
+				balance = lp_balances.balance + EXCLUDED.balance,
+				last_block_num = GREATEST(lp_balances.last_block_num, EXCLUDED.last_block_num),
+				updated_at = NOW()
+		`, chainId, key.PoolAddress, key.WalletAddress, delta.String(), blockNum).Error; err != nil {
+			log.Logger.Error("更新 LP 余额失败",
+				zap.String("pool_address", key.PoolAddress),
+				zap.String("wallet", key.WalletAddress),
+				zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+// saveLPTransfers 逐条插入 LP Transfer，已存在的跳过，只有新插入的记录计入余额
+func saveLPTransfers(tx *gorm.DB, chainId int, transfers []*model.LPTokenTransfer, targetBlockNum uint64) error {
+	inserted := make([]*model.LPTokenTransfer, 0, len(transfers))
+	for _, t := range transfers {
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "chain_id"}, {Name: "tx_hash"}, {Name: "log_index"}},
+			DoNothing: true,
+		}).Create(t)
+		if result.Error != nil {
+			log.Logger.Error("插入 LP Transfer 失败", zap.String("tx_hash", t.TxHash), zap.Error(result.Error))
+			return result.Error
+		}
+		if result.RowsAffected > 0 {
+			inserted = append(inserted, t)
+		}
+	}
+	if len(inserted) < len(transfers) {
+		log.Logger.Info("跳过已存在的 LP Transfer", zap.Int("skipped", len(transfers)-len(inserted)))
+	}
+	return applyLPBalanceDeltas(tx, chainId, lpBalanceDeltas(inserted, 1), int64(targetBlockNum))
+}
+
+// rollbackLPTransfers 撤销范围内的 LP Transfer 对余额的影响，再删除记录
+func rollbackLPTransfers(tx *gorm.DB, scope rollbackScope) error {
+	var transfers []*model.LPTokenTransfer
+	if err := scope.where(tx, "pool_address").Find(&transfers).Error; err != nil {
+		return err
+	}
+	if len(transfers) == 0 {
+		return nil
+	}
+	if err := applyLPBalanceDeltas(tx, scope.ChainId, lpBalanceDeltas(transfers, -1), 0); err != nil {
+		return err
+	}
+	log.Logger.Info("回滚 LP Transfer", zap.Int("chain_id", scope.ChainId), zap.Int("transfer_count", len(transfers)))
+	ids := make([]int64, 0, len(transfers))
+	for _, t := range transfers {
+		ids = append(ids, t.Id)
+	}
+	return tx.Where("id IN ?", ids).Delete(&model.LPTokenTransfer{}).Error
+}
@@ -0,0 +1,201 @@
+package sync
+
+import (
+	"math/big"
+	"sort"
+	"testing"
+
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"go.uber.org/zap"
+)
+
+const (
+	lpPool    = "0x00000000000000000000000000000000000000e5"
+	lpPool2   = "0x00000000000000000000000000000000000000f6"
+	lpTx      = "0x00000000000000000000000000000000000000000000000000000000000000aa"
+	feeTo     = "0x0000000000000000000000000000000000000f0e"
+	lpAlice   = "0x000000000000000000000000000000000000a11c"
+	lpBob     = "0x0000000000000000000000000000000000000b0b"
+	lpPoolMix = "0x00000000000000000000000000000000000000E5"
+	lpTxMix   = "0x00000000000000000000000000000000000000000000000000000000000000AA"
+)
+
+func lpTransfer(pool, from, to, value string, logIndex int) *model.LPTokenTransfer {
+	return &model.LPTokenTransfer{PoolAddress: pool, FromAddress: from, ToAddress: to, Value: value, LogIndex: logIndex, TxHash: lpTx}
+}
+
+func lpEvent(pool, eventType string, logIndex int) *model.LiquidityPoolEvent {
+	return &model.LiquidityPoolEvent{PoolAddress: pool, TxHash: lpTxMix, EventType: eventType, LogIndex: logIndex}
+}
+
+func TestMarkProtocolFeeMints(t *testing.T) {
+	log.Logger = zap.NewNop()
+
+	tests := []struct {
+		name      string
+		transfers []*model.LPTokenTransfer
+		events    []*model.LiquidityPoolEvent
+		wantFee   []int // 标记为协议手续费的 Transfer 日志索引
+	}{
+		{
+			name:      "plain mint",
+			transfers: []*model.LPTokenTransfer{lpTransfer(lpPool, zeroAddress, lpAlice, "100", 1)},
+			events:    []*model.LiquidityPoolEvent{lpEvent(lpPoolMix, "AddLiquidity", 3)},
+		},
+		{
+			name: "fee mint before deposit mint",
+			transfers: []*model.LPTokenTransfer{
+				lpTransfer(lpPool, zeroAddress, feeTo, "5", 1),
+				lpTransfer(lpPool, zeroAddress, lpAlice, "100", 2),
+			},
+			events:  []*model.LiquidityPoolEvent{lpEvent(lpPoolMix, "AddLiquidity", 4)},
+			wantFee: []int{1},
+		},
+		{
+			name: "fee mint before burn",
+			transfers: []*model.LPTokenTransfer{
+				lpTransfer(lpPool, lpAlice, lpPool, "100", 0),
+				lpTransfer(lpPool, zeroAddress, feeTo, "5", 1),
+				lpTransfer(lpPool, lpPool, zeroAddress, "100", 2),
+			},
+			events:  []*model.LiquidityPoolEvent{lpEvent(lpPoolMix, "RemoveLiquidity", 4)},
+			wantFee: []int{1},
+		},
+		{
+			name: "first mint locks minimum liquidity",
+			transfers: []*model.LPTokenTransfer{
+				lpTransfer(lpPool, zeroAddress, zeroAddress, "1000", 1),
+				lpTransfer(lpPool, zeroAddress, lpAlice, "99000", 2),
+			},
+			events: []*model.LiquidityPoolEvent{lpEvent(lpPoolMix, "AddLiquidity", 4)},
+		},
+		{
+			name: "two deposits in one tx",
+			transfers: []*model.LPTokenTransfer{
+				lpTransfer(lpPool, zeroAddress, lpAlice, "100", 1),
+				lpTransfer(lpPool, zeroAddress, lpBob, "50", 4),
+			},
+			events: []*model.LiquidityPoolEvent{lpEvent(lpPoolMix, "AddLiquidity", 3), lpEvent(lpPoolMix, "AddLiquidity", 6)},
+		},
+		{
+			name:      "mint followed by swap",
+			transfers: []*model.LPTokenTransfer{lpTransfer(lpPool, zeroAddress, lpAlice, "100", 1)},
+			events:    []*model.LiquidityPoolEvent{lpEvent(lpPoolMix, "Swap", 3)},
+		},
+		{
+			name:      "burn in another pool of the same tx",
+			transfers: []*model.LPTokenTransfer{lpTransfer(lpPool, zeroAddress, feeTo, "5", 1)},
+			events:    []*model.LiquidityPoolEvent{lpEvent(lpPool2, "RemoveLiquidity", 4)},
+		},
+		{
+			name:      "no pool event",
+			transfers: []*model.LPTokenTransfer{lpTransfer(lpPool, zeroAddress, feeTo, "5", 1)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batch := &decodedBatch{LPTransfers: tt.transfers, LiquidityPoolEvents: tt.events}
+			markProtocolFeeMints(batch)
+			var got []int
+			for _, transfer := range batch.LPTransfers {
+				if transfer.IsProtocolFee {
+					got = append(got, transfer.LogIndex)
+				}
+			}
+			sort.Ints(got)
+			if len(got) != len(tt.wantFee) {
+				t.Fatalf("protocol fee mints = %v, want %v", got, tt.wantFee)
+			}
+			for i := range got {
+				if got[i] != tt.wantFee[i] {
+					t.Fatalf("protocol fee mints = %v, want %v", got, tt.wantFee)
+				}
+			}
+		})
+	}
+}
+
+func TestLPBalanceDeltas(t *testing.T) {
+	tests := []struct {
+		name      string
+		transfers []*model.LPTokenTransfer
+		sign      int64
+		want      map[lpBalanceKey]string
+	}{
+		{
+			name:      "transfer moves balance",
+			transfers: []*model.LPTokenTransfer{lpTransfer(lpPool, lpAlice, lpBob, "30", 0)},
+			sign:      1,
+			want: map[lpBalanceKey]string{
+				{lpPool, lpAlice}: "-30",
+				{lpPool, lpBob}:   "30",
+			},
+		},
+		{
+			name:      "mint does not debit zero address",
+			transfers: []*model.LPTokenTransfer{lpTransfer(lpPool, zeroAddress, lpAlice, "100", 0)},
+			sign:      1,
+			want:      map[lpBalanceKey]string{{lpPool, lpAlice}: "100"},
+		},
+		{
+			name:      "burn does not credit zero address",
+			transfers: []*model.LPTokenTransfer{lpTransfer(lpPool, lpPool, zeroAddress, "40", 0)},
+			sign:      1,
+			want:      map[lpBalanceKey]string{{lpPool, lpPool}: "-40"},
+		},
+		{
+			name:      "minimum liquidity is credited to zero address",
+			transfers: []*model.LPTokenTransfer{lpTransfer(lpPool, zeroAddress, zeroAddress, "1000", 0)},
+			sign:      1,
+			want:      map[lpBalanceKey]string{{lpPool, zeroAddress}: "1000"},
+		},
+		{
+			name: "deltas aggregate per pool and wallet",
+			transfers: []*model.LPTokenTransfer{
+				lpTransfer(lpPool, zeroAddress, lpAlice, "100", 0),
+				lpTransfer(lpPool, lpAlice, lpPool, "60", 1),
+				lpTransfer(lpPool, lpPool, zeroAddress, "60", 2),
+				lpTransfer(lpPool2, zeroAddress, lpAlice, "7", 3),
+			},
+			sign: 1,
+			want: map[lpBalanceKey]string{
+				{lpPool, lpAlice}:  "40",
+				{lpPool, lpPool}:   "0",
+				{lpPool2, lpAlice}: "7",
+			},
+		},
+		{
+			name: "rollback negates",
+			transfers: []*model.LPTokenTransfer{
+				lpTransfer(lpPool, zeroAddress, lpAlice, "100", 0),
+				lpTransfer(lpPool, lpAlice, lpBob, "30", 1),
+			},
+			sign: -1,
+			want: map[lpBalanceKey]string{
+				{lpPool, lpAlice}: "-70",
+				{lpPool, lpBob}:   "-30",
+			},
+		},
+		{
+			name:      "invalid value is skipped",
+			transfers: []*model.LPTokenTransfer{lpTransfer(lpPool, lpAlice, lpBob, "not-a-number", 0)},
+			sign:      1,
+			want:      map[lpBalanceKey]string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := lpBalanceDeltas(tt.transfers, tt.sign)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d balances, want %d: %v", len(got), len(tt.want), got)
+			}
+			for key, want := range tt.want {
+				w, _ := new(big.Int).SetString(want, 10)
+				if got[key] == nil || got[key].Cmp(w) != 0 {
+					t.Errorf("delta %+v = %v, want %s", key, got[key], want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,347 @@
+package sync
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/ctx"
+	"gorm.io/gorm"
+)
+
+// defaultPoolFeeRate 交易对与工厂均未配置手续费率时使用的 UniswapV2 费率，与 service.DefaultFeeRate 一致
+const defaultPoolFeeRate = 0.003
+
+// poolDataPeriod 交易对汇总周期，交易量取同周期的 K 线
+type poolDataPeriod struct {
+	Table      string
+	Resolution string
+	Seconds    int64
+}
+
+var poolDataPeriods = []poolDataPeriod{
+	{Table: "pool_hour_data", Resolution: "1h", Seconds: 3600},
+	{Table: "pool_day_data", Resolution: "1d", Seconds: 86400},
+}
+
+// tokenPriceAtSQL 代币在某个时间点的 USD 价格：取该时间之前最近一次定价，早于第一条记录时取第一条
+// 用于拼接 SQL，链 ID 取命名参数 @chain_id
+func tokenPriceAtSQL(tokenExpr, tsExpr string) string {
+	return `COALESCE(
+	         (SELECT t.price_usd FROM token_prices t
+	           WHERE t.chain_id = @chain_id AND t.token_address = ` + tokenExpr + ` AND t.computed_at <= ` + tsExpr + `
+	           ORDER BY t.computed_at DESC LIMIT 1),
+	         (SELECT t.price_usd FROM token_prices t
+	           WHERE t.chain_id = @chain_id AND t.token_address = ` + tokenExpr + `
+	           ORDER BY t.computed_at ASC LIMIT 1))`
+}
+
+// poolTVLExpr 由储备量 r0/r1、精度 d0/d1 与价格 p0/p1 列估算 TVL，规则与服务层 poolTVLUSD 一致：
+// 两侧都已定价时相加，只有一侧定价时取该侧的两倍
+const poolTVLExpr = `CASE WHEN p0 IS NOT NULL AND p1 IS NOT NULL
+	            THEN r0 / power(10::NUMERIC, d0) * p0 + r1 / power(10::NUMERIC, d1) * p1
+	            WHEN p0 IS NOT NULL THEN 2 * r0 / power(10::NUMERIC, d0) * p0
+	            WHEN p1 IS NOT NULL THEN 2 * r1 / power(10::NUMERIC, d1) * p1 END`
+
+// refreshPoolDataSQL 由 K 线与 LP 持仓账本重算交易对汇总，%[1]s 为表名
+// 周期取有 K 线或 LP 变动的周期；储备量取周期结束前最近一条带储备量的事件，TVL 按周期结束时（当前周期为现在）的价格估值
+// 手续费率取交易对配置、工厂配置、默认值中第一个非空的；工厂 feeTo 开启时按协议分成比例拆出协议手续费
+// 协议收入为周期内铸造给 feeTo 的 LP 代币占铸造后总供应量的比例乘以铸造前的 TVL（按铸造时价格）
+// 总供应量由 lp_supply 对 LP 余额变动一次窗口累加得到，不逐条铸造重新求和
+// 超出列精度的金额置空，避免整个批次事务失败
+var refreshPoolDataSQL = `
+INSERT INTO %[1]s (chain_id, pool_address, period_start, reserve0, reserve1, tvl_usd, volume0, volume1,
+	volume_usd, fees_usd, lp_fees_usd, protocol_fees_usd, protocol_revenue_usd, tx_count, swap_count, lp_count, updated_at)
+WITH periods AS (
+	SELECT pool_address, bucket_start AS period_start
+	  FROM pool_candles
+	 WHERE chain_id = @chain_id
+	   AND resolution = @resolution
+	   AND pool_address IN @pools
+	   AND bucket_start >= @start
+	   AND bucket_start < @end
+	UNION
+	SELECT pool_address, to_timestamp(floor(extract(epoch FROM block_timestamp) / @seconds) * @seconds)
+	  FROM lp_token_transfers
+	 WHERE chain_id = @chain_id
+	   AND pool_address IN @pools
+	   AND block_timestamp >= @start
+	   AND block_timestamp < @end
+), lp_supply AS (
+	SELECT pool_address,
+	       block_number,
+	       log_index,
+	       SUM(SUM(delta)) OVER (PARTITION BY pool_address ORDER BY block_number, log_index) AS supply
+	  FROM lp_balance_changes
+	 WHERE chain_id = @chain_id
+	   AND pool_address IN @pools
+	 GROUP BY pool_address, block_number, log_index
+), fee_mints AS (
+	SELECT t.pool_address,
+	       to_timestamp(floor(extract(epoch FROM t.block_timestamp) / @seconds) * @seconds) AS period_start,
+	       t.block_timestamp AS ts,
+	       t.value / NULLIF(s.supply, 0) AS share,
+	       p.token0_decimals AS d0,
+	       p.token1_decimals AS d1,
+	       LOWER(p.token0_address) AS token0,
+	       LOWER(p.token1_address) AS token1,
+	       r.reserve0 AS r0,
+	       r.reserve1 AS r1
+	  FROM lp_token_transfers t
+	  JOIN lp_supply s
+	    ON s.pool_address = t.pool_address
+	   AND s.block_number = t.block_number
+	   AND s.log_index = t.log_index
+	  JOIN liquidity_pools p
+	    ON p.chain_id = @chain_id
+	   AND LOWER(p.pool_address) = t.pool_address
+	  JOIN LATERAL (
+	       SELECT e.reserve0, e.reserve1
+	         FROM liquidity_pool_events e
+	        WHERE e.chain_id = @chain_id
+	          AND e.pool_address = p.pool_address
+	          AND (e.block_number, e.log_index) < (t.block_number, t.log_index)
+	          AND e.reserve0 > 0
+	          AND e.reserve1 > 0
+	        ORDER BY e.block_number DESC, e.log_index DESC
+	        LIMIT 1) r ON TRUE
+	 WHERE t.chain_id = @chain_id
+	   AND t.pool_address IN @pools
+	   AND t.is_protocol_fee
+	   AND t.block_timestamp >= @start
+	   AND t.block_timestamp < @end
+), fee_priced AS (
+	SELECT m.*,
+	       ` + tokenPriceAtSQL("m.token0", "m.ts") + ` AS p0,
+	       ` + tokenPriceAtSQL("m.token1", "m.ts") + ` AS p1
+	  FROM fee_mints m
+), fee_revenue AS (
+	SELECT pool_address, period_start, SUM(share * (` + poolTVLExpr + `)) AS revenue
+	  FROM fee_priced
+	 GROUP BY pool_address, period_start
+), state AS (
+	SELECT per.pool_address,
+	       per.period_start,
+	       per.period_start + @seconds * INTERVAL '1 second' AS period_end,
+	       p.pool_address AS event_pool,
+	       p.token0_decimals AS d0,
+	       p.token1_decimals AS d1,
+	       LOWER(p.token0_address) AS token0,
+	       LOWER(p.token1_address) AS token1,
+	       c.volume0,
+	       c.volume1,
+	       c.volume_usd,
+	       c.event_count,
+	       c.swap_count,
+	       COALESCE(p.fee_rate, f.fee_rate, @default_fee_rate) AS fee_rate,
+	       CASE WHEN f.fee_to IS NOT NULL AND f.fee_to <> @zero_address THEN f.protocol_fee_share ELSE 0 END AS protocol_share,
+	       fr.revenue AS protocol_revenue
+	  FROM periods per
+	  JOIN liquidity_pools p
+	    ON p.chain_id = @chain_id
+	   AND LOWER(p.pool_address) = per.pool_address
+	  LEFT JOIN dex_factories f
+	    ON f.chain_id = @chain_id
+	   AND f.factory_address = LOWER(p.factory_address)
+	  LEFT JOIN fee_revenue fr
+	    ON fr.pool_address = per.pool_address
+	   AND fr.period_start = per.period_start
+	  LEFT JOIN pool_candles c
+	    ON c.chain_id = @chain_id
+	   AND c.pool_address = per.pool_address
+	   AND c.resolution = @resolution
+	   AND c.bucket_start = per.period_start
+), valued AS (
+	SELECT s.*,
+	       COALESCE(r.reserve0, 0) AS r0,
+	       COALESCE(r.reserve1, 0) AS r1,
+	       ` + tokenPriceAtSQL("s.token0", "LEAST(s.period_end, NOW())") + ` AS p0,
+	       ` + tokenPriceAtSQL("s.token1", "LEAST(s.period_end, NOW())") + ` AS p1,
+	       (SELECT COUNT(*) FROM (
+	          SELECT b.wallet_address
+	            FROM lp_balance_changes b
+	           WHERE b.chain_id = @chain_id
+	             AND b.pool_address = s.pool_address
+	             AND b.wallet_address <> @zero_address
+	             AND b.block_timestamp < s.period_end
+	           GROUP BY b.wallet_address
+	          HAVING SUM(b.delta) > 0) h) AS lp_count
+	  FROM state s
+	  LEFT JOIN LATERAL (
+	       SELECT e.reserve0, e.reserve1
+	         FROM liquidity_pool_events e
+	        WHERE e.chain_id = @chain_id
+	          AND e.pool_address = s.event_pool
+	          AND COALESCE(e.block_timestamp, e.created_at) < s.period_end
+	          AND e.reserve0 > 0
+	          AND e.reserve1 > 0
+	        ORDER BY e.block_number DESC, e.log_index DESC
+	        LIMIT 1) r ON TRUE
+)
+SELECT @chain_id,
+       pool_address,
+       period_start,
+       r0,
+       r1,
+       CASE WHEN tvl < 1e33 THEN tvl END,
+       COALESCE(volume0, 0),
+       COALESCE(volume1, 0),
+       volume_usd,
+       volume_usd * fee_rate,
+       volume_usd * fee_rate * (1 - protocol_share),
+       volume_usd * fee_rate * protocol_share,
+       CASE WHEN protocol_revenue < 1e33 THEN protocol_revenue END,
+       COALESCE(event_count, 0),
+       COALESCE(swap_count, 0),
+       lp_count,
+       NOW()
+  FROM (SELECT valued.*, ` + poolTVLExpr + ` AS tvl FROM valued) v`
+
+// refreshCurrentTVLSQL 按最新价格刷新当前周期的 TVL，储备量取记录中的值，%[1]s 为表名
+var refreshCurrentTVLSQL = `
+UPDATE %[1]s d
+   SET tvl_usd = CASE WHEN v.tvl < 1e33 THEN v.tvl END,
+       updated_at = NOW()
+  FROM (SELECT valued.id, ` + poolTVLExpr + ` AS tvl
+          FROM (SELECT d2.id,
+                       d2.reserve0 AS r0,
+                       d2.reserve1 AS r1,
+                       p.token0_decimals AS d0,
+                       p.token1_decimals AS d1,
+                       ` + tokenPriceAtSQL("LOWER(p.token0_address)", "NOW()") + ` AS p0,
+                       ` + tokenPriceAtSQL("LOWER(p.token1_address)", "NOW()") + ` AS p1
+                  FROM %[1]s d2
+                  JOIN liquidity_pools p
+                    ON p.chain_id = d2.chain_id
+                   AND LOWER(p.pool_address) = d2.pool_address
+                 WHERE d2.chain_id = @chain_id
+                   AND d2.period_start = @period_start) valued) v
+ WHERE d.id = v.id`
+
+// refreshPoolData 重算交易对在 [from, to] 所覆盖周期内的汇总，须在对应 K 线更新之后调用；pools 为小写地址
+func refreshPoolData(tx *gorm.DB, chainId int64, pools []string, from, to time.Time) error {
+	if len(pools) == 0 {
+		return nil
+	}
+	for _, period := range poolDataPeriods {
+		start := candleBucket(from, period.Seconds)
+		end := candleBucket(to, period.Seconds).Add(time.Duration(period.Seconds) * time.Second)
+		if err := tx.Table(period.Table).
+			Where("chain_id = ? AND pool_address IN ? AND period_start >= ? AND period_start < ?", chainId, pools, start, end).
+			Delete(&model.PoolPeriodData{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(fmt.Sprintf(refreshPoolDataSQL, period.Table), map[string]interface{}{
+			"chain_id":         chainId,
+			"pools":            pools,
+			"start":            start,
+			"end":              end,
+			"resolution":       period.Resolution,
+			"seconds":          period.Seconds,
+			"default_fee_rate": defaultPoolFeeRate,
+			"zero_address":     zeroAddress,
+		}).Error; err != nil {
+			return fmt.Errorf("汇总 %s 失败: %w", period.Table, err)
+		}
+	}
+	return nil
+}
+
+// refreshCurrentPoolTVL 按最新代币价格刷新该链当前小时与当天汇总的 TVL，由定价任务在每轮定价后调用
+func refreshCurrentPoolTVL(chainId int64, now time.Time) error {
+	for _, period := range poolDataPeriods {
+		if err := ctx.Ctx.DB.Exec(fmt.Sprintf(refreshCurrentTVLSQL, period.Table), map[string]interface{}{
+			"chain_id":     chainId,
+			"period_start": candleBucket(now, period.Seconds),
+		}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// poolSpan 交易对受影响的时间范围
+type poolSpan struct {
+	From time.Time
+	To   time.Time
+}
+
+// add 把时间点并入范围，零值时间按当前时间处理
+func (s *poolSpan) add(t time.Time) {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	if s.From.IsZero() || t.Before(s.From) {
+		s.From = t
+	}
+	if t.After(s.To) {
+		s.To = t
+	}
+}
+
+// refreshPoolSpans 逐个交易对重算汇总，key 为小写地址
+func refreshPoolSpans(tx *gorm.DB, chainId int64, spans map[string]*poolSpan) error {
+	for pool, span := range spans {
+		if err := refreshPoolData(tx, chainId, []string{pool}, span.From, span.To); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// refreshBatchPoolData 批次写入流动性池事件与 LP Transfer 后重算所涉及交易对的汇总
+func refreshBatchPoolData(tx *gorm.DB, chainId int, batch *decodedBatch) error {
+	spans := make(map[string]*poolSpan)
+	touch := func(pool string, t time.Time) {
+		key := strings.ToLower(pool)
+		if spans[key] == nil {
+			spans[key] = &poolSpan{}
+		}
+		spans[key].add(t)
+	}
+	for _, event := range batch.LiquidityPoolEvents {
+		ts := event.BlockTimestamp
+		if ts.IsZero() {
+			ts = event.CreatedAt
+		}
+		touch(event.PoolAddress, ts)
+	}
+	for _, transfer := range batch.LPTransfers {
+		touch(transfer.PoolAddress, transfer.BlockTimestamp)
+	}
+	return refreshPoolSpans(tx, int64(chainId), spans)
+}
+
+// rollbackPoolSpans 回滚前统计范围内各交易对的事件与 LP Transfer 时间范围，回滚完成后据此重算汇总
+func rollbackPoolSpans(tx *gorm.DB, scope rollbackScope) (map[string]*poolSpan, error) {
+	type spanRow struct {
+		PoolAddress string
+		MinTime     time.Time
+		MaxTime     time.Time
+	}
+	var rows []spanRow
+	if err := scope.where(tx.Model(&model.LiquidityPoolEvent{}), "pool_address").
+		Select("LOWER(pool_address) AS pool_address, MIN(COALESCE(block_timestamp, created_at)) AS min_time, MAX(COALESCE(block_timestamp, created_at)) AS max_time").
+		Group("LOWER(pool_address)").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	var transferRows []spanRow
+	if err := scope.where(tx.Model(&model.LPTokenTransfer{}), "pool_address").
+		Select("pool_address, MIN(COALESCE(block_timestamp, created_at)) AS min_time, MAX(COALESCE(block_timestamp, created_at)) AS max_time").
+		Group("pool_address").
+		Scan(&transferRows).Error; err != nil {
+		return nil, err
+	}
+
+	spans := make(map[string]*poolSpan)
+	for _, row := range append(rows, transferRows...) {
+		if spans[row.PoolAddress] == nil {
+			spans[row.PoolAddress] = &poolSpan{}
+		}
+		spans[row.PoolAddress].add(row.MinTime)
+		spans[row.PoolAddress].add(row.MaxTime)
+	}
+	return spans, nil
+}
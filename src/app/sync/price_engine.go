@@ -0,0 +1,325 @@
+package sync
+
+import (
+	"context"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/config"
+	"github.com/mumu/cryptoSwap/src/core/ctx"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"github.com/shopspring/decimal"
+	"go.uber.org/zap"
+)
+
+// 未在配置中指定时使用的定价参数
+const (
+	defaultPricingInterval   = 60
+	defaultPricingMaxHops    = 3
+	defaultMinLiquidityUSD   = 1000
+	defaultPriceMaxDeviation = 0.2
+	// priceRecordInterval 价格未变化时追加历史记录的最长间隔，保证按时间点查询价格时有足够近的记录
+	priceRecordInterval = time.Hour
+	// priceChangeTolerance 相对变化小于该值视为价格未变化
+	priceChangeTolerance = 1e-9
+)
+
+// pricingSettings 定价参数
+type pricingSettings struct {
+	Interval        time.Duration
+	Anchors         map[int64]map[string]bool // 链 ID -> 小写的锚定稳定币地址
+	MaxHops         int
+	MinLiquidityUSD float64
+	MaxDeviation    float64
+}
+
+// newPricingSettings 根据配置生成定价参数，未配置项取默认值
+func newPricingSettings() pricingSettings {
+	cfg := config.Conf.Pricing
+	settings := pricingSettings{
+		Interval:        defaultPricingInterval * time.Second,
+		Anchors:         make(map[int64]map[string]bool),
+		MaxHops:         defaultPricingMaxHops,
+		MinLiquidityUSD: defaultMinLiquidityUSD,
+		MaxDeviation:    defaultPriceMaxDeviation,
+	}
+	if cfg.Interval > 0 {
+		settings.Interval = time.Duration(cfg.Interval) * time.Second
+	}
+	for key, anchors := range cfg.Anchors {
+		chainId, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			log.Logger.Warn("锚定稳定币配置的链 ID 无效，已忽略", zap.String("chain_id", key))
+			continue
+		}
+		for _, anchor := range anchors {
+			if !common.IsHexAddress(anchor) {
+				log.Logger.Warn("锚定稳定币不是合约地址，已忽略", zap.Int64("chain_id", chainId), zap.String("anchor", anchor))
+				continue
+			}
+			if settings.Anchors[chainId] == nil {
+				settings.Anchors[chainId] = make(map[string]bool)
+			}
+			settings.Anchors[chainId][strings.ToLower(anchor)] = true
+		}
+	}
+	if cfg.MaxHops > 0 {
+		settings.MaxHops = cfg.MaxHops
+	}
+	if cfg.MinLiquidityUSD > 0 {
+		settings.MinLiquidityUSD = cfg.MinLiquidityUSD
+	}
+	if cfg.MaxDeviation > 0 {
+		settings.MaxDeviation = cfg.MaxDeviation
+	}
+	return settings
+}
+
+// isAnchor 代币是否为该链的锚定稳定币，只按地址匹配：符号任何人都能冒用
+func (s pricingSettings) isAnchor(chainId int64, address string) bool {
+	return s.Anchors[chainId][address]
+}
+
+// tokenQuote 推导出的代币价格
+type tokenQuote struct {
+	Symbol       string
+	Price        float64
+	LiquidityUSD float64
+	Hops         int
+	PoolAddress  string
+}
+
+// poolEdge 池子中某个代币指向另一侧代币的边，储备量已按精度换算
+type poolEdge struct {
+	PoolAddress  string
+	Other        string
+	Reserve      float64
+	OtherReserve float64
+}
+
+// priceCandidate 经一个池子得到的候选价格
+type priceCandidate struct {
+	Price        float64
+	LiquidityUSD float64
+	PoolAddress  string
+}
+
+// RunPriceEngine 定期按池子储备量推导各链代币的 USD 价格并写入价格历史，阻塞直到上下文取消
+func RunPriceEngine(c context.Context) error {
+	settings := newPricingSettings()
+	ticker := time.NewTicker(settings.Interval)
+	defer ticker.Stop()
+	log.Logger.Info("代币定价任务已启动",
+		zap.Duration("interval", settings.Interval),
+		zap.Int("max_hops", settings.MaxHops),
+		zap.Int("anchored_chains", len(settings.Anchors)))
+
+	for {
+		// 多副本部署时每个周期只由一个副本计算
+		if tryRunOnce("pricing", settings.Interval) {
+			updateTokenPrices(settings)
+		}
+		select {
+		case <-c.Done():
+			log.Logger.Info("代币定价任务已停止")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// updateTokenPrices 逐链计算并保存代币价格
+func updateTokenPrices(settings pricingSettings) {
+	var chainIds []int64
+	if err := ctx.Ctx.DB.Model(&model.LiquidityPool{}).Distinct("chain_id").Pluck("chain_id", &chainIds).Error; err != nil {
+		log.Logger.Error("查询流动性池所在链失败", zap.Error(err))
+		return
+	}
+	now := time.Now()
+	for _, chainId := range chainIds {
+		if len(settings.Anchors[chainId]) == 0 {
+			log.Logger.Debug("链未配置锚定稳定币，跳过定价", zap.Int64("chain_id", chainId))
+			continue
+		}
+		var pools []model.LiquidityPool
+		if err := ctx.Ctx.DB.Where("chain_id = ? AND is_active = ? AND reserve0 > 0 AND reserve1 > 0", chainId, true).
+			Find(&pools).Error; err != nil {
+			log.Logger.Error("查询流动性池失败", zap.Int64("chain_id", chainId), zap.Error(err))
+			continue
+		}
+		quotes := computeTokenPrices(chainId, pools, settings)
+		saved, err := saveTokenPrices(chainId, quotes, now)
+		if err != nil {
+			log.Logger.Error("保存代币价格失败", zap.Int64("chain_id", chainId), zap.Error(err))
+			continue
+		}
+		// 当前周期的 TVL 随价格变化，不等下一笔事件
+		if err := refreshCurrentPoolTVL(chainId, now); err != nil {
+			log.Logger.Error("刷新交易对当前 TVL 失败", zap.Int64("chain_id", chainId), zap.Error(err))
+		}
+		log.Logger.Info("代币定价完成",
+			zap.Int64("chain_id", chainId),
+			zap.Int("pool_count", len(pools)),
+			zap.Int("priced_count", len(quotes)),
+			zap.Int("saved_count", saved))
+	}
+}
+
+// computeTokenPrices 从链上配置的锚定稳定币出发逐跳推导代币价格
+// 每一跳只使用上一跳已定价的代币：候选池子已定价一侧的流动性需达到阈值，丢弃偏离加权中位数过多的候选后取流动性最深的池子
+func computeTokenPrices(chainId int64, pools []model.LiquidityPool, settings pricingSettings) map[string]*tokenQuote {
+	quotes := make(map[string]*tokenQuote)
+	symbols := make(map[string]string)
+	edges := make(map[string][]poolEdge)
+	for _, pool := range pools {
+		token0 := strings.ToLower(pool.Token0Address)
+		token1 := strings.ToLower(pool.Token1Address)
+		reserve0 := tokenAmount(pool.Reserve0, pool.Token0Decimals)
+		reserve1 := tokenAmount(pool.Reserve1, pool.Token1Decimals)
+		if token0 == "" || token1 == "" || reserve0 <= 0 || reserve1 <= 0 {
+			continue
+		}
+		poolAddress := strings.ToLower(pool.PoolAddress)
+		edges[token0] = append(edges[token0], poolEdge{PoolAddress: poolAddress, Other: token1, Reserve: reserve0, OtherReserve: reserve1})
+		edges[token1] = append(edges[token1], poolEdge{PoolAddress: poolAddress, Other: token0, Reserve: reserve1, OtherReserve: reserve0})
+		symbols[token0] = pool.Token0Symbol
+		symbols[token1] = pool.Token1Symbol
+	}
+	for token, symbol := range symbols {
+		if settings.isAnchor(chainId, token) {
+			quotes[token] = &tokenQuote{Symbol: symbol, Price: 1}
+		}
+	}
+
+	for hop := 1; hop <= settings.MaxHops; hop++ {
+		found := make(map[string]*tokenQuote)
+		for token, tokenEdges := range edges {
+			if quotes[token] != nil {
+				continue
+			}
+			var candidates []priceCandidate
+			for _, edge := range tokenEdges {
+				other := quotes[edge.Other]
+				if other == nil {
+					continue
+				}
+				liquidity := edge.OtherReserve * other.Price
+				if liquidity < settings.MinLiquidityUSD {
+					continue
+				}
+				candidates = append(candidates, priceCandidate{
+					Price:        liquidity / edge.Reserve,
+					LiquidityUSD: liquidity,
+					PoolAddress:  edge.PoolAddress,
+				})
+			}
+			if best, ok := pickPrice(candidates, settings.MaxDeviation); ok {
+				found[token] = &tokenQuote{
+					Symbol:       symbols[token],
+					Price:        best.Price,
+					LiquidityUSD: best.LiquidityUSD,
+					Hops:         hop,
+					PoolAddress:  best.PoolAddress,
+				}
+			}
+		}
+		if len(found) == 0 {
+			break
+		}
+		for token, quote := range found {
+			quotes[token] = quote
+		}
+	}
+	return quotes
+}
+
+// pickPrice 丢弃偏离按流动性加权中位数超过 maxDeviation 的候选，在剩余候选中取流动性最深的
+func pickPrice(candidates []priceCandidate, maxDeviation float64) (priceCandidate, bool) {
+	if len(candidates) == 0 {
+		return priceCandidate{}, false
+	}
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].Price < candidates[j].Price })
+	var total float64
+	for _, candidate := range candidates {
+		total += candidate.LiquidityUSD
+	}
+	median := candidates[len(candidates)-1].Price
+	var cumulative float64
+	for _, candidate := range candidates {
+		cumulative += candidate.LiquidityUSD
+		if cumulative*2 >= total {
+			median = candidate.Price
+			break
+		}
+	}
+
+	var best priceCandidate
+	found := false
+	for _, candidate := range candidates {
+		if math.Abs(candidate.Price/median-1) > maxDeviation {
+			continue
+		}
+		if !found || candidate.LiquidityUSD > best.LiquidityUSD {
+			best = candidate
+			found = true
+		}
+	}
+	return best, found
+}
+
+// saveTokenPrices 价格发生变化或距上次记录超过 priceRecordInterval 时追加价格历史，返回写入条数
+func saveTokenPrices(chainId int64, quotes map[string]*tokenQuote, now time.Time) (int, error) {
+	if len(quotes) == 0 {
+		return 0, nil
+	}
+	var latest []model.TokenPrice
+	if err := ctx.Ctx.DB.Raw(`SELECT DISTINCT ON (token_address) * FROM token_prices
+		WHERE chain_id = ? ORDER BY token_address, computed_at DESC`, chainId).Scan(&latest).Error; err != nil {
+		return 0, err
+	}
+	previous := make(map[string]model.TokenPrice, len(latest))
+	for _, price := range latest {
+		previous[price.TokenAddress] = price
+	}
+
+	var records []*model.TokenPrice
+	for token, quote := range quotes {
+		if last, ok := previous[token]; ok && now.Sub(last.ComputedAt) < priceRecordInterval {
+			lastPrice := last.PriceUSD.InexactFloat64()
+			if lastPrice > 0 && math.Abs(quote.Price/lastPrice-1) < priceChangeTolerance {
+				continue
+			}
+		}
+		records = append(records, &model.TokenPrice{
+			ChainId:      chainId,
+			TokenAddress: token,
+			Symbol:       quote.Symbol,
+			PriceUSD:     decimal.NewFromFloat(quote.Price),
+			LiquidityUSD: decimal.NewFromFloat(quote.LiquidityUSD).Round(2),
+			Hops:         quote.Hops,
+			PoolAddress:  quote.PoolAddress,
+			ComputedAt:   now,
+		})
+	}
+	if len(records) == 0 {
+		return 0, nil
+	}
+	return len(records), ctx.Ctx.DB.CreateInBatches(records, 100).Error
+}
+
+// tokenAmount 将最小单位的数量按精度换算为代币数量
+func tokenAmount(amount string, decimals int) float64 {
+	v, ok := new(big.Float).SetString(amount)
+	if !ok {
+		return 0
+	}
+	v.Quo(v, new(big.Float).SetFloat64(math.Pow10(decimals)))
+	f, _ := v.Float64()
+	return f
+}
@@ -0,0 +1,194 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/testutil"
+)
+
+const (
+	usdc = "0x00000000000000000000000000000000000000a1"
+	weth = "0x00000000000000000000000000000000000000b2"
+	foo  = "0x00000000000000000000000000000000000000c3"
+	bar  = "0x00000000000000000000000000000000000000d4"
+	// fakeUSDC 冒用 USDC 符号的代币
+	fakeUSDC = "0x00000000000000000000000000000000000000e7"
+)
+
+func testPool(address, token0, symbol0 string, decimals0 int, reserve0, token1, symbol1 string, decimals1 int, reserve1 string) model.LiquidityPool {
+	return model.LiquidityPool{
+		PoolAddress:    address,
+		Token0Address:  token0,
+		Token0Symbol:   symbol0,
+		Token0Decimals: decimals0,
+		Reserve0:       reserve0,
+		Token1Address:  token1,
+		Token1Symbol:   symbol1,
+		Token1Decimals: decimals1,
+		Reserve1:       reserve1,
+	}
+}
+
+func TestPickPrice(t *testing.T) {
+	tests := []struct {
+		name       string
+		candidates []priceCandidate
+		deviation  float64
+		wantPool   string
+		wantOK     bool
+	}{
+		{"no candidates", nil, 0.2, "", false},
+		{"single candidate", []priceCandidate{{Price: 3, LiquidityUSD: 10, PoolAddress: "a"}}, 0.2, "a", true},
+		{
+			name: "deepest within deviation wins",
+			candidates: []priceCandidate{
+				{Price: 1.00, LiquidityUSD: 100, PoolAddress: "a"},
+				{Price: 1.05, LiquidityUSD: 300, PoolAddress: "b"},
+				{Price: 0.98, LiquidityUSD: 200, PoolAddress: "c"},
+			},
+			deviation: 0.2, wantPool: "b", wantOK: true,
+		},
+		{
+			name: "deep outlier rejected by liquidity weighted median",
+			candidates: []priceCandidate{
+				{Price: 1.00, LiquidityUSD: 200, PoolAddress: "a"},
+				{Price: 1.01, LiquidityUSD: 150, PoolAddress: "b"},
+				{Price: 5.00, LiquidityUSD: 300, PoolAddress: "manipulated"},
+			},
+			deviation: 0.2, wantPool: "a", wantOK: true,
+		},
+		{
+			name: "median follows liquidity not count",
+			candidates: []priceCandidate{
+				{Price: 1.0, LiquidityUSD: 10, PoolAddress: "a"},
+				{Price: 1.1, LiquidityUSD: 10, PoolAddress: "b"},
+				{Price: 9.0, LiquidityUSD: 1000, PoolAddress: "deep"},
+			},
+			deviation: 0.2, wantPool: "deep", wantOK: true,
+		},
+		{
+			name: "tight deviation keeps only the median",
+			candidates: []priceCandidate{
+				{Price: 1.00, LiquidityUSD: 100, PoolAddress: "a"},
+				{Price: 1.10, LiquidityUSD: 120, PoolAddress: "b"},
+				{Price: 1.20, LiquidityUSD: 110, PoolAddress: "c"},
+			},
+			deviation: 0.01, wantPool: "b", wantOK: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := pickPrice(tt.candidates, tt.deviation)
+			if ok != tt.wantOK || got.PoolAddress != tt.wantPool {
+				t.Errorf("pickPrice() = (%q, %v), want (%q, %v)", got.PoolAddress, ok, tt.wantPool, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestComputeTokenPrices(t *testing.T) {
+	// 2,000,000 USDC / 1,000 WETH：WETH = 2000
+	usdcWeth := testPool("0xPOOL1", usdc, "USDC", 6, "2000000000000", weth, "WETH", 18, "1000000000000000000000")
+	// 被操纵的浅池：1,500 USDC / 0.1 WETH
+	usdcWethThin := testPool("0xPOOL2", weth, "WETH", 18, "100000000000000000", usdc, "USDC", 6, "1500000000")
+	// 100 WETH / 400,000 FOO：FOO = 0.5，两跳
+	wethFoo := testPool("0xPOOL3", foo, "FOO", 18, "400000000000000000000000", weth, "WETH", 18, "100000000000000000000")
+	// 只有 500 USDC 流动性，低于阈值
+	usdcBar := testPool("0xPOOL4", usdc, "USDC", 6, "500000000", bar, "BAR", 18, "1000000000000000000")
+	// 储备量为 0 的池子不参与定价
+	emptyBar := testPool("0xPOOL5", weth, "WETH", 18, "0", bar, "BAR", 18, "0")
+	// 冒名 USDC 的深池：100,000,000 fakeUSDC / 1,000 WETH，若按符号视为锚定币会把 WETH 定为 100000
+	fakeUsdcWeth := testPool("0xPOOL6", fakeUSDC, "USDC", 6, "100000000000000", weth, "WETH", 18, "1000000000000000000000")
+
+	settings := pricingSettings{
+		Anchors:         map[int64]map[string]bool{1: {usdc: true}},
+		MaxHops:         3,
+		MinLiquidityUSD: 1000,
+		MaxDeviation:    0.2,
+	}
+
+	type want struct {
+		price float64
+		hops  int
+		pool  string
+	}
+	tests := []struct {
+		name     string
+		pools    []model.LiquidityPool
+		settings func(pricingSettings) pricingSettings
+		want     map[string]want
+	}{
+		{
+			name:  "anchor and one hop",
+			pools: []model.LiquidityPool{usdcWeth},
+			want:  map[string]want{usdc: {1, 0, ""}, weth: {2000, 1, "0xpool1"}},
+		},
+		{
+			name:  "two hops through priced token",
+			pools: []model.LiquidityPool{usdcWeth, wethFoo},
+			want:  map[string]want{usdc: {1, 0, ""}, weth: {2000, 1, "0xpool1"}, foo: {0.5, 2, "0xpool3"}},
+		},
+		{
+			name:     "max hops limits depth",
+			pools:    []model.LiquidityPool{usdcWeth, wethFoo},
+			settings: func(s pricingSettings) pricingSettings { s.MaxHops = 1; return s },
+			want:     map[string]want{usdc: {1, 0, ""}, weth: {2000, 1, "0xpool1"}},
+		},
+		{
+			name:  "manipulated thin pool does not move price",
+			pools: []model.LiquidityPool{usdcWethThin, usdcWeth},
+			want:  map[string]want{usdc: {1, 0, ""}, weth: {2000, 1, "0xpool1"}},
+		},
+		{
+			name:  "pools below liquidity threshold or without reserves are skipped",
+			pools: []model.LiquidityPool{usdcWeth, usdcBar, emptyBar},
+			want:  map[string]want{usdc: {1, 0, ""}, weth: {2000, 1, "0xpool1"}},
+		},
+		{
+			name:  "spoofed anchor symbol is priced through real anchor",
+			pools: []model.LiquidityPool{fakeUsdcWeth, usdcWeth},
+			want:  map[string]want{usdc: {1, 0, ""}, weth: {2000, 1, "0xpool1"}, fakeUSDC: {0.02, 2, "0xpool6"}},
+		},
+		{
+			name:  "spoofed anchor symbol alone prices nothing",
+			pools: []model.LiquidityPool{fakeUsdcWeth},
+			want:  map[string]want{},
+		},
+		{
+			name:  "anchors of another chain are ignored",
+			pools: []model.LiquidityPool{usdcWeth},
+			settings: func(s pricingSettings) pricingSettings {
+				s.Anchors = map[int64]map[string]bool{5: {usdc: true}}
+				return s
+			},
+			want: map[string]want{},
+		},
+		{
+			name:  "no anchor prices nothing",
+			pools: []model.LiquidityPool{wethFoo},
+			want:  map[string]want{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := settings
+			if tt.settings != nil {
+				s = tt.settings(s)
+			}
+			quotes := computeTokenPrices(1, tt.pools, s)
+			if len(quotes) != len(tt.want) {
+				t.Fatalf("priced %d tokens, want %d", len(quotes), len(tt.want))
+			}
+			for token, w := range tt.want {
+				quote := quotes[token]
+				if quote == nil {
+					t.Fatalf("token %s not priced", token)
+				}
+				if !testutil.ApproxEqual(quote.Price, w.price) || quote.Hops != w.hops || quote.PoolAddress != w.pool {
+					t.Errorf("token %s = {%v %d %q}, want {%v %d %q}", token, quote.Price, quote.Hops, quote.PoolAddress, w.price, w.hops, w.pool)
+				}
+			}
+		})
+	}
+}
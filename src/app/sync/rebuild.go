@@ -0,0 +1,159 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/ctx"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"go.uber.org/zap"
+)
+
+// RebuildOptions 离线重建参数
+type RebuildOptions struct {
+	ChainId     int
+	Contract    string // 为空时重建该链全部监听配置
+	ServiceType string
+	FromBlock   uint64 // 为 0 时从已归档范围的起点开始
+	ToBlock     uint64 // 为 0 时取监听配置的游标
+	DryRun      bool
+	BatchSize   uint64
+}
+
+// RunRebuild 从原始日志归档重新解码并重写派生数据，不访问 RPC
+// 交易对的代币地址取自已登记的 liquidity_pools，未登记的交易对的事件写入死信
+// 每个区段在同一事务中先撤销已有派生数据再写入，与回填相同；不读写游标、区块哈希与归档本身
+func RunRebuild(c context.Context, opts RebuildOptions) error {
+	var chains []model.Chain
+	if opts.Contract != "" {
+		chain, err := findChainConfig(opts.ChainId, opts.Contract, opts.ServiceType)
+		if err != nil {
+			return err
+		}
+		chains = append(chains, *chain)
+	} else {
+		query := ctx.Ctx.DB.Where("chain_id = ?", opts.ChainId)
+		if opts.ServiceType != "" {
+			query = query.Where("service_type = ?", opts.ServiceType)
+		}
+		if err := query.Order("id ASC").Find(&chains).Error; err != nil {
+			return err
+		}
+		if len(chains) == 0 {
+			return fmt.Errorf("未找到链 %d 的监听配置", opts.ChainId)
+		}
+	}
+
+	for _, chain := range chains {
+		if err := rebuildChain(c, chain, opts); err != nil {
+			return fmt.Errorf("重建合约 %s（%s）失败: %w", chain.Address, chain.ServiceType, err)
+		}
+	}
+	return nil
+}
+
+// rebuildChain 重建一条监听配置的派生数据
+func rebuildChain(c context.Context, chain model.Chain, opts RebuildOptions) error {
+	syncer, err := newOfflineSyncer(chain)
+	if err != nil {
+		return err
+	}
+
+	toBlock := opts.ToBlock
+	if toBlock == 0 {
+		toBlock = chain.LastBlockNum
+	}
+	if toBlock > chain.LastBlockNum {
+		return fmt.Errorf("结束区块 %d 超过实时游标 %d", toBlock, chain.LastBlockNum)
+	}
+	fromBlock := opts.FromBlock
+	if fromBlock == 0 {
+		var covering model.RawLogRange
+		if err := ctx.Ctx.DB.Where("chain_row_id = ? AND from_block <= ? AND to_block >= ?", chain.Id, toBlock, toBlock).
+			First(&covering).Error; err != nil {
+			return fmt.Errorf("区块 %d 尚未归档: %w", toBlock, err)
+		}
+		fromBlock = covering.FromBlock
+	}
+	if fromBlock > toBlock {
+		return fmt.Errorf("起始区块 %d 大于结束区块 %d", fromBlock, toBlock)
+	}
+	// 未归档的区段重建后会丢失派生数据，需先用 backfill 补齐归档
+	covered, err := archiveCovers(chain.Id, fromBlock, toBlock)
+	if err != nil {
+		return err
+	}
+	if !covered {
+		return fmt.Errorf("区块 [%d, %d] 未完整归档，请先用 backfill 回填该区段", fromBlock, toBlock)
+	}
+
+	batchSize := opts.BatchSize
+	if batchSize == 0 {
+		batchSize = syncer.settings.MaxBatchSize
+	}
+	log.Logger.Info("开始从归档重建",
+		zap.Int("chain_id", syncer.chainId),
+		zap.String("contract_address", chain.Address),
+		zap.String("service_type", chain.ServiceType),
+		zap.Uint64("from_block", fromBlock),
+		zap.Uint64("to_block", toBlock),
+		zap.Bool("dry_run", opts.DryRun))
+
+	for from := fromBlock; from <= toBlock; {
+		select {
+		case <-c.Done():
+			return fmt.Errorf("重建已取消，已完成至区块 %d", from-1)
+		default:
+		}
+		to := from + batchSize - 1
+		if to > toBlock || to < from {
+			to = toBlock
+		}
+
+		logs, logContext, err := loadRawLogs(syncer.chainId, syncer.contractAddresses, from, to)
+		if err != nil {
+			return err
+		}
+		batch := &decodedBatch{FromBlock: from, ToBlock: to, Logs: logs, FromArchive: true, Offline: true}
+		syncer.decodeLogs(batch, syncer.eventLogs(logs), logContext)
+
+		if opts.DryRun {
+			printBatch(batch)
+		} else if err := replaceBatch(syncer, batch); err != nil {
+			return fmt.Errorf("区段 [%d, %d] 提交失败: %w，已完成至区块 %d", from, to, err, from-1)
+		}
+		log.Logger.Info("重建区段完成",
+			zap.Uint64("from_block", from),
+			zap.Uint64("to_block", to),
+			zap.Int("log_count", len(logs)),
+			zap.Int("event_count", batch.eventCount()))
+
+		if to == toBlock {
+			break
+		}
+		from = to + 1
+	}
+	log.Logger.Info("重建完成", zap.String("contract_address", chain.Address), zap.Uint64("to_block", toBlock))
+	return nil
+}
+
+// newOfflineSyncer 构造不带链客户端的监听器，只用于从归档解码
+// 工厂配置的交易对地址取自已登记的 liquidity_pools
+func newOfflineSyncer(chain model.Chain) (*chainSyncer, error) {
+	decoders, ok := getDecoderRegistry().forService(chain.ServiceType)
+	if !ok {
+		return nil, fmt.Errorf("未知的服务类型 %q", chain.ServiceType)
+	}
+	chainId := int(chain.ChainId)
+	s := &chainSyncer{
+		chain:             chain,
+		chainId:           chainId,
+		decoders:          decoders,
+		topicFilter:       topicFilter(decoders),
+		settings:          newSyncSettings(chainId),
+		contractAddresses: []string{chain.Address},
+	}
+	s.refreshWatchedAddresses()
+	return s, nil
+}
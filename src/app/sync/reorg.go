@@ -0,0 +1,441 @@
+package sync
+
+import (
+	"errors"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/chainclient/evm"
+	"github.com/mumu/cryptoSwap/src/core/ctx"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// blockHashRetention 每个合约保留的已处理区块哈希深度，同时也是可自动回滚的最大重组深度
+const blockHashRetention = 256
+
+// checkReorg 校验游标之后第一个区块的父哈希与已存的游标区块哈希是否一致
+// 不一致说明发生了链重组：定位分叉点并回滚，返回 true 表示本轮应跳过，下一轮从分叉点重新索引
+func checkReorg(evmClient *evm.Evm, lease *chainLease, chainId int, address string, lastBlockNum uint64) (bool, error) {
+	var stored model.BlockHash
+	err := ctx.Ctx.DB.Where("chain_id = ? AND contract_address = ? AND block_number = ?", chainId, address, lastBlockNum).
+		First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		// 首次运行或哈希已被清理，无从比较
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	header, err := evmClient.GetHeaderByNumber(new(big.Int).SetUint64(lastBlockNum + 1))
+	if err != nil {
+		return false, err
+	}
+	if strings.EqualFold(header.ParentHash.Hex(), stored.BlockHash) {
+		return false, nil
+	}
+
+	log.Logger.Warn("检测到链重组，父哈希不一致",
+		zap.Int("chain_id", chainId),
+		zap.String("contract_address", address),
+		zap.Uint64("block_number", lastBlockNum),
+		zap.String("stored_hash", stored.BlockHash),
+		zap.String("parent_hash", header.ParentHash.Hex()))
+
+	forkBlock, err := findForkPoint(evmClient, chainId, address, lastBlockNum)
+	if err != nil {
+		return false, err
+	}
+	if err := rollbackToBlock(lease, chainId, forkBlock); err != nil {
+		return false, err
+	}
+	log.Logger.Info("链重组回滚完成，将从分叉点重新索引",
+		zap.Int("chain_id", chainId),
+		zap.Uint64("fork_block", forkBlock),
+		zap.Uint64("orphaned_from", forkBlock+1))
+	return true, nil
+}
+
+// findForkPoint 从游标区块向前逐个比对已存哈希与当前规范链哈希，返回最后一个仍一致的区块号
+func findForkPoint(evmClient *evm.Evm, chainId int, address string, lastBlockNum uint64) (uint64, error) {
+	var stored []model.BlockHash
+	if err := ctx.Ctx.DB.Where("chain_id = ? AND contract_address = ? AND block_number <= ?", chainId, address, lastBlockNum).
+		Order("block_number DESC").
+		Limit(blockHashRetention).
+		Find(&stored).Error; err != nil {
+		return 0, err
+	}
+
+	forkBlock, found, err := searchForkPoint(stored, lastBlockNum, func(blockNum uint64) (string, error) {
+		header, err := evmClient.GetHeaderByNumber(new(big.Int).SetUint64(blockNum))
+		if err != nil {
+			return "", err
+		}
+		return header.Hash().Hex(), nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		log.Logger.Warn("重组深度超出已存区块哈希范围，回退到最早记录之前",
+			zap.Int("chain_id", chainId),
+			zap.String("contract_address", address),
+			zap.Uint64("fork_block", forkBlock))
+	}
+	return forkBlock, nil
+}
+
+// searchForkPoint 按区块号降序比对已存哈希与 canonicalHash 返回的规范链哈希，返回最后一个仍一致的区块号
+// 全部不一致时 found 为 false，退回到最早记录之前；没有记录时退回保留深度之前
+func searchForkPoint(stored []model.BlockHash, lastBlockNum uint64, canonicalHash func(uint64) (string, error)) (uint64, bool, error) {
+	for _, b := range stored {
+		hash, err := canonicalHash(b.BlockNumber)
+		if err != nil {
+			return 0, false, err
+		}
+		if strings.EqualFold(hash, b.BlockHash) {
+			return b.BlockNumber, true, nil
+		}
+	}
+
+	var forkBlock uint64
+	if len(stored) > 0 && stored[len(stored)-1].BlockNumber > 0 {
+		forkBlock = stored[len(stored)-1].BlockNumber - 1
+	} else if lastBlockNum > blockHashRetention {
+		forkBlock = lastBlockNum - blockHashRetention
+	}
+	return forkBlock, false, nil
+}
+
+// saveBlockHashes 记录本批次处理过的区块哈希（含日志所在区块与批次末尾区块），并清理超出保留深度的旧记录
+func saveBlockHashes(tx *gorm.DB, chainId int, address string, logs []types.Log, target *types.Header) error {
+	hashes := make(map[uint64]model.BlockHash)
+	for _, vLog := range logs {
+		hashes[vLog.BlockNumber] = model.BlockHash{
+			ChainId:         int64(chainId),
+			ContractAddress: address,
+			BlockNumber:     vLog.BlockNumber,
+			BlockHash:       vLog.BlockHash.Hex(),
+		}
+	}
+	targetNum := target.Number.Uint64()
+	hashes[targetNum] = model.BlockHash{
+		ChainId:         int64(chainId),
+		ContractAddress: address,
+		BlockNumber:     targetNum,
+		BlockHash:       target.Hash().Hex(),
+		ParentHash:      target.ParentHash.Hex(),
+	}
+
+	rows := make([]model.BlockHash, 0, len(hashes))
+	for _, h := range hashes {
+		rows = append(rows, h)
+	}
+
+	if err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "chain_id"}, {Name: "contract_address"}, {Name: "block_number"}},
+		DoUpdates: clause.AssignmentColumns([]string{"block_hash", "parent_hash"}),
+	}).CreateInBatches(rows, 100).Error; err != nil {
+		log.Logger.Error("保存区块哈希失败", zap.Error(err))
+		return err
+	}
+	if targetNum > blockHashRetention {
+		if err := tx.Where("chain_id = ? AND contract_address = ? AND block_number < ?", chainId, address, targetNum-blockHashRetention).
+			Delete(&model.BlockHash{}).Error; err != nil {
+			log.Logger.Error("清理历史区块哈希失败", zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+// rollbackScope 回滚范围：链上 [FromBlock, ToBlock] 区块内的派生数据
+type rollbackScope struct {
+	ChainId   int
+	FromBlock uint64
+	ToBlock   uint64   // 0 表示不设上限
+	Addresses []string // 合约地址，为空表示整条链
+	DropPairs bool     // 是否删除范围内 PairCreated 登记的交易对，仅链重组时需要
+}
+
+// where 按范围过滤，addressColumn 为事件表中表示合约地址的列
+func (r rollbackScope) where(db *gorm.DB, addressColumn string) *gorm.DB {
+	return r.whereBlock(db, "block_number", addressColumn)
+}
+
+// whereBlock 按范围过滤，blockColumn 为表示区块号的列
+func (r rollbackScope) whereBlock(db *gorm.DB, blockColumn, addressColumn string) *gorm.DB {
+	db = db.Where("chain_id = ? AND "+blockColumn+" >= ?", r.ChainId, r.FromBlock)
+	if r.ToBlock > 0 {
+		db = db.Where(blockColumn+" <= ?", r.ToBlock)
+	}
+	if len(r.Addresses) > 0 {
+		addresses := make([]string, 0, len(r.Addresses))
+		for _, address := range r.Addresses {
+			addresses = append(addresses, strings.ToLower(address))
+		}
+		db = db.Where("LOWER("+addressColumn+") IN ?", addresses)
+	}
+	return db
+}
+
+// rollbackToBlock 回滚该链分叉点之后的全部派生数据与聚合值，并把游标退回分叉点
+// 重组影响整条链，因此按链回滚；各合约的监听协程每轮都会从数据库重新读取游标
+func rollbackToBlock(lease *chainLease, chainId int, forkBlock uint64) error {
+	scope := rollbackScope{ChainId: chainId, FromBlock: forkBlock + 1, DropPairs: true}
+	return ctx.Ctx.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lease.fence(tx); err != nil {
+			return err
+		}
+		if err := rollbackRange(tx, scope); err != nil {
+			return err
+		}
+		if err := tx.Where("chain_id = ? AND block_number > ?", chainId, forkBlock).
+			Delete(&model.BlockHash{}).Error; err != nil {
+			log.Logger.Error("删除孤块哈希失败", zap.Error(err))
+			return err
+		}
+		if err := orphanRawLogs(tx, chainId, forkBlock); err != nil {
+			log.Logger.Error("标记孤块原始日志失败", zap.Error(err))
+			return err
+		}
+		if err := deleteOrphanedDeadLetters(tx, chainId, forkBlock); err != nil {
+			log.Logger.Error("删除孤块死信失败", zap.Error(err))
+			return err
+		}
+		return tx.Model(&model.Chain{}).
+			Where("chain_id = ? AND last_block_num > ?", int64(chainId), forkBlock).
+			Update("last_block_num", forkBlock).Error
+	})
+}
+
+// rollbackRange 撤销范围内的派生数据与聚合值，供链重组回滚与区块回填（先删后写）共用
+func rollbackRange(tx *gorm.DB, scope rollbackScope) error {
+	// 交易对汇总同时依赖流动性事件与 LP Transfer，两者都回滚后再重算
+	spans, err := rollbackPoolSpans(tx, scope)
+	if err != nil {
+		log.Logger.Error("统计待回滚的交易对汇总失败", zap.Error(err))
+		return err
+	}
+	if err := rollbackStakingRecords(tx, scope); err != nil {
+		log.Logger.Error("回滚质押记录失败", zap.Error(err))
+		return err
+	}
+	if err := rollbackStakingEvents(tx, scope); err != nil {
+		log.Logger.Error("回滚质押合约事件失败", zap.Error(err))
+		return err
+	}
+	if err := rollbackLiquidityPoolEvents(tx, scope); err != nil {
+		log.Logger.Error("回滚流动性池事件失败", zap.Error(err))
+		return err
+	}
+	if err := rollbackLPTransfers(tx, scope); err != nil {
+		log.Logger.Error("回滚 LP Transfer 失败", zap.Error(err))
+		return err
+	}
+	if err := rollbackAirdropEvents(tx, scope); err != nil {
+		log.Logger.Error("回滚空投事件失败", zap.Error(err))
+		return err
+	}
+	if err := refreshPoolSpans(tx, int64(scope.ChainId), spans); err != nil {
+		log.Logger.Error("重算交易对汇总失败", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// rollbackStakingRecords 撤销范围内的质押/提现记录对 users.total_amount 与已计入积分的 jf_amount 的影响，再删除记录
+func rollbackStakingRecords(tx *gorm.DB, scope rollbackScope) error {
+	var records []*model.UserOperationRecord
+	if err := scope.where(tx, "contract_address").
+		Where("event_type IN ?", []string{"Staked", "Withdrawn"}).
+		Find(&records).Error; err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	for _, record := range records {
+		amount := record.Amount
+		if record.EventType == "Withdrawn" {
+			amount = amount.Neg()
+		}
+		// 操作时间不晚于 jf_time 的记录已被积分任务计入 jf_amount，一并撤销
+		if err := tx.Exec(`
+			UPDATE users SET
+				total_amount = total_amount - ?,
+				jf_amount = jf_amount - CASE WHEN jf_time >= ? THEN ?::numeric ELSE 0 END
+			WHERE chain_id = ? AND token_address = ? AND address = ?
+		`, amount, record.OperationTime, amount, scope.ChainId, record.TokenAddress, record.Address).Error; err != nil {
+			return err
+		}
+	}
+
+	log.Logger.Info("回滚质押记录", zap.Int("chain_id", scope.ChainId), zap.Int("record_count", len(records)))
+	ids := make([]int64, 0, len(records))
+	for _, record := range records {
+		ids = append(ids, record.Id)
+	}
+	return tx.Where("id IN ?", ids).Delete(&model.UserOperationRecord{}).Error
+}
+
+// rollbackLiquidityPoolEvents 删除范围内的流动性池事件并扣减池子交易计数，
+// 储备量来自范围内 Sync 的池子退回到剩余的最近一条事件
+func rollbackLiquidityPoolEvents(tx *gorm.DB, scope rollbackScope) error {
+	type poolCount struct {
+		PoolAddress string
+		Cnt         int64
+		MinTime     time.Time
+		MaxTime     time.Time
+	}
+	var counts []poolCount
+	if err := scope.where(tx.Model(&model.LiquidityPoolEvent{}), "pool_address").
+		Select("pool_address, COUNT(*) AS cnt, MIN(COALESCE(block_timestamp, created_at)) AS min_time, MAX(COALESCE(block_timestamp, created_at)) AS max_time").
+		Group("pool_address").
+		Scan(&counts).Error; err != nil {
+		return err
+	}
+	for _, c := range counts {
+		if err := tx.Model(&model.LiquidityPool{}).
+			Where("chain_id = ? AND pool_address = ?", scope.ChainId, c.PoolAddress).
+			Update("tx_count", gorm.Expr("GREATEST(tx_count - ?, 0)", c.Cnt)).Error; err != nil {
+			return err
+		}
+	}
+	if err := scope.where(tx, "pool_address").
+		Delete(&model.LiquidityPoolEvent{}).Error; err != nil {
+		return err
+	}
+	if err := restorePoolReserves(tx, scope); err != nil {
+		return err
+	}
+	// 被删除事件所在周期的 K 线由剩余事件重新聚合，须在删除交易对之前进行
+	for _, c := range counts {
+		if err := refreshCandles(tx, int64(scope.ChainId), []string{c.PoolAddress}, c.MinTime, c.MaxTime); err != nil {
+			return err
+		}
+	}
+	if !scope.DropPairs {
+		return nil
+	}
+	// 孤块中 PairCreated 登记的交易对一并删除，重新索引时会再次发现
+	return tx.Where("chain_id = ? AND created_block >= ?", scope.ChainId, scope.FromBlock).
+		Delete(&model.LiquidityPool{}).Error
+}
+
+// restorePoolReserves 储备量位置落在范围内的池子，取剩余事件中最近一条带储备量的记录；
+// 没有可用记录时清空储备量位置，由之后的第一条 Sync 覆盖
+func restorePoolReserves(tx *gorm.DB, scope rollbackScope) error {
+	var pools []model.LiquidityPool
+	if err := scope.whereBlock(tx, "reserve_block_num", "pool_address").Find(&pools).Error; err != nil {
+		return err
+	}
+	for _, pool := range pools {
+		var latest []model.LiquidityPoolEvent
+		if err := tx.Where("chain_id = ? AND pool_address = ? AND (reserve0 > 0 OR reserve1 > 0)", pool.ChainId, pool.PoolAddress).
+			Order("block_number DESC, log_index DESC NULLS LAST, id DESC").
+			Limit(1).
+			Find(&latest).Error; err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"reserve_block_num": nil, "reserve_log_index": nil}
+		if len(latest) > 0 {
+			updates = map[string]interface{}{
+				"reserve0":          latest[0].Reserve0,
+				"reserve1":          latest[0].Reserve1,
+				"price":             latest[0].Price,
+				"reserve_block_num": latest[0].BlockNumber,
+				"reserve_log_index": latest[0].LogIndex,
+			}
+		}
+		if err := tx.Model(&model.LiquidityPool{}).Where("id = ?", pool.Id).Updates(updates).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rollbackAirdropEvents 删除范围内的空投领取与总奖励更新事件，并恢复白名单总奖励
+// airdrop_campaigns 由重新索引时 AirdropCreated 的 UPSERT 覆盖，这里不做处理
+func rollbackAirdropEvents(tx *gorm.DB, scope rollbackScope) error {
+	if err := scope.where(tx, "contract_address").
+		Delete(&model.RewardClaimedEvent{}).Error; err != nil {
+		return err
+	}
+
+	var removed []model.TotalRewardUpdatedEvent
+	if err := scope.where(tx, "contract_address").
+		Order("block_number ASC, log_index ASC").
+		Find(&removed).Error; err != nil {
+		return err
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+	ids := make([]int64, 0, len(removed))
+	for _, e := range removed {
+		ids = append(ids, e.Id)
+	}
+	if err := tx.Where("id IN ?", ids).
+		Delete(&model.TotalRewardUpdatedEvent{}).Error; err != nil {
+		return err
+	}
+	return restoreWhitelistTotals(tx, removed)
+}
+
+// restoreWhitelistTotals 对受影响的 (airdrop_id, wallet) 重新确定白名单总奖励：
+// 仍有剩余更新事件则取最新一条，否则恢复最早被删除事件记录的 previous_total_reward
+func restoreWhitelistTotals(tx *gorm.DB, removed []model.TotalRewardUpdatedEvent) error {
+	type whitelistKey struct {
+		AirdropId string
+		Wallet    string
+	}
+	sort.SliceStable(removed, func(i, j int) bool {
+		if removed[i].BlockNumber != removed[j].BlockNumber {
+			return removed[i].BlockNumber < removed[j].BlockNumber
+		}
+		return removed[i].LogIndex < removed[j].LogIndex
+	})
+	earliest := make(map[whitelistKey]model.TotalRewardUpdatedEvent)
+	for _, e := range removed {
+		key := whitelistKey{AirdropId: e.AirdropId, Wallet: strings.ToLower(e.UserAddress)}
+		if _, ok := earliest[key]; !ok {
+			earliest[key] = e
+		}
+	}
+
+	for key, first := range earliest {
+		var latest model.TotalRewardUpdatedEvent
+		err := tx.Where("airdrop_id = ? AND user_address = ?", key.AirdropId, key.Wallet).
+			Order("block_number DESC, log_index DESC").
+			First(&latest).Error
+		switch {
+		case err == nil:
+			err = tx.Exec(`UPDATE airdrop_whitelist SET total_reward = ? WHERE airdrop_id = ? AND wallet_address = ?`,
+				latest.TotalReward, key.AirdropId, key.Wallet).Error
+		case errors.Is(err, gorm.ErrRecordNotFound) && first.PreviousTotalReward == nil:
+			// 白名单记录由事件创建，回滚时一并删除（保留已写入 proof 的记录）
+			err = tx.Exec(`DELETE FROM airdrop_whitelist WHERE airdrop_id = ? AND wallet_address = ? AND proof IS NULL`,
+				key.AirdropId, key.Wallet).Error
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			err = tx.Exec(`UPDATE airdrop_whitelist SET total_reward = ? WHERE airdrop_id = ? AND wallet_address = ?`,
+				*first.PreviousTotalReward, key.AirdropId, key.Wallet).Error
+		}
+		if err != nil {
+			log.Logger.Error("恢复白名单总奖励失败",
+				zap.String("airdrop_id", key.AirdropId),
+				zap.String("wallet", key.Wallet),
+				zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
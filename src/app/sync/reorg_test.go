@@ -0,0 +1,63 @@
+package sync
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/mumu/cryptoSwap/src/app/model"
+)
+
+func TestSearchForkPoint(t *testing.T) {
+	stored := []model.BlockHash{
+		{BlockNumber: 105, BlockHash: "0xorphan105"},
+		{BlockNumber: 104, BlockHash: "0xorphan104"},
+		{BlockNumber: 103, BlockHash: "0xABC103"},
+		{BlockNumber: 100, BlockHash: "0xabc100"},
+	}
+	canonical := map[uint64]string{
+		105: "0xnew105",
+		104: "0xnew104",
+		103: "0xabc103",
+		100: "0xabc100",
+	}
+
+	tests := []struct {
+		name         string
+		stored       []model.BlockHash
+		lastBlockNum uint64
+		canonical    map[uint64]string
+		want         uint64
+		wantFound    bool
+	}{
+		{"cursor block still canonical", stored[2:], 103, canonical, 103, true},
+		{"fork below orphaned blocks, hash compare ignores case", stored, 105, canonical, 103, true},
+		{"skips gaps between stored blocks", stored, 105, map[uint64]string{100: "0xabc100"}, 100, true},
+		{"all orphaned falls back before oldest record", stored, 105, map[uint64]string{}, 99, false},
+		{"oldest record is genesis", []model.BlockHash{{BlockNumber: 0, BlockHash: "0xold"}}, 10, map[uint64]string{}, 0, false},
+		{"no records falls back by retention depth", nil, 1000, nil, 1000 - blockHashRetention, false},
+		{"no records near genesis", nil, 10, nil, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, found, err := searchForkPoint(tt.stored, tt.lastBlockNum, func(blockNum uint64) (string, error) {
+				return tt.canonical[blockNum], nil
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tt.want || found != tt.wantFound {
+				t.Errorf("searchForkPoint() = (%d, %v), want (%d, %v)", got, found, tt.want, tt.wantFound)
+			}
+		})
+	}
+}
+
+func TestSearchForkPointHeaderError(t *testing.T) {
+	want := errors.New("rpc unavailable")
+	_, _, err := searchForkPoint([]model.BlockHash{{BlockNumber: 1, BlockHash: "0x1"}}, 1, func(uint64) (string, error) {
+		return "", want
+	})
+	if !errors.Is(err, want) {
+		t.Fatalf("err = %v, want %v", err, want)
+	}
+}
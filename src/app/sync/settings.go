@@ -0,0 +1,187 @@
+package sync
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mumu/cryptoSwap/src/core/chainclient/evm"
+	"github.com/mumu/cryptoSwap/src/core/config"
+)
+
+// 未在配置中指定时使用的默认调优参数
+const (
+	defaultConfirmations = 6
+	defaultPollInterval  = 12
+	defaultBatchSize     = 1000
+	defaultMinBatchSize  = 10
+	// batchGrowAfter 连续成功多少批次后尝试扩大批次
+	batchGrowAfter = 3
+)
+
+// 最终性模式
+const (
+	finalityLatest    = "latest"
+	finalitySafe      = "safe"
+	finalityFinalized = "finalized"
+)
+
+// 监听模式
+const (
+	modePoll      = "poll"
+	modeSubscribe = "subscribe"
+)
+
+// syncSettings 单条链的索引器调优参数
+type syncSettings struct {
+	Mode          string
+	Confirmations uint64
+	Finality      string
+	PollInterval  time.Duration
+	MaxBatchSize  uint64
+	MinBatchSize  uint64
+}
+
+// newSyncSettings 根据链配置生成调优参数，未配置项取默认值
+func newSyncSettings(chainId int) syncSettings {
+	cfg, _ := config.GetChainConfig(chainId)
+	settings := syncSettings{
+		Mode:          modePoll,
+		Confirmations: defaultConfirmations,
+		Finality:      finalityLatest,
+		PollInterval:  defaultPollInterval * time.Second,
+		MaxBatchSize:  defaultBatchSize,
+		MinBatchSize:  defaultMinBatchSize,
+	}
+	if strings.ToLower(cfg.Mode) == modeSubscribe {
+		settings.Mode = modeSubscribe
+	}
+	if cfg.Confirmations != nil {
+		settings.Confirmations = *cfg.Confirmations
+	}
+	switch strings.ToLower(cfg.Finality) {
+	case finalitySafe:
+		settings.Finality = finalitySafe
+	case finalityFinalized:
+		settings.Finality = finalityFinalized
+	}
+	if cfg.PollInterval > 0 {
+		settings.PollInterval = time.Duration(cfg.PollInterval) * time.Second
+	}
+	if cfg.BatchSize > 0 {
+		settings.MaxBatchSize = cfg.BatchSize
+	}
+	if cfg.MinBatchSize > 0 {
+		settings.MinBatchSize = cfg.MinBatchSize
+	}
+	if settings.MinBatchSize > settings.MaxBatchSize {
+		settings.MinBatchSize = settings.MaxBatchSize
+	}
+	return settings
+}
+
+// safeBlockNumber 返回本链可以安全索引到的最高区块
+// finality 为 safe/finalized 时直接读取对应标签的区块头，否则用最新高度减去确认块数
+func (s syncSettings) safeBlockNumber(evmClient *evm.Evm) (uint64, error) {
+	if s.Finality == finalitySafe || s.Finality == finalityFinalized {
+		return evmClient.GetBlockNumberByTag(s.Finality)
+	}
+	currentBlock, err := evmClient.GetBlockNumber()
+	if err != nil {
+		return 0, err
+	}
+	if currentBlock < s.Confirmations {
+		return 0, nil
+	}
+	return currentBlock - s.Confirmations, nil
+}
+
+// indexesTip 是否索引到最新区块（finality 为 latest 且确认块数为 0）
+func (s syncSettings) indexesTip() bool {
+	return s.Finality == finalityLatest && s.Confirmations == 0
+}
+
+// batchSizer 自适应批次大小：RPC 报返回结果过多或区块范围过大时减半，连续成功后逐步翻倍恢复
+// 回填时多个区段并发拉取共用同一个 batchSizer，因此加锁
+type batchSizer struct {
+	mu        sync.Mutex
+	size      uint64
+	min       uint64
+	max       uint64
+	successes int
+}
+
+func newBatchSizer(settings syncSettings) *batchSizer {
+	return &batchSizer{
+		size: settings.MaxBatchSize,
+		min:  settings.MinBatchSize,
+		max:  settings.MaxBatchSize,
+	}
+}
+
+// Size 当前批次大小
+func (b *batchSizer) Size() uint64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.size
+}
+
+// OnSuccess 记录一次成功的拉取，连续成功达到阈值后扩大批次
+func (b *batchSizer) OnSuccess() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.size >= b.max {
+		return
+	}
+	b.successes++
+	if b.successes < batchGrowAfter {
+		return
+	}
+	b.successes = 0
+	b.size *= 2
+	if b.size > b.max {
+		b.size = b.max
+	}
+}
+
+// OnError 根据错误类型调整批次，返回 true 表示批次已缩小，可立即重试
+func (b *batchSizer) OnError(err error) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.successes = 0
+	if !isRangeError(err) || b.size <= b.min {
+		return false
+	}
+	b.size /= 2
+	if b.size < b.min {
+		b.size = b.min
+	}
+	return true
+}
+
+// rangeErrorKeywords 各家 RPC 对结果过多/区块范围过大的错误描述
+var rangeErrorKeywords = []string{
+	"too many results",
+	"query returned more than",
+	"block range",
+	"range is too large",
+	"range too large",
+	"exceed maximum block range",
+	"limit exceeded",
+	"response size exceeded",
+	"log response size",
+}
+
+// isRangeError 判断是否为可通过缩小区块范围解决的错误
+func isRangeError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	for _, keyword := range rangeErrorKeywords {
+		if strings.Contains(msg, keyword) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,80 @@
+package sync
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestIsRangeError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"alchemy", errors.New("Log response size exceeded. You can make eth_getLogs requests with up to a 2K block range"), true},
+		{"infura", errors.New("query returned more than 10000 results"), true},
+		{"geth", errors.New("exceed maximum block range: 5000"), true},
+		{"upper case", errors.New("TOO MANY RESULTS"), true},
+		{"timeout", errors.New("context deadline exceeded"), false},
+		{"connection", errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isRangeError(tt.err); got != tt.want {
+				t.Errorf("isRangeError(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBatchSizer(t *testing.T) {
+	rangeErr := errors.New("block range is too large")
+	otherErr := errors.New("connection reset by peer")
+
+	// op: 'r' 范围错误，'o' 其他错误，'s' 成功
+	tests := []struct {
+		name       string
+		min, max   uint64
+		ops        string
+		wantShrunk []bool // 每次 OnError 的返回值
+		wantSize   uint64
+	}{
+		{"starts at max", 10, 1000, "", nil, 1000},
+		{"range error halves", 10, 1000, "r", []bool{true}, 500},
+		{"other error keeps size", 10, 1000, "o", []bool{false}, 1000},
+		{"clamps to min", 10, 30, "rr", []bool{true, true}, 10},
+		{"at min cannot shrink", 10, 20, "rrr", []bool{true, false, false}, 10},
+		{"grows after consecutive successes", 10, 1000, "rrsss", []bool{true, true}, 500},
+		{"not enough successes", 10, 1000, "rrss", []bool{true, true}, 250},
+		{"error resets success streak", 10, 1000, "rrssossss", []bool{true, true, false}, 500},
+		{"growth clamps to max", 10, 1000, "rrrsssssssss", []bool{true, true, true}, 1000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sizer := newBatchSizer(syncSettings{MinBatchSize: tt.min, MaxBatchSize: tt.max})
+			var shrunk []bool
+			for _, op := range tt.ops {
+				switch op {
+				case 'r':
+					shrunk = append(shrunk, sizer.OnError(rangeErr))
+				case 'o':
+					shrunk = append(shrunk, sizer.OnError(otherErr))
+				case 's':
+					sizer.OnSuccess()
+				}
+			}
+			if len(shrunk) != len(tt.wantShrunk) {
+				t.Fatalf("OnError results = %v, want %v", shrunk, tt.wantShrunk)
+			}
+			for i := range shrunk {
+				if shrunk[i] != tt.wantShrunk[i] {
+					t.Fatalf("OnError results = %v, want %v", shrunk, tt.wantShrunk)
+				}
+			}
+			if got := sizer.Size(); got != tt.wantSize {
+				t.Errorf("Size() = %d, want %d", got, tt.wantSize)
+			}
+		})
+	}
+}
@@ -0,0 +1,157 @@
+package sync
+
+import (
+	"strings"
+	"time"
+
+	"github.com/mumu/cryptoSwap/src/app/model"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// StakingEvents StakeV2 质押合约的收益领取、池子创建、份额价格与暂停事件
+type StakingEvents struct {
+	RewardClaims []*model.StakingRewardClaim
+	Pools        []*model.StakingPool
+	SharePrices  []*model.StakingSharePrice
+	PauseEvents  []*model.StakingPauseEvent
+}
+
+// count 事件总数
+func (s *StakingEvents) count() int {
+	return len(s.RewardClaims) + len(s.Pools) + len(s.SharePrices) + len(s.PauseEvents)
+}
+
+// stakingEvents 返回批次中的质押合约事件集合，不存在时创建
+func (b *decodedBatch) stakingEvents() *StakingEvents {
+	if b.StakingEvents == nil {
+		b.StakingEvents = &StakingEvents{}
+	}
+	return b.StakingEvents
+}
+
+// handleClaimRewards ClaimRewards(address indexed user, uint256 indexed poolId, uint256 reward, uint256 claimedAt)
+func handleClaimRewards(e *decodedEvent, batch *decodedBatch) error {
+	events := batch.stakingEvents()
+	events.RewardClaims = append(events.RewardClaims, &model.StakingRewardClaim{
+		ChainId:         int64(e.ChainId),
+		ContractAddress: strings.ToLower(e.Log.Address.Hex()),
+		UserAddress:     strings.ToLower(e.address("user")),
+		PoolId:          e.bigInt("poolId").Int64(),
+		Reward:          e.bigInt("reward").String(),
+		ClaimedAt:       time.Unix(e.bigInt("claimedAt").Int64(), 0),
+		BlockNumber:     int64(e.Log.BlockNumber),
+		BlockTimestamp:  e.BlockTime,
+		TxHash:          strings.ToLower(e.Log.TxHash.Hex()),
+		LogIndex:        int(e.Log.Index),
+	})
+	return nil
+}
+
+// handlePoolCreated PoolCreated(uint256 indexed poolId, address indexed token, uint256 lockDuration, string name)
+func handlePoolCreated(e *decodedEvent, batch *decodedBatch) error {
+	events := batch.stakingEvents()
+	events.Pools = append(events.Pools, &model.StakingPool{
+		ChainId:         int64(e.ChainId),
+		ContractAddress: strings.ToLower(e.Log.Address.Hex()),
+		PoolId:          e.bigInt("poolId").Int64(),
+		TokenAddress:    strings.ToLower(e.address("token")),
+		LockDuration:    e.bigInt("lockDuration").Int64(),
+		Name:            e.str("name"),
+		BlockNumber:     int64(e.Log.BlockNumber),
+		BlockTimestamp:  e.BlockTime,
+		TxHash:          strings.ToLower(e.Log.TxHash.Hex()),
+		LogIndex:        int(e.Log.Index),
+	})
+	return nil
+}
+
+// handleSharePriceUpdated SharePriceUpdated(uint256 date, uint256 price)
+func handleSharePriceUpdated(e *decodedEvent, batch *decodedBatch) error {
+	events := batch.stakingEvents()
+	events.SharePrices = append(events.SharePrices, &model.StakingSharePrice{
+		ChainId:         int64(e.ChainId),
+		ContractAddress: strings.ToLower(e.Log.Address.Hex()),
+		PriceDate:       e.bigInt("date").String(),
+		Price:           e.bigInt("price").String(),
+		BlockNumber:     int64(e.Log.BlockNumber),
+		BlockTimestamp:  e.BlockTime,
+		TxHash:          strings.ToLower(e.Log.TxHash.Hex()),
+		LogIndex:        int(e.Log.Index),
+	})
+	return nil
+}
+
+// handlePaused Paused(address account)
+func handlePaused(e *decodedEvent, batch *decodedBatch) error {
+	appendPauseEvent(e, batch, true)
+	return nil
+}
+
+// handleUnpaused Unpaused(address account)
+func handleUnpaused(e *decodedEvent, batch *decodedBatch) error {
+	appendPauseEvent(e, batch, false)
+	return nil
+}
+
+func appendPauseEvent(e *decodedEvent, batch *decodedBatch, paused bool) {
+	events := batch.stakingEvents()
+	events.PauseEvents = append(events.PauseEvents, &model.StakingPauseEvent{
+		ChainId:         int64(e.ChainId),
+		ContractAddress: strings.ToLower(e.Log.Address.Hex()),
+		Paused:          paused,
+		Account:         strings.ToLower(e.address("account")),
+		BlockNumber:     int64(e.Log.BlockNumber),
+		BlockTimestamp:  e.BlockTime,
+		TxHash:          strings.ToLower(e.Log.TxHash.Hex()),
+		LogIndex:        int(e.Log.Index),
+	})
+}
+
+// saveStakingEvents 保存质押合约事件，重复事件按唯一约束忽略
+// 合约当前的暂停状态与份额价格由视图 staking_contract_state 从事件表得出，无需单独维护
+func saveStakingEvents(tx *gorm.DB, events *StakingEvents) error {
+	doNothing := clause.OnConflict{DoNothing: true}
+	if len(events.Pools) > 0 {
+		if err := tx.Clauses(doNothing).Create(&events.Pools).Error; err != nil {
+			log.Logger.Error("保存质押池创建事件失败", zap.Error(err))
+			return err
+		}
+	}
+	if len(events.RewardClaims) > 0 {
+		if err := tx.Clauses(doNothing).Create(&events.RewardClaims).Error; err != nil {
+			log.Logger.Error("保存质押收益领取事件失败", zap.Error(err))
+			return err
+		}
+	}
+	if len(events.SharePrices) > 0 {
+		if err := tx.Clauses(doNothing).Create(&events.SharePrices).Error; err != nil {
+			log.Logger.Error("保存份额价格事件失败", zap.Error(err))
+			return err
+		}
+	}
+	if len(events.PauseEvents) > 0 {
+		if err := tx.Clauses(doNothing).Create(&events.PauseEvents).Error; err != nil {
+			log.Logger.Error("保存合约暂停事件失败", zap.Error(err))
+			return err
+		}
+	}
+	return nil
+}
+
+// rollbackStakingEvents 删除范围内的 StakeV2 质押合约事件，范围内创建的质押池一并删除
+func rollbackStakingEvents(tx *gorm.DB, scope rollbackScope) error {
+	for _, table := range []interface{}{
+		&model.StakingRewardClaim{},
+		&model.StakingPool{},
+		&model.StakingSharePrice{},
+		&model.StakingPauseEvent{},
+	} {
+		if err := scope.where(tx, "contract_address").Delete(table).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
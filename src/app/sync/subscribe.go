@@ -0,0 +1,228 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"go.uber.org/zap"
+)
+
+// 订阅通道缓冲大小
+const (
+	subscribeHeadBuffer = 16
+	subscribeLogBuffer  = 1024
+)
+
+// errWatchListChanged 监听地址列表变化（发现新交易对），需要重新订阅
+var errWatchListChanged = errors.New("watch list changed")
+
+// runSubscribe 订阅模式：通过 WebSocket 接收新区块与日志
+// 订阅建立前遗漏的区块、断线期间的区块以及重组回滚的区块均通过 eth_getLogs 补齐
+func (s *chainSyncer) runSubscribe(c context.Context) {
+	for {
+		err := s.stream(c)
+		if c.Err() != nil {
+			log.Logger.Info("统一事件监听任务已停止", zap.Int("chain_id", s.chainId))
+			return
+		}
+		if errors.Is(err, errWatchListChanged) {
+			// 新订阅建立前的区块会通过轮询补齐
+			log.Logger.Info("监听地址变化，重新订阅", zap.Int("chain_id", s.chainId))
+			continue
+		}
+		log.Logger.Warn("订阅中断，回退轮询后重新订阅", zap.Int("chain_id", s.chainId), zap.Error(err))
+
+		// 断线期间先按轮询间隔拉取一次，避免长时间停滞
+		select {
+		case <-c.Done():
+			log.Logger.Info("统一事件监听任务已停止", zap.Int("chain_id", s.chainId))
+			return
+		case <-time.After(s.settings.PollInterval):
+			s.pollOnce()
+		}
+	}
+}
+
+// stream 建立一次订阅并处理推送，订阅出错时返回
+func (s *chainSyncer) stream(c context.Context) error {
+	subCtx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	logsCh := make(chan types.Log, subscribeLogBuffer)
+	logSub, err := s.evmClient.SubscribeFilterLogs(subCtx, s.contractAddresses, s.topicFilter, logsCh)
+	if err != nil {
+		return err
+	}
+	defer logSub.Unsubscribe()
+
+	headsCh := make(chan *types.Header, subscribeHeadBuffer)
+	headSub, err := s.evmClient.SubscribeNewHead(subCtx, headsCh)
+	if err != nil {
+		return err
+	}
+	defer headSub.Unsubscribe()
+
+	// 订阅建立后读取最新高度，之后出块的日志都会推送到缓冲区，此前的区块需要补齐
+	head, err := s.evmClient.GetBlockNumber()
+	if err != nil {
+		return err
+	}
+	buffer := newLogBuffer(head + 1)
+	log.Logger.Info("日志订阅已建立", zap.Int("chain_id", s.chainId), zap.Uint64("stream_start", head+1))
+
+	for {
+		select {
+		case <-c.Done():
+			return nil
+		case err := <-logSub.Err():
+			return err
+		case err := <-headSub.Err():
+			return err
+		case vLog := <-logsCh:
+			buffer.add(vLog)
+		case <-headsCh:
+			// 处理前取出已到达的日志，减少区块头先于日志到达的情况
+			drainLogs(logsCh, buffer)
+			if !s.beginBatch() {
+				// 已暂停：日志继续缓存，恢复后的第一个新区块统一处理
+				continue
+			}
+			changed := s.onNewHead(c, buffer)
+			s.endBatch()
+			if changed {
+				return errWatchListChanged
+			}
+		}
+	}
+}
+
+// onNewHead 收到新区块后处理到安全高度为止的所有区块
+// 返回 true 表示监听地址发生变化，缓冲区中缺少新地址的日志，需要重新订阅
+// 调用方持有批次锁；暂停后在当前批次完成时返回
+func (s *chainSyncer) onNewHead(c context.Context, buffer *logBuffer) bool {
+	for c.Err() == nil && !s.paused.Load() {
+		if s.refreshWatchedAddresses() {
+			return true
+		}
+		lastBlockNum, targetBlockNum, ok := s.nextRange()
+		if !ok {
+			return false
+		}
+		fromBlockNum := lastBlockNum + 1
+
+		if fromBlockNum < buffer.coverFrom {
+			// 缓冲区未覆盖的区块（订阅前或重组回滚后）通过轮询补齐
+			if targetBlockNum >= buffer.coverFrom {
+				targetBlockNum = buffer.coverFrom - 1
+			}
+			log.Logger.Info("补齐订阅缺口",
+				zap.Int("chain_id", s.chainId),
+				zap.Uint64("from_block", fromBlockNum),
+				zap.Uint64("to_block", targetBlockNum))
+			logs, err := s.fetchLogs(fromBlockNum, targetBlockNum)
+			if err != nil {
+				return false
+			}
+			if !s.processRange(fromBlockNum, targetBlockNum, logs) {
+				return false
+			}
+			continue
+		}
+
+		logs := buffer.rangeLogs(fromBlockNum, targetBlockNum)
+		if s.settings.indexesTip() {
+			// 确认块数为 0 时目标区块即最新区块，其日志可能晚于区块头推送，提交后再到达的日志会被丢弃，
+			// 因此最新区块通过 eth_getLogs 读取，之前的区块在上一个区块头到达时已是最新区块并以同样方式处理
+			tipLogs, err := s.fetchLogs(targetBlockNum, targetBlockNum)
+			if err != nil {
+				return false
+			}
+			logs = append(buffer.rangeLogs(fromBlockNum, targetBlockNum-1), tipLogs...)
+		}
+		if !s.processRange(fromBlockNum, targetBlockNum, logs) {
+			return false
+		}
+		buffer.prune(targetBlockNum)
+	}
+	return false
+}
+
+// drainLogs 非阻塞地取出通道中已有的日志
+func drainLogs(logsCh <-chan types.Log, buffer *logBuffer) {
+	for {
+		select {
+		case vLog := <-logsCh:
+			buffer.add(vLog)
+		default:
+			return
+		}
+	}
+}
+
+// logBuffer 按区块缓存订阅推送的日志，coverFrom 之后的区块日志是完整的
+type logBuffer struct {
+	coverFrom uint64
+	blocks    map[uint64][]types.Log
+}
+
+func newLogBuffer(coverFrom uint64) *logBuffer {
+	return &logBuffer{
+		coverFrom: coverFrom,
+		blocks:    make(map[uint64][]types.Log),
+	}
+}
+
+// add 加入一条日志，Removed 为 true 表示该日志因重组被移除
+func (b *logBuffer) add(vLog types.Log) {
+	if vLog.BlockNumber < b.coverFrom {
+		return
+	}
+	logs := b.blocks[vLog.BlockNumber]
+	for i, existing := range logs {
+		if existing.TxHash == vLog.TxHash && existing.Index == vLog.Index {
+			logs = append(logs[:i], logs[i+1:]...)
+			break
+		}
+	}
+	if !vLog.Removed {
+		logs = append(logs, vLog)
+	}
+	if len(logs) == 0 {
+		delete(b.blocks, vLog.BlockNumber)
+		return
+	}
+	b.blocks[vLog.BlockNumber] = logs
+}
+
+// rangeLogs 返回 [from, to] 区块范围内的日志，按区块号和日志索引排序
+func (b *logBuffer) rangeLogs(from, to uint64) []types.Log {
+	var logs []types.Log
+	for blockNum, blockLogs := range b.blocks {
+		if blockNum >= from && blockNum <= to {
+			logs = append(logs, blockLogs...)
+		}
+	}
+	sort.Slice(logs, func(i, j int) bool {
+		if logs[i].BlockNumber != logs[j].BlockNumber {
+			return logs[i].BlockNumber < logs[j].BlockNumber
+		}
+		return logs[i].Index < logs[j].Index
+	})
+	return logs
+}
+
+// prune 丢弃已处理的区块，之后回滚到这些区块需要重新通过轮询补齐
+func (b *logBuffer) prune(upTo uint64) {
+	for blockNum := range b.blocks {
+		if blockNum <= upTo {
+			delete(b.blocks, blockNum)
+		}
+	}
+	if upTo+1 > b.coverFrom {
+		b.coverFrom = upTo + 1
+	}
+}
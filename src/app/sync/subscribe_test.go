@@ -0,0 +1,136 @@
+package sync
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+)
+
+func testLog(block uint64, tx byte, index uint, removed bool) types.Log {
+	return types.Log{
+		BlockNumber: block,
+		TxHash:      common.BytesToHash([]byte{tx}),
+		Index:       index,
+		Removed:     removed,
+	}
+}
+
+// logIDs 以 "区块/日志索引" 表示日志，便于比较
+func logIDs(logs []types.Log) []string {
+	ids := make([]string, 0, len(logs))
+	for _, vLog := range logs {
+		ids = append(ids, fmt.Sprintf("%d/%d", vLog.BlockNumber, vLog.Index))
+	}
+	return ids
+}
+
+func equalIDs(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestLogBufferRangeLogs(t *testing.T) {
+	tests := []struct {
+		name     string
+		logs     []types.Log
+		from, to uint64
+		want     []string
+	}{
+		{
+			name: "sorted by block then index",
+			logs: []types.Log{testLog(12, 3, 5, false), testLog(11, 2, 7, false), testLog(12, 1, 2, false), testLog(11, 2, 1, false)},
+			from: 10, to: 20,
+			want: []string{"11/1", "11/7", "12/2", "12/5"},
+		},
+		{
+			name: "range bounds are inclusive",
+			logs: []types.Log{testLog(10, 1, 0, false), testLog(11, 1, 1, false), testLog(12, 1, 2, false), testLog(13, 1, 3, false)},
+			from: 11, to: 12,
+			want: []string{"11/1", "12/2"},
+		},
+		{
+			name: "logs before coverFrom are ignored",
+			logs: []types.Log{testLog(9, 1, 0, false), testLog(10, 1, 1, false)},
+			from: 0, to: 20,
+			want: []string{"10/1"},
+		},
+		{
+			name: "removed log is dropped",
+			logs: []types.Log{testLog(11, 1, 0, false), testLog(11, 2, 1, false), testLog(11, 1, 0, true)},
+			from: 10, to: 20,
+			want: []string{"11/1"},
+		},
+		{
+			name: "removed then re-added after reorg",
+			logs: []types.Log{testLog(11, 1, 0, false), testLog(11, 1, 0, true), testLog(11, 1, 0, false)},
+			from: 10, to: 20,
+			want: []string{"11/0"},
+		},
+		{
+			name: "duplicate push is kept once",
+			logs: []types.Log{testLog(11, 1, 0, false), testLog(11, 1, 0, false)},
+			from: 10, to: 20,
+			want: []string{"11/0"},
+		},
+		{
+			name: "removal of unknown log is a no-op",
+			logs: []types.Log{testLog(11, 1, 0, true)},
+			from: 10, to: 20,
+			want: []string{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := newLogBuffer(10)
+			for _, vLog := range tt.logs {
+				buffer.add(vLog)
+			}
+			if got := logIDs(buffer.rangeLogs(tt.from, tt.to)); !equalIDs(got, tt.want) {
+				t.Errorf("rangeLogs(%d, %d) = %v, want %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogBufferPrune(t *testing.T) {
+	tests := []struct {
+		name          string
+		coverFrom     uint64
+		upTo          uint64
+		wantCoverFrom uint64
+		wantLogs      []string
+	}{
+		{"drops processed blocks", 10, 11, 12, []string{"12/2", "13/3"}},
+		{"prune below coverFrom keeps coverFrom", 10, 5, 10, []string{"10/0", "11/1", "12/2", "13/3"}},
+		{"prune everything", 10, 20, 21, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buffer := newLogBuffer(tt.coverFrom)
+			for i := uint64(10); i <= 13; i++ {
+				buffer.add(testLog(i, 1, uint(i-10), false))
+			}
+			buffer.prune(tt.upTo)
+			if buffer.coverFrom != tt.wantCoverFrom {
+				t.Errorf("coverFrom = %d, want %d", buffer.coverFrom, tt.wantCoverFrom)
+			}
+			if got := logIDs(buffer.rangeLogs(0, 100)); !equalIDs(got, tt.wantLogs) {
+				t.Errorf("logs after prune = %v, want %v", got, tt.wantLogs)
+			}
+			// 已丢弃的区块不再接收迟到的日志
+			buffer.add(testLog(tt.wantCoverFrom-1, 9, 99, false))
+			if got := logIDs(buffer.rangeLogs(0, 100)); !equalIDs(got, tt.wantLogs) {
+				t.Errorf("late log below coverFrom was buffered: %v", got)
+			}
+		})
+	}
+}
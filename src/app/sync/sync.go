@@ -2,381 +2,569 @@ package sync
 
 import (
 	"context"
+	"errors"
 	"math/big"
 	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/mumu/cryptoSwap/src/app/model"
 	"github.com/mumu/cryptoSwap/src/core/chainclient/evm"
 	"github.com/mumu/cryptoSwap/src/core/ctx"
 	"github.com/mumu/cryptoSwap/src/core/log"
+	"github.com/mumu/cryptoSwap/src/core/metrics"
+	"github.com/shopspring/decimal"
 	"go.uber.org/zap"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func StartSync(c context.Context) {
+// maxAddressesPerQuery 单次 eth_getLogs 请求的最大合约地址数
+const maxAddressesPerQuery = 500
+
+// 服务类型，对应 chain.service_type，决定该合约使用哪些事件解码器
+const (
+	serviceTypeStaking   = "staking"
+	serviceTypeLiquidity = "liquidity"
+	serviceTypeAirdrop   = "airdrop"
+	// serviceTypeFactory 监听 Uniswap V2 工厂合约及其创建的全部交易对
+	serviceTypeFactory = "factory"
+)
+
+// StartSync 为每条监听配置启动事件监听，阻塞直到上下文取消且全部监听器退出
+// 启用租约时按链竞争 Redis 租约，多个副本中每条链只由持有租约的副本处理
+func StartSync(c context.Context) error {
 	var wg sync.WaitGroup
 	// 查询所有链信息
-	// 查询所有链信息
 	var chains []model.Chain
 	// 修复：直接查询所有链信息，而不是循环查询
-	err := ctx.Ctx.DB.Model(&model.Chain{}).Find(&chains).Error
+	err := ctx.Ctx.DB.Model(&model.Chain{}).Order("id ASC").Find(&chains).Error
 	if err != nil {
 		log.Logger.Error("查询所有链信息失败", zap.Error(err))
-		return
+		return err
 	}
 
 	if len(chains) == 0 {
 		log.Logger.Warn("未找到任何链配置信息")
-		return
+		return nil
 	}
-	log.Logger.Info("开始启动统一事件监听", zap.Int("chain_count", len(chains)))
-	// 为每条链启动事件监听
+	indexerCtx.Store(c)
+	log.Logger.Info("开始启动统一事件监听", zap.Int("contract_count", len(chains)), zap.Bool("lease", leaseEnabled()))
+
+	// 链重组按链回滚，租约也按链划分
+	byChain := make(map[int][]model.Chain)
 	for _, chain := range chains {
+		byChain[int(chain.ChainId)] = append(byChain[int(chain.ChainId)], chain)
+	}
+	for chainId, chainRows := range byChain {
 		wg.Add(1)
-		go func(chain model.Chain) {
+		go func(chainId int, chainRows []model.Chain) {
 			defer wg.Done()
+			if leaseEnabled() {
+				runWithLease(c, chainId, chainRows)
+			} else {
+				runChainSyncers(c, chainRows, nil)
+			}
+		}(chainId, chainRows)
+	}
 
-			chainId := int(chain.ChainId)
-			evmClient := ctx.GetClient(chainId).(*evm.Evm)
-			if evmClient == nil {
-				log.Logger.Error("链客户端获取失败，无法启动监听", zap.Int("chain_id", chainId))
+	// 等待全部监听器在上下文取消后处理完当前批次并退出
+	wg.Wait()
+	return nil
+}
+
+// runChainSyncers 为一条链的每条监听配置（链, 合约, 服务类型）启动监听，各自独立维护游标，阻塞直到全部退出
+func runChainSyncers(c context.Context, chains []model.Chain, lease *chainLease) {
+	var wg sync.WaitGroup
+	for _, chain := range chains {
+		wg.Add(1)
+		go func(chain model.Chain) {
+			defer wg.Done()
+			// 租约易主后重新读取游标与暂停状态
+			if err := ctx.Ctx.DB.Where("id = ?", chain.Id).First(&chain).Error; err != nil {
+				log.Logger.Error("读取监听配置失败", zap.Int64("id", chain.Id), zap.Error(err))
 				return
 			}
+			syncer := newChainSyncer(chain)
+			if syncer == nil {
+				return
+			}
+			syncer.lease = lease
+			if chain.Paused {
+				log.Logger.Info("监听配置已暂停，等待通过管理接口恢复", zap.Int64("id", chain.Id), zap.String("contract_address", chain.Address))
+			}
+			registerSyncer(syncer)
+			defer unregisterSyncer(syncer)
+			syncer.run(c)
+		}(chain)
+	}
+	wg.Wait()
+}
 
-			log.Logger.Info("启动统一事件监听",
-				zap.Int("chain_id", chainId))
+// chainSyncer 单条监听配置（链 + 合约地址 + 服务类型）的事件监听器
+type chainSyncer struct {
+	chain             model.Chain
+	chainId           int
+	evmClient         *evm.Evm
+	decoders          map[common.Hash]*eventDecoder
+	topicFilter       [][]common.Hash
+	settings          syncSettings
+	sizer             *batchSizer
+	contractAddresses []string
+	batchMu           sync.Mutex  // 批次锁：nextRange 到 processRange 期间持有，重置游标与暂停时等待
+	paused            atomic.Bool // 通过管理接口暂停
+	state             syncerState
+	lease             *chainLease // 未启用租约时为 nil
+}
 
-			lastBlockNum := chain.LastBlockNum
+// newChainSyncer 创建监听器，链客户端或合约地址缺失时返回 nil
+func newChainSyncer(chain model.Chain) *chainSyncer {
+	chainId := int(chain.ChainId)
+	evmClient := ctx.GetClient(chainId).(*evm.Evm)
+	if evmClient == nil {
+		log.Logger.Error("链客户端获取失败，无法启动监听", zap.Int("chain_id", chainId))
+		return nil
+	}
+	// 直接使用链信息中的合约地址
+	if chain.Address == "" {
+		log.Logger.Warn("链配置中未设置合约地址", zap.Int("chain_id", chainId))
+		return nil
+	}
+	decoders, ok := getDecoderRegistry().forService(chain.ServiceType)
+	if !ok {
+		log.Logger.Error("未知的服务类型，跳过该合约",
+			zap.Int("chain_id", chainId),
+			zap.String("contract_address", chain.Address),
+			zap.String("service_type", chain.ServiceType))
+		return nil
+	}
 
-			// 定义所有需要监听的事件topic hash
-			// 质押池事件
-			stakedTopic := crypto.Keccak256Hash([]byte("Staked(address,uint256,address,uint256,uint256,uint256)")).Hex()
-			withdrawnTopic := crypto.Keccak256Hash([]byte("Withdrawn(address,uint256,address,uint256,uint256)")).Hex()
+	// 按链配置确定确认数、最终性模式、轮询间隔与批次大小
+	settings := newSyncSettings(chainId)
+	log.Logger.Info("索引器调优参数",
+		zap.Int("chain_id", chainId),
+		zap.String("mode", settings.Mode),
+		zap.Uint64("confirmations", settings.Confirmations),
+		zap.String("finality", settings.Finality),
+		zap.Duration("poll_interval", settings.PollInterval),
+		zap.Uint64("max_batch_size", settings.MaxBatchSize),
+		zap.Uint64("min_batch_size", settings.MinBatchSize))
+
+	s := &chainSyncer{
+		chain:             chain,
+		chainId:           chainId,
+		evmClient:         evmClient,
+		decoders:          decoders,
+		topicFilter:       topicFilter(decoders),
+		settings:          settings,
+		sizer:             newBatchSizer(settings),
+		contractAddresses: []string{chain.Address},
+	}
+	s.paused.Store(chain.Paused)
+	return s
+}
 
-			// 流动性池事件
-			swapTopic := crypto.Keccak256Hash([]byte("Swap(address,uint256,uint256,uint256,uint256,address)")).Hex()
-			mintTopic := crypto.Keccak256Hash([]byte("Mint(address,uint256,uint256)")).Hex()
-			burnTopic := crypto.Keccak256Hash([]byte("Burn(address,uint256,uint256,address)")).Hex()
+// run 按配置的模式运行监听，订阅模式要求 ws:// 或 wss:// 端点
+func (s *chainSyncer) run(c context.Context) {
+	log.Logger.Info("启动统一事件监听",
+		zap.Int("chain_id", s.chainId),
+		zap.String("contract_address", s.chain.Address),
+		zap.String("service_type", s.chain.ServiceType))
+	if s.isFactory() {
+		// 补登记工厂合约已有的交易对，失败不影响后续监听，新交易对仍会通过 PairCreated 发现
+		if err := backfillFactoryPairs(s.evmClient, s.chainId, s.chain.Address); err != nil {
+			log.Logger.Error("补登记工厂交易对失败", zap.Int("chain_id", s.chainId), zap.Error(err))
+		}
+		// feeTo 决定协议分成是否开启，读取失败时沿用上次记录
+		if err := syncFactoryFeeTo(s.evmClient, s.chainId, s.chain.Address); err != nil {
+			log.Logger.Error("刷新工厂 feeTo 失败", zap.Int("chain_id", s.chainId), zap.Error(err))
+		}
+		s.refreshWatchedAddresses()
+	}
+	if s.settings.Mode == modeSubscribe {
+		if s.evmClient.SupportsSubscription() {
+			s.runSubscribe(c)
+			return
+		}
+		log.Logger.Warn("订阅模式需要 WebSocket 端点，回退到轮询模式", zap.Int("chain_id", s.chainId))
+	}
+	s.runPoll(c)
+}
 
-			// 空投事件
-			rewardClaimedTopic := crypto.Keccak256Hash([]byte("RewardClaimed(uint256,address,uint256,uint256,uint256,uint256,uint256)")).Hex()
-			updateTotalRewardTopic := crypto.Keccak256Hash([]byte("UpdateTotalRewardUpdated(uint256,address,uint256,uint256,uint256,uint256)")).Hex()
-			airdropCreatedTopic := crypto.Keccak256Hash([]byte("AirdropCreated(uint256,string,bytes32,uint256,uint256)")).Hex()
-			airdropActivatedTopic := crypto.Keccak256Hash([]byte("AirdropActivated(uint256)")).Hex()
+// runPoll 轮询模式：按固定间隔拉取日志
+func (s *chainSyncer) runPoll(c context.Context) {
+	ticker := time.NewTicker(s.settings.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Done():
+			log.Logger.Info("统一事件监听任务已停止", zap.Int("chain_id", s.chainId))
+			return
+		case <-ticker.C:
+			s.pollOnce()
+		}
+	}
+}
 
-			// 直接使用链信息中的合约地址
-			contractAddresses := []string{chain.Address}
-			if chain.Address == "" {
-				log.Logger.Warn("链配置中未设置合约地址", zap.Int("chain_id", chainId))
-				return
-			}
+// isFactory 是否为工厂合约监听
+func (s *chainSyncer) isFactory() bool {
+	return s.chain.ServiceType == serviceTypeFactory
+}
 
-			ticker := time.NewTicker(12 * time.Second)
-			defer ticker.Stop()
-
-			for {
-				select {
-				case <-c.Done():
-					log.Logger.Info("统一事件监听任务已停止", zap.Int("chain_id", chainId))
-					return
-				case <-ticker.C:
-					// 获取当前块的高度
-					currentBlock, err := evmClient.GetBlockNumber()
-					if err != nil {
-						log.Logger.Error("获取当前区块高度失败", zap.Int("chain_id", chainId), zap.Error(err))
-						continue
-					}
-
-					targetBlockNum := currentBlock - 6
-					if targetBlockNum <= lastBlockNum {
-						log.Logger.Debug("当前区块高度不足，跳过本次执行",
-							zap.Int("chain_id", chainId),
-							zap.Uint64("last_BlockNum", lastBlockNum),
-							zap.Uint64("current_block", currentBlock))
-						continue
-					}
-
-					// 当断开链接很久时，分批次拉取日志，一次拉取1000个块的日志
-					if targetBlockNum-lastBlockNum > 1000 {
-						targetBlockNum = lastBlockNum + 1000
-					}
-
-					log.Logger.Info("开始监听事件日志",
-						zap.Uint64("from_block", lastBlockNum),
-						zap.Uint64("to_block", targetBlockNum),
-						zap.String("contract_address", chain.Address))
-
-					// 监听链配置中的合约地址的事件
-					// 修改：合并循环获取日志和错误处理
-					var allLogs []types.Log
-					for _, address := range contractAddresses {
-						logs, err := evmClient.GetFilterLogs(big.NewInt(int64(lastBlockNum)), big.NewInt(int64(targetBlockNum)), address)
-						if err != nil {
-							log.Logger.Error("GetFilterLogs failed!", zap.String("address", address), zap.Error(err))
-							continue
-						}
-						allLogs = append(allLogs, logs...)
-					}
-
-					if len(allLogs) == 0 {
-						log.Logger.Debug("GetFilterLogs is empty")
-						//即使没有事件也要更新区块高度
-						if err := updateBlockNumber(chainId, targetBlockNum, chain.Address); err != nil {
-							log.Logger.Error("更新区块高度失败", zap.Error(err))
-						} else {
-							lastBlockNum = targetBlockNum + 1
-						}
-						continue
-					}
-
-					var userOperationRecords []*model.UserOperationRecord
-					var liquidityPoolEvents []*model.LiquidityPoolEvent
-					var airdropEvents *AirdropEvents
-					//var rewardClaimedEvents []*model.RewardClaimedEvent
-					//var totalRewardUpdatedEvents []*model.TotalRewardUpdatedEvent
-					//var airdropCreatedEvents []*AirdropCreatedInfo
-					//var airdropActivatedIds []string
-					// 获取交易发送者（真实用户地址）
-
-					// 解析日志并分类处理
-					for _, vLog := range allLogs {
-						if len(vLog.Topics) == 0 {
-							continue
-						}
-						address, _ := evmClient.GetUserAddress(vLog)
-
-						topic0 := vLog.Topics[0].Hex()
-						switch topic0 {
-						case stakedTopic:
-							stakedStruct := analysisStakedTopic(vLog, chainId)
-							if stakedStruct != nil {
-								userOperationRecords = append(userOperationRecords, stakedStruct)
-							}
-						case withdrawnTopic:
-							withdrawnStruct := analysisWithdrawnTopic(vLog, chainId)
-							if withdrawnStruct != nil {
-								userOperationRecords = append(userOperationRecords, withdrawnStruct)
-							}
-						case swapTopic, mintTopic, burnTopic:
-							event := parseLiquidityPoolEvent(vLog, chainId, address)
-							if event != nil {
-								liquidityPoolEvents = append(liquidityPoolEvents, event)
-							}
-						case rewardClaimedTopic, updateTotalRewardTopic, airdropCreatedTopic, airdropActivatedTopic:
-							// 处理空投相关事件
-							if airdropEvents == nil {
-								airdropEvents = &AirdropEvents{}
-							}
-							parsedEvents := ParseAirdropEvents(vLog, chainId, address)
-							if parsedEvents != nil {
-								// 合并解析到的事件
-								airdropEvents.RewardClaimedEvents = append(airdropEvents.RewardClaimedEvents, parsedEvents.RewardClaimedEvents...)
-								airdropEvents.TotalRewardUpdatedEvents = append(airdropEvents.TotalRewardUpdatedEvents, parsedEvents.TotalRewardUpdatedEvents...)
-								airdropEvents.AirdropCreatedEvents = append(airdropEvents.AirdropCreatedEvents, parsedEvents.AirdropCreatedEvents...)
-								airdropEvents.AirdropActivatedIds = append(airdropEvents.AirdropActivatedIds, parsedEvents.AirdropActivatedIds...)
-							}
-						default:
-							log.Logger.Debug("未知的事件类型",
-								zap.String("topic0", topic0),
-								zap.String("tx_hash", vLog.TxHash.Hex()))
-						}
-					}
-
-					// 分别处理不同类型的事件
-					success := true
-
-					if len(userOperationRecords) > 0 {
-						log.Logger.Info("解析质押池事件成功", zap.Int("event_count", len(userOperationRecords)))
-						if err := updateDbUserAmount(userOperationRecords, chainId, targetBlockNum, chain.Address); err != nil {
-							log.Logger.Error("保存质押池事件失败", zap.Error(err))
-							success = false
-						}
-					}
-
-					if len(liquidityPoolEvents) > 0 {
-						log.Logger.Info("解析流动性池事件成功", zap.Int("event_count", len(liquidityPoolEvents)))
-						if err := saveLiquidityPoolEvents(liquidityPoolEvents, chainId, targetBlockNum, chain.Address); err != nil {
-							log.Logger.Error("保存流动性池事件失败", zap.Error(err))
-							success = false
-						}
-					}
-
-					// 统一保存空投事件
-					if airdropEvents != nil {
-						if err := SaveAirdropEvents(airdropEvents, chainId, targetBlockNum, chain.Address); err != nil {
-							log.Logger.Error("保存空投事件失败", zap.Error(err))
-							success = false
-						}
-					}
-
-					// 如果所有事件处理成功，更新区块高度
-					if success {
-						if len(userOperationRecords) == 0 && len(liquidityPoolEvents) == 0 && (airdropEvents == nil ||
-							(len(airdropEvents.RewardClaimedEvents) == 0 &&
-								len(airdropEvents.TotalRewardUpdatedEvents) == 0 &&
-								len(airdropEvents.AirdropCreatedEvents) == 0 &&
-								len(airdropEvents.AirdropActivatedIds) == 0)) {
-							// 没有事件时也要更新区块高度
-							if err := updateBlockNumber(chainId, targetBlockNum, chain.Address); err != nil {
-								log.Logger.Error("更新区块高度失败", zap.Error(err))
-							} else {
-								lastBlockNum = targetBlockNum + 1
-							}
-						} else {
-							lastBlockNum = targetBlockNum + 1
-						}
-					}
-				}
-			}
-		}(chain)
+// refreshWatchedAddresses 工厂监听从数据库重新加载交易对地址，使新登记的交易对无需重启即可被监听
+// 返回地址列表是否发生变化
+func (s *chainSyncer) refreshWatchedAddresses() bool {
+	if !s.isFactory() {
+		return false
 	}
+	pairs, err := loadFactoryPairs(s.chainId, s.chain.Address)
+	if err != nil {
+		log.Logger.Error("加载工厂交易对失败", zap.Int("chain_id", s.chainId), zap.Error(err))
+		return false
+	}
+	addresses := append([]string{s.chain.Address}, pairs...)
+	if sameAddresses(addresses, s.contractAddresses) {
+		return false
+	}
+	log.Logger.Info("监听地址已更新",
+		zap.Int("chain_id", s.chainId),
+		zap.String("factory", s.chain.Address),
+		zap.Int("pair_count", len(pairs)))
+	s.contractAddresses = addresses
+	return true
+}
 
-	//一直等待
-	wg.Wait()
+// sameAddresses 两组地址是否为同一集合，不区分大小写与顺序；交易对同时有增有减时数量不变，不能只比较长度
+func sameAddresses(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	set := make(map[string]bool, len(a))
+	for _, address := range a {
+		set[strings.ToLower(address)] = true
+	}
+	for _, address := range b {
+		if !set[strings.ToLower(address)] {
+			return false
+		}
+	}
+	return true
 }
 
-// updateBlockNumber 更新区块高度
-func updateBlockNumber(chainId int, blockNum uint64, address string) error {
-	return ctx.Ctx.DB.Model(&model.Chain{}).
-		Where("chain_id = ? AND address = ?", int64(chainId), address).
-		Update("last_block_num", blockNum).Error
+// pollOnce 轮询处理一个批次，已暂停时跳过
+func (s *chainSyncer) pollOnce() {
+	if !s.beginBatch() {
+		return
+	}
+	defer s.endBatch()
+	s.refreshWatchedAddresses()
+	lastBlockNum, targetBlockNum, ok := s.nextRange()
+	if !ok {
+		return
+	}
+	logs, err := s.fetchLogs(lastBlockNum+1, targetBlockNum)
+	if err != nil {
+		return
+	}
+	s.processRange(lastBlockNum+1, targetBlockNum, logs)
 }
 
-// analysisStakedTopic 解析质押事件
-func analysisStakedTopic(vLog types.Log, chainId int) *model.UserOperationRecord {
-	// 修改:更新日志解析逻辑以匹配新的事件格式
-	if len(vLog.Topics) >= 4 && len(vLog.Data) >= 96 {
-		// user在topics[1]中 (indexed)
-		user := common.BytesToAddress(vLog.Topics[1].Bytes()).Hex()
-
-		// poolId在topics[2]中 (indexed)
-		poolId := new(big.Int).SetBytes(common.TrimLeftZeroes(vLog.Topics[2].Bytes()))
-
-		// tokenAddress在topics[3]中 (indexed)
-		tokenAddress := common.BytesToAddress(vLog.Topics[3].Bytes()).Hex()
-
-		// amount, stakedAt, unlockTime在data中
-		data := vLog.Data
-		amount := new(big.Int).SetBytes(common.TrimLeftZeroes(data[0:32]))
-		stakedAt := new(big.Int).SetBytes(common.TrimLeftZeroes(data[32:64]))
-		unlockTime := new(big.Int).SetBytes(common.TrimLeftZeroes(data[64:96]))
-
-		// 创建质押记录并保存到数据库
-		userOperationRecord := model.UserOperationRecord{
-			ChainId:       int64(chainId),
-			Address:       user,
-			PoolId:        poolId.Int64(), // 修改:将big.Int转换为int64
-			TokenAddress:  tokenAddress,
-			Amount:        amount.Int64(),
-			OperationTime: time.UnixMilli(stakedAt.Int64()),
-			UnlockTime:    time.UnixMilli(unlockTime.Int64()),
-			TxHash:        vLog.TxHash.Hex(),
-			BlockNumber:   int64(vLog.BlockNumber),
-			EventType:     "Staked",
+// nextRange 计算下一批次的区块范围 (lastBlockNum, targetBlockNum]，并在处理前完成重组检测
+func (s *chainSyncer) nextRange() (uint64, uint64, bool) {
+	chainId := s.chainId
+	// 每轮从数据库读取游标（最后已处理的区块）与暂停状态，重组回滚、其他副本的管理操作后能及时感知
+	lastBlockNum, paused, err := loadCursor(s.chain.Id)
+	if err != nil {
+		log.Logger.Error("读取区块游标失败", zap.Int("chain_id", chainId), zap.Error(err))
+		s.recordError(err)
+		return 0, 0, false
+	}
+	if paused {
+		s.paused.Store(true)
+		return 0, 0, false
+	}
+
+	// 获取可安全索引的区块高度（确认块数或 safe/finalized 标签）
+	targetBlockNum, err := s.settings.safeBlockNumber(s.evmClient)
+	if err != nil {
+		log.Logger.Error("获取当前区块高度失败", zap.Int("chain_id", chainId), zap.Error(err))
+		s.recordError(err)
+		return 0, 0, false
+	}
+	s.setHead(targetBlockNum)
+	s.updateLag(lastBlockNum)
+
+	if targetBlockNum <= lastBlockNum {
+		log.Logger.Debug("当前区块高度不足，跳过本次执行",
+			zap.Int("chain_id", chainId),
+			zap.Uint64("last_BlockNum", lastBlockNum),
+			zap.Uint64("safe_block", targetBlockNum))
+		return 0, 0, false
+	}
+
+	// 校验游标区块是否仍在规范链上，发生重组时回滚，下一轮从分叉点重新索引
+	reorged, err := checkReorg(s.evmClient, s.lease, chainId, s.chain.Address, lastBlockNum)
+	if err != nil {
+		log.Logger.Error("链重组检测失败", zap.Int("chain_id", chainId), zap.Error(err))
+		s.recordError(err)
+		return 0, 0, false
+	}
+	if reorged {
+		return 0, 0, false
+	}
+
+	// 当断开链接很久时，分批次拉取日志，批次大小随 RPC 报错自适应调整
+	if batchSize := s.sizer.Size(); targetBlockNum-lastBlockNum > batchSize {
+		targetBlockNum = lastBlockNum + batchSize
+	}
+	return lastBlockNum, targetBlockNum, true
+}
+
+// fetchLogs 拉取区块范围内所有监听合约的日志，任一请求失败则整体失败
+func (s *chainSyncer) fetchLogs(fromBlockNum, targetBlockNum uint64) ([]types.Log, error) {
+	return s.fetchAddressLogs(s.contractAddresses, fromBlockNum, targetBlockNum)
+}
+
+// fetchAddressLogs 按地址分组拉取已注册事件的日志，避免单次请求地址过多
+func (s *chainSyncer) fetchAddressLogs(addresses []string, fromBlockNum, targetBlockNum uint64) ([]types.Log, error) {
+	var allLogs []types.Log
+	for start := 0; start < len(addresses); start += maxAddressesPerQuery {
+		end := start + maxAddressesPerQuery
+		if end > len(addresses) {
+			end = len(addresses)
+		}
+		logs, err := s.evmClient.GetFilterLogsWithTopics(new(big.Int).SetUint64(fromBlockNum), new(big.Int).SetUint64(targetBlockNum), addresses[start:end], s.topicFilter)
+		if err != nil {
+			log.Logger.Error("GetFilterLogs failed!", zap.Int("chain_id", s.chainId), zap.Error(err))
+			s.recordError(err)
+			if s.sizer.OnError(err) {
+				log.Logger.Warn("RPC 区块范围受限，缩小批次",
+					zap.Int("chain_id", s.chainId),
+					zap.Uint64("batch_size", s.sizer.Size()))
+			}
+			// 日志拉取不完整时不推进游标，避免漏数据
+			return nil, err
 		}
-		return &userOperationRecord
+		allLogs = append(allLogs, logs...)
 	}
-	return nil
+	s.sizer.OnSuccess()
+	return allLogs, nil
 }
 
-// analysisWithdrawnTopic 解析提现事件
-func analysisWithdrawnTopic(vLog types.Log, chainId int) *model.UserOperationRecord {
-	if len(vLog.Topics) >= 4 && len(vLog.Data) >= 64 {
-		// user在topics[1]中 (indexed)
-		user := common.BytesToAddress(vLog.Topics[1].Bytes()).Hex()
-
-		// poolId在topics[2]中 (indexed)
-		poolId := new(big.Int).SetBytes(common.TrimLeftZeroes(vLog.Topics[2].Bytes()))
-
-		// tokenAddress在topics[3]中 (indexed)
-		tokenAddress := common.BytesToAddress(vLog.Topics[3].Bytes()).Hex()
-
-		// amount, withdrawnAt在data中
-		data := vLog.Data
-		amount := new(big.Int).SetBytes(common.TrimLeftZeroes(data[0:32]))
-		withdrawnAt := new(big.Int).SetBytes(common.TrimLeftZeroes(data[32:64]))
-
-		// 创建提现记录并保存到数据库 (复用StakedRecord模型)
-		userOperationRecord := model.UserOperationRecord{
-			ChainId:       int64(chainId),
-			Address:       user,
-			PoolId:        poolId.Int64(), // 修改:将big.Int转换为int64
-			TokenAddress:  tokenAddress,
-			Amount:        amount.Int64(),
-			OperationTime: time.UnixMilli(withdrawnAt.Int64()), // 解除质押时间
-			//UnlockTime:    ni,                                 // 不再使用此字段
-			TxHash:      vLog.TxHash.Hex(),
-			BlockNumber: int64(vLog.BlockNumber),
-			EventType:   "Withdrawn",
+// processRange 解析并保存区块范围内的日志，成功后推进游标并记录区块哈希
+func (s *chainSyncer) processRange(fromBlockNum, targetBlockNum uint64, allLogs []types.Log) bool {
+	chainId := s.chainId
+	chain := s.chain
+	start := time.Now()
+	outcome := metrics.OutcomeError
+	defer func() {
+		metrics.BatchDuration.WithLabelValues(strconv.Itoa(chainId), chain.ServiceType, outcome).Observe(time.Since(start).Seconds())
+	}()
+
+	// 批次末尾区块头，处理成功后记录其哈希供下一轮校验
+	targetHeader, err := s.evmClient.GetHeaderByNumber(new(big.Int).SetUint64(targetBlockNum))
+	if err != nil {
+		log.Logger.Error("获取批次末尾区块头失败", zap.Int("chain_id", chainId), zap.Error(err))
+		s.recordError(err)
+		return false
+	}
+
+	log.Logger.Info("开始监听事件日志",
+		zap.Uint64("from_block", fromBlockNum),
+		zap.Uint64("to_block", targetBlockNum),
+		zap.String("contract_address", chain.Address),
+		zap.String("service_type", chain.ServiceType))
+
+	batch, err := s.decodeRange(fromBlockNum, targetBlockNum, allLogs)
+	if err != nil {
+		log.Logger.Error("解析事件日志失败", zap.Int("chain_id", chainId), zap.Error(err))
+		s.recordError(err)
+		return false
+	}
+	// 全部事件、游标与区块哈希在同一事务中提交，任一失败则整体回滚，下一轮重新处理
+	// 先写入防护令牌：租约已被其他副本接管时整体回滚
+	err = ctx.Ctx.DB.Transaction(func(tx *gorm.DB) error {
+		if err := s.lease.fence(tx); err != nil {
+			return err
+		}
+		if err := saveBatch(tx, batch, chainId); err != nil {
+			return err
+		}
+		if err := archiveBatch(tx, chain.Id, batch); err != nil {
+			return err
+		}
+		if err := advanceCursor(tx, chain.Id, fromBlockNum-1, targetBlockNum); err != nil {
+			log.Logger.Error("更新区块高度失败", zap.Error(err))
+			return err
+		}
+		if err := saveBlockHashes(tx, chainId, chain.Address, batch.Logs, targetHeader); err != nil {
+			log.Logger.Error("保存区块哈希失败", zap.Error(err))
+			return err
 		}
-		return &userOperationRecord
+		return nil
+	})
+	if err != nil {
+		s.recordError(err)
+		return false
+	}
+	outcome = metrics.OutcomeOk
+	s.recordBatch()
+	s.updateLag(targetBlockNum)
+	for event, count := range batch.EventCounts {
+		metrics.LogsProcessed.WithLabelValues(strconv.Itoa(chainId), event).Add(float64(count))
+	}
+	for _, dl := range batch.DeadLetters {
+		metrics.DeadLetters.WithLabelValues(strconv.Itoa(chainId), dl.Decoder).Inc()
+	}
+	return true
+}
+
+// loadCursor 读取监听配置的游标（最后已处理的区块号）与暂停状态，每条 chain 记录独立维护
+func loadCursor(id int64) (uint64, bool, error) {
+	var chain model.Chain
+	err := ctx.Ctx.DB.Model(&model.Chain{}).
+		Where("id = ?", id).
+		First(&chain).Error
+	return chain.LastBlockNum, chain.Paused, err
+}
+
+// errCursorMoved 批次处理期间游标被修改（重组回滚、重置游标或其他副本已提交）
+var errCursorMoved = errors.New("游标已被修改，放弃本批次")
+
+// advanceCursor 游标仍为批次开始时的值才推进，避免与重置游标、其他副本重复提交同一范围
+func advanceCursor(tx *gorm.DB, id int64, expected, blockNum uint64) error {
+	res := tx.Model(&model.Chain{}).
+		Where("id = ? AND last_block_num = ?", id, expected).
+		Update("last_block_num", blockNum)
+	if res.Error != nil {
+		return res.Error
 	}
+	if res.RowsAffected == 0 {
+		return errCursorMoved
+	}
+	return nil
+}
+
+// updateBlockNumber 更新区块高度，只推进本条监听配置的游标
+func updateBlockNumber(tx *gorm.DB, id int64, blockNum uint64) error {
+	return tx.Model(&model.Chain{}).
+		Where("id = ?", id).
+		Update("last_block_num", blockNum).Error
+}
+
+// handleStaked 质押事件
+func handleStaked(e *decodedEvent, batch *decodedBatch) error {
+	batch.UserOperationRecords = append(batch.UserOperationRecords, &model.UserOperationRecord{
+		ChainId:         int64(e.ChainId),
+		Address:         e.address("user"),
+		PoolId:          e.bigInt("poolId").Int64(),
+		TokenAddress:    e.address("tokenAddress"),
+		Amount:          decimal.NewFromBigInt(e.bigInt("amount"), 0),
+		OperationTime:   time.UnixMilli(e.bigInt("stakedAt").Int64()),
+		UnlockTime:      time.UnixMilli(e.bigInt("unlockTime").Int64()),
+		TxHash:          e.Log.TxHash.Hex(),
+		BlockNumber:     int64(e.Log.BlockNumber),
+		BlockTimestamp:  e.BlockTime,
+		LogIndex:        int(e.Log.Index),
+		EventType:       "Staked",
+		ContractAddress: e.Log.Address.Hex(),
+	})
 	return nil
 }
 
-// updateDbUserAmount 更新数据库用户金额
-func updateDbUserAmount(userOperationRecords []*model.UserOperationRecord, chainId int, targetBlockNum uint64, address string) error {
+// handleWithdrawn 解除质押事件
+func handleWithdrawn(e *decodedEvent, batch *decodedBatch) error {
+	batch.UserOperationRecords = append(batch.UserOperationRecords, &model.UserOperationRecord{
+		ChainId:         int64(e.ChainId),
+		Address:         e.address("user"),
+		PoolId:          e.bigInt("poolId").Int64(),
+		TokenAddress:    e.address("tokenAddress"),
+		Amount:          decimal.NewFromBigInt(e.bigInt("amount"), 0),
+		OperationTime:   time.UnixMilli(e.bigInt("withdrawnAt").Int64()), // 解除质押时间
+		TxHash:          e.Log.TxHash.Hex(),
+		BlockNumber:     int64(e.Log.BlockNumber),
+		BlockTimestamp:  e.BlockTime,
+		LogIndex:        int(e.Log.Index),
+		EventType:       "Withdrawn",
+		ContractAddress: e.Log.Address.Hex(),
+	})
+	return nil
+}
+
+// updateDbUserAmount 更新数据库用户金额，游标由调用方统一推进
+// 记录按 (chain_id, tx_hash, log_index) 去重，只有实际插入的记录才计入用户金额，重放、回填与重启都不会重复累加
+func updateDbUserAmount(tx *gorm.DB, userOperationRecords []*model.UserOperationRecord, chainId int, targetBlockNum uint64) error {
 	if len(userOperationRecords) == 0 {
-		// 更新质押池服务配置的最后区块号
-		if err := ctx.Ctx.DB.Model(&model.Chain{}).Where("chain_id = ? AND address = ?", int64(chainId), address).Update("last_block_num", targetBlockNum).Error; err != nil {
-			log.Logger.Error("更新质押池最后区块号失败", zap.Int("chain_id", chainId), zap.Error(err))
-			return err
-		}
-		log.Logger.Info("更新数据表最后区块号成功" + strconv.Itoa(int(targetBlockNum)))
 		return nil
 	}
-	txErr := ctx.Ctx.DB.Transaction(func(tx *gorm.DB) error {
-		// 批量插入用户操作记录
-		if err := tx.CreateInBatches(userOperationRecords, 100).Error; err != nil {
-			log.Logger.Error("批量插入用户操作记录失败", zap.Error(err))
-			return err
+	// 逐条插入用户操作记录，已存在的跳过
+	inserted := make([]*model.UserOperationRecord, 0, len(userOperationRecords))
+	for _, record := range userOperationRecords {
+		result := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "chain_id"}, {Name: "tx_hash"}, {Name: "log_index"}},
+			DoNothing: true,
+		}).Create(record)
+		if result.Error != nil {
+			log.Logger.Error("插入用户操作记录失败", zap.String("tx_hash", record.TxHash), zap.Error(result.Error))
+			return result.Error
 		}
-		type userTokenKey struct {
-			Address      string
-			TokenAddress string
+		if result.RowsAffected > 0 {
+			inserted = append(inserted, record)
 		}
-		userAmounts := make(map[userTokenKey]*big.Int)
-		for _, record := range userOperationRecords {
-			key := userTokenKey{
-				Address:      record.Address,
-				TokenAddress: record.TokenAddress,
-			}
-			amount := big.NewInt(record.Amount)
-			if userAmounts[key] == nil {
-				userAmounts[key] = big.NewInt(0)
-			}
-			if record.EventType == "Staked" {
-				userAmounts[key].Add(userAmounts[key], amount)
-			} else if record.EventType == "Withdrawn" {
-				userAmounts[key].Sub(userAmounts[key], amount)
-			}
+	}
+	if len(inserted) < len(userOperationRecords) {
+		log.Logger.Info("跳过已存在的用户操作记录", zap.Int("skipped", len(userOperationRecords)-len(inserted)))
+	}
+	userOperationRecords = inserted
+	type userTokenKey struct {
+		Address      string
+		TokenAddress string
+	}
+	userAmounts := make(map[userTokenKey]decimal.Decimal)
+	for _, record := range userOperationRecords {
+		key := userTokenKey{
+			Address:      record.Address,
+			TokenAddress: record.TokenAddress,
 		}
-		//更新每个用户tokenAddress总金额
-		for key, amount := range userAmounts {
-			// 修改:使用UPSERT操作处理用户记录不存在的情况
-			if err := tx.Exec(`
-									INSERT INTO users (chain_id, token_address, address, total_amount, last_block_num)
-									VALUES (?, ?, ?, ?, ?)
-									ON CONFLICT (chain_id, token_address, address)
-									DO UPDATE SET
-										total_amount = users.total_amount + ?,
-										last_block_num = ?
-								`, chainId, key.TokenAddress, key.Address, amount.Int64(), targetBlockNum, amount.Int64(), targetBlockNum).Error; err != nil {
-				log.Logger.Error("更新用户总金额失败", zap.String("user", key.Address), zap.String("token_address", key.TokenAddress), zap.String("amount", amount.String()), zap.Error(err))
-				return err
-			}
+		if record.EventType == "Staked" {
+			userAmounts[key] = userAmounts[key].Add(record.Amount)
+		} else if record.EventType == "Withdrawn" {
+			userAmounts[key] = userAmounts[key].Sub(record.Amount)
 		}
-		// 更新质押池服务配置的最后区块号
-		if err := tx.Model(&model.Chain{}).Where("chain_id = ? AND address = ?", int64(chainId), address).Update("last_block_num", targetBlockNum).Error; err != nil {
-			log.Logger.Error("更新质押池最后区块号失败", zap.Int("chain_id", chainId), zap.Error(err))
+	}
+	//更新每个用户tokenAddress总金额
+	for key, amount := range userAmounts {
+		// 修改:使用UPSERT操作处理用户记录不存在的情况
+		if err := tx.Exec(`
+								INSERT INTO users (chain_id, token_address, address, total_amount, last_block_num)
+								VALUES (?, ?, ?, ?, ?)
+								ON CONFLICT (chain_id, token_address, address)
+								DO UPDATE SET
+									total_amount = users.total_amount + ?,
+									last_block_num = ?
+							`, chainId, key.TokenAddress, key.Address, amount, targetBlockNum, amount, targetBlockNum).Error; err != nil {
+			log.Logger.Error("更新用户总金额失败", zap.String("user", key.Address), zap.String("token_address", key.TokenAddress), zap.String("amount", amount.String()), zap.Error(err))
 			return err
 		}
-		return nil
-	})
-	return txErr
+	}
+	// 操作时间不晚于 jf_time 的记录不会再被积分任务扫描到，直接计入 jf_amount（与回滚逻辑对称）
+	for _, record := range userOperationRecords {
+		amount := record.Amount
+		if record.EventType == "Withdrawn" {
+			amount = amount.Neg()
+		}
+		if err := tx.Exec(`
+			UPDATE users SET jf_amount = jf_amount + ?
+			WHERE chain_id = ? AND token_address = ? AND address = ? AND jf_time >= ?
+		`, amount, chainId, record.TokenAddress, record.Address, record.OperationTime).Error; err != nil {
+			log.Logger.Error("更新用户积分基数失败", zap.String("user", record.Address), zap.Error(err))
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,24 @@
+package sync
+
+import "testing"
+
+func TestSameAddresses(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b []string
+		want bool
+	}{
+		{"both empty", nil, nil, true},
+		{"same order", []string{"0xFactory", "0xA"}, []string{"0xFactory", "0xA"}, true},
+		{"different order and case", []string{"0xfactory", "0xa", "0xB"}, []string{"0xB", "0xFactory", "0xA"}, true},
+		{"pair added", []string{"0xFactory", "0xA"}, []string{"0xFactory", "0xA", "0xB"}, false},
+		{"one pair replaced by another", []string{"0xFactory", "0xA", "0xC"}, []string{"0xFactory", "0xA", "0xB"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := sameAddresses(tt.a, tt.b); got != tt.want {
+				t.Errorf("sameAddresses(%v, %v) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+		})
+	}
+}
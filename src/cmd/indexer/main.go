@@ -1,6 +1,14 @@
 package main
 
-import "github.com/mumu/cryptoSwap/src/core"
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/mumu/cryptoSwap/src/app/sync"
+	"github.com/mumu/cryptoSwap/src/core"
+)
 
 const (
 	// ConfigFile 配置文件路径
@@ -8,5 +16,166 @@ const (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "backfill" {
+		runBackfill(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rebuild" {
+		runRebuild(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "rebuild-candles" {
+		runRebuildCandles(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "retry-dead-letters" {
+		runRetryDeadLetters(os.Args[2:])
+		return
+	}
 	core.Start(ConfigFile, 2)
 }
+
+// runBackfill indexer backfill --chain --contract --from --to [--dry-run]
+func runBackfill(args []string) {
+	fs := flag.NewFlagSet("backfill", flag.ExitOnError)
+	chainId := fs.Int("chain", 0, "链ID")
+	contract := fs.String("contract", "", "合约地址")
+	service := fs.String("service", "", "服务类型，同一合约配置了多个服务类型时必填")
+	from := fs.Uint64("from", 0, "起始区块（含）")
+	to := fs.Uint64("to", 0, "结束区块（含）")
+	dryRun := fs.Bool("dry-run", false, "只打印解码出的事件，不写库")
+	workers := fs.Int("workers", 4, "并发拉取与解码的区段数")
+	batch := fs.Uint64("batch", 0, "每个区段的区块数，默认取链配置的 batch_size")
+	configFile := fs.String("config", ConfigFile, "配置文件路径")
+	_ = fs.Parse(args)
+
+	if *chainId == 0 || *contract == "" || *to == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	err := core.Backfill(*configFile, sync.BackfillOptions{
+		ChainId:     *chainId,
+		Contract:    *contract,
+		ServiceType: *service,
+		FromBlock:   *from,
+		ToBlock:     *to,
+		DryRun:      *dryRun,
+		Workers:     *workers,
+		BatchSize:   *batch,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "回填失败:", err)
+		os.Exit(1)
+	}
+}
+
+// runRebuild indexer rebuild --chain [--contract] [--from] [--to] [--dry-run]
+func runRebuild(args []string) {
+	fs := flag.NewFlagSet("rebuild", flag.ExitOnError)
+	chainId := fs.Int("chain", 0, "链ID")
+	contract := fs.String("contract", "", "合约地址，为空时重建该链全部监听配置")
+	service := fs.String("service", "", "服务类型")
+	from := fs.Uint64("from", 0, "起始区块（含），默认取已归档范围的起点")
+	to := fs.Uint64("to", 0, "结束区块（含），默认取实时游标")
+	dryRun := fs.Bool("dry-run", false, "只打印解码出的事件，不写库")
+	batch := fs.Uint64("batch", 0, "每个区段的区块数，默认取链配置的 batch_size")
+	configFile := fs.String("config", ConfigFile, "配置文件路径")
+	_ = fs.Parse(args)
+
+	if *chainId == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	err := core.Rebuild(*configFile, sync.RebuildOptions{
+		ChainId:     *chainId,
+		Contract:    *contract,
+		ServiceType: *service,
+		FromBlock:   *from,
+		ToBlock:     *to,
+		DryRun:      *dryRun,
+		BatchSize:   *batch,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "重建失败:", err)
+		os.Exit(1)
+	}
+}
+
+// runRebuildCandles indexer rebuild-candles --chain [--pool] [--from] [--to]
+func runRebuildCandles(args []string) {
+	fs := flag.NewFlagSet("rebuild-candles", flag.ExitOnError)
+	chainId := fs.Int("chain", 0, "链ID")
+	pool := fs.String("pool", "", "交易对地址，为空时重建该链全部池子")
+	from := fs.String("from", "", "起始时间，2006-01-02 或 RFC3339，默认取最早的事件")
+	to := fs.String("to", "", "结束时间，2006-01-02 或 RFC3339，默认取当前时间")
+	configFile := fs.String("config", ConfigFile, "配置文件路径")
+	_ = fs.Parse(args)
+
+	if *chainId == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	fromTime, err := parseTimeFlag(*from)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "起始时间格式错误:", err)
+		os.Exit(2)
+	}
+	toTime, err := parseTimeFlag(*to)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "结束时间格式错误:", err)
+		os.Exit(2)
+	}
+
+	err = core.RebuildCandles(*configFile, sync.CandleRebuildOptions{
+		ChainId: *chainId,
+		Pool:    *pool,
+		From:    fromTime,
+		To:      toTime,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "重建 K 线失败:", err)
+		os.Exit(1)
+	}
+}
+
+// parseTimeFlag 解析日期（UTC）或 RFC3339 时间，空字符串返回零值
+func parseTimeFlag(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// runRetryDeadLetters indexer retry-dead-letters [--chain] [--id] [--decoder] [--limit]
+func runRetryDeadLetters(args []string) {
+	fs := flag.NewFlagSet("retry-dead-letters", flag.ExitOnError)
+	chainId := fs.Int("chain", 0, "链ID，为 0 时不限")
+	id := fs.Int64("id", 0, "只重试指定 id 的死信")
+	decoder := fs.String("decoder", "", "只重试指定解码器的死信，如 UniswapV2Pair.Swap")
+	limit := fs.Int("limit", 100, "本次最多重试的条数")
+	configFile := fs.String("config", ConfigFile, "配置文件路径")
+	_ = fs.Parse(args)
+
+	res, err := core.RetryDeadLetters(*configFile, sync.DeadLetterRetryOptions{
+		ChainId: *chainId,
+		Id:      *id,
+		Decoder: *decoder,
+		Limit:   *limit,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "重试死信失败:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("共 %d 条，成功 %d 条，失败 %d 条\n", res.Total, res.Resolved, res.Failed)
+	for dlId, msg := range res.Errors {
+		fmt.Printf("  #%d: %s\n", dlId, msg)
+	}
+	if res.Failed > 0 {
+		os.Exit(1)
+	}
+}
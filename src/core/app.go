@@ -4,6 +4,9 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/mumu/cryptoSwap/src/abi"
 	"github.com/mumu/cryptoSwap/src/app/sync"
@@ -26,8 +29,6 @@ import (
 // @Param serverType query int true "服务器类型 (1: API服务, 2: 监听服务)"
 // @Router /start [post]
 func Start(configFile string, serverType int) {
-	c, cancel := context.WithCancel(context.Background())
-	defer cancel()
 	// 初始化配置信息
 	initConfig(configFile)
 	// 初始化日志组件
@@ -40,19 +41,76 @@ func Start(configFile string, serverType int) {
 	initChainClient()
 	// 初始化ABI管理器
 	abi.InitABIManager()
+
+	lifecycle := NewLifecycle()
 	if serverType == 1 {
-		initApiGin()
+		lifecycle.Add("api-http", initApiGin(lifecycle))
 	} else if serverType == 2 {
-		//开启线程获取scan log
-		initSync(c)
-		//计算积分
-		initComputeIntegral()
-		// 初始化Gin
-		initGin()
+		// 事件监听、积分定时任务、代币定价与 HTTP 服务并行运行
+		lifecycle.Add("indexer", initSync)
+		lifecycle.Add("integral-cron", initComputeIntegral)
+		lifecycle.Add("price-engine", sync.RunPriceEngine)
+		lifecycle.Add("indexer-http", initGin(lifecycle))
+	}
 
+	// 收到 SIGINT/SIGTERM 时取消上下文：监听器处理完当前批次后退出，定时任务停止，HTTP 服务优雅关闭
+	c, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := lifecycle.Run(c); err != nil {
+		log.Logger.Error("服务异常退出", zap.Error(err))
+		os.Exit(1)
 	}
 }
 
+// Backfill 以回填模式运行：初始化依赖后重新索引指定区块范围，不启动实时监听与 HTTP 服务
+func Backfill(configFile string, opts sync.BackfillOptions) error {
+	initConfig(configFile)
+	initLog()
+	initDB()
+	initChainClient()
+	abi.InitABIManager()
+
+	c, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return sync.RunBackfill(c, opts)
+}
+
+// Rebuild 以离线重建模式运行：从原始日志归档重新生成派生数据，不初始化链客户端
+func Rebuild(configFile string, opts sync.RebuildOptions) error {
+	initConfig(configFile)
+	initLog()
+	initDB()
+	abi.InitABIManager()
+
+	c, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return sync.RunRebuild(c, opts)
+}
+
+// RebuildCandles 由已入库的流动性池事件重建 K 线与交易对汇总，不访问 RPC
+func RebuildCandles(configFile string, opts sync.CandleRebuildOptions) error {
+	initConfig(configFile)
+	initLog()
+	initDB()
+
+	c, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return sync.RebuildCandles(c, opts)
+}
+
+// RetryDeadLetters 重试死信队列中待处理的日志；初始化链客户端，以便重试 PairCreated 时读取代币元数据
+func RetryDeadLetters(configFile string, opts sync.DeadLetterRetryOptions) (*sync.DeadLetterRetryResult, error) {
+	initConfig(configFile)
+	initLog()
+	initDB()
+	initChainClient()
+	abi.InitABIManager()
+
+	c, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return sync.RetryDeadLetters(c, opts)
+}
+
 func initConfig(configFile string) {
 	ctx.Ctx.Config = config.InitConfig(configFile)
 }
@@ -92,29 +150,29 @@ func initChainClient() {
 
 	ctx.Ctx.ChainMap = chainMap
 }
-func initGin() {
+func initGin(lifecycle *Lifecycle) func(c context.Context) error {
 	r := router.InitRouter()
 	ctx.Ctx.Gin = r
+	r.GET("/health", lifecycle.HealthHandler)
 	router.Bind(r, &ctx.Ctx)
-	err := r.Run(":" + ctx.Ctx.Config.App.Port)
-	if err != nil {
-		panic(err)
+	return func(c context.Context) error {
+		return serveHTTP(c, ":"+ctx.Ctx.Config.App.Port, r)
 	}
 }
 
-func initApiGin() {
+func initApiGin(lifecycle *Lifecycle) func(c context.Context) error {
 	r := router.InitRouter()
 	ctx.Ctx.Gin = r
+	r.GET("/health", lifecycle.HealthHandler)
 	router.ApiBind(r, &ctx.Ctx)
-	err := r.Run(":" + ctx.Ctx.Config.App.APIPort)
-	if err != nil {
-		panic(err)
+	return func(c context.Context) error {
+		return serveHTTP(c, ":"+ctx.Ctx.Config.App.APIPort, r)
 	}
 }
 
-func initSync(c context.Context) {
-	sync.StartSync(c)
+func initSync(c context.Context) error {
+	return sync.StartSync(c)
 }
-func initComputeIntegral() {
-	sync.InitComputeIntegral()
+func initComputeIntegral(c context.Context) error {
+	return sync.RunComputeIntegral(c)
 }
@@ -0,0 +1,244 @@
+package evm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"github.com/mumu/cryptoSwap/src/core/metrics"
+	"go.uber.org/zap"
+)
+
+const (
+	senderCacheSize    = 65536
+	blockTimeCacheSize = 4096
+	// rpcBatchSize 单个 JSON-RPC 批量请求包含的调用数，多数节点服务商限制在 100 左右
+	rpcBatchSize = 100
+)
+
+// LogContext 一批日志对应的交易发送者与区块时间
+type LogContext struct {
+	Senders    map[common.Hash]common.Address // 交易哈希 -> 发送者
+	Timestamps map[common.Hash]uint64         // 区块哈希 -> 区块时间戳（秒）
+}
+
+// Sender 日志所在交易的发送者
+func (lc *LogContext) Sender(vLog types.Log) string {
+	return lc.Senders[vLog.TxHash].Hex()
+}
+
+// BlockTime 日志所在区块的时间戳
+func (lc *LogContext) BlockTime(vLog types.Log) uint64 {
+	return lc.Timestamps[vLog.BlockHash]
+}
+
+// rpcReceipt eth_getBlockReceipts 返回中需要的字段
+type rpcReceipt struct {
+	TransactionHash common.Hash    `json:"transactionHash"`
+	From            common.Address `json:"from"`
+}
+
+// rpcTransaction eth_getTransactionByHash 返回中需要的字段
+type rpcTransaction struct {
+	Hash common.Hash    `json:"hash"`
+	From common.Address `json:"from"`
+}
+
+// rpcBlockHeader eth_getBlockByHash 返回中需要的字段
+type rpcBlockHeader struct {
+	Timestamp hexutil.Uint64 `json:"timestamp"`
+}
+
+// ResolveLogContext 按区块解析日志的交易发送者与区块时间
+// 发送者优先通过 eth_getBlockReceipts 每个区块请求一次，节点不支持时批量请求 eth_getTransactionByHash；
+// 区块时间优先使用日志自带的 blockTimestamp，缺失时批量请求区块头；结果按交易哈希/区块哈希缓存
+func (c *Evm) ResolveLogContext(logs []types.Log) (*LogContext, error) {
+	lc := &LogContext{
+		Senders:    make(map[common.Hash]common.Address),
+		Timestamps: make(map[common.Hash]uint64),
+	}
+	if len(logs) == 0 {
+		return lc, nil
+	}
+
+	// 未命中缓存的交易按区块分组，区块时间缺失的区块单独收集
+	missingTxs := make(map[common.Hash][]common.Hash)
+	var missingBlocks []common.Hash
+	for _, vLog := range logs {
+		if _, ok := lc.Timestamps[vLog.BlockHash]; !ok {
+			if vLog.BlockTimestamp > 0 {
+				lc.Timestamps[vLog.BlockHash] = vLog.BlockTimestamp
+				c.blockTimes.Add(vLog.BlockHash, vLog.BlockTimestamp)
+			} else if ts, ok := c.blockTimes.Get(vLog.BlockHash); ok {
+				lc.Timestamps[vLog.BlockHash] = ts
+			} else {
+				lc.Timestamps[vLog.BlockHash] = 0
+				missingBlocks = append(missingBlocks, vLog.BlockHash)
+			}
+		}
+		if _, ok := lc.Senders[vLog.TxHash]; ok {
+			continue
+		}
+		if from, ok := c.senders.Get(vLog.TxHash); ok {
+			lc.Senders[vLog.TxHash] = from
+			continue
+		}
+		lc.Senders[vLog.TxHash] = common.Address{}
+		missingTxs[vLog.BlockHash] = append(missingTxs[vLog.BlockHash], vLog.TxHash)
+	}
+
+	if err := c.fetchBlockTimestamps(missingBlocks, lc); err != nil {
+		return nil, err
+	}
+	if len(missingTxs) == 0 {
+		return lc, nil
+	}
+
+	if !c.receiptsUnsupported.Load() {
+		if err := c.fetchSendersByReceipts(missingTxs, lc); err != nil {
+			if !isMethodNotSupported(err) {
+				return nil, err
+			}
+			log.Logger.Warn("节点不支持 eth_getBlockReceipts，改为批量查询交易", zap.Error(err))
+			c.receiptsUnsupported.Store(true)
+		}
+	}
+
+	var remaining []common.Hash
+	for _, txHashes := range missingTxs {
+		for _, txHash := range txHashes {
+			if _, ok := c.senders.Peek(txHash); !ok {
+				remaining = append(remaining, txHash)
+			}
+		}
+	}
+	if err := c.fetchSendersByTransactions(remaining, lc); err != nil {
+		return nil, err
+	}
+	return lc, nil
+}
+
+// fetchBlockTimestamps 批量请求区块头获取区块时间
+func (c *Evm) fetchBlockTimestamps(blockHashes []common.Hash, lc *LogContext) error {
+	for start := 0; start < len(blockHashes); start += rpcBatchSize {
+		end := min(start+rpcBatchSize, len(blockHashes))
+		headers := make([]*rpcBlockHeader, end-start)
+		batch := make([]rpc.BatchElem, end-start)
+		for i, blockHash := range blockHashes[start:end] {
+			batch[i] = rpc.BatchElem{Method: "eth_getBlockByHash", Args: []interface{}{blockHash, false}, Result: &headers[i]}
+		}
+		if err := c.batchCall(batch); err != nil {
+			return err
+		}
+		for i, blockHash := range blockHashes[start:end] {
+			if headers[i] == nil {
+				// 区块已被重组移除，由调用方重试整个批次
+				return fmt.Errorf("区块 %s 不存在", blockHash.Hex())
+			}
+			ts := uint64(headers[i].Timestamp)
+			lc.Timestamps[blockHash] = ts
+			c.blockTimes.Add(blockHash, ts)
+		}
+	}
+	return nil
+}
+
+// fetchSendersByReceipts 每个区块请求一次 eth_getBlockReceipts，同时缓存区块内所有交易的发送者
+func (c *Evm) fetchSendersByReceipts(missingTxs map[common.Hash][]common.Hash, lc *LogContext) error {
+	blockHashes := make([]common.Hash, 0, len(missingTxs))
+	for blockHash := range missingTxs {
+		blockHashes = append(blockHashes, blockHash)
+	}
+	for start := 0; start < len(blockHashes); start += rpcBatchSize {
+		end := min(start+rpcBatchSize, len(blockHashes))
+		receipts := make([][]rpcReceipt, end-start)
+		batch := make([]rpc.BatchElem, end-start)
+		for i, blockHash := range blockHashes[start:end] {
+			batch[i] = rpc.BatchElem{Method: "eth_getBlockReceipts", Args: []interface{}{blockHash}, Result: &receipts[i]}
+		}
+		if err := c.batchCall(batch); err != nil {
+			return err
+		}
+		for i, blockHash := range blockHashes[start:end] {
+			for _, receipt := range receipts[i] {
+				c.senders.Add(receipt.TransactionHash, receipt.From)
+			}
+			for _, txHash := range missingTxs[blockHash] {
+				if from, ok := c.senders.Peek(txHash); ok {
+					lc.Senders[txHash] = from
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// fetchSendersByTransactions 批量请求 eth_getTransactionByHash 获取发送者
+func (c *Evm) fetchSendersByTransactions(txHashes []common.Hash, lc *LogContext) error {
+	for start := 0; start < len(txHashes); start += rpcBatchSize {
+		end := min(start+rpcBatchSize, len(txHashes))
+		txs := make([]*rpcTransaction, end-start)
+		batch := make([]rpc.BatchElem, end-start)
+		for i, txHash := range txHashes[start:end] {
+			batch[i] = rpc.BatchElem{Method: "eth_getTransactionByHash", Args: []interface{}{txHash}, Result: &txs[i]}
+		}
+		if err := c.batchCall(batch); err != nil {
+			return err
+		}
+		for i, txHash := range txHashes[start:end] {
+			if txs[i] == nil {
+				return fmt.Errorf("交易 %s 不存在", txHash.Hex())
+			}
+			lc.Senders[txHash] = txs[i].From
+			c.senders.Add(txHash, txs[i].From)
+		}
+	}
+	return nil
+}
+
+// batchCall 发送 JSON-RPC 批量请求，返回第一个失败的调用错误
+func (c *Evm) batchCall(batch []rpc.BatchElem) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	start := time.Now()
+	err := c.client.Client().BatchCallContext(context.Background(), batch)
+	metrics.RPCDuration.WithLabelValues(batch[0].Method).Observe(time.Since(start).Seconds())
+	for _, elem := range batch {
+		elemErr := err
+		if elemErr == nil {
+			elemErr = elem.Error
+		}
+		metrics.RPCRequests.WithLabelValues(elem.Method, metrics.Outcome(elemErr)).Inc()
+	}
+	if err != nil {
+		log.Logger.Error("BatchCall failed!", zap.String("method", batch[0].Method), zap.Error(err))
+		return err
+	}
+	for _, elem := range batch {
+		if elem.Error != nil {
+			log.Logger.Error("BatchCall failed!", zap.String("method", elem.Method), zap.Error(elem.Error))
+			return elem.Error
+		}
+	}
+	return nil
+}
+
+// isMethodNotSupported 节点是否返回方法不存在/不支持
+func isMethodNotSupported(err error) bool {
+	var rpcErr rpc.Error
+	if errors.As(err, &rpcErr) && rpcErr.ErrorCode() == -32601 {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "method not found") ||
+		strings.Contains(msg, "not supported") ||
+		strings.Contains(msg, "eth_getblockreceipts")
+}
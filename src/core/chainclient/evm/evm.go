@@ -3,17 +3,30 @@ package evm
 import (
 	"context"
 	"math/big"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/lru"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/mumu/cryptoSwap/src/core/log"
+	"github.com/mumu/cryptoSwap/src/core/metrics"
 	"go.uber.org/zap"
 )
 
 type Evm struct {
-	client *ethclient.Client
+	client   *ethclient.Client
+	endpoint string
+	// senders 交易哈希 -> 发送者，同一交易的多条日志以及重复处理的区块无需再次请求
+	senders *lru.Cache[common.Hash, common.Address]
+	// blockTimes 区块哈希 -> 区块时间戳
+	blockTimes *lru.Cache[common.Hash, uint64]
+	// receiptsUnsupported 节点不支持 eth_getBlockReceipts 时改为批量 eth_getTransactionByHash
+	receiptsUnsupported atomic.Bool
 }
 
 func New(nodeUrl string) (*Evm, error) {
@@ -23,7 +36,10 @@ func New(nodeUrl string) (*Evm, error) {
 	}
 
 	return &Evm{
-		client: c,
+		client:     c,
+		endpoint:   nodeUrl,
+		senders:    lru.NewCache[common.Hash, common.Address](senderCacheSize),
+		blockTimes: lru.NewCache[common.Hash, uint64](blockTimeCacheSize),
 	}, err
 }
 
@@ -31,7 +47,9 @@ func (c *Evm) Client() interface{} {
 	return c.client
 }
 func (c *Evm) GetBlockNumber() (uint64, error) {
+	start := time.Now()
 	blockNumber, err := c.client.BlockNumber(context.Background())
+	metrics.ObserveRPC("eth_blockNumber", start, err)
 	if err != nil {
 		log.Logger.Error("getBlockNumber failed!", zap.Error(err))
 		return blockNumber, err
@@ -45,7 +63,9 @@ func (c *Evm) GetFilterLogs(fromBlock *big.Int, toBlock *big.Int, contractAddres
 		Topics:    nil,
 		Addresses: []common.Address{common.HexToAddress(contractAddress)},
 	}
+	start := time.Now()
 	logs, err := c.client.FilterLogs(context.Background(), q)
+	metrics.ObserveRPC("eth_getLogs", start, err)
 	if err != nil {
 		log.Logger.Error("GetFilterLogs failed!", zap.Error(err))
 		return nil, err
@@ -53,9 +73,59 @@ func (c *Evm) GetFilterLogs(fromBlock *big.Int, toBlock *big.Int, contractAddres
 	return logs, nil
 }
 
+// CallContract 调用合约只读方法（最新区块）
+func (c *Evm) CallContract(contractAddress string, data []byte) ([]byte, error) {
+	to := common.HexToAddress(contractAddress)
+	start := time.Now()
+	out, err := c.client.CallContract(context.Background(), ethereum.CallMsg{To: &to, Data: data}, nil)
+	metrics.ObserveRPC("eth_call", start, err)
+	return out, err
+}
+
+// SupportsSubscription 节点端点是否支持订阅（ws:// 或 wss://）
+func (c *Evm) SupportsSubscription() bool {
+	endpoint := strings.ToLower(c.endpoint)
+	return strings.HasPrefix(endpoint, "ws://") || strings.HasPrefix(endpoint, "wss://")
+}
+
+// SubscribeNewHead 订阅新区块头
+func (c *Evm) SubscribeNewHead(ctx context.Context, ch chan<- *types.Header) (ethereum.Subscription, error) {
+	start := time.Now()
+	sub, err := c.client.SubscribeNewHead(ctx, ch)
+	metrics.ObserveRPC("eth_subscribe", start, err)
+	if err != nil {
+		log.Logger.Error("SubscribeNewHead failed!", zap.Error(err))
+		return nil, err
+	}
+	return sub, nil
+}
+
+// SubscribeFilterLogs 订阅指定合约地址的日志，topics 为 nil 时不按事件过滤
+func (c *Evm) SubscribeFilterLogs(ctx context.Context, contractAddresses []string, topics [][]common.Hash, ch chan<- types.Log) (ethereum.Subscription, error) {
+	addresses := make([]common.Address, 0, len(contractAddresses))
+	for _, address := range contractAddresses {
+		addresses = append(addresses, common.HexToAddress(address))
+	}
+	start := time.Now()
+	sub, err := c.client.SubscribeFilterLogs(ctx, ethereum.FilterQuery{Addresses: addresses, Topics: topics}, ch)
+	metrics.ObserveRPC("eth_subscribe", start, err)
+	if err != nil {
+		log.Logger.Error("SubscribeFilterLogs failed!", zap.Error(err))
+		return nil, err
+	}
+	return sub, nil
+}
+
+// GetUserAddress 获取日志所在交易的发送者，优先使用缓存
+// 批量处理日志时应使用 ResolveLogContext，按区块一次性解析
 func (c *Evm) GetUserAddress(vLog types.Log) (string, error) {
+	if from, ok := c.senders.Get(vLog.TxHash); ok {
+		return from.Hex(), nil
+	}
 	// 获取原始交易
+	start := time.Now()
 	tx, _, err := c.client.TransactionByHash(context.Background(), vLog.TxHash)
+	metrics.ObserveRPC("eth_getTransactionByHash", start, err)
 	if err != nil {
 		log.Logger.Error("获取交易失败",
 			zap.String("txHash", vLog.TxHash.Hex()),
@@ -74,6 +144,7 @@ func (c *Evm) GetUserAddress(vLog types.Log) (string, error) {
 		return "0x0000000000000000000000000000000000000000", nil
 	}
 
+	c.senders.Add(vLog.TxHash, from)
 	return from.Hex(), nil
 }
 
@@ -90,7 +161,9 @@ func (c *Evm) GetFilterLogsWithTopics(fromBlock *big.Int, toBlock *big.Int, cont
 		Topics:    topics,
 		Addresses: addresses,
 	}
+	start := time.Now()
 	logs, err := c.client.FilterLogs(context.Background(), q)
+	metrics.ObserveRPC("eth_getLogs", start, err)
 	if err != nil {
 		log.Logger.Error("GetFilterLogsWithTopics failed!", zap.Error(err))
 		return nil, err
@@ -100,10 +173,45 @@ func (c *Evm) GetFilterLogsWithTopics(fromBlock *big.Int, toBlock *big.Int, cont
 
 // GetBlockByNumber 根据区块号获取区块信息
 func (c *Evm) GetBlockByNumber(blockNumber *big.Int) (*types.Block, error) {
+	start := time.Now()
 	block, err := c.client.BlockByNumber(context.Background(), blockNumber)
+	metrics.ObserveRPC("eth_getBlockByNumber", start, err)
 	if err != nil {
 		log.Logger.Error("GetBlockByNumber failed!", zap.Error(err))
 		return nil, err
 	}
 	return block, nil
 }
+
+// GetHeaderByNumber 根据区块号获取区块头，用于校验区块哈希与父哈希
+func (c *Evm) GetHeaderByNumber(blockNumber *big.Int) (*types.Header, error) {
+	start := time.Now()
+	header, err := c.client.HeaderByNumber(context.Background(), blockNumber)
+	metrics.ObserveRPC("eth_getBlockByNumber", start, err)
+	if err != nil {
+		log.Logger.Error("GetHeaderByNumber failed!", zap.Error(err))
+		return nil, err
+	}
+	return header, nil
+}
+
+// GetBlockNumberByTag 获取 safe/finalized 等区块标签对应的区块高度
+func (c *Evm) GetBlockNumberByTag(tag string) (uint64, error) {
+	var number rpc.BlockNumber
+	switch tag {
+	case "safe":
+		number = rpc.SafeBlockNumber
+	case "finalized":
+		number = rpc.FinalizedBlockNumber
+	default:
+		number = rpc.LatestBlockNumber
+	}
+	start := time.Now()
+	header, err := c.client.HeaderByNumber(context.Background(), big.NewInt(number.Int64()))
+	metrics.ObserveRPC("eth_getBlockByNumber", start, err)
+	if err != nil {
+		log.Logger.Error("GetBlockNumberByTag failed!", zap.String("tag", tag), zap.Error(err))
+		return 0, err
+	}
+	return header.Number.Uint64(), nil
+}
@@ -14,15 +14,18 @@ type Config struct {
 	Monitor MonitorConfig
 	Pgsql   PgsqlConfig
 	Redis   RedisConfig
+	Lease   LeaseConfig
+	Pricing PricingConfig
 	Chains  []ChainConfig
 }
 type AppConfig struct {
-	Name      string `toml:"name" json:"name"`
-	APIPort   string `toml:"apiPort" json:"apiPort"`
-	Port      string `toml:"port" json:"port"`
-	Version   string `toml:"version" json:"version"`
-	JwtSecret string `toml:"jwtSecret" json:"jwtSecret"` //添加jwt秘钥配置
-	JwtTTL    int    `toml:"jwtTtl" json:"jwtTtl"`
+	Name       string `toml:"name" json:"name"`
+	APIPort    string `toml:"apiPort" json:"apiPort"`
+	Port       string `toml:"port" json:"port"`
+	Version    string `toml:"version" json:"version"`
+	JwtSecret  string `toml:"jwtSecret" json:"jwtSecret"` //添加jwt秘钥配置
+	JwtTTL     int    `toml:"jwtTtl" json:"jwtTtl"`
+	AdminToken string `toml:"adminToken" json:"-"` // 索引器管理接口令牌（Bearer），为空时管理接口不可用
 }
 
 type MonitorConfig struct {
@@ -49,10 +52,46 @@ type RedisConfig struct {
 	PoolSize    int    `toml:"poolSize" json:"poolSize"`
 }
 
+// LeaseConfig 多副本部署时的按链租约（Redis），同一条链同一时刻只有持有租约的副本处理
+type LeaseConfig struct {
+	Disable   bool   `toml:"disable" json:"disable"`      // 关闭租约，只能运行单个索引器副本
+	TTL       int    `toml:"ttl" json:"ttl"`              // 租约有效期，单位秒，默认 10；持有者每 ttl/3 续约一次
+	ReplicaId string `toml:"replica_id" json:"replicaId"` // 副本标识，默认 主机名-进程号
+}
+
+// PricingConfig 代币 USD 定价：从锚定稳定币出发，经已索引池子逐跳推导其他代币价格
+type PricingConfig struct {
+	Interval        int                 `toml:"interval" json:"interval"`                 // 定价间隔，单位秒，默认 60
+	Anchors         map[string][]string `toml:"anchors" json:"anchors"`                   // 链 ID -> 锚定稳定币合约地址，价格视为 1 USD；未配置锚定币的链不定价
+	MaxHops         int                 `toml:"max_hops" json:"maxHops"`                  // 到锚定稳定币的最多跳数，默认 3
+	MinLiquidityUSD float64             `toml:"min_liquidity_usd" json:"minLiquidityUsd"` // 池子已定价一侧的最低流动性（USD），低于该值的池子不参与定价，默认 1000
+	MaxDeviation    float64             `toml:"max_deviation" json:"maxDeviation"`        // 候选价格偏离按流动性加权中位数超过该比例时丢弃，默认 0.2
+}
+
 type ChainConfig struct {
 	Name     string `toml:"name" json:"name"`
 	ChainId  int    `toml:"chain_id" json:"chainId"`
 	Endpoint string `toml:"endpoint" json:"endpoint"`
+	// 以下为索引器调优参数，未配置时使用默认值
+	Mode          string  `toml:"mode" json:"mode"`                   // 监听模式: poll(默认)/subscribe，subscribe 需要 ws:// 或 wss:// 端点
+	Confirmations *uint64 `toml:"confirmations" json:"confirmations"` // 确认块数，finality 为 latest 时生效，可配置为 0
+	Finality      string  `toml:"finality" json:"finality"`           // 最终性模式: latest(默认)/safe/finalized
+	PollInterval  int     `toml:"poll_interval" json:"pollInterval"`  // 轮询间隔，单位秒；订阅模式下为断线回退时的轮询间隔
+	BatchSize     uint64  `toml:"batch_size" json:"batchSize"`        // 单批次最大区块数
+	MinBatchSize  uint64  `toml:"min_batch_size" json:"minBatchSize"` // 自适应缩小批次的下限
+}
+
+// GetChainConfig 根据链ID查找链配置
+func GetChainConfig(chainId int) (ChainConfig, bool) {
+	if Conf == nil {
+		return ChainConfig{}, false
+	}
+	for _, chain := range Conf.Chains {
+		if chain.ChainId == chainId {
+			return chain, true
+		}
+	}
+	return ChainConfig{}, false
 }
 
 // InitConfig 初始化配置
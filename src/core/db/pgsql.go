@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/mumu/cryptoSwap/src/core/config"
 	"github.com/mumu/cryptoSwap/src/core/log"
+	"github.com/mumu/cryptoSwap/src/core/metrics"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
@@ -28,6 +29,10 @@ func InitPgsql() *gorm.DB {
 	if err != nil {
 		panic(err)
 	}
+	// 记录语句耗时
+	if err := db.Use(metrics.GormPlugin{}); err != nil {
+		panic(err)
+	}
 	DB = db
 	return db
 }
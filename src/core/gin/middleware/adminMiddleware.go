@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mumu/cryptoSwap/src/core/config"
+	"github.com/mumu/cryptoSwap/src/core/result"
+)
+
+// AdminMiddleware
+//
+//	@Description: 索引器管理接口鉴权，校验请求头中的 Bearer 令牌与配置的 adminToken 是否一致；未配置令牌时拒绝全部请求
+//	@return gin.HandlerFunc
+func AdminMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminToken := config.Conf.App.AdminToken
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if adminToken == "" || !ok || subtle.ConstantTimeCompare([]byte(token), []byte(adminToken)) != 1 {
+			result.Error(c, result.Unauthorized)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
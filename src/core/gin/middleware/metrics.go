@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mumu/cryptoSwap/src/core/metrics"
+	"github.com/mumu/cryptoSwap/src/core/result"
+)
+
+// MetricsMiddleware
+//
+//	@Description: 按路由统计请求数与耗时，code 取 result.Response 的业务状态码，未经 result 返回的请求记为 none
+//	@return gin.HandlerFunc
+func MetricsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			// 未匹配的路由不使用原始路径，避免标签基数失控
+			route = "unmatched"
+		}
+		code := "none"
+		if v, ok := c.Get(result.CodeKey); ok {
+			if n, ok := v.(int); ok {
+				code = strconv.Itoa(n)
+			}
+		}
+		method := c.Request.Method
+		metrics.HTTPRequests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status()), code).Inc()
+		metrics.HTTPDuration.WithLabelValues(method, route, code).Observe(time.Since(start).Seconds())
+	}
+}
@@ -6,9 +6,11 @@ import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 	"github.com/mumu/cryptoSwap/src/app/api"
+	"github.com/mumu/cryptoSwap/src/app/api/admin"
 	"github.com/mumu/cryptoSwap/src/core/config"
 	"github.com/mumu/cryptoSwap/src/core/ctx"
 	"github.com/mumu/cryptoSwap/src/core/gin/middleware"
+	"github.com/mumu/cryptoSwap/src/core/metrics"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
 )
@@ -19,6 +21,7 @@ func InitRouter() *gin.Engine {
 	r := gin.New()                             // 新建一个gin引擎实例
 	r.Use(middleware.HttpLogMiddleware())      // 使用日志中间件
 	r.Use(middleware.LanguageMiddleware())     // 使用语言中间件
+	r.Use(middleware.MetricsMiddleware())      // 使用指标中间件，在恢复中间件之前以便统计 panic 的请求
 	r.Use(middleware.RecoverPanicMiddleware()) // 使用恢复中间件
 
 	r.Use(cors.New(cors.Config{ // 使用cors中间件
@@ -30,6 +33,9 @@ func InitRouter() *gin.Engine {
 		MaxAge:           1 * time.Hour,
 	}))
 
+	// Prometheus 指标
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	return r
 }
 func Bind(r *gin.Engine, ctx *ctx.Context) {
@@ -53,6 +59,29 @@ func Bind(r *gin.Engine, ctx *ctx.Context) {
 	//	v.GET("/evm/get_block_by_num/:block_num", evmApi.GetBlockByNum)
 	//}
 
+	// 索引器管理接口（需要 adminToken）
+	adminGroup := r.Group("/admin")
+	adminGroup.Use(middleware.AdminMiddleware())
+
+	indexerApi := admin.NewIndexerApi()
+	// 监听配置的游标、高度、延迟与最近错误
+	adminGroup.GET("/chains", indexerApi.ListChains)
+	// 暂停/恢复监听
+	adminGroup.POST("/chains/pause", indexerApi.PauseChain)
+	adminGroup.POST("/chains/resume", indexerApi.ResumeChain)
+	// 重置游标
+	adminGroup.POST("/chains/resetCursor", indexerApi.ResetCursor)
+	// 回填任务
+	adminGroup.POST("/backfills", indexerApi.StartBackfill)
+	adminGroup.GET("/backfills", indexerApi.ListBackfills)
+	adminGroup.GET("/backfills/:id", indexerApi.GetBackfill)
+	adminGroup.POST("/backfills/:id/cancel", indexerApi.CancelBackfill)
+
+	deadLetterApi := admin.NewDeadLetterApi()
+	// 死信列表
+	adminGroup.GET("/deadLetters", deadLetterApi.List)
+	// 重试死信
+	adminGroup.POST("/deadLetters/retry", deadLetterApi.Retry)
 }
 
 func ApiBind(r *gin.Engine, ctx *ctx.Context) {
@@ -82,6 +111,12 @@ func ApiBind(r *gin.Engine, ctx *ctx.Context) {
 	v.POST("/liquidity/poolPerformance", liquidityPoolApi.GetPoolPerformance)
 	//5.获取流动性池事件列表
 	v.GET("/liquidity-pool-events", liquidityPoolApi.GetLiquidityPoolEvents)
+	//6.获取交易对 K 线
+	v.GET("/liquidity/pools/:address/candles", liquidityPoolApi.GetPoolCandles)
+	//7.获取交易对 TVL/交易量/手续费时间序列
+	v.GET("/liquidity/pools/:address/history", liquidityPoolApi.GetPoolHistory)
+	//8.获取钱包 LP 持仓分析（价值、存取、手续费、无常损失、净收益）
+	v.GET("/liquidity/positions", liquidityPoolApi.GetLPPositions)
 
 	airDropApi := api.NewAirDropApi()
 	// 空投相关接口（开放访问，地址可从token或参数解析）
@@ -106,4 +141,10 @@ func ApiBind(r *gin.Engine, ctx *ctx.Context) {
 	v.POST("/stake/records", stakeApi.GetStakeRecords)
 	// 获取用户的质押概览
 	v.POST("/stake/overview", stakeApi.GetStakeOverview)
+	// 获取质押池列表及合约暂停状态
+	v.GET("/stake/pools", stakeApi.GetStakingPools)
+	// 获取用户的收益领取记录
+	v.POST("/stake/rewardClaims", stakeApi.GetRewardClaims)
+	// 获取份额价格历史
+	v.GET("/stake/sharePrices", stakeApi.GetSharePrices)
 }
@@ -0,0 +1,179 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mumu/cryptoSwap/src/core/log"
+	"go.uber.org/zap"
+)
+
+// 组件状态
+const (
+	componentStarting = "starting"
+	componentRunning  = "running"
+	componentStopping = "stopping"
+	componentStopped  = "stopped"
+	componentFailed   = "failed"
+)
+
+// httpShutdownTimeout HTTP 服务优雅关闭时等待进行中请求的最长时间
+const httpShutdownTimeout = 10 * time.Second
+
+// ComponentHealth 组件健康状态
+type ComponentHealth struct {
+	Name      string     `json:"name"`
+	Status    string     `json:"status"`
+	Error     string     `json:"error,omitempty"`
+	StartedAt *time.Time `json:"startedAt,omitempty"`
+	StoppedAt *time.Time `json:"stoppedAt,omitempty"`
+}
+
+// component 由生命周期管理器运行的组件，run 阻塞直到上下文取消或出错
+type component struct {
+	name   string
+	run    func(c context.Context) error
+	health ComponentHealth
+}
+
+// Lifecycle 组件生命周期管理器：并行启动各组件，任一组件出错或收到退出信号时取消上下文，
+// 等待全部组件退出后返回
+type Lifecycle struct {
+	mu         sync.RWMutex
+	components []*component
+}
+
+func NewLifecycle() *Lifecycle {
+	return &Lifecycle{}
+}
+
+// Add 注册组件，需在 Run 之前调用
+func (l *Lifecycle) Add(name string, run func(c context.Context) error) {
+	l.components = append(l.components, &component{
+		name:   name,
+		run:    run,
+		health: ComponentHealth{Name: name, Status: componentStarting},
+	})
+}
+
+// Run 启动全部组件并阻塞，返回第一个出错组件的错误；正常退出的组件不影响其他组件
+func (l *Lifecycle) Run(c context.Context) error {
+	runCtx, cancel := context.WithCancel(c)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	errCh := make(chan error, len(l.components))
+	for _, comp := range l.components {
+		wg.Add(1)
+		go func(comp *component) {
+			defer wg.Done()
+			l.setStatus(comp, componentRunning, nil)
+			log.Logger.Info("组件已启动", zap.String("component", comp.name))
+			err := comp.run(runCtx)
+			if err != nil && !errors.Is(err, context.Canceled) {
+				l.setStatus(comp, componentFailed, err)
+				log.Logger.Error("组件异常退出，开始关闭其他组件", zap.String("component", comp.name), zap.Error(err))
+				errCh <- err
+				cancel()
+				return
+			}
+			l.setStatus(comp, componentStopped, nil)
+			log.Logger.Info("组件已停止", zap.String("component", comp.name))
+		}(comp)
+	}
+
+	<-runCtx.Done()
+	log.Logger.Info("正在关闭组件")
+	l.mu.Lock()
+	for _, comp := range l.components {
+		if comp.health.Status == componentRunning {
+			comp.health.Status = componentStopping
+		}
+	}
+	l.mu.Unlock()
+	wg.Wait()
+	log.Logger.Info("全部组件已关闭")
+
+	select {
+	case err := <-errCh:
+		return err
+	default:
+		return nil
+	}
+}
+
+// setStatus 更新组件状态
+func (l *Lifecycle) setStatus(comp *component, status string, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	comp.health.Status = status
+	switch status {
+	case componentRunning:
+		comp.health.StartedAt = &now
+	case componentStopped, componentFailed:
+		comp.health.StoppedAt = &now
+	}
+	if err != nil {
+		comp.health.Error = err.Error()
+	}
+}
+
+// Health 返回各组件的健康状态
+func (l *Lifecycle) Health() []ComponentHealth {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	health := make([]ComponentHealth, 0, len(l.components))
+	for _, comp := range l.components {
+		health = append(health, comp.health)
+	}
+	return health
+}
+
+// Healthy 全部组件处于运行中或已正常结束
+func (l *Lifecycle) Healthy() bool {
+	for _, h := range l.Health() {
+		if h.Status != componentRunning && h.Status != componentStopped {
+			return false
+		}
+	}
+	return true
+}
+
+// HealthHandler 健康检查接口，有组件未就绪、异常或正在关闭时返回 503
+func (l *Lifecycle) HealthHandler(c *gin.Context) {
+	status := http.StatusOK
+	if !l.Healthy() {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, gin.H{"healthy": status == http.StatusOK, "components": l.Health()})
+}
+
+// serveHTTP 运行 HTTP 服务直到上下文取消，随后等待进行中的请求完成
+func serveHTTP(c context.Context, addr string, handler http.Handler) error {
+	server := &http.Server{Addr: addr, Handler: handler}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-c.Done():
+	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Logger.Error("HTTP 服务关闭超时", zap.String("addr", addr), zap.Error(err))
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
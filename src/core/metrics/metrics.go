@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// 调用结果标签值
+const (
+	OutcomeOk    = "ok"
+	OutcomeError = "error"
+)
+
+// 索引器指标
+var (
+	// BlocksBehind 监听配置的游标落后可安全索引高度的区块数
+	BlocksBehind = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "indexer_blocks_behind",
+		Help: "Blocks between the safe head and the indexer cursor.",
+	}, []string{"chain_id", "contract", "service_type"})
+
+	// LogsProcessed 已提交的事件数，按解码器（事件类型）统计
+	LogsProcessed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "indexer_logs_processed_total",
+		Help: "Decoded logs committed by the live indexer, by event type.",
+	}, []string{"chain_id", "event"})
+
+	// DeadLetters 写入死信队列的日志数
+	DeadLetters = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "indexer_dead_letters_total",
+		Help: "Logs that failed to decode or handle and were written to the dead-letter table.",
+	}, []string{"chain_id", "event"})
+
+	// BatchDuration 单个批次从解码到提交的耗时
+	BatchDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "indexer_batch_duration_seconds",
+		Help:    "Time to decode and commit one indexer batch.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"chain_id", "service_type", "outcome"})
+)
+
+// RPC 指标
+var (
+	// RPCRequests 通过 evm.Evm 发出的 JSON-RPC 调用数，批量请求按其中每个调用计数
+	RPCRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "rpc_requests_total",
+		Help: "JSON-RPC calls made through the EVM client, by method and outcome.",
+	}, []string{"method", "outcome"})
+
+	// RPCDuration JSON-RPC 请求耗时，批量请求按一次请求记录
+	RPCDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "rpc_request_duration_seconds",
+		Help:    "JSON-RPC request latency, batch requests are observed once.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+)
+
+// 数据库指标
+var (
+	// DBQueryDuration GORM 语句耗时
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "GORM statement latency, by operation, table and outcome.",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"operation", "table", "outcome"})
+)
+
+// HTTP 指标
+var (
+	// HTTPRequests HTTP 请求数，code 为 result.Response 中的业务状态码
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "HTTP requests, by route, HTTP status and business result code.",
+	}, []string{"method", "route", "status", "code"})
+
+	// HTTPDuration HTTP 请求耗时
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency, by route and business result code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "code"})
+)
+
+// Outcome 由错误得到调用结果标签
+func Outcome(err error) string {
+	if err != nil {
+		return OutcomeError
+	}
+	return OutcomeOk
+}
+
+// ObserveRPC 记录一次 JSON-RPC 调用
+func ObserveRPC(method string, start time.Time, err error) {
+	RPCRequests.WithLabelValues(method, Outcome(err)).Inc()
+	RPCDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// Handler /metrics 接口
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
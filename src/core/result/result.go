@@ -13,6 +13,8 @@ const (
 	CodeOk = 0
 	// MsgOk 请求成功消息
 	MsgOk = "OK"
+	// CodeKey 写入 gin.Context 的业务状态码，供指标中间件读取
+	CodeKey = "result_code"
 
 	LANG_ZH = 1
 	LANG_EN = 2
@@ -21,6 +23,8 @@ const (
 	ErrorCode = 100000
 	// InvalidParameter 参数错误状态码 1001xx
 	InvalidParameter = 100100
+	// Unauthorized 未授权状态码 1002xx
+	Unauthorized = 100200
 
 	// SystemError 系统级别错误状态码 2开头
 	SystemError = 200000
@@ -51,6 +55,10 @@ var ErrMsgMap = map[int]map[int]string{
 		LANG_ZH: "参数错误，请检查",
 		LANG_EN: "Invalid parameters",
 	},
+	Unauthorized: {
+		LANG_ZH: "未授权的访问",
+		LANG_EN: "Unauthorized",
+	},
 	SystemError: {
 		LANG_ZH: "服务器内部错误，请稍后重试",
 		LANG_EN: "Internal server error, please try again later",
@@ -97,6 +105,7 @@ type Response struct {
 }
 
 func OK(c *gin.Context, v interface{}) {
+	c.Set(CodeKey, CodeOk)
 	c.JSON(http.StatusOK, &Response{
 		TraceId: GetTraceId(c.Request.Context()),
 		Code:    CodeOk,
@@ -107,6 +116,7 @@ func OK(c *gin.Context, v interface{}) {
 
 func Error(c *gin.Context, errorCode int) {
 	msg := getErrorMsg(errorCode, GetLang(c))
+	c.Set(CodeKey, errorCode)
 	c.JSON(http.StatusOK, &Response{
 		TraceId: GetTraceId(c.Request.Context()),
 		Code:    errorCode,
@@ -120,6 +130,7 @@ func SysError(c *gin.Context, message string) {
 	if message == "" {
 		msg = ErrMsgMap[SystemError][GetLang(c)]
 	}
+	c.Set(CodeKey, SystemError)
 	c.JSON(http.StatusOK, &Response{
 		TraceId: GetTraceId(c.Request.Context()),
 		Code:    SystemError,
@@ -130,6 +141,7 @@ func SysError(c *gin.Context, message string) {
 
 func ErrorData(c *gin.Context, errorCode int, data interface{}) {
 	msg := getErrorMsg(errorCode, GetLang(c))
+	c.Set(CodeKey, errorCode)
 	c.JSON(http.StatusOK, &Response{
 		TraceId: GetTraceId(c.Request.Context()),
 		Code:    errorCode,
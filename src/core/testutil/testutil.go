@@ -0,0 +1,9 @@
+// Package testutil 单元测试共用的断言辅助函数
+package testutil
+
+import "math"
+
+// ApproxEqual 按相对误差 1e-9 比较浮点数，want 绝对值小于 1 时按绝对误差比较
+func ApproxEqual(got, want float64) bool {
+	return math.Abs(got-want) <= 1e-9*math.Max(1, math.Abs(want))
+}